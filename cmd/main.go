@@ -4,17 +4,24 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"wallet/internal/cli"
+	"wallet/internal/grpcserver"
+	"wallet/internal/logging"
 	"wallet/internal/server"
+	"wallet/internal/tracing"
 
 	"github.com/joho/godotenv"
+	"google.golang.org/grpc"
 )
 
-func gracefulShutdown(apiServer *http.Server, done chan bool) {
+func gracefulShutdown(apiServer *http.Server, grpcServer *grpc.Server, shutdownTracing func(context.Context) error, done chan bool) {
 	// Create context that listens for the interrupt signal from the OS.
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
@@ -31,6 +38,13 @@ func gracefulShutdown(apiServer *http.Server, done chan bool) {
 	if err := apiServer.Shutdown(ctx); err != nil {
 		log.Printf("Server forced to shutdown with error: %v", err)
 	}
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+	// Flush the spans of the requests that just finished
+	if err := shutdownTracing(ctx); err != nil {
+		log.Printf("Tracing forced to shutdown with error: %v", err)
+	}
 
 	log.Println("Server exiting")
 
@@ -49,6 +63,11 @@ func gracefulShutdown(apiServer *http.Server, done chan bool) {
 // @host      localhost:8080
 // @BasePath  /api/v1
 
+// @securityDefinitions.apikey OperatorToken
+// @in header
+// @name Authorization
+// @description "Bearer " followed by a finance operator's token from OPERATOR_TOKENS
+
 // @schemes http https
 // @externalDocs.description  OpenAPI
 // @externalDocs.url          https://swagger.io/resources/open-api/
@@ -56,15 +75,44 @@ func main() {
 	if err := godotenv.Load(); err != nil {
 		log.Fatal("Error loading .env file")
 	}
+
+	// Run a maintenance subcommand instead of the server, e.g. `wallet reconcile`.
+	// Its logs go to stderr to keep the command output parseable
+	if len(os.Args) > 1 {
+		logging.Setup(os.Stderr)
+		os.Exit(cli.Run(os.Args[1:]))
+	}
+	logging.Setup(os.Stdout)
+
+	shutdownTracing, err := tracing.Setup(context.Background())
+	if err != nil {
+		log.Fatal("Failed to set up tracing: ", err)
+	}
+
 	server := server.NewServer()
 
+	// Serve the gRPC API on its own port, if configured
+	var grpcServer *grpc.Server
+	if port := os.Getenv("GRPC_PORT"); port != "" {
+		listener, err := net.Listen("tcp", ":"+port)
+		if err != nil {
+			panic(fmt.Sprintf("grpc listen error: %s", err))
+		}
+		grpcServer = grpcserver.NewServer()
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Printf("grpc server error: %v", err)
+			}
+		}()
+	}
+
 	// Create a done channel to signal when the shutdown is complete
 	done := make(chan bool, 1)
 
 	// Run graceful shutdown in a separate goroutine
-	go gracefulShutdown(server, done)
+	go gracefulShutdown(server, grpcServer, shutdownTracing, done)
 
-	err := server.ListenAndServe()
+	err = server.ListenAndServe()
 	if err != nil && err != http.ErrServerClosed {
 		panic(fmt.Sprintf("http server error: %s", err))
 	}
@@ -52,15 +52,281 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Bad request",
+                        "description": "Malformed request",
                         "schema": {
-                            "$ref": "#/definitions/dto.AccountBadRequestResponse"
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "User already exists",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/accounts/{id}/adjustments": {
+            "post": {
+                "security": [
+                    {
+                        "OperatorToken": []
+                    }
+                ],
+                "description": "Credit (positive amount) or debit (negative amount) an account by hand with a reason code and a note. Adjustments up to ADJUSTMENT_APPROVAL_THRESHOLD are posted straight away; larger ones stay pending until an operator other than the one who made them approves them. The operator is the one the bearer token belongs to.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "adjustments"
+                ],
+                "summary": "Adjust a balance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Account ID or account number",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Amount, reason code and note",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.AdjustmentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Adjustment posted or pending approval",
+                        "schema": {
+                            "$ref": "#/definitions/dto.AdjustmentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Malformed request or invalid account ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Operator token missing or unknown",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Account not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Account is not open",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed or insufficient funds",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/accounts/{id}/balance": {
+            "get": {
+                "description": "Get the balance of an account as it stood at the given time, defaulting to now",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "accounts"
+                ],
+                "summary": "Get an account balance",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Account ID or account number",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "RFC 3339 timestamp, e.g. 2025-01-31T23:59:59Z",
+                        "name": "as_of",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Balance at the requested time",
+                        "schema": {
+                            "$ref": "#/definitions/dto.BalanceResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid account ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Account not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid as_of",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/accounts/{id}/beneficiaries": {
+            "get": {
+                "description": "List the bank accounts the account holder can withdraw to, oldest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "withdrawals"
+                ],
+                "summary": "List beneficiaries",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Account ID or account number",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Beneficiaries",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.BeneficiaryResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid account ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Account not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Add a bank account the account holder can withdraw to: an IBAN, whose check digits are verified, with an optional BIC for SEPA transfers, or a routing and account number for ACH transfers.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "withdrawals"
+                ],
+                "summary": "Add a beneficiary",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Account ID or account number",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Bank account",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateBeneficiaryRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Beneficiary added",
+                        "schema": {
+                            "$ref": "#/definitions/dto.BeneficiaryResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Malformed request or invalid account ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Account not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/dto.Problem"
                         }
                     }
                 }
@@ -68,7 +334,7 @@ const docTemplate = `{
         },
         "/accounts/{id}/charge": {
             "post": {
-                "description": "Charge an account with the given amount",
+                "description": "Charge an account with the given amount, which must not exceed its available balance. A pending charge, such as a card authorisation, holds the amount until it is settled or fails.\nA description, an external reference, the merchant's name and category code and a JSON object of metadata may be attached to the charge.",
                 "consumes": [
                     "application/json"
                 ],
@@ -82,7 +348,7 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Account ID",
+                        "description": "Account ID or account number",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -105,15 +371,118 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Bad request",
+                        "description": "Malformed request or invalid account ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Account not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Account is frozen",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed or insufficient funds",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/accounts/{id}/statements": {
+            "get": {
+                "description": "Download the transactions of an account over a period, between its opening and closing balances, with the balance after each transaction.\nfrom and to are dates (YYYY-MM-DD, in server time) or RFC 3339 timestamps; a date as to includes that whole day.\nThe period defaults to the current month up to now.",
+                "produces": [
+                    "text/csv",
+                    "application/pdf",
+                    "application/x-ofx",
+                    "application/vnd.intu.qfx",
+                    "text/plain",
+                    "application/xml"
+                ],
+                "tags": [
+                    "accounts"
+                ],
+                "summary": "Export an account statement",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Account ID or account number",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of the period, inclusive",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the period",
+                        "name": "to",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "csv",
+                            "pdf",
+                            "ofx",
+                            "qfx",
+                            "mt940",
+                            "camt053"
+                        ],
+                        "type": "string",
+                        "default": "csv",
+                        "description": "Statement format",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The statement",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid account ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Account not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Invalid period or format",
                         "schema": {
-                            "$ref": "#/definitions/dto.AccountBadRequestResponse"
+                            "$ref": "#/definitions/dto.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/dto.Problem"
                         }
                     }
                 }
@@ -121,7 +490,7 @@ const docTemplate = `{
         },
         "/accounts/{id}/top-up": {
             "post": {
-                "description": "Top up an account with the given amount",
+                "description": "Top up an account with the given amount, collected from the payment method by the payment provider. The top-up is returned posted once the payment succeeds, or pending while the provider processes it; its webhook then settles or fails the top-up.\nA pending top-up, such as a bank transfer, is not collected and only credits the account once it is settled.\nA description, an external reference and a JSON object of metadata may be attached to the top-up.",
                 "consumes": [
                     "application/json"
                 ],
@@ -135,7 +504,7 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Account ID",
+                        "description": "Account ID or account number",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -158,43 +527,1742 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Bad request",
+                        "description": "Malformed request or invalid account ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "402": {
+                        "description": "Payment declined",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Account not found",
                         "schema": {
-                            "$ref": "#/definitions/dto.AccountBadRequestResponse"
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Account is frozen",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
                         }
                     },
                     "500": {
                         "description": "Internal server error",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "502": {
+                        "description": "Payment provider error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "dto.AccountBadRequestResponse": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string",
-                    "default": "Invalid request"
+        },
+        "/accounts/{id}/transactions": {
+            "get": {
+                "description": "Page through an account's transactions, newest first, optionally only those matching a search. q matches the ref, description, external reference, merchant name or metadata, ignoring case.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "transactions"
+                ],
+                "summary": "List an account's transactions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Account ID or account number",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Text to search for",
+                        "name": "q",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "External reference",
+                        "name": "external_ref",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Four-digit merchant category code",
+                        "name": "merchant_category_code",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 50,
+                        "description": "Page size, at most 100",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Transactions",
+                        "schema": {
+                            "$ref": "#/definitions/dto.TransactionListResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid account ID or cursor",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Account not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/accounts/{id}/withdrawals": {
+            "post": {
+                "description": "Request a withdrawal to one of the account's beneficiaries. The amount is held against the available balance until the next payout run sends the withdrawal to the bank; it is debited once the bank confirms the transfer and given back if the bank returns it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "withdrawals"
+                ],
+                "summary": "Withdraw to a bank account",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Account ID or account number",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Beneficiary and amount",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.WithdrawalRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Withdrawal requested",
+                        "schema": {
+                            "$ref": "#/definitions/dto.WithdrawalResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Malformed request or invalid account ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Account or beneficiary not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Account is frozen or not open",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed or insufficient funds",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/adjustments": {
+            "get": {
+                "description": "List the adjustments in a status, oldest first. The pending adjustments, listed by default, are the ones waiting for approval.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "adjustments"
+                ],
+                "summary": "List adjustments",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "pending",
+                        "description": "pending, approved or rejected",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Adjustments",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.AdjustmentResponse"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unknown status",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/adjustments/{id}": {
+            "get": {
+                "description": "Get an adjustment with its status, who made and reviewed it, and once posted the transaction it was posted as",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "adjustments"
+                ],
+                "summary": "Get an adjustment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Adjustment ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Adjustment",
+                        "schema": {
+                            "$ref": "#/definitions/dto.AdjustmentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid adjustment ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Adjustment not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/adjustments/{id}/approve": {
+            "post": {
+                "security": [
+                    {
+                        "OperatorToken": []
+                    }
+                ],
+                "description": "Approve a pending adjustment and post it to the account. The operator, authenticated by their bearer token, must not be the one who made the adjustment.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "adjustments"
+                ],
+                "summary": "Approve an adjustment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Adjustment ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Review note",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ReviewAdjustmentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Adjustment approved and posted",
+                        "schema": {
+                            "$ref": "#/definitions/dto.AdjustmentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Malformed request or invalid adjustment ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Operator token missing or unknown",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "403": {
+                        "description": "Operator made the adjustment",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Adjustment or account not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Adjustment already reviewed, or account not open",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Insufficient funds",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/adjustments/{id}/reject": {
+            "post": {
+                "security": [
+                    {
+                        "OperatorToken": []
+                    }
+                ],
+                "description": "Reject a pending adjustment with a note saying why. The operator who made the adjustment may reject it to withdraw it.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "adjustments"
+                ],
+                "summary": "Reject an adjustment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Adjustment ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Reason for the rejection",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ReviewAdjustmentRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Adjustment rejected",
+                        "schema": {
+                            "$ref": "#/definitions/dto.AdjustmentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Malformed request or invalid adjustment ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Operator token missing or unknown",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Adjustment not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Adjustment already reviewed",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Note missing",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/batches": {
+            "post": {
+                "security": [
+                    {
+                        "OperatorToken": []
+                    }
+                ],
+                "description": "Submit up to 10000 top-ups and charges, e.g. a payroll run, to be processed in the background. The batch is sent either as JSON or as CSV, in a text/csv body or the \"file\" field of a multipart/form-data upload, with the header account_id,operation,amount,reference; CSV batches pass their mode as a query parameter.\nIn atomic mode, limited to 1000 items, every item is applied or, if one fails, none are; in best_effort mode (the default) items are applied independently. Item references are unique across all batches, so a batch that is submitted twice is refused.",
+                "consumes": [
+                    "application/json",
+                    "text/csv",
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "batches"
+                ],
+                "summary": "Submit a batch of top-ups and charges",
+                "parameters": [
+                    {
+                        "enum": [
+                            "atomic",
+                            "best_effort"
+                        ],
+                        "type": "string",
+                        "description": "Mode of a CSV batch",
+                        "name": "mode",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Batch of items",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateBatchRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Batch accepted for processing",
+                        "schema": {
+                            "$ref": "#/definitions/dto.BatchResponse"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "URL of the batch's status"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Malformed request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Operator token missing or unknown",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Reference already submitted",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "413": {
+                        "description": "Batch too large",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/batches/{id}": {
+            "get": {
+                "description": "Get the status of a batch and the result of each of its items",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "batches"
+                ],
+                "summary": "Get a batch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Batch ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Batch status",
+                        "schema": {
+                            "$ref": "#/definitions/dto.BatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid batch ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Batch not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/deposits": {
+            "get": {
+                "description": "List the deposits in a status, oldest first. The unmatched deposits, listed by default, are the suspense queue.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "deposits"
+                ],
+                "summary": "List deposits",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "unmatched",
+                        "description": "matched, unmatched or assigned",
+                        "name": "status",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deposits",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.DepositResponse"
+                            }
+                        }
+                    },
+                    "422": {
+                        "description": "Unknown status",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "OperatorToken": []
+                    }
+                ],
+                "description": "Record a bank transfer reported by the bank. It is credited as a top-up to the account whose virtual IBAN it was sent to, or whose virtual IBAN its remittance information quotes. Transfers that match no open account are left unmatched in the suspense queue.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "deposits"
+                ],
+                "summary": "Receive a bank transfer",
+                "parameters": [
+                    {
+                        "description": "Bank transfer",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.DepositRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Deposit recorded, matched or unmatched",
+                        "schema": {
+                            "$ref": "#/definitions/dto.DepositResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Malformed request",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Operator token missing or unknown",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Transfer already received",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/deposits/{id}": {
+            "get": {
+                "description": "Get a deposit with its status and, once credited, the account and top-up it was credited by",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "deposits"
+                ],
+                "summary": "Get a deposit",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Deposit ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deposit",
+                        "schema": {
+                            "$ref": "#/definitions/dto.DepositResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid deposit ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Deposit not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/deposits/{id}/assign": {
+            "post": {
+                "security": [
+                    {
+                        "OperatorToken": []
+                    }
+                ],
+                "description": "Take an unmatched deposit out of the suspense queue by crediting it to an account as a top-up",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "deposits"
+                ],
+                "summary": "Assign a deposit",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Deposit ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Account to credit",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.AssignDepositRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Deposit assigned",
+                        "schema": {
+                            "$ref": "#/definitions/dto.DepositResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Malformed request or invalid deposit ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Operator token missing or unknown",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Deposit or account not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Deposit not in the suspense queue, or account frozen or not open",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/graphql": {
+            "post": {
+                "description": "Query users, accounts and transactions, or top up and charge accounts, in a single request. Queries deeper than 8 levels or with a complexity above 1000 are rejected.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "graphql"
+                ],
+                "summary": "Execute a GraphQL query",
+                "parameters": [
+                    {
+                        "description": "GraphQL request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.GraphQLRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Query result",
+                        "schema": {
+                            "$ref": "#/definitions/dto.GraphQLResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid or too expensive query",
+                        "schema": {
+                            "$ref": "#/definitions/dto.GraphQLResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payments/webhooks/{provider}": {
+            "post": {
+                "description": "Apply the outcome of a payment sent by its provider, which settles or fails the top-up it funds. Webhooks must carry the provider's signature; those sent more than once change nothing.",
+                "consumes": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Receive a payment provider webhook",
+                "parameters": [
+                    {
+                        "enum": [
+                            "fake"
+                        ],
+                        "type": "string",
+                        "description": "Payment provider",
+                        "name": "provider",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "Webhook applied"
+                    },
+                    "400": {
+                        "description": "Invalid webhook",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Payment not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/transactions/{id}/fail": {
+            "post": {
+                "security": [
+                    {
+                        "OperatorToken": []
+                    }
+                ],
+                "description": "Fail a pending top-up or charge, releasing the amount a pending charge holds. The body, giving a reason, is optional.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "transactions"
+                ],
+                "summary": "Fail a pending transaction",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Transaction ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Reason",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/dto.TransactionStatusRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Transaction failed",
+                        "schema": {
+                            "$ref": "#/definitions/dto.TransactionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Malformed request or invalid transaction ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Operator token missing or unknown",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Transaction not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Transaction is not pending",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/transactions/{id}/refund": {
+            "post": {
+                "security": [
+                    {
+                        "OperatorToken": []
+                    }
+                ],
+                "description": "Reverse a top-up that was collected through the payment provider and return its money. The amount must still be available. If the provider fails, the top-up stays reversed and refunding it again retries the provider.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "payments"
+                ],
+                "summary": "Refund a top-up",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Transaction ID of the top-up",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Reason",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.RefundRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Payment refunded",
+                        "schema": {
+                            "$ref": "#/definitions/dto.PaymentResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Malformed request or invalid transaction ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Operator token missing or unknown",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Payment not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Payment cannot be refunded",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed or insufficient funds",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "502": {
+                        "description": "Payment provider error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/transactions/{id}/reverse": {
+            "post": {
+                "security": [
+                    {
+                        "OperatorToken": []
+                    }
+                ],
+                "description": "Undo a posted top-up or charge, e.g. for a chargeback, with a new posted transaction of the opposite type, which is returned. The original is marked reversed. Reversing a top-up needs its amount to be available.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "transactions"
+                ],
+                "summary": "Reverse a posted transaction",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Transaction ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Reason",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ReverseTransactionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The reversing transaction",
+                        "schema": {
+                            "$ref": "#/definitions/dto.TransactionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Malformed request or invalid transaction ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Operator token missing or unknown",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Transaction not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Transaction is not posted",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "422": {
+                        "description": "Validation failed or insufficient funds",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/transactions/{id}/settle": {
+            "post": {
+                "security": [
+                    {
+                        "OperatorToken": []
+                    }
+                ],
+                "description": "Post a pending top-up or charge. A settled top-up credits the account; a settled charge turns its hold into a debit.\nTop-ups funded through the payment provider are settled by their payment's outcome and cannot be settled here.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "transactions"
+                ],
+                "summary": "Settle a pending transaction",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Transaction ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Transaction settled",
+                        "schema": {
+                            "$ref": "#/definitions/dto.TransactionResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid transaction ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "401": {
+                        "description": "Operator token missing or unknown",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Transaction not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "409": {
+                        "description": "Transaction is not pending or is settled by its payment",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        },
+        "/withdrawals/{id}": {
+            "get": {
+                "description": "Get a withdrawal with its status: requested, submitted, completed, returned with the bank's reason, or failed if its hold lapsed before it was paid out",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "withdrawals"
+                ],
+                "summary": "Get a withdrawal",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Withdrawal ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Withdrawal",
+                        "schema": {
+                            "$ref": "#/definitions/dto.WithdrawalResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid withdrawal ID",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "404": {
+                        "description": "Withdrawal not found",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Problem"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "dto.AdjustmentRequest": {
+            "type": "object",
+            "required": [
+                "amount",
+                "note",
+                "reason_code"
+            ],
+            "properties": {
+                "amount": {
+                    "description": "Amount credits the account when positive and debits it when negative.",
+                    "type": "number",
+                    "example": -25
+                },
+                "note": {
+                    "type": "string",
+                    "example": "Unrecoverable negative balance"
+                },
+                "reason_code": {
+                    "type": "string",
+                    "enum": [
+                        "goodwill",
+                        "write_off",
+                        "correction",
+                        "fee_refund",
+                        "chargeback",
+                        "other"
+                    ],
+                    "example": "write_off"
+                }
+            }
+        },
+        "dto.AdjustmentResponse": {
+            "type": "object",
+            "properties": {
+                "account_id": {
+                    "type": "string"
+                },
+                "amount": {
+                    "type": "number",
+                    "example": -25
+                },
+                "id": {
+                    "type": "string"
+                },
+                "note": {
+                    "type": "string"
+                },
+                "reason_code": {
+                    "type": "string",
+                    "example": "write_off"
+                },
+                "requested_by": {
+                    "type": "string",
+                    "example": "alice"
+                },
+                "review_note": {
+                    "type": "string"
+                },
+                "reviewed_at": {
+                    "type": "string"
+                },
+                "reviewed_by": {
+                    "type": "string",
+                    "example": "bob"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "pending"
+                },
+                "transaction_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.AssignDepositRequest": {
+            "type": "object",
+            "required": [
+                "account_id"
+            ],
+            "properties": {
+                "account_id": {
+                    "type": "string",
+                    "example": "3f0c1e8a-2b7d-4c59-9e61-7a8b5d4c3e21"
+                }
+            }
+        },
+        "dto.BalanceResponse": {
+            "type": "object",
+            "properties": {
+                "account_id": {
+                    "type": "string"
+                },
+                "as_of": {
+                    "type": "string"
+                },
+                "balance": {
+                    "type": "number"
+                },
+                "snapshot_closing_at": {
+                    "type": "string"
+                },
+                "transactions_applied": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.BatchItemRequest": {
+            "type": "object",
+            "properties": {
+                "account_id": {
+                    "type": "string",
+                    "example": "0b9e7c3a-5b1e-4d8e-9a57-3f2c1d0e9b8a"
+                },
+                "amount": {
+                    "type": "number",
+                    "example": 1250
+                },
+                "operation": {
+                    "type": "string",
+                    "example": "top-up"
+                },
+                "reference": {
+                    "type": "string",
+                    "example": "PAYROLL-2025-01-0042"
+                }
+            }
+        },
+        "dto.BatchItemResult": {
+            "type": "object",
+            "properties": {
+                "account_id": {
+                    "type": "string"
+                },
+                "amount": {
+                    "type": "number"
+                },
+                "error": {
+                    "type": "string",
+                    "example": "insufficient balance"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "operation": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "succeeded"
+                },
+                "transaction_ref": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.BatchResponse": {
+            "type": "object",
+            "properties": {
+                "completed_at": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "failed": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "items": {
+                    "description": "Items are the per-item results, in submission order.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.BatchItemResult"
+                    }
+                },
+                "mode": {
+                    "type": "string",
+                    "example": "best_effort"
+                },
+                "pending": {
+                    "type": "integer"
+                },
+                "skipped": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "completed"
+                },
+                "succeeded": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.BeneficiaryResponse": {
+            "type": "object",
+            "properties": {
+                "account_id": {
+                    "type": "string"
+                },
+                "account_number": {
+                    "type": "string"
+                },
+                "bic": {
+                    "type": "string"
+                },
+                "iban": {
+                    "type": "string",
+                    "example": "DE89370400440532013000"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "routing_number": {
+                    "type": "string"
+                },
+                "savings": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "dto.ChargeRequest": {
+            "type": "object",
+            "required": [
+                "amount"
+            ],
+            "properties": {
+                "amount": {
+                    "type": "number"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "Weekly groceries"
+                },
+                "external_ref": {
+                    "description": "ExternalRef is the client's own reference, such as an order ID.",
+                    "type": "string",
+                    "example": "order-1042"
+                },
+                "merchant_category_code": {
+                    "description": "MerchantCategoryCode is the four-digit ISO 18245 merchant category\ncode.",
+                    "type": "string",
+                    "example": "5411"
+                },
+                "merchant_name": {
+                    "type": "string",
+                    "example": "Corner Shop"
+                },
+                "metadata": {
+                    "description": "Metadata is a JSON object of the client's own keys and values.",
+                    "type": "object"
+                },
+                "pending": {
+                    "description": "Pending holds the amount until the charge is settled or fails.",
+                    "type": "boolean"
+                }
+            }
+        },
+        "dto.ChargeResponse": {
+            "type": "object",
+            "properties": {
+                "account_id": {
+                    "type": "string"
+                },
+                "amount": {
+                    "type": "number"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "Weekly groceries"
+                },
+                "external_ref": {
+                    "description": "ExternalRef is the client's own reference, such as an order ID.",
+                    "type": "string",
+                    "example": "order-1042"
+                },
+                "merchant_category_code": {
+                    "description": "MerchantCategoryCode is the four-digit ISO 18245 merchant category\ncode.",
+                    "type": "string",
+                    "example": "5411"
+                },
+                "merchant_name": {
+                    "type": "string",
+                    "example": "Corner Shop"
+                },
+                "metadata": {
+                    "description": "Metadata is a JSON object of the client's own keys and values.",
+                    "type": "object"
+                },
+                "new_balance": {
+                    "type": "number"
+                },
+                "transaction_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.CreateAccountRequest": {
+            "type": "object",
+            "required": [
+                "email",
+                "first_name",
+                "last_name"
+            ],
+            "properties": {
+                "email": {
+                    "type": "string"
+                },
+                "first_name": {
+                    "type": "string"
+                },
+                "last_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.CreateAccountResponse": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "number"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "first_name": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_name": {
+                    "type": "string"
+                },
+                "number": {
+                    "description": "Number is the account number, which routes accept in place of the ID.",
+                    "type": "string",
+                    "example": "1234567897"
+                },
+                "virtual_iban": {
+                    "description": "VirtualIBAN is the IBAN to send bank transfers to the account to.",
+                    "type": "string",
+                    "example": "DE02000000000123456789"
+                }
+            }
+        },
+        "dto.CreateBatchRequest": {
+            "type": "object",
+            "required": [
+                "items"
+            ],
+            "properties": {
+                "items": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.BatchItemRequest"
+                    }
+                },
+                "mode": {
+                    "description": "Mode is atomic or best_effort, the default.",
+                    "type": "string",
+                    "example": "best_effort"
                 }
             }
         },
-        "dto.ChargeRequest": {
+        "dto.CreateBeneficiaryRequest": {
             "type": "object",
             "required": [
-                "amount"
+                "name"
+            ],
+            "properties": {
+                "account_number": {
+                    "type": "string",
+                    "example": "123456789"
+                },
+                "bic": {
+                    "type": "string",
+                    "example": "COBADEFFXXX"
+                },
+                "iban": {
+                    "type": "string",
+                    "example": "DE89 3704 0044 0532 0130 00"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "Jane Doe"
+                },
+                "routing_number": {
+                    "type": "string",
+                    "example": "021000021"
+                },
+                "savings": {
+                    "description": "Savings marks an ACH account as a savings rather than a checking\naccount.",
+                    "type": "boolean"
+                }
+            }
+        },
+        "dto.DepositRequest": {
+            "type": "object",
+            "required": [
+                "amount",
+                "reference"
             ],
             "properties": {
                 "amount": {
-                    "type": "number"
+                    "type": "number",
+                    "example": 120
+                },
+                "booked_at": {
+                    "type": "string"
+                },
+                "creditor_iban": {
+                    "description": "CreditorIBAN is the virtual IBAN the transfer was sent to.",
+                    "type": "string",
+                    "example": "DE02000000000123456789"
+                },
+                "currency": {
+                    "description": "Currency defaults to the wallet's.",
+                    "type": "string",
+                    "example": "USD"
+                },
+                "debtor_iban": {
+                    "type": "string",
+                    "example": "DE89370400440532013000"
+                },
+                "debtor_name": {
+                    "type": "string",
+                    "example": "Jane Doe"
+                },
+                "reference": {
+                    "description": "Reference is the bank's unique reference of the transfer.",
+                    "type": "string",
+                    "example": "2025030300012345"
+                },
+                "remittance": {
+                    "type": "string",
+                    "example": "Wallet top-up"
                 }
             }
         },
-        "dto.ChargeResponse": {
+        "dto.DepositResponse": {
             "type": "object",
             "properties": {
                 "account_id": {
@@ -203,53 +2271,201 @@ const docTemplate = `{
                 "amount": {
                     "type": "number"
                 },
-                "new_balance": {
-                    "type": "number"
+                "booked_at": {
+                    "type": "string"
+                },
+                "creditor_iban": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "debtor_iban": {
+                    "type": "string"
+                },
+                "debtor_name": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string",
+                    "example": "no account has the virtual IBAN"
+                },
+                "reference": {
+                    "type": "string"
+                },
+                "remittance": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "unmatched"
                 },
                 "transaction_id": {
                     "type": "string"
                 }
             }
         },
-        "dto.CreateAccountRequest": {
+        "dto.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string",
+                    "example": "amount"
+                },
+                "message": {
+                    "type": "string",
+                    "example": "must be greater than 0"
+                }
+            }
+        },
+        "dto.GraphQLError": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.GraphQLRequest": {
             "type": "object",
             "required": [
-                "email",
-                "first_name",
-                "last_name"
+                "query"
             ],
             "properties": {
-                "email": {
+                "operationName": {
                     "type": "string"
                 },
-                "first_name": {
+                "query": {
                     "type": "string"
                 },
-                "last_name": {
-                    "type": "string"
+                "variables": {
+                    "type": "object",
+                    "additionalProperties": {}
                 }
             }
         },
-        "dto.CreateAccountResponse": {
+        "dto.GraphQLResponse": {
             "type": "object",
             "properties": {
-                "balance": {
-                    "type": "number"
+                "data": {
+                    "type": "object",
+                    "additionalProperties": {}
                 },
-                "email": {
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.GraphQLError"
+                    }
+                }
+            }
+        },
+        "dto.PaymentResponse": {
+            "type": "object",
+            "properties": {
+                "account_id": {
                     "type": "string"
                 },
-                "first_name": {
+                "amount": {
+                    "type": "number"
+                },
+                "failure_reason": {
                     "type": "string"
                 },
                 "id": {
                     "type": "string"
                 },
-                "last_name": {
+                "intent_id": {
+                    "type": "string"
+                },
+                "provider": {
+                    "type": "string",
+                    "example": "fake"
+                },
+                "refund_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "refunded"
+                },
+                "transaction_id": {
                     "type": "string"
                 }
             }
         },
+        "dto.Problem": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "insufficient_funds"
+                },
+                "detail": {
+                    "type": "string",
+                    "example": "insufficient balance"
+                },
+                "errors": {
+                    "description": "Errors lists the invalid fields of a validation_failed problem.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.FieldError"
+                    }
+                },
+                "instance": {
+                    "type": "string",
+                    "example": "/api/v1/accounts/0b9e.../charge"
+                },
+                "status": {
+                    "type": "integer",
+                    "example": 422
+                },
+                "title": {
+                    "type": "string",
+                    "example": "Insufficient funds"
+                },
+                "type": {
+                    "type": "string",
+                    "example": "urn:wallet:problem:insufficient_funds"
+                }
+            }
+        },
+        "dto.RefundRequest": {
+            "type": "object",
+            "required": [
+                "reason"
+            ],
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "example": "customer request"
+                }
+            }
+        },
+        "dto.ReverseTransactionRequest": {
+            "type": "object",
+            "required": [
+                "reason"
+            ],
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "example": "chargeback"
+                }
+            }
+        },
+        "dto.ReviewAdjustmentRequest": {
+            "type": "object",
+            "properties": {
+                "note": {
+                    "description": "Note is optional when approving and required when rejecting.",
+                    "type": "string",
+                    "example": "Checked against the bank statement"
+                }
+            }
+        },
         "dto.TopUpRequest": {
             "type": "object",
             "required": [
@@ -258,6 +2474,37 @@ const docTemplate = `{
             "properties": {
                 "amount": {
                     "type": "number"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "Weekly groceries"
+                },
+                "external_ref": {
+                    "description": "ExternalRef is the client's own reference, such as an order ID.",
+                    "type": "string",
+                    "example": "order-1042"
+                },
+                "merchant_category_code": {
+                    "description": "MerchantCategoryCode is the four-digit ISO 18245 merchant category\ncode.",
+                    "type": "string",
+                    "example": "5411"
+                },
+                "merchant_name": {
+                    "type": "string",
+                    "example": "Corner Shop"
+                },
+                "metadata": {
+                    "description": "Metadata is a JSON object of the client's own keys and values.",
+                    "type": "object"
+                },
+                "payment_method": {
+                    "description": "PaymentMethod is what the payment provider collects the top-up from,\ne.g. fake_card or fake_card_delayed with the fake provider.",
+                    "type": "string",
+                    "example": "fake_card"
+                },
+                "pending": {
+                    "description": "Pending leaves the top-up pending until it is settled, e.g. for a\nbank transfer, instead of collecting it with PaymentMethod.",
+                    "type": "boolean"
                 }
             }
         },
@@ -270,6 +2517,28 @@ const docTemplate = `{
                 "amount": {
                     "type": "number"
                 },
+                "description": {
+                    "type": "string",
+                    "example": "Weekly groceries"
+                },
+                "external_ref": {
+                    "description": "ExternalRef is the client's own reference, such as an order ID.",
+                    "type": "string",
+                    "example": "order-1042"
+                },
+                "merchant_category_code": {
+                    "description": "MerchantCategoryCode is the four-digit ISO 18245 merchant category\ncode.",
+                    "type": "string",
+                    "example": "5411"
+                },
+                "merchant_name": {
+                    "type": "string",
+                    "example": "Corner Shop"
+                },
+                "metadata": {
+                    "description": "Metadata is a JSON object of the client's own keys and values.",
+                    "type": "object"
+                },
                 "new_balance": {
                     "type": "number"
                 },
@@ -277,6 +2546,151 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "dto.TransactionListResponse": {
+            "type": "object",
+            "properties": {
+                "next_cursor": {
+                    "description": "NextCursor continues the listing; it is empty on the last page.",
+                    "type": "string"
+                },
+                "transactions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.TransactionResponse"
+                    }
+                }
+            }
+        },
+        "dto.TransactionResponse": {
+            "type": "object",
+            "properties": {
+                "account_id": {
+                    "type": "string"
+                },
+                "amount": {
+                    "type": "number"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "Weekly groceries"
+                },
+                "external_ref": {
+                    "description": "ExternalRef is the client's own reference, such as an order ID.",
+                    "type": "string",
+                    "example": "order-1042"
+                },
+                "merchant_category_code": {
+                    "description": "MerchantCategoryCode is the four-digit ISO 18245 merchant category\ncode.",
+                    "type": "string",
+                    "example": "5411"
+                },
+                "merchant_name": {
+                    "type": "string",
+                    "example": "Corner Shop"
+                },
+                "metadata": {
+                    "description": "Metadata is a JSON object of the client's own keys and values.",
+                    "type": "object"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "posted"
+                },
+                "transaction_id": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "Type is top-up, charge or adjustment. The amounts of adjustments are\nsigned, with debits negative.",
+                    "type": "string",
+                    "enum": [
+                        "top-up",
+                        "charge",
+                        "adjustment"
+                    ],
+                    "example": "charge"
+                }
+            }
+        },
+        "dto.TransactionStatusRequest": {
+            "type": "object",
+            "properties": {
+                "reason": {
+                    "type": "string",
+                    "example": "card authorisation expired"
+                }
+            }
+        },
+        "dto.WithdrawalRequest": {
+            "type": "object",
+            "required": [
+                "amount",
+                "beneficiary_id"
+            ],
+            "properties": {
+                "amount": {
+                    "type": "number",
+                    "example": 250
+                },
+                "beneficiary_id": {
+                    "type": "string",
+                    "example": "0b9e7c3a-5b1e-4d8e-9a57-3f2c1d0e9b8a"
+                }
+            }
+        },
+        "dto.WithdrawalResponse": {
+            "type": "object",
+            "properties": {
+                "account_id": {
+                    "type": "string"
+                },
+                "amount": {
+                    "type": "number"
+                },
+                "beneficiary_id": {
+                    "type": "string"
+                },
+                "completed_at": {
+                    "type": "string"
+                },
+                "end_to_end_id": {
+                    "type": "string",
+                    "example": "WD0B9E7C3A5B1E4D8E9A573F2C1D0E9B8A"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "payout_id": {
+                    "type": "string"
+                },
+                "return_code": {
+                    "type": "string",
+                    "example": "AC04"
+                },
+                "return_reason": {
+                    "type": "string",
+                    "example": "Closed account number"
+                },
+                "status": {
+                    "type": "string",
+                    "example": "submitted"
+                },
+                "trace_number": {
+                    "type": "string",
+                    "example": "021000020000001"
+                },
+                "transaction_id": {
+                    "type": "string"
+                }
+            }
+        }
+    },
+    "securityDefinitions": {
+        "OperatorToken": {
+            "description": "\"Bearer \" followed by a finance operator's token from OPERATOR_TOKENS",
+            "type": "apiKey",
+            "name": "Authorization",
+            "in": "header"
         }
     },
     "externalDocs": {
@@ -0,0 +1,62 @@
+package banking
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// AccountNumberLength is the number of digits of a wallet account number,
+// the last of which is a Luhn check digit.
+const AccountNumberLength = 10
+
+var ErrInvalidAccountNumber = errors.New("invalid account number")
+
+// NewAccountNumber appends the Luhn check digit to the given digits, which
+// must be one fewer than AccountNumberLength.
+func NewAccountNumber(digits string) (string, error) {
+	if len(digits) != AccountNumberLength-1 || !allDigits(digits) {
+		return "", fmt.Errorf("%w: %q is not %d digits", ErrInvalidAccountNumber, digits, AccountNumberLength-1)
+	}
+	return digits + string(luhnDigit(digits)), nil
+}
+
+// ValidateAccountNumber verifies the check digit of an account number,
+// given with or without the spaces and dashes it is printed with, and
+// returns it as digits only.
+func ValidateAccountNumber(number string) (string, error) {
+	number = strings.NewReplacer(" ", "", "-", "").Replace(number)
+	if len(number) != AccountNumberLength || !allDigits(number) {
+		return "", fmt.Errorf("%w: must be %d digits", ErrInvalidAccountNumber, AccountNumberLength)
+	}
+	if luhnDigit(number[:len(number)-1]) != number[len(number)-1] {
+		return "", fmt.Errorf("%w: check digit mismatch", ErrInvalidAccountNumber)
+	}
+	return number, nil
+}
+
+// luhnDigit is the Luhn check digit of digits, which catches any single
+// mistyped digit and most swaps of adjacent digits.
+func luhnDigit(digits string) byte {
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		// Double every other digit, starting with the rightmost
+		if (len(digits)-1-i)%2 == 0 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
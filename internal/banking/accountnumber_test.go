@@ -0,0 +1,31 @@
+package banking
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAccountNumber(t *testing.T) {
+	number, err := NewAccountNumber("123456789")
+	if err != nil || number != "1234567897" {
+		t.Fatalf("NewAccountNumber: got %q, %v want %q", number, err, "1234567897")
+	}
+
+	for _, input := range []string{"1234567897", "1234 5678 97", "12345-67897"} {
+		if got, err := ValidateAccountNumber(input); err != nil || got != number {
+			t.Errorf("ValidateAccountNumber(%q): got %q, %v want %q", input, got, err, number)
+		}
+	}
+	invalid := []string{
+		"",
+		"1234567898", // check digit
+		"1234567987", // swapped digits
+		"123456789",  // length
+		"12345678A7", // characters
+	}
+	for _, input := range invalid {
+		if _, err := ValidateAccountNumber(input); !errors.Is(err, ErrInvalidAccountNumber) {
+			t.Errorf("ValidateAccountNumber(%q): got error %v want %v", input, err, ErrInvalidAccountNumber)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Package banking validates the identifiers of bank accounts the wallet
+// pays out to: IBANs and BICs for SEPA transfers, and ABA routing numbers
+// for ACH transfers. It also issues the identifiers of the wallet's own
+// accounts: their virtual IBANs and account numbers.
+package banking
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrInvalidIBAN          = errors.New("invalid IBAN")
+	ErrInvalidBIC           = errors.New("invalid BIC")
+	ErrInvalidRoutingNumber = errors.New("invalid routing number")
+)
+
+// ibanLengths is the length of the IBANs of each country that issues them,
+// from the SWIFT IBAN registry.
+var ibanLengths = map[string]int{
+	"AD": 24, "AE": 23, "AL": 28, "AT": 20, "AZ": 28, "BA": 20, "BE": 16,
+	"BG": 22, "BH": 22, "BR": 29, "BY": 28, "CH": 21, "CR": 22, "CY": 28,
+	"CZ": 24, "DE": 22, "DK": 18, "DO": 28, "EE": 20, "EG": 29, "ES": 24,
+	"FI": 18, "FO": 18, "FR": 27, "GB": 22, "GE": 22, "GI": 23, "GL": 18,
+	"GR": 27, "GT": 28, "HR": 21, "HU": 28, "IE": 22, "IL": 23, "IQ": 23,
+	"IS": 26, "IT": 27, "JO": 30, "KW": 30, "KZ": 20, "LB": 28, "LC": 32,
+	"LI": 21, "LT": 20, "LU": 20, "LV": 21, "MC": 27, "MD": 24, "ME": 22,
+	"MK": 19, "MR": 27, "MT": 31, "MU": 30, "NL": 18, "NO": 15, "PK": 24,
+	"PL": 28, "PS": 29, "PT": 25, "QA": 29, "RO": 24, "RS": 22, "SA": 24,
+	"SC": 31, "SE": 24, "SI": 19, "SK": 24, "SM": 27, "ST": 25, "SV": 28,
+	"TL": 23, "TN": 24, "TR": 26, "UA": 29, "VA": 22, "VG": 24, "XK": 20,
+}
+
+var bicPattern = regexp.MustCompile(`^[A-Z]{4}[A-Z]{2}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+
+// NormalizeIBAN returns an IBAN in its electronic form: upper case and
+// without the spaces of the printed form.
+func NormalizeIBAN(iban string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(iban), ""))
+}
+
+// ValidateIBAN checks the country, length and check digits of an IBAN and
+// returns it normalised.
+func ValidateIBAN(iban string) (string, error) {
+	iban = NormalizeIBAN(iban)
+	if len(iban) < 4 {
+		return "", fmt.Errorf("%w: too short", ErrInvalidIBAN)
+	}
+	length, ok := ibanLengths[iban[:2]]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown country %q", ErrInvalidIBAN, iban[:2])
+	}
+	if len(iban) != length {
+		return "", fmt.Errorf("%w: %s IBANs have %d characters, not %d", ErrInvalidIBAN, iban[:2], length, len(iban))
+	}
+	remainder, ok := mod97(iban[4:] + iban[:4])
+	if !ok {
+		return "", fmt.Errorf("%w: only letters and digits are allowed", ErrInvalidIBAN)
+	}
+	if remainder != 1 {
+		return "", fmt.Errorf("%w: check digits do not match", ErrInvalidIBAN)
+	}
+	return iban, nil
+}
+
+// NewIBAN returns the IBAN of a country's basic bank account number, with
+// its check digits computed.
+func NewIBAN(country, bban string) (string, error) {
+	country, bban = strings.ToUpper(country), NormalizeIBAN(bban)
+	length, ok := ibanLengths[country]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown country %q", ErrInvalidIBAN, country)
+	}
+	if len(bban) != length-4 {
+		return "", fmt.Errorf("%w: %s account numbers have %d characters, not %d", ErrInvalidIBAN, country, length-4, len(bban))
+	}
+	remainder, ok := mod97(bban + country + "00")
+	if !ok {
+		return "", fmt.Errorf("%w: only letters and digits are allowed", ErrInvalidIBAN)
+	}
+	return fmt.Sprintf("%s%02d%s", country, 98-remainder, bban), nil
+}
+
+// Length returns the length of the IBANs of a country, or 0 if the country
+// does not issue them.
+func Length(country string) int {
+	return ibanLengths[strings.ToUpper(country)]
+}
+
+// mod97 returns the ISO 7064 MOD 97-10 remainder of s, in which letters
+// count as the numbers 10 to 35. It reports false if s has other characters.
+func mod97(s string) (int, bool) {
+	remainder := 0
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			remainder = (remainder*10 + int(r-'0')) % 97
+		case r >= 'A' && r <= 'Z':
+			remainder = (remainder*100 + int(r-'A') + 10) % 97
+		default:
+			return 0, false
+		}
+	}
+	return remainder, true
+}
+
+// ValidateBIC checks the form of a BIC and returns it in upper case.
+func ValidateBIC(bic string) (string, error) {
+	bic = strings.ToUpper(strings.TrimSpace(bic))
+	if !bicPattern.MatchString(bic) {
+		return "", fmt.Errorf("%w: a BIC has 8 or 11 letters and digits", ErrInvalidBIC)
+	}
+	return bic, nil
+}
+
+// ValidateRoutingNumber checks the length and check digit of an ABA routing
+// number.
+func ValidateRoutingNumber(routingNumber string) error {
+	if len(routingNumber) != 9 {
+		return fmt.Errorf("%w: a routing number has 9 digits", ErrInvalidRoutingNumber)
+	}
+	weights := [9]int{3, 7, 1, 3, 7, 1, 3, 7, 1}
+	sum := 0
+	for i, r := range routingNumber {
+		if r < '0' || r > '9' {
+			return fmt.Errorf("%w: a routing number has 9 digits", ErrInvalidRoutingNumber)
+		}
+		sum += int(r-'0') * weights[i]
+	}
+	if sum%10 != 0 {
+		return fmt.Errorf("%w: check digit does not match", ErrInvalidRoutingNumber)
+	}
+	return nil
+}
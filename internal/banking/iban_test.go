@@ -0,0 +1,59 @@
+package banking
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateIBAN(t *testing.T) {
+	valid := map[string]string{
+		"DE89 3704 0044 0532 0130 00":       "DE89370400440532013000",
+		"gb82west12345698765432":            "GB82WEST12345698765432",
+		"FR14 2004 1010 0505 0001 3M02 606": "FR1420041010050500013M02606",
+		"NO9386011117947":                   "NO9386011117947",
+	}
+	for input, want := range valid {
+		got, err := ValidateIBAN(input)
+		if err != nil || got != want {
+			t.Errorf("ValidateIBAN(%q): got %q, %v want %q", input, got, err, want)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"DE88370400440532013000",   // check digits
+		"DE8937040044053201300",    // length
+		"ZZ89370400440532013000",   // country
+		"DE89-3704-0044-0532-0130", // characters
+	}
+	for _, input := range invalid {
+		if _, err := ValidateIBAN(input); !errors.Is(err, ErrInvalidIBAN) {
+			t.Errorf("ValidateIBAN(%q): got error %v want %v", input, err, ErrInvalidIBAN)
+		}
+	}
+}
+
+func TestNewIBAN(t *testing.T) {
+	for _, want := range []string{"DE89370400440532013000", "GB82WEST12345698765432", "NO9386011117947"} {
+		got, err := NewIBAN(want[:2], want[4:])
+		if err != nil || got != want {
+			t.Errorf("NewIBAN(%q, %q): got %q, %v want %q", want[:2], want[4:], got, err, want)
+		}
+	}
+	if _, err := NewIBAN("DE", "3704004405320130"); !errors.Is(err, ErrInvalidIBAN) {
+		t.Errorf("NewIBAN with a short account number: got error %v want %v", err, ErrInvalidIBAN)
+	}
+}
+
+func TestValidateRoutingNumber(t *testing.T) {
+	for _, input := range []string{"021000021", "011000015", "121000248"} {
+		if err := ValidateRoutingNumber(input); err != nil {
+			t.Errorf("ValidateRoutingNumber(%q): got error %v want nil", input, err)
+		}
+	}
+	for _, input := range []string{"021000022", "02100002", "02100002A"} {
+		if err := ValidateRoutingNumber(input); !errors.Is(err, ErrInvalidRoutingNumber) {
+			t.Errorf("ValidateRoutingNumber(%q): got error %v want %v", input, err, ErrInvalidRoutingNumber)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"time"
+
+	"wallet/internal/database"
+	"wallet/internal/models"
+	"wallet/internal/repository"
+	"wallet/internal/services"
+
+	"github.com/google/uuid"
+)
+
+func account(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "create" {
+		return errors.New("usage: wallet account create -user <user-id|email>")
+	}
+
+	fs := flag.NewFlagSet("account create", flag.ContinueOnError)
+	owner := fs.String("user", "", "ID or email of the owning user (required)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *owner == "" {
+		return errors.New("-user is required")
+	}
+
+	db := database.New()
+	user, err := findUser(ctx, db.GetDB(), *owner)
+	if err != nil {
+		return err
+	}
+	created, err := services.NewAccountService(db.GetDB()).WithActor(operator()).CreateAccount(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created account %s (number %s) for %s\n", created.ID, accountNumber(*created), user.Email)
+	return nil
+}
+
+func balance(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("balance", flag.ContinueOnError)
+	asOf := fs.String("as-of", "", "RFC 3339 instant to compute the balance at (default now)")
+	positional, err := parseArgs(fs, args, "account-id")
+	if err != nil {
+		return err
+	}
+	accountID, err := parseAccountID(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	at := time.Now()
+	if *asOf != "" {
+		if at, err = time.Parse(time.RFC3339Nano, *asOf); err != nil {
+			return fmt.Errorf("invalid -as-of: %w", err)
+		}
+	}
+
+	db := database.New()
+	result, err := services.NewBalanceService(db.GetDB()).BalanceAsOf(ctx, accountID, at)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%.2f as of %s\n", result.Balance, result.AsOf.Format(time.RFC3339))
+	return nil
+}
+
+func transactions(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("transactions", flag.ContinueOnError)
+	limit := fs.Int("limit", services.DefaultPageSize, fmt.Sprintf("page size, at most %d", services.MaxPageSize))
+	cursor := fs.String("cursor", "", "cursor printed after the previous page")
+	query := fs.String("q", "", "only transactions whose ref, description, external reference, merchant name or metadata contain this text")
+	externalRef := fs.String("external-ref", "", "only transactions with this external reference")
+	categoryCode := fs.String("mcc", "", "only transactions with this merchant category code")
+	asJSON := fs.Bool("json", false, "print the page as JSON")
+	positional, err := parseArgs(fs, args, "account-id")
+	if err != nil {
+		return err
+	}
+	accountID, err := parseAccountID(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	db := database.New()
+	filter := repository.TransactionFilter{Query: *query, ExternalRef: *externalRef, MerchantCategoryCode: *categoryCode}
+	page, err := services.NewTransactionService(db.GetDB()).SearchTransactions(ctx, accountID, filter, *cursor, *limit)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		objects := make([]map[string]any, len(page.Transactions))
+		for i, t := range page.Transactions {
+			objects[i] = transactionObject(t)
+		}
+		return printJSON(map[string]any{"transactions": objects, "next_cursor": page.NextCursor})
+	}
+
+	w := newTable()
+	fmt.Fprintln(w, "CREATED\tTYPE\tAMOUNT\tREF\tDESCRIPTION\tMERCHANT")
+	for _, t := range page.Transactions {
+		fmt.Fprintf(w, "%s\t%s\t%.2f\t%s\t%s\t%s\n", t.CreatedAt.Format("2006-01-02 15:04:05"), t.TransactionType, t.Amount, t.Ref, t.Description, t.MerchantName)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if page.NextCursor != "" {
+		fmt.Printf("\nnext page: -cursor %s\n", page.NextCursor)
+	}
+	return nil
+}
+
+func freeze(ctx context.Context, args []string) error {
+	return changeStatus(ctx, "freeze", args, services.AccountService.Freeze)
+}
+
+func unfreeze(ctx context.Context, args []string) error {
+	return changeStatus(ctx, "unfreeze", args, services.AccountService.Unfreeze)
+}
+
+func changeStatus(ctx context.Context, name string, args []string, operation func(svc services.AccountService, ctx context.Context, accountID uuid.UUID, reason string) (*models.Account, error)) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	reason := fs.String("reason", "", "why the change is made, recorded in the audit log (required)")
+	positional, err := parseArgs(fs, args, "account-id")
+	if err != nil {
+		return err
+	}
+	if *reason == "" {
+		return errors.New("-reason is required")
+	}
+	accountID, err := parseAccountID(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	db := database.New()
+	updated, err := operation(services.NewAccountService(db.GetDB()).WithActor(operator()), ctx, accountID, *reason)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("account %s is now %s\n", updated.ID, updated.Status)
+	return nil
+}
+
+func adjust(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("adjust", flag.ContinueOnError)
+	amount := fs.Float64("amount", 0, "signed amount; positive credits, negative debits (required)")
+	reason := fs.String("reason", "", "reason code: goodwill, write_off, correction, fee_refund, chargeback or other (required)")
+	note := fs.String("note", "", "why the adjustment is made, recorded in the ledger and the audit log (required)")
+	positional, err := parseArgs(fs, args, "account-id")
+	if err != nil {
+		return err
+	}
+	if *amount == 0 || *reason == "" || *note == "" {
+		return errors.New("-amount, -reason and -note are required")
+	}
+	accountID, err := parseAccountID(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+
+	db := database.New()
+	adjustments, err := newAdjustmentService(db)
+	if err != nil {
+		return err
+	}
+	adjustment, err := adjustments.Request(ctx, accountID, *amount, models.AdjustmentReason(*reason), *note)
+	if err != nil {
+		return err
+	}
+
+	if adjustment.Status == models.AdjustmentPending {
+		fmt.Printf("adjustment %s of %.2f is pending approval by another operator\n", adjustment.ID, adjustment.Amount)
+		return nil
+	}
+	account, err := services.NewAccountService(db.GetDB()).GetAccountByID(ctx, accountID)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("posted adjustment %s of %.2f, balance is now %.2f\n", adjustment.ID, adjustment.Amount, account.Balance)
+	return nil
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"wallet/internal/database"
+	"wallet/internal/models"
+	"wallet/internal/services"
+
+	"github.com/google/uuid"
+)
+
+const adjustmentsUsage = "usage: wallet adjustments list [-status pending] [-json] | wallet adjustments approve <adjustment-id> [-note <note>] | wallet adjustments reject <adjustment-id> -note <note>"
+
+func adjustments(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New(adjustmentsUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		return listAdjustments(ctx, args[1:])
+	case "approve":
+		return reviewAdjustment(ctx, "approve", services.AdjustmentService.Approve, args[1:])
+	case "reject":
+		return reviewAdjustment(ctx, "reject", services.AdjustmentService.Reject, args[1:])
+	default:
+		return errors.New(adjustmentsUsage)
+	}
+}
+
+// newAdjustmentService returns the adjustment service acting as the
+// operator authenticated by WALLET_OPERATOR_TOKEN. Unlike other changes,
+// adjustments are not made as the OS user: telling the maker from the
+// checker needs the name the operator also has over the API.
+func newAdjustmentService(db database.Service) (services.AdjustmentService, error) {
+	actor, err := authenticatedOperator()
+	if err != nil {
+		return nil, err
+	}
+	return services.NewAdjustmentService(db.GetDB(), services.AdjustmentApprovalThresholdFromEnv()).WithActor(actor), nil
+}
+
+func listAdjustments(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("adjustments list", flag.ContinueOnError)
+	status := fs.String("status", string(models.AdjustmentPending), "pending, approved or rejected")
+	asJSON := fs.Bool("json", false, "print the adjustments as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	switch models.AdjustmentStatus(*status) {
+	case models.AdjustmentPending, models.AdjustmentApproved, models.AdjustmentRejected:
+	default:
+		return fmt.Errorf("unknown status %q", *status)
+	}
+
+	db := database.New()
+	list, err := services.NewAdjustmentService(db.GetDB(), services.AdjustmentApprovalThresholdFromEnv()).ListAdjustments(ctx, models.AdjustmentStatus(*status))
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(list)
+	}
+	w := newTable()
+	fmt.Fprintln(w, "ID\tACCOUNT\tAMOUNT\tREASON\tNOTE\tREQUESTED BY\tREVIEWED BY")
+	for _, a := range list {
+		fmt.Fprintf(w, "%s\t%s\t%.2f\t%s\t%s\t%s\t%s\n", a.ID, a.AccountID, a.Amount, a.ReasonCode, a.Note, a.RequestedBy, a.ReviewedBy)
+	}
+	return w.Flush()
+}
+
+func reviewAdjustment(ctx context.Context, name string, review func(services.AdjustmentService, context.Context, uuid.UUID, string) (*models.Adjustment, error), args []string) error {
+	fs := flag.NewFlagSet("adjustments "+name, flag.ContinueOnError)
+	note := fs.String("note", "", "review note, required to reject")
+	positional, err := parseArgs(fs, args, "adjustment-id")
+	if err != nil {
+		return err
+	}
+	adjustmentID, err := uuid.Parse(positional[0])
+	if err != nil {
+		return errors.New("invalid adjustment ID")
+	}
+
+	db := database.New()
+	adjustments, err := newAdjustmentService(db)
+	if err != nil {
+		return err
+	}
+	adjustment, err := review(adjustments, ctx, adjustmentID, *note)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("adjustment %s of %.2f is now %s\n", adjustment.ID, adjustment.Amount, adjustment.Status)
+	return nil
+}
@@ -0,0 +1,52 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"wallet/internal/database"
+	"wallet/internal/services"
+)
+
+var errAuditTampered = errors.New("audit log integrity check failed")
+
+func audit(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "verify" {
+		return errors.New("usage: wallet audit verify [-json]")
+	}
+
+	fs := flag.NewFlagSet("audit verify", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the result as JSON")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	db := database.New()
+	result, err := services.NewAuditService(db.GetDB()).Verify(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		if err := printJSON(result); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("verified %d audit entries\n", result.EntriesChecked)
+		if len(result.Problems) > 0 {
+			w := newTable()
+			fmt.Fprintln(w, "SEQUENCE\tPROBLEM\tDETAIL")
+			for _, p := range result.Problems {
+				fmt.Fprintf(w, "%d\t%s\t%s\n", p.Sequence, p.Kind, p.Detail)
+			}
+			w.Flush()
+		}
+	}
+
+	if !result.Intact() {
+		return errAuditTampered
+	}
+	return nil
+}
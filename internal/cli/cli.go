@@ -0,0 +1,150 @@
+// Package cli implements the wallet subcommands that run against the
+// configured database without starting the HTTP server.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"os/user"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+
+	"wallet/internal/database"
+	"wallet/internal/services"
+
+	"github.com/google/uuid"
+)
+
+// command is a single wallet subcommand.
+type command struct {
+	usage string
+	run   func(ctx context.Context, args []string) error
+}
+
+var commands = map[string]command{
+	"account":      {usage: "open an additional account for a user (account create)", run: account},
+	"adjust":       {usage: "make a manual credit or debit with a reason code and a note", run: adjust},
+	"adjustments":  {usage: "review the adjustments waiting for approval (adjustments list|approve|reject)", run: adjustments},
+	"audit":        {usage: "verify the audit log hash chain (audit verify)", run: audit},
+	"balance":      {usage: "show an account's balance, optionally at a point in time", run: balance},
+	"deposits":     {usage: "import bank reports and work the suspense queue (deposits import|list|assign)", run: deposits},
+	"export":       {usage: "export users, accounts or transactions as CSV or JSON", run: export},
+	"freeze":       {usage: "block top-ups and charges on an account", run: freeze},
+	"payouts":      {usage: "write payout files or import bank returns (payouts run|import)", run: payouts},
+	"projections":  {usage: "replay the event store into the accounts and transactions tables (projections rebuild)", run: projections},
+	"reconcile":    {usage: "recompute balances from transactions and report mismatches", run: reconcile},
+	"snapshot":     {usage: "record end-of-day balance snapshots for a day", run: snapshot},
+	"transactions": {usage: "list or search an account's transactions, newest first", run: transactions},
+	"unfreeze":     {usage: "lift a freeze from an account", run: unfreeze},
+	"user":         {usage: "create a user with an account, or show a user (user create|show)", run: userCommand},
+}
+
+// Run executes the subcommand named by args[0] and returns the process exit code.
+func Run(args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(os.Stdout)
+		return 0
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", args[0])
+		printUsage(os.Stderr)
+		return 2
+	}
+
+	// Interrupting the command cancels its queries
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if err := cmd.run(ctx, args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: wallet [command]")
+	fmt.Fprintln(w, "\nWithout a command the HTTP server is started.")
+	fmt.Fprintln(w, "\nCommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-14s %s\n", name, commands[name].usage)
+	}
+}
+
+// operator attributes CLI changes to the operator authenticated by
+// WALLET_OPERATOR_TOKEN, if any, or else to the OS user running the command.
+func operator() services.Actor {
+	if actor, err := authenticatedOperator(); err == nil {
+		return actor
+	}
+	name := "cli"
+	if u, err := user.Current(); err == nil {
+		name = "cli:" + u.Username
+	}
+	return services.Actor{Name: name}
+}
+
+// authenticatedOperator returns the operator from OPERATOR_TOKENS whose token
+// is in WALLET_OPERATOR_TOKEN, named as they are over the API.
+func authenticatedOperator() (services.Actor, error) {
+	token := os.Getenv("WALLET_OPERATOR_TOKEN")
+	if token == "" {
+		return services.Actor{}, errors.New("set WALLET_OPERATOR_TOKEN to your operator token")
+	}
+	name, ok := services.AuthenticateOperator(services.OperatorsFromEnv(), token)
+	if !ok {
+		return services.Actor{}, errors.New("unknown operator token in WALLET_OPERATOR_TOKEN")
+	}
+	return services.Actor{Name: name}, nil
+}
+
+// parseArgs parses the flags that follow the given leading positional
+// arguments and returns the positional values.
+func parseArgs(fs *flag.FlagSet, args []string, positional ...string) ([]string, error) {
+	n := len(positional)
+	if len(args) < n || (n > 0 && strings.HasPrefix(args[n-1], "-")) {
+		return nil, fmt.Errorf("usage: wallet %s <%s> [flags]", fs.Name(), strings.Join(positional, "> <"))
+	}
+	if err := fs.Parse(args[n:]); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return args[:n], nil
+}
+
+// parseAccountID accepts an account ID or an account number, which it looks
+// up once its check digit is verified.
+func parseAccountID(ctx context.Context, raw string) (uuid.UUID, error) {
+	return services.NewAccountService(database.New().GetDB()).ResolveAccountID(ctx, raw)
+}
+
+func newTable() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+}
+
+func printJSON(v any) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"path/filepath"
+	"strings"
+	"testing"
+	"wallet/internal/database"
+	"wallet/internal/services"
+)
+
+func TestAccountCommands(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("OPERATOR_TOKENS", "alice:alice-token")
+	t.Setenv("WALLET_OPERATOR_TOKEN", "alice-token")
+	path := filepath.Join(t.TempDir(), "wallet.db")
+	db, err := database.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	output, code := runCommand(t, path, "user create -email jane@example.com -first-name Jane -last-name Doe")
+	if code != 0 || !strings.Contains(output, "created user") {
+		t.Fatalf("user create: got exit code %d and output %q want 0", code, output)
+	}
+	if output, code := runCommand(t, path, "user create -email jane@example.com -first-name Jane -last-name Doe"); code != 1 {
+		t.Errorf("duplicate user create: got exit code %d and output %q want 1", code, output)
+	}
+	user, err := services.NewUserService(db.GetDB()).GetUserByEmail(ctx, "jane@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts, err := services.NewAccountService(db.GetDB()).GetAccountsByUserID(ctx, user.ID)
+	if err != nil || len(accounts) != 1 {
+		t.Fatalf("got accounts %v, %v want one", accounts, err)
+	}
+	id := accounts[0].ID.String()
+
+	tests := []struct {
+		command string
+		code    int
+		want    string
+	}{
+		{command: "account create -user jane@example.com", want: "created account"},
+		{command: "adjust " + id + " -amount 25 -reason goodwill -note welcome", want: "balance is now 25.00"},
+		{command: "adjust " + id + " -amount 25 -reason goodwill", code: 1, want: "-note are required"},
+		{command: "balance " + id, want: "25.00 as of"},
+		{command: "freeze " + id + " -reason fraud", want: "is now frozen"},
+		{command: "freeze " + id + " -reason fraud", code: 1},
+		// Adjustments are still allowed on frozen accounts
+		{command: "adjust " + id + " -amount -5 -reason correction -note typo", want: "balance is now 20.00"},
+		{command: "unfreeze " + id + " -reason cleared", want: "is now active"},
+		{command: "balance " + id, want: "20.00 as of"},
+		{command: "transactions " + id + " -json", want: `"transaction_type": "adjustment"`},
+		{command: "user show jane@example.com", want: id},
+		{command: "balance " + id + " -as-of yesterday", code: 1, want: "invalid -as-of"},
+		{command: "frobnicate", code: 2, want: `unknown command "frobnicate"`},
+	}
+	for _, tt := range tests {
+		output, code := runCommand(t, path, tt.command)
+		if code != tt.code || !strings.Contains(output, tt.want) {
+			t.Errorf("%s: got exit code %d and output %q want %d and %q", tt.command, code, output, tt.code, tt.want)
+		}
+	}
+
+	output, code = runCommand(t, path, "export -table accounts")
+	if code != 0 {
+		t.Fatalf("export: got exit code %d and output %q want 0", code, output)
+	}
+	records, err := csv.NewReader(strings.NewReader(output)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || records[0][0] != "id" {
+		t.Errorf("export: got records %v want a header and two accounts", records)
+	}
+}
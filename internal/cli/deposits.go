@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"wallet/internal/database"
+	"wallet/internal/models"
+	"wallet/internal/services"
+
+	"github.com/google/uuid"
+)
+
+const depositsUsage = "usage: wallet deposits import <file> [-json] | wallet deposits list [-status unmatched] [-json] | wallet deposits assign <deposit-id> <account-id>"
+
+func deposits(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New(depositsUsage)
+	}
+
+	switch args[0] {
+	case "import":
+		return importDeposits(ctx, args[1:])
+	case "list":
+		return listDeposits(ctx, args[1:])
+	case "assign":
+		return assignDeposit(ctx, args[1:])
+	default:
+		return errors.New(depositsUsage)
+	}
+}
+
+func importDeposits(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("deposits import", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	positional, err := parseArgs(fs, args, "file")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(positional[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	db := database.New()
+	report, err := services.NewDepositService(db.GetDB()).WithActor(operator()).Import(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		if err := printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("deposits: %d matched, %d unmatched, %d duplicate(s)\n", report.Matched, report.Unmatched, report.Duplicates)
+		if len(report.Errors) > 0 {
+			w := newTable()
+			fmt.Fprintln(w, "REFERENCE\tERROR")
+			for _, e := range report.Errors {
+				fmt.Fprintf(w, "%s\t%s\n", e.Reference, e.Error)
+			}
+			w.Flush()
+		}
+	}
+
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%d credit(s) could not be recorded", len(report.Errors))
+	}
+	return nil
+}
+
+func listDeposits(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("deposits list", flag.ContinueOnError)
+	status := fs.String("status", string(models.DepositUnmatched), "matched, unmatched or assigned")
+	asJSON := fs.Bool("json", false, "print the deposits as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+	switch models.DepositStatus(*status) {
+	case models.DepositMatched, models.DepositUnmatched, models.DepositAssigned:
+	default:
+		return fmt.Errorf("unknown status %q", *status)
+	}
+
+	db := database.New()
+	list, err := services.NewDepositService(db.GetDB()).ListDeposits(ctx, models.DepositStatus(*status))
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		return printJSON(list)
+	}
+	w := newTable()
+	fmt.Fprintln(w, "ID\tREFERENCE\tAMOUNT\tCREDITOR IBAN\tDEBTOR\tREMITTANCE\tREASON")
+	for _, d := range list {
+		fmt.Fprintf(w, "%s\t%s\t%.2f %s\t%s\t%s\t%s\t%s\n", d.ID, d.Reference, d.Amount, d.Currency, d.CreditorIBAN, d.DebtorName, d.Remittance, d.Reason)
+	}
+	return w.Flush()
+}
+
+func assignDeposit(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("deposits assign", flag.ContinueOnError)
+	positional, err := parseArgs(fs, args, "deposit-id", "account-id")
+	if err != nil {
+		return err
+	}
+	depositID, err := uuid.Parse(positional[0])
+	if err != nil {
+		return errors.New("invalid deposit ID")
+	}
+	accountID, err := parseAccountID(ctx, positional[1])
+	if err != nil {
+		return err
+	}
+
+	db := database.New()
+	deposit, err := services.NewDepositService(db.GetDB()).WithActor(operator()).Assign(ctx, depositID, accountID)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("assigned deposit %s (%.2f %s) to account %s\n", deposit.Reference, deposit.Amount, deposit.Currency, accountID)
+	return nil
+}
@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"wallet/internal/database"
+	"wallet/internal/services"
+)
+
+const payoutsUsage = "usage: wallet payouts run [-json] | wallet payouts import <file> [-json]"
+
+func payouts(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New(payoutsUsage)
+	}
+
+	switch args[0] {
+	case "run":
+		return runPayouts(ctx, args[1:])
+	case "import":
+		return importReturns(ctx, args[1:])
+	default:
+		return errors.New(payoutsUsage)
+	}
+}
+
+func runPayouts(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("payouts run", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the payout files as JSON")
+	if _, err := parseArgs(fs, args); err != nil {
+		return err
+	}
+
+	db := database.New()
+	withdrawals := services.NewWithdrawalService(db.GetDB(), services.PayoutConfigFromEnv()).WithActor(operator())
+	// Print the files that were written even if another format failed
+	written, runErr := withdrawals.RunPayouts(ctx)
+
+	if *asJSON {
+		if err := printJSON(written); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("wrote %d payout file(s)\n", len(written))
+		if len(written) > 0 {
+			w := newTable()
+			fmt.Fprintln(w, "FILE\tFORMAT\tMESSAGE ID\tWITHDRAWALS\tTOTAL")
+			for _, p := range written {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%.2f\n", p.FileName, p.Format, p.MessageID, p.Count, p.Total)
+			}
+			w.Flush()
+		}
+	}
+	return runErr
+}
+
+func importReturns(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("payouts import", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	positional, err := parseArgs(fs, args, "file")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(positional[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	db := database.New()
+	report, err := services.NewWithdrawalService(db.GetDB(), services.PayoutConfigFromEnv()).WithActor(operator()).ImportReturns(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		if err := printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("%s payouts: %d completed, %d returned, %d unchanged\n", report.Format, report.Completed, report.Returned, report.Unchanged)
+		if len(report.Errors) > 0 {
+			w := newTable()
+			fmt.Fprintln(w, "REFERENCE\tERROR")
+			for _, e := range report.Errors {
+				fmt.Fprintf(w, "%s\t%s\n", e.Reference, e.Error)
+			}
+			w.Flush()
+		}
+	}
+
+	if len(report.Errors) > 0 {
+		return fmt.Errorf("%d status(es) could not be applied", len(report.Errors))
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"wallet/internal/database"
+	"wallet/internal/services"
+)
+
+func projections(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] != "rebuild" {
+		return errors.New("usage: wallet projections rebuild")
+	}
+
+	db := database.New()
+	result, err := services.NewProjectionService(db.GetDB(), operator()).Rebuild(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("backfilled %d stream(s) and replayed %d event(s)\n", result.StreamsBackfilled, result.EventsReplayed)
+	return nil
+}
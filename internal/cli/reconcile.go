@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"wallet/internal/database"
+	"wallet/internal/services"
+)
+
+// errUnbalanced makes the command exit non-zero so it can gate cron jobs.
+var errUnbalanced = errors.New("ledger is out of balance")
+
+func reconcile(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("reconcile", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	db := database.New()
+	report, err := services.NewReconciliationService(db.GetDB()).Reconcile(ctx)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		if err := printJSON(report); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("checked %d account(s) in %s\n", report.AccountsChecked, report.FinishedAt.Sub(report.StartedAt))
+		if len(report.Mismatches) > 0 {
+			w := newTable()
+			fmt.Fprintln(w, "ACCOUNT\tSTORED\tCOMPUTED\tDIFFERENCE\tSTORED AVAILABLE\tCOMPUTED AVAILABLE\tTRANSACTIONS")
+			for _, m := range report.Mismatches {
+				fmt.Fprintf(w, "%s\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f\t%d\n", m.AccountID, m.StoredBalance, m.ComputedBalance, m.Difference, m.StoredAvailableBalance, m.ComputedAvailableBalance, m.TransactionCount)
+			}
+			w.Flush()
+		}
+		if len(report.UnfundedTopUps) > 0 {
+			fmt.Printf("%d top-up(s) posted although their payment failed\n", len(report.UnfundedTopUps))
+			w := newTable()
+			fmt.Fprintln(w, "TRANSACTION\tACCOUNT\tAMOUNT\tINTENT")
+			for _, u := range report.UnfundedTopUps {
+				fmt.Fprintf(w, "%s\t%s\t%.2f\t%s\n", u.TransactionID, u.AccountID, u.Amount, u.IntentID)
+			}
+			w.Flush()
+		}
+	}
+
+	if !report.Balanced() {
+		return errUnbalanced
+	}
+	return nil
+}
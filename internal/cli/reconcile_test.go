@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"wallet/internal/database"
+	"wallet/internal/models"
+	"wallet/internal/services"
+)
+
+// TestMain runs a wallet command instead of the tests when the test binary
+// is started by runCommand, as the database is opened from WALLET_DB_URL
+// when the process starts.
+func TestMain(m *testing.M) {
+	if os.Getenv("WALLET_TEST_COMMAND") != "" {
+		os.Exit(Run(strings.Fields(os.Getenv("WALLET_TEST_COMMAND"))))
+	}
+	os.Exit(m.Run())
+}
+
+// runCommand runs a wallet command against the database at path and
+// returns its output and exit code.
+func runCommand(t *testing.T, path, command string) (string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "WALLET_TEST_COMMAND="+command, "WALLET_DB_URL="+path)
+	output, err := cmd.CombinedOutput()
+	var exit *exec.ExitError
+	switch {
+	case errors.As(err, &exit):
+		return string(output), exit.ExitCode()
+	case err != nil:
+		t.Fatal(err)
+	}
+	return string(output), 0
+}
+
+func TestReconcileExitStatus(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "wallet.db")
+	db, err := database.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	accounts := services.NewAccountService(db.GetDB())
+	account, err := accounts.CreateAccountWithUser(ctx, "jane@example.com", "Jane", "Doe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := accounts.TopUp(ctx, account.ID, 100, models.TransactionDetails{}); err != nil {
+		t.Fatal(err)
+	}
+
+	output, code := runCommand(t, path, "reconcile")
+	if code != 0 || !strings.Contains(output, "checked 1 account(s)") {
+		t.Errorf("balanced books: got exit code %d and output %q want 0 and one account checked", code, output)
+	}
+
+	// Drift the projection behind the services' back
+	if err := db.GetDB().Exec("UPDATE accounts SET balance = 105 WHERE id = ?", account.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	output, code = runCommand(t, path, "reconcile")
+	if code != 1 || !strings.Contains(output, account.ID.String()) || !strings.Contains(output, errUnbalanced.Error()) {
+		t.Errorf("drifted books: got exit code %d and output %q want 1 and the account reported", code, output)
+	}
+	output, code = runCommand(t, path, "reconcile -json")
+	if code != 1 || !strings.Contains(output, `"difference": 5`) {
+		t.Errorf("drifted books as JSON: got exit code %d and output %q want 1 and a difference of 5", code, output)
+	}
+}
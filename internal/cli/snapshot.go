@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"wallet/internal/database"
+	"wallet/internal/services"
+)
+
+func snapshot(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	date := fs.String("date", "", "day to snapshot as YYYY-MM-DD (default yesterday)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	day := services.StartOfDay(time.Now()).AddDate(0, 0, -1)
+	if *date != "" {
+		parsed, err := time.ParseInLocation(time.DateOnly, *date, time.Local)
+		if err != nil {
+			return fmt.Errorf("invalid -date: %w", err)
+		}
+		day = parsed
+	}
+
+	db := database.New()
+	n, err := services.NewBalanceService(db.GetDB()).SnapshotDay(ctx, day)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("recorded %d balance snapshot(s) for %s\n", n, day.Format(time.DateOnly))
+	return nil
+}
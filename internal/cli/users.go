@@ -0,0 +1,106 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+
+	"wallet/internal/database"
+	"wallet/internal/models"
+	"wallet/internal/services"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func userCommand(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: wallet user create|show")
+	}
+	switch args[0] {
+	case "create":
+		return userCreate(ctx, args[1:])
+	case "show":
+		return userShow(ctx, args[1:])
+	}
+	return fmt.Errorf("unknown user command %q", args[0])
+}
+
+func userCreate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("user create", flag.ContinueOnError)
+	email := fs.String("email", "", "email address (required)")
+	firstName := fs.String("first-name", "", "first name (required)")
+	lastName := fs.String("last-name", "", "last name (required)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *email == "" || *firstName == "" || *lastName == "" {
+		return errors.New("-email, -first-name and -last-name are required")
+	}
+
+	db := database.New()
+	account, err := services.NewAccountService(db.GetDB()).WithActor(operator()).CreateAccountWithUser(ctx, *email, *firstName, *lastName)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("created user %s with account %s\n", account.UserID, account.ID)
+	return nil
+}
+
+func userShow(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("user show", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the user as JSON")
+	positional, err := parseArgs(fs, args, "user-id|email")
+	if err != nil {
+		return err
+	}
+
+	db := database.New()
+	user, err := findUser(ctx, db.GetDB(), positional[0])
+	if err != nil {
+		return err
+	}
+	accounts, err := services.NewAccountService(db.GetDB()).GetAccountsByUserID(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		objects := make([]map[string]any, len(accounts))
+		for i, a := range accounts {
+			objects[i] = accountObject(a)
+		}
+		result := userObject(*user)
+		result["accounts"] = objects
+		return printJSON(result)
+	}
+
+	fmt.Printf("%s %s <%s>\nid: %s\ncreated: %s\n\n", user.FirstName, user.LastName, user.Email, user.ID, user.CreatedAt.Format("2006-01-02 15:04:05"))
+	w := newTable()
+	fmt.Fprintln(w, "ACCOUNT\tNUMBER\tSTATUS\tBALANCE\tCREATED")
+	for _, a := range accounts {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%.2f\t%s\n", a.ID, accountNumber(a), a.Status, a.Balance, a.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+	return w.Flush()
+}
+
+// findUser looks a user up by ID, falling back to email.
+func findUser(ctx context.Context, db *gorm.DB, idOrEmail string) (*models.User, error) {
+	userService := services.NewUserService(db)
+
+	var (
+		user *models.User
+		err  error
+	)
+	if userID, parseErr := uuid.Parse(idOrEmail); parseErr == nil {
+		user, err = userService.GetUserByID(ctx, userID)
+	} else {
+		user, err = userService.GetUserByEmail(ctx, idOrEmail)
+	}
+	if errors.Is(err, services.ErrUserNotFound) {
+		return nil, fmt.Errorf("user %q not found", idOrEmail)
+	}
+	return user, err
+}
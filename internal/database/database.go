@@ -6,8 +6,12 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+	"wallet/internal/logging"
 	"wallet/internal/models"
+	"wallet/internal/repository"
+	"wallet/internal/tracing"
 
 	_ "github.com/joho/godotenv/autoload"
 	"gorm.io/driver/sqlite"
@@ -29,7 +33,8 @@ type Service interface {
 }
 
 type service struct {
-	db *gorm.DB
+	db   *gorm.DB
+	path string
 }
 
 var (
@@ -37,26 +42,115 @@ var (
 	dbInstance *service
 )
 
+// appendOnlyTriggers keep the audit log and the event store immutable.
+var appendOnlyTriggers = []string{
+	`CREATE TRIGGER IF NOT EXISTS audit_entries_no_update BEFORE UPDATE ON audit_entries
+	BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END`,
+	`CREATE TRIGGER IF NOT EXISTS audit_entries_no_delete BEFORE DELETE ON audit_entries
+	BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END`,
+	`CREATE TRIGGER IF NOT EXISTS events_no_update BEFORE UPDATE ON events
+	BEGIN SELECT RAISE(ABORT, 'event store is append-only'); END`,
+	`CREATE TRIGGER IF NOT EXISTS events_no_delete BEFORE DELETE ON events
+	BEGIN SELECT RAISE(ABORT, 'event store is append-only'); END`,
+}
+
 func New() Service {
 	// Reuse Connection
 	if dbInstance != nil {
 		return dbInstance
 	}
 
-	db, err := gorm.Open(sqlite.Open(dburl), &gorm.Config{})
+	db, err := open(dburl)
 	if err != nil {
-		log.Fatal("Failed to connect to the database:", err)
+		log.Fatal(err)
 	}
 
-	err = db.AutoMigrate(&models.User{}, &models.Account{}, &models.Transaction{})
+	dbInstance = db
+	return dbInstance
+}
+
+// Open connects to the SQLite database at path, creating it if needed, and
+// migrates it. Unlike New it always opens a new connection, e.g. to a
+// temporary database in tests.
+func Open(path string) (Service, error) {
+	db, err := open(path)
 	if err != nil {
-		log.Fatal("Failed to auto-migrate tables:", err)
+		return nil, err
 	}
+	return db, nil
+}
 
-	dbInstance = &service{
-		db: db,
+// dsn adds the connection options the wallet relies on to path. Transactions
+// begin IMMEDIATE, taking the write lock up front: a deferred transaction
+// that reads and then writes cannot wait for the lock once another writer
+// holds it, and fails with SQLITE_BUSY at once instead.
+func dsn(path string) string {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
 	}
-	return dbInstance
+	return fmt.Sprintf("%s%s_txlock=immediate&_busy_timeout=%d", path, separator, repository.BusyTimeout.Milliseconds())
+}
+
+func open(path string) (*service, error) {
+	db, err := gorm.Open(sqlite.Open(dsn(path)), &gorm.Config{Logger: logging.GormLogger{}, TranslateError: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the database: %w", err)
+	}
+
+	// Trace queries as children of the caller's span
+	if err := db.Use(tracing.GormPlugin{}); err != nil {
+		return nil, fmt.Errorf("failed to install the tracing plugin: %w", err)
+	}
+
+	// Columns added to existing tables need backfilling once they exist
+	migrator := db.Migrator()
+	backfills := []string{}
+	if migrator.HasTable(&models.Account{}) && !migrator.HasColumn(&models.Account{}, "AvailableBalance") {
+		// Accounts had no pending charges before
+		backfills = append(backfills, "UPDATE accounts SET available_balance = balance")
+	}
+	if migrator.HasTable(&models.Transaction{}) && !migrator.HasColumn(&models.Transaction{}, "PostedAt") {
+		// Transactions were posted when they were created
+		backfills = append(backfills, "UPDATE transactions SET posted_at = created_at")
+	}
+
+	// AutoMigrate only creates CHECK constraints that are missing, so the
+	// transaction type check from before adjustments had a type of their own
+	// is dropped for it to be created anew
+	if migrator.HasTable(&models.Transaction{}) {
+		var current int64
+		err := db.Raw("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = 'transactions' AND sql LIKE ?", "%'"+string(models.AdjustmentTransaction)+"'%").Scan(&current).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect the transactions table: %w", err)
+		}
+		if current == 0 {
+			if err := migrator.DropConstraint(&models.Transaction{}, "chk_transactions_transaction_type"); err != nil {
+				return nil, fmt.Errorf("failed to drop the transaction type check: %w", err)
+			}
+		}
+	}
+
+	err = db.AutoMigrate(&models.User{}, &models.Account{}, &models.Transaction{}, &models.BalanceSnapshot{}, &models.AuditEntry{}, &models.Event{}, &models.Batch{}, &models.BatchItem{}, &models.Payment{}, &models.Beneficiary{}, &models.Withdrawal{}, &models.Payout{}, &models.Deposit{}, &models.Adjustment{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to auto-migrate tables: %w", err)
+	}
+	for _, backfill := range backfills {
+		if err := db.Exec(backfill).Error; err != nil {
+			return nil, fmt.Errorf("failed to backfill new columns: %w", err)
+		}
+	}
+
+	// Reject in-place edits of the audit log and the event store; tampering
+	// with the audit log that bypasses the triggers is still caught by the
+	// hash chain
+	for _, trigger := range appendOnlyTriggers {
+		if err := db.Exec(trigger).Error; err != nil {
+			return nil, fmt.Errorf("failed to create append-only triggers: %w", err)
+		}
+	}
+
+	return &service{db: db, path: path}, nil
 }
 
 func (s *service) Health() map[string]string {
@@ -121,8 +215,12 @@ func (s *service) Health() map[string]string {
 // If the connection is successfully closed, it returns nil.
 // If an error occurs while closing the connection, it returns the error.
 func (s *service) Close() error {
-	log.Printf("Disconnected from database: %s", dburl)
-	return s.Close()
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	log.Printf("Disconnected from database: %s", s.path)
+	return sqlDB.Close()
 }
 
 // getDB returns the Gorm database instance.
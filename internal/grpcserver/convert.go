@@ -0,0 +1,71 @@
+package grpcserver
+
+import (
+	"encoding/json"
+
+	walletv1 "wallet/api/wallet/v1"
+	"wallet/internal/models"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func toAccount(a *models.Account) *walletv1.Account {
+	account := &walletv1.Account{
+		Id:               a.ID.String(),
+		Balance:          a.Balance,
+		AvailableBalance: a.AvailableBalance,
+		UserId:           a.UserID.String(),
+		Version:          int64(a.Version),
+		Status:           string(a.Status),
+		CreatedAt:        timestamppb.New(a.CreatedAt),
+		UpdatedAt:        timestamppb.New(a.UpdatedAt),
+	}
+	if a.User.ID != uuid.Nil {
+		account.User = &walletv1.User{
+			Id:        a.User.ID.String(),
+			Email:     a.User.Email,
+			FirstName: a.User.FirstName,
+			LastName:  a.User.LastName,
+		}
+	}
+	return account
+}
+
+func toTransaction(t *models.Transaction) *walletv1.Transaction {
+	transaction := &walletv1.Transaction{
+		Id:        t.ID.String(),
+		Type:      string(t.TransactionType),
+		Amount:    t.Amount,
+		Ref:       t.Ref,
+		Status:    string(t.Status),
+		AccountId: t.AccountID.String(),
+		CreatedAt: timestamppb.New(t.CreatedAt),
+		Details: &walletv1.TransactionDetails{
+			Description:          t.Description,
+			Metadata:             string(t.Metadata),
+			ExternalRef:          t.ExternalRef,
+			MerchantName:         t.MerchantName,
+			MerchantCategoryCode: t.MerchantCategoryCode,
+		},
+	}
+	if t.Account.ID != uuid.Nil {
+		transaction.Account = toAccount(&t.Account)
+	}
+	return transaction
+}
+
+// fromTransactionDetails returns the details of a top-up or charge request,
+// which are validated by the services.
+func fromTransactionDetails(d *walletv1.TransactionDetails) models.TransactionDetails {
+	details := models.TransactionDetails{
+		Description:          d.GetDescription(),
+		ExternalRef:          d.GetExternalRef(),
+		MerchantName:         d.GetMerchantName(),
+		MerchantCategoryCode: d.GetMerchantCategoryCode(),
+	}
+	if metadata := d.GetMetadata(); metadata != "" {
+		details.Metadata = json.RawMessage(metadata)
+	}
+	return details
+}
@@ -0,0 +1,61 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"wallet/internal/services"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// statusCodes maps the services' domain errors to gRPC status codes, following
+// the status codes the HTTP API uses for the same errors.
+var statusCodes = []struct {
+	err  error
+	code codes.Code
+}{
+	{services.ErrInvalidAccountID, codes.InvalidArgument},
+	{services.ErrAccountNotFound, codes.NotFound},
+	{services.ErrUserNotFound, codes.NotFound},
+	{services.ErrTransactionNotFound, codes.NotFound},
+	{services.ErrDuplicateUser, codes.AlreadyExists},
+	{services.ErrDuplicateTransaction, codes.AlreadyExists},
+	{services.ErrInsufficientFunds, codes.FailedPrecondition},
+	{services.ErrAccountNotOpen, codes.FailedPrecondition},
+	{services.ErrAccountFrozen, codes.FailedPrecondition},
+	{services.ErrAccountAlreadyFrozen, codes.FailedPrecondition},
+	{services.ErrAccountNotFrozen, codes.FailedPrecondition},
+	{services.ErrInvalidTransition, codes.FailedPrecondition},
+	{services.ErrPaymentDeclined, codes.FailedPrecondition},
+	{services.ErrPaymentProvider, codes.Unavailable},
+	{services.ErrConcurrentModification, codes.Aborted},
+	{context.DeadlineExceeded, codes.DeadlineExceeded},
+	{context.Canceled, codes.Canceled},
+}
+
+// toStatus maps service errors to gRPC statuses. Unexpected errors are
+// logged and reported without their details.
+func toStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	// Cursors are called page tokens in the gRPC API
+	if errors.Is(err, services.ErrInvalidCursor) {
+		return status.Error(codes.InvalidArgument, "invalid page token")
+	}
+	for _, sc := range statusCodes {
+		if errors.Is(err, sc.err) {
+			return status.Error(sc.code, err.Error())
+		}
+	}
+	var invalid *services.ValidationError
+	if errors.As(err, &invalid) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	slog.Error("grpc request failed", "error", err)
+	return status.Error(codes.Internal, "an unexpected error occurred")
+}
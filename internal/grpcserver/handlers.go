@@ -0,0 +1,181 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"time"
+
+	walletv1 "wallet/api/wallet/v1"
+	"wallet/internal/logging"
+	"wallet/internal/server/dto"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// requestActor identifies the caller for the audit log from the x-actor
+// metadata, falling back to the peer address.
+func requestActor(ctx context.Context) services.Actor {
+	actor := services.Actor{RequestID: logging.RequestID(ctx)}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("x-actor"); len(v) > 0 {
+			actor.Name = v[0]
+		}
+	}
+	if actor.Name == "" {
+		actor.Name = "grpc"
+		if p, ok := peer.FromContext(ctx); ok {
+			if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+				actor.Name = "grpc:" + host
+			}
+		}
+	}
+	return actor
+}
+
+// validate applies the same binding rules as the HTTP handlers.
+func validate(request any) error {
+	if err := binding.Validator.ValidateStruct(request); err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	return nil
+}
+
+// parseAccountID resolves an account_id field, which may also hold an
+// account number.
+func (s *Server) parseAccountID(ctx context.Context, id string) (uuid.UUID, error) {
+	accountID, err := s.AccountService.ResolveAccountID(ctx, id)
+	if err != nil {
+		return uuid.Nil, toStatus(err)
+	}
+	return accountID, nil
+}
+
+func (s *Server) CreateAccount(ctx context.Context, req *walletv1.CreateAccountRequest) (*walletv1.Account, error) {
+	request := dto.CreateAccountRequest{
+		Email:     req.GetEmail(),
+		FirstName: req.GetFirstName(),
+		LastName:  req.GetLastName(),
+	}
+	if err := validate(&request); err != nil {
+		return nil, err
+	}
+
+	account, err := s.AccountService.WithActor(requestActor(ctx)).CreateAccountWithUser(ctx, request.Email, request.FirstName, request.LastName)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toAccount(account), nil
+}
+
+func (s *Server) GetAccount(ctx context.Context, req *walletv1.GetAccountRequest) (*walletv1.Account, error) {
+	accountID, err := s.parseAccountID(ctx, req.GetAccountId())
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := s.AccountService.GetAccountByID(ctx, accountID)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toAccount(account), nil
+}
+
+func (s *Server) TopUp(ctx context.Context, req *walletv1.TopUpRequest) (*walletv1.Transaction, error) {
+	accountID, err := s.parseAccountID(ctx, req.GetAccountId())
+	if err != nil {
+		return nil, err
+	}
+	request := dto.TopUpRequest{Amount: req.GetAmount()}
+	if err := validate(&request); err != nil {
+		return nil, err
+	}
+
+	// The request has no payment method, so the provider's default is used
+	transaction, err := s.FundingService.WithActor(requestActor(ctx)).TopUp(ctx, accountID, request.Amount, "", fromTransactionDetails(req.GetDetails()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toTransaction(transaction), nil
+}
+
+func (s *Server) Charge(ctx context.Context, req *walletv1.ChargeRequest) (*walletv1.Transaction, error) {
+	accountID, err := s.parseAccountID(ctx, req.GetAccountId())
+	if err != nil {
+		return nil, err
+	}
+	request := dto.ChargeRequest{Amount: req.GetAmount()}
+	if err := validate(&request); err != nil {
+		return nil, err
+	}
+
+	transaction, err := s.AccountService.WithActor(requestActor(ctx)).Charge(ctx, accountID, request.Amount, fromTransactionDetails(req.GetDetails()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return toTransaction(transaction), nil
+}
+
+func (s *Server) ListTransactions(ctx context.Context, req *walletv1.ListTransactionsRequest) (*walletv1.ListTransactionsResponse, error) {
+	accountID, err := s.parseAccountID(ctx, req.GetAccountId())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetPageSize() < 0 {
+		return nil, status.Error(codes.InvalidArgument, "page_size must not be negative")
+	}
+
+	// Distinguish an unknown account from one without transactions
+	if _, err := s.AccountService.GetAccountByID(ctx, accountID); err != nil {
+		return nil, toStatus(err)
+	}
+
+	page, err := s.TransactionService.ListTransactions(ctx, accountID, req.GetPageToken(), int(req.GetPageSize()))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	response := &walletv1.ListTransactionsResponse{NextPageToken: page.NextCursor}
+	for i := range page.Transactions {
+		response.Transactions = append(response.Transactions, toTransaction(&page.Transactions[i]))
+	}
+	return response, nil
+}
+
+func (s *Server) WatchAccount(req *walletv1.WatchAccountRequest, stream grpc.ServerStreamingServer[walletv1.Account]) error {
+	accountID, err := s.parseAccountID(stream.Context(), req.GetAccountId())
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(s.watchInterval)
+	defer ticker.Stop()
+
+	lastVersion := -1
+	for {
+		account, err := s.AccountService.GetAccountByID(stream.Context(), accountID)
+		if err != nil {
+			return toStatus(err)
+		}
+
+		// Every change to the account bumps its stream version
+		if account.Version != lastVersion {
+			if err := stream.Send(toAccount(account)); err != nil {
+				return err
+			}
+			lastVersion = account.Version
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
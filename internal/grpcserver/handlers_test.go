@@ -0,0 +1,272 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	walletv1 "wallet/api/wallet/v1"
+	"wallet/internal/models"
+	"wallet/internal/payments"
+	"wallet/internal/repository"
+	"wallet/internal/services"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// newTestServer returns a server whose services keep their data in memory.
+func newTestServer() *Server {
+	store := repository.NewMemoryStore()
+	return &Server{
+		AccountService:     services.NewAccountServiceWithStore(store),
+		TransactionService: services.NewTransactionServiceWithStore(store),
+		FundingService:     services.NewFundingServiceWithStore(store, payments.NewFakeProvider("", nil)),
+		watchInterval:      10 * time.Millisecond,
+	}
+}
+
+// newTestClient serves s over an in-memory connection with the same
+// interceptors as NewServer.
+func newTestClient(t *testing.T, s *Server) walletv1.WalletServiceClient {
+	t.Helper()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(unaryInterceptor), grpc.ChainStreamInterceptor(streamInterceptor))
+	walletv1.RegisterWalletServiceServer(server, s)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return walletv1.NewWalletServiceClient(conn)
+}
+
+func TestStatusCodes(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t, newTestServer())
+	account, err := client.CreateAccount(ctx, &walletv1.CreateAccountRequest{Email: "jane@example.com", FirstName: "Jane", LastName: "Doe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.TopUp(ctx, &walletv1.TopUpRequest{AccountId: account.Id, Amount: 10}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{
+			name: "invalid email",
+			call: func() error {
+				_, err := client.CreateAccount(ctx, &walletv1.CreateAccountRequest{Email: "jane", FirstName: "Jane", LastName: "Doe"})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "duplicate user",
+			call: func() error {
+				_, err := client.CreateAccount(ctx, &walletv1.CreateAccountRequest{Email: "jane@example.com", FirstName: "Jane", LastName: "Doe"})
+				return err
+			},
+			want: codes.AlreadyExists,
+		},
+		{
+			name: "unknown account",
+			call: func() error {
+				_, err := client.GetAccount(ctx, &walletv1.GetAccountRequest{AccountId: uuid.NewString()})
+				return err
+			},
+			want: codes.NotFound,
+		},
+		{
+			name: "zero top-up",
+			call: func() error {
+				_, err := client.TopUp(ctx, &walletv1.TopUpRequest{AccountId: account.Id})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "insufficient funds",
+			call: func() error {
+				_, err := client.Charge(ctx, &walletv1.ChargeRequest{AccountId: account.Id, Amount: 20})
+				return err
+			},
+			want: codes.FailedPrecondition,
+		},
+		{
+			name: "negative page size",
+			call: func() error {
+				_, err := client.ListTransactions(ctx, &walletv1.ListTransactionsRequest{AccountId: account.Id, PageSize: -1})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "invalid page token",
+			call: func() error {
+				_, err := client.ListTransactions(ctx, &walletv1.ListTransactionsRequest{AccountId: account.Id, PageToken: "not-a-token"})
+				return err
+			},
+			want: codes.InvalidArgument,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(tt.call()); got != tt.want {
+				t.Errorf("got code %v want %v", got, tt.want)
+			}
+		})
+	}
+
+	var header metadata.MD
+	if _, err := client.GetAccount(metadata.AppendToOutgoingContext(ctx, "x-request-id", "req-42"), &walletv1.GetAccountRequest{AccountId: account.Id}, grpc.Header(&header)); err != nil {
+		t.Fatal(err)
+	}
+	if got := header.Get("x-request-id"); len(got) != 1 || got[0] != "req-42" {
+		t.Errorf("got request ID %v want req-42", got)
+	}
+}
+
+func TestWatchAccount(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := newTestClient(t, newTestServer())
+	account, err := client.CreateAccount(ctx, &walletv1.CreateAccountRequest{Email: "jane@example.com", FirstName: "Jane", LastName: "Doe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.WatchAccount(ctx, &walletv1.WatchAccountRequest{AccountId: account.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The current state comes first, then every change
+	got, err := stream.Recv()
+	if err != nil || got.GetBalance() != 0 {
+		t.Fatalf("initial state: got %v, %v want balance 0", got, err)
+	}
+	for _, want := range []float64{15, 40} {
+		if _, err := client.TopUp(ctx, &walletv1.TopUpRequest{AccountId: account.Id, Amount: want - got.GetBalance()}); err != nil {
+			t.Fatal(err)
+		}
+		got, err = stream.Recv()
+		if err != nil || got.GetBalance() != want {
+			t.Fatalf("after a top-up: got %v, %v want balance %v", got, err, want)
+		}
+	}
+
+	stream, err = client.WatchAccount(ctx, &walletv1.WatchAccountRequest{AccountId: uuid.NewString()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); status.Code(err) != codes.NotFound {
+		t.Errorf("unknown account: got error %v want %v", err, codes.NotFound)
+	}
+}
+
+func TestTransactionDetails(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	account, err := s.CreateAccount(ctx, &walletv1.CreateAccountRequest{Email: "jane@example.com", FirstName: "Jane", LastName: "Doe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.TopUp(ctx, &walletv1.TopUpRequest{AccountId: account.Id, Amount: 50, Details: &walletv1.TransactionDetails{Description: "Salary"}}); err != nil {
+		t.Fatal(err)
+	}
+	details := &walletv1.TransactionDetails{
+		Description:          "Weekly groceries",
+		Metadata:             `{"basket": 12}`,
+		ExternalRef:          "order-1042",
+		MerchantName:         "Corner Shop",
+		MerchantCategoryCode: "5411",
+	}
+	charge, err := s.Charge(ctx, &walletv1.ChargeRequest{AccountId: account.Id, Amount: 20, Details: details})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Metadata comes back compacted
+	want := proto.Clone(details).(*walletv1.TransactionDetails)
+	want.Metadata = `{"basket":12}`
+	if !proto.Equal(charge.GetDetails(), want) {
+		t.Errorf("charge details: got %v want %v", charge.GetDetails(), want)
+	}
+
+	page, err := s.ListTransactions(ctx, &walletv1.ListTransactionsRequest{AccountId: account.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := byType(page.Transactions)
+	if len(listed) != 2 || !proto.Equal(listed[string(models.Charge)].GetDetails(), want) || listed[string(models.TopUp)].GetDetails().GetDescription() != "Salary" {
+		t.Errorf("listed transactions: got %v want the charge and the top-up with their details", page.Transactions)
+	}
+
+	_, err = s.Charge(ctx, &walletv1.ChargeRequest{AccountId: account.Id, Amount: 1, Details: &walletv1.TransactionDetails{Metadata: "[1, 2]"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("charge with metadata that is not an object: got error %v want %v", err, codes.InvalidArgument)
+	}
+}
+
+func TestPendingTransactions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServer()
+	account, err := s.CreateAccount(ctx, &walletv1.CreateAccountRequest{Email: "jane@example.com", FirstName: "Jane", LastName: "Doe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	topUp, err := s.TopUp(ctx, &walletv1.TopUpRequest{AccountId: account.Id, Amount: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topUp.GetStatus() != string(models.TransactionPosted) {
+		t.Errorf("top-up: got status %q want %q", topUp.GetStatus(), models.TransactionPosted)
+	}
+
+	// A card authorisation made through another API
+	if _, err := s.AccountService.PendingCharge(ctx, uuid.MustParse(account.Id), 20, models.TransactionDetails{}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.GetAccount(ctx, &walletv1.GetAccountRequest{AccountId: account.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.GetBalance() != 50 || got.GetAvailableBalance() != 30 {
+		t.Errorf("account: got balance %v available %v want 50 available 30", got.GetBalance(), got.GetAvailableBalance())
+	}
+	page, err := s.ListTransactions(ctx, &walletv1.ListTransactionsRequest{AccountId: account.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := byType(page.Transactions)
+	if len(listed) != 2 || listed[string(models.Charge)].GetStatus() != string(models.TransactionPending) {
+		t.Errorf("listed transactions: got %v want a pending charge", page.Transactions)
+	}
+}
+
+// byType indexes transactions by their type. Transactions made within the
+// same millisecond are listed in no particular order.
+func byType(transactions []*walletv1.Transaction) map[string]*walletv1.Transaction {
+	indexed := map[string]*walletv1.Transaction{}
+	for _, transaction := range transactions {
+		indexed[transaction.GetType()] = transaction
+	}
+	return indexed
+}
@@ -0,0 +1,78 @@
+package grpcserver
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+
+	"wallet/internal/logging"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// requestIDMetadata is the metadata key request IDs are read from and echoed in.
+var requestIDMetadata = strings.ToLower(logging.RequestIDHeader)
+
+// withRequestID propagates the caller's x-request-id, or generates one, into
+// the context and the response headers.
+func withRequestID(ctx context.Context) context.Context {
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(requestIDMetadata); len(v) > 0 {
+			requestID = v[0]
+		}
+	}
+	if !logging.ValidRequestID(requestID) {
+		requestID = uuid.NewString()
+	}
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, requestID))
+	return logging.WithRequestID(ctx, requestID)
+}
+
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	level := slog.LevelInfo
+	switch code {
+	case codes.OK, codes.Canceled:
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	default:
+		level = slog.LevelWarn
+	}
+	slog.LogAttrs(ctx, level, "grpc request",
+		slog.String("method", method),
+		slog.String("code", code.String()),
+		slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+	)
+}
+
+func unaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	ctx = withRequestID(ctx)
+	resp, err := handler(ctx, req)
+	logCall(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+func streamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx := withRequestID(ss.Context())
+	err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	logCall(ctx, info.FullMethod, start, err)
+	return err
+}
+
+// contextStream overrides the context of a server stream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
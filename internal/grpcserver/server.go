@@ -0,0 +1,50 @@
+// Package grpcserver serves the WalletService gRPC API on top of the same
+// services as the HTTP server.
+package grpcserver
+
+import (
+	"time"
+
+	walletv1 "wallet/api/wallet/v1"
+	"wallet/internal/database"
+	"wallet/internal/payments"
+	"wallet/internal/services"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+type Server struct {
+	walletv1.UnimplementedWalletServiceServer
+
+	AccountService     services.AccountService
+	TransactionService services.TransactionService
+	FundingService     services.FundingService
+
+	// watchInterval is how often WatchAccount polls for changes.
+	watchInterval time.Duration
+}
+
+func NewServer() *grpc.Server {
+	db := database.New()
+
+	walletServer := &Server{
+		AccountService:     services.NewAccountService(db.GetDB()),
+		TransactionService: services.NewTransactionService(db.GetDB()),
+		FundingService:     services.NewFundingService(db.GetDB(), payments.New()),
+		watchInterval:      time.Second,
+	}
+
+	// Continue traces from the W3C trace context in the request metadata
+	server := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(unaryInterceptor),
+		grpc.ChainStreamInterceptor(streamInterceptor),
+	)
+	walletv1.RegisterWalletServiceServer(server, walletServer)
+	// Let tools such as grpcurl discover the API
+	reflection.Register(server)
+
+	return server
+}
@@ -0,0 +1,238 @@
+// Package inbound reads the bank transfers the wallet receives from the
+// ISO 20022 reports its bank sends: camt.053 statements, camt.052 intraday
+// reports and camt.054 credit notifications.
+package inbound
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallet/internal/banking"
+)
+
+// ErrInvalidReport is returned for a file that is not a camt.052, camt.053
+// or camt.054 report, or that has a credit the wallet cannot identify.
+var ErrInvalidReport = errors.New("invalid bank report")
+
+// Credit is a bank transfer received by the wallet.
+type Credit struct {
+	// Reference is the bank's unique reference of the credit.
+	Reference string
+	Amount    float64
+	Currency  string
+	// CreditorIBAN is the IBAN the transfer was sent to, which is the
+	// virtual IBAN of the account to credit.
+	CreditorIBAN string
+	DebtorName   string
+	DebtorIBAN   string
+	// Remittance is the unstructured remittance information, in which
+	// senders sometimes quote the virtual IBAN.
+	Remittance string
+	BookedAt   *time.Time
+}
+
+// maxReportBytes bounds the size of a report.
+const maxReportBytes = 32 << 20
+
+// The camt elements the wallet reads. Their names are the same in every
+// version; the few that moved are listed under both paths.
+type camtDocument struct {
+	Statements    []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+	Reports       []camtStatement `xml:"BkToCstmrAcctRpt>Rpt"`
+	Notifications []camtStatement `xml:"BkToCstmrDbtCdtNtfctn>Ntfctn"`
+}
+
+type camtStatement struct {
+	IBAN    string      `xml:"Acct>Id>IBAN"`
+	Entries []camtEntry `xml:"Ntry"`
+}
+
+type camtEntry struct {
+	Reference string     `xml:"NtryRef"`
+	Amount    camtAmount `xml:"Amt"`
+	Direction string     `xml:"CdtDbtInd"`
+	Reversal  bool       `xml:"RvslInd"`
+	Status    camtStatus `xml:"Sts"`
+	BookedOn  string     `xml:"BookgDt>Dt"`
+	BookedAt  string     `xml:"BookgDt>DtTm"`
+	BankRef   string     `xml:"AcctSvcrRef"`
+	Details   []struct {
+		BankRef         string     `xml:"Refs>AcctSvcrRef"`
+		EndToEndID      string     `xml:"Refs>EndToEndId"`
+		TransactionID   string     `xml:"Refs>TxId"`
+		Amount          camtAmount `xml:"Amt"`
+		InstructedAmt   camtAmount `xml:"AmtDtls>TxAmt>Amt"`
+		DebtorName      string     `xml:"RltdPties>Dbtr>Nm"`
+		DebtorPartyName string     `xml:"RltdPties>Dbtr>Pty>Nm"`
+		DebtorIBAN      string     `xml:"RltdPties>DbtrAcct>Id>IBAN"`
+		CreditorIBAN    string     `xml:"RltdPties>CdtrAcct>Id>IBAN"`
+		Remittance      []string   `xml:"RmtInf>Ustrd"`
+	} `xml:"NtryDtls>TxDtls"`
+}
+
+// camtStatus is the status of an entry, given as text before
+// camt.053.001.08 and as a code since.
+type camtStatus struct {
+	Code string `xml:"Cd"`
+	Text string `xml:",chardata"`
+}
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// ParseCamt reads the credits of a camt.052, camt.053 or camt.054 report.
+// Debits, reversals and entries the bank has not booked yet are left out.
+func ParseCamt(r io.Reader) ([]Credit, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxReportBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxReportBytes {
+		return nil, fmt.Errorf("%w: larger than %d bytes", ErrInvalidReport, maxReportBytes)
+	}
+
+	var doc camtDocument
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidReport, err)
+	}
+	statements := append(append(doc.Statements, doc.Reports...), doc.Notifications...)
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("%w: no statement, report or notification", ErrInvalidReport)
+	}
+
+	credits := []Credit{}
+	for _, st := range statements {
+		for _, e := range st.Entries {
+			if e.Direction != "CRDT" || e.Reversal {
+				continue
+			}
+			if status := strings.TrimSpace(e.Status.Code + e.Status.Text); status != "" && status != "BOOK" {
+				continue
+			}
+			entryCredits, err := entryCredits(st, e)
+			if err != nil {
+				return nil, err
+			}
+			credits = append(credits, entryCredits...)
+		}
+	}
+	return credits, nil
+}
+
+// entryCredits splits an entry into a credit per transaction. A batch
+// booking lists its transactions, each with its own amount.
+func entryCredits(st camtStatement, e camtEntry) ([]Credit, error) {
+	bookedAt, err := camtBookingDate(e)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(e.Details) == 0 {
+		amount, err := camtAmountValue(e.Amount)
+		if err != nil {
+			return nil, err
+		}
+		reference := firstOf(e.BankRef, e.Reference)
+		if reference == "" {
+			return nil, fmt.Errorf("%w: a credit has no reference", ErrInvalidReport)
+		}
+		return []Credit{{
+			Reference:    reference,
+			Amount:       amount,
+			Currency:     e.Amount.Currency,
+			CreditorIBAN: banking.NormalizeIBAN(st.IBAN),
+			BookedAt:     bookedAt,
+		}}, nil
+	}
+
+	credits := make([]Credit, len(e.Details))
+	for i, d := range e.Details {
+		amount := firstAmount(d.Amount, d.InstructedAmt)
+		if len(e.Details) == 1 && amount.Value == "" {
+			amount = e.Amount
+		}
+		value, err := camtAmountValue(amount)
+		if err != nil {
+			return nil, err
+		}
+
+		reference := d.BankRef
+		if reference == "" && e.BankRef != "" {
+			reference = e.BankRef
+			if len(e.Details) > 1 {
+				reference += "/" + strconv.Itoa(i+1)
+			}
+		}
+		if reference = firstOf(reference, d.TransactionID, d.EndToEndID); reference == "" {
+			return nil, fmt.Errorf("%w: a credit has no reference", ErrInvalidReport)
+		}
+
+		credits[i] = Credit{
+			Reference:    reference,
+			Amount:       value,
+			Currency:     amount.Currency,
+			CreditorIBAN: banking.NormalizeIBAN(firstOf(d.CreditorIBAN, st.IBAN)),
+			DebtorName:   strings.TrimSpace(firstOf(d.DebtorName, d.DebtorPartyName)),
+			DebtorIBAN:   banking.NormalizeIBAN(d.DebtorIBAN),
+			Remittance:   strings.TrimSpace(strings.Join(d.Remittance, " ")),
+			BookedAt:     bookedAt,
+		}
+	}
+	return credits, nil
+}
+
+func camtBookingDate(e camtEntry) (*time.Time, error) {
+	switch {
+	case e.BookedAt != "":
+		t, err := time.Parse(time.RFC3339, e.BookedAt)
+		if err != nil {
+			// Banks often leave out the offset
+			t, err = time.ParseInLocation("2006-01-02T15:04:05", e.BookedAt, time.Local)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: booking time %q", ErrInvalidReport, e.BookedAt)
+		}
+		return &t, nil
+	case e.BookedOn != "":
+		t, err := time.ParseInLocation(time.DateOnly, e.BookedOn, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("%w: booking date %q", ErrInvalidReport, e.BookedOn)
+		}
+		return &t, nil
+	}
+	return nil, nil
+}
+
+func camtAmountValue(amount camtAmount) (float64, error) {
+	value, err := strconv.ParseFloat(strings.TrimSpace(amount.Value), 64)
+	if err != nil || value <= 0 || amount.Currency == "" {
+		return 0, fmt.Errorf("%w: amount %q %s", ErrInvalidReport, amount.Value, amount.Currency)
+	}
+	return value, nil
+}
+
+func firstAmount(amounts ...camtAmount) camtAmount {
+	for _, a := range amounts {
+		if strings.TrimSpace(a.Value) != "" {
+			return a
+		}
+	}
+	return camtAmount{}
+}
+
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,116 @@
+package inbound
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCamt053(t *testing.T) {
+	statement := `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.08"><BkToCstmrStmt><Stmt>
+<Acct><Id><IBAN>DE89370400440532013000</IBAN></Id></Acct>
+<Ntry>
+  <Amt Ccy="EUR">100.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts><Cd>BOOK</Cd></Sts>
+  <BookgDt><Dt>2025-03-03</Dt></BookgDt><AcctSvcrRef>BANK-1</AcctSvcrRef>
+  <NtryDtls><TxDtls>
+    <Refs><EndToEndId>E2E-1</EndToEndId></Refs>
+    <RltdPties><Dbtr><Pty><Nm> Jane Doe </Nm></Pty></Dbtr><DbtrAcct><Id><IBAN>fr14 2004 1010 0505 0001 3m02 606</IBAN></Id></DbtrAcct>
+      <CdtrAcct><Id><IBAN>de02 1001 0010 0123 4567 89</IBAN></Id></CdtrAcct></RltdPties>
+    <RmtInf><Ustrd>Top-up</Ustrd><Ustrd>DE02100100100123456789</Ustrd></RmtInf>
+  </TxDtls></NtryDtls>
+</Ntry>
+<Ntry>
+  <Amt Ccy="EUR">75.50</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts>BOOK</Sts>
+  <BookgDt><DtTm>2025-03-04T10:30:00+01:00</DtTm></BookgDt><AcctSvcrRef>BANK-2</AcctSvcrRef>
+  <NtryDtls>
+    <TxDtls><Refs><TxId>TX-1</TxId></Refs><Amt Ccy="EUR">50.00</Amt><RltdPties><Dbtr><Nm>John Smith</Nm></Dbtr></RltdPties></TxDtls>
+    <TxDtls><Refs><AcctSvcrRef>BANK-2-B</AcctSvcrRef></Refs><AmtDtls><TxAmt><Amt Ccy="EUR">25.50</Amt></TxAmt></AmtDtls></TxDtls>
+  </NtryDtls>
+</Ntry>
+<Ntry><Amt Ccy="EUR">10.00</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts>BOOK</Sts><AcctSvcrRef>BANK-3</AcctSvcrRef></Ntry>
+<Ntry><Amt Ccy="EUR">10.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><RvslInd>true</RvslInd><Sts>BOOK</Sts><AcctSvcrRef>BANK-4</AcctSvcrRef></Ntry>
+<Ntry><Amt Ccy="EUR">10.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts><Cd>PDNG</Cd></Sts><AcctSvcrRef>BANK-5</AcctSvcrRef></Ntry>
+</Stmt></BkToCstmrStmt></Document>`
+
+	credits, err := ParseCamt(strings.NewReader(statement))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bookedOn := time.Date(2025, time.March, 3, 0, 0, 0, 0, time.Local)
+	bookedAt := time.Date(2025, time.March, 4, 10, 30, 0, 0, time.FixedZone("", 3600))
+	// Debits, reversals and pending entries are left out, and the
+	// transactions of a batch booking are credited one by one
+	want := []Credit{
+		{
+			Reference:    "BANK-1",
+			Amount:       100,
+			Currency:     "EUR",
+			CreditorIBAN: "DE02100100100123456789",
+			DebtorName:   "Jane Doe",
+			DebtorIBAN:   "FR1420041010050500013M02606",
+			Remittance:   "Top-up DE02100100100123456789",
+			BookedAt:     &bookedOn,
+		},
+		{Reference: "BANK-2/1", Amount: 50, Currency: "EUR", CreditorIBAN: "DE89370400440532013000", DebtorName: "John Smith", BookedAt: &bookedAt},
+		{Reference: "BANK-2-B", Amount: 25.5, Currency: "EUR", CreditorIBAN: "DE89370400440532013000", BookedAt: &bookedAt},
+	}
+	if len(credits) != len(want) {
+		t.Fatalf("got %d credits %+v want %d", len(credits), credits, len(want))
+	}
+	for i := range want {
+		got := credits[i]
+		if got.BookedAt == nil || !got.BookedAt.Equal(*want[i].BookedAt) {
+			t.Errorf("credit %d: got booked at %v want %v", i, got.BookedAt, *want[i].BookedAt)
+		}
+		got.BookedAt, want[i].BookedAt = nil, nil
+		if !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("credit %d: got %+v want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestParseCamtReportsAndNotifications(t *testing.T) {
+	for name, report := range map[string]string{
+		"camt.052": `<Document><BkToCstmrAcctRpt><Rpt><Acct><Id><IBAN>DE89370400440532013000</IBAN></Id></Acct>
+<Ntry><NtryRef>REF-1</NtryRef><Amt Ccy="EUR">12.34</Amt><CdtDbtInd>CRDT</CdtDbtInd><BookgDt><DtTm>2025-03-04T10:30:00</DtTm></BookgDt></Ntry>
+</Rpt></BkToCstmrAcctRpt></Document>`,
+		"camt.054": `<Document><BkToCstmrDbtCdtNtfctn><Ntfctn><Acct><Id><IBAN>DE89370400440532013000</IBAN></Id></Acct>
+<Ntry><NtryRef>REF-1</NtryRef><Amt Ccy="EUR">12.34</Amt><CdtDbtInd>CRDT</CdtDbtInd><BookgDt><DtTm>2025-03-04T10:30:00</DtTm></BookgDt></Ntry>
+</Ntfctn></BkToCstmrDbtCdtNtfctn></Document>`,
+	} {
+		credits, err := ParseCamt(strings.NewReader(report))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		// Booking times without an offset are local
+		bookedAt := time.Date(2025, time.March, 4, 10, 30, 0, 0, time.Local)
+		if len(credits) != 1 || credits[0].Reference != "REF-1" || credits[0].Amount != 12.34 || credits[0].CreditorIBAN != "DE89370400440532013000" ||
+			credits[0].BookedAt == nil || !credits[0].BookedAt.Equal(bookedAt) {
+			t.Errorf("%s: got credits %+v want 12.34 EUR referenced REF-1 booked at %v", name, credits, bookedAt)
+		}
+	}
+}
+
+func TestParseCamtInvalid(t *testing.T) {
+	entry := func(ntry string) string {
+		return `<Document><BkToCstmrStmt><Stmt><Acct><Id><IBAN>DE89370400440532013000</IBAN></Id></Acct>` + ntry + `</Stmt></BkToCstmrStmt></Document>`
+	}
+	for name, report := range map[string]string{
+		"empty":        "",
+		"not XML":      "reference,amount\n",
+		"no statement": `<Document><BkToCstmrStmt></BkToCstmrStmt></Document>`,
+		"no reference": entry(`<Ntry><Amt Ccy="EUR">10.00</Amt><CdtDbtInd>CRDT</CdtDbtInd></Ntry>`),
+		"amount":       entry(`<Ntry><NtryRef>REF-1</NtryRef><Amt Ccy="EUR">-10.00</Amt><CdtDbtInd>CRDT</CdtDbtInd></Ntry>`),
+		"currency":     entry(`<Ntry><NtryRef>REF-1</NtryRef><Amt>10.00</Amt><CdtDbtInd>CRDT</CdtDbtInd></Ntry>`),
+		"booking date": entry(`<Ntry><NtryRef>REF-1</NtryRef><Amt Ccy="EUR">10.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><BookgDt><Dt>03/03/2025</Dt></BookgDt></Ntry>`),
+		"size":         "<Document>" + strings.Repeat(" ", maxReportBytes),
+	} {
+		if _, err := ParseCamt(strings.NewReader(report)); !errors.Is(err, ErrInvalidReport) {
+			t.Errorf("%s: got error %v want %v", name, err, ErrInvalidReport)
+		}
+	}
+}
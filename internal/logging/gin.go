@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader is the header request IDs are read from and echoed in.
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDMiddleware propagates the caller's X-Request-ID, or generates one,
+// into the request context and the response headers.
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		if !ValidRequestID(requestID) {
+			requestID = uuid.NewString()
+		}
+		c.Request = c.Request.WithContext(WithRequestID(c.Request.Context(), requestID))
+		c.Header(RequestIDHeader, requestID)
+		c.Next()
+	}
+}
+
+// ValidRequestID accepts caller supplied IDs of up to 128 visible ASCII
+// characters, so they are safe to log and echo back.
+func ValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > 128 {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] <= ' ' || requestID[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// AccessLogMiddleware logs one record per request, in place of gin's
+// plain-text logger.
+func AccessLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", c.Request.Method),
+			slog.String("route", c.FullPath()),
+			slog.String("path", c.Request.URL.Path),
+			slog.Int("status", status),
+			slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.String("client_ip", c.ClientIP()),
+			slog.Int("bytes", c.Writer.Size()),
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		slog.LogAttrs(c.Request.Context(), level, "http request", attrs...)
+	}
+}
@@ -0,0 +1,51 @@
+package logging
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestIDMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestIDMiddleware())
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, RequestID(c.Request.Context()))
+	})
+
+	tests := []struct {
+		name      string
+		requestID string
+		generated bool
+	}{
+		{name: "propagated", requestID: "req-42"},
+		{name: "missing", generated: true},
+		{name: "with spaces", requestID: "req 42", generated: true},
+		{name: "too long", requestID: strings.Repeat("a", 129), generated: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.requestID != "" {
+				req.Header.Set(RequestIDHeader, tt.requestID)
+			}
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			got := rr.Header().Get(RequestIDHeader)
+			if got != rr.Body.String() {
+				t.Errorf("got header %q and context %q want the same ID", got, rr.Body.String())
+			}
+			switch {
+			case !tt.generated && got != tt.requestID:
+				t.Errorf("got request ID %q want %q", got, tt.requestID)
+			case tt.generated && (got == tt.requestID || !ValidRequestID(got)):
+				t.Errorf("got request ID %q want a generated one", got)
+			}
+		})
+	}
+}
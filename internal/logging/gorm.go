@@ -0,0 +1,67 @@
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// slowQueryThreshold is the duration above which queries are logged as warnings.
+const slowQueryThreshold = 200 * time.Millisecond
+
+// GormLogger sends gorm's logs to slog. Failed and slow queries are logged
+// at warn/error level, every query at debug level. Statements are logged
+// without their bound values so no personal data reaches the logs.
+type GormLogger struct{}
+
+func (l GormLogger) LogMode(gormlogger.LogLevel) gormlogger.Interface {
+	return l
+}
+
+func (GormLogger) Info(ctx context.Context, msg string, args ...any) {
+	slog.InfoContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (GormLogger) Warn(ctx context.Context, msg string, args ...any) {
+	slog.WarnContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (GormLogger) Error(ctx context.Context, msg string, args ...any) {
+	slog.ErrorContext(ctx, fmt.Sprintf(msg, args...))
+}
+
+func (GormLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+
+	level := slog.LevelDebug
+	switch {
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		level = slog.LevelError
+	case elapsed > slowQueryThreshold:
+		level = slog.LevelWarn
+	}
+	if !slog.Default().Enabled(ctx, level) {
+		return
+	}
+
+	sql, rows := fc()
+	attrs := []slog.Attr{
+		slog.String("sql", sql),
+		slog.Int64("rows", rows),
+		slog.Float64("duration_ms", float64(elapsed.Microseconds())/1000),
+	}
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	slog.LogAttrs(ctx, level, "database query", attrs...)
+}
+
+// ParamsFilter drops the bound values from the statements passed to Trace.
+func (GormLogger) ParamsFilter(_ context.Context, sql string, _ ...any) (string, []any) {
+	return sql, nil
+}
@@ -0,0 +1,129 @@
+// Package logging configures structured JSON logging with log/slog.
+//
+// Records are enriched with the request ID and trace context found in the
+// context they are logged with, and personal data is redacted: attributes
+// named after personal fields are masked and email addresses are masked
+// wherever they appear in a message or string value.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"regexp"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID carried by ctx, if any.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+// Setup makes a JSON logger writing to w the default for both log/slog and
+// the standard log package. The level is read from LOG_LEVEL (debug, info,
+// warn or error; default info).
+func Setup(w io.Writer) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(os.Getenv("LOG_LEVEL"))); err != nil {
+		level = slog.LevelInfo
+	}
+	slog.SetDefault(slog.New(NewHandler(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))))
+}
+
+// NewHandler wraps next so that records get the request ID and trace context
+// of their context and are redacted before they are written.
+func NewHandler(next slog.Handler) slog.Handler {
+	return &handler{next: next}
+}
+
+type handler struct {
+	next slog.Handler
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, redactString(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(a))
+		return true
+	})
+
+	if requestID := RequestID(ctx); requestID != "" {
+		redacted.AddAttrs(slog.String("request_id", requestID))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		redacted.AddAttrs(slog.String("trace_id", sc.TraceID().String()), slog.String("span_id", sc.SpanID().String()))
+	}
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+	return &handler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	return &handler{next: h.next.WithGroup(name)}
+}
+
+// personalKeys are attribute keys whose values are always masked.
+var personalKeys = map[string]bool{
+	"email":      true,
+	"first_name": true,
+	"last_name":  true,
+	"name":       true,
+	"full_name":  true,
+}
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+
+func redactAttr(a slog.Attr) slog.Attr {
+	value := a.Value.Resolve()
+	switch {
+	case value.Kind() == slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for i, member := range group {
+			redacted[i] = redactAttr(member)
+		}
+		return slog.Group(a.Key, redacted...)
+	case personalKeys[strings.ToLower(a.Key)]:
+		if value.Kind() == slog.KindString && emailPattern.MatchString(value.String()) {
+			return slog.String(a.Key, redactString(value.String()))
+		}
+		return slog.String(a.Key, "[REDACTED]")
+	case value.Kind() == slog.KindString:
+		return slog.String(a.Key, redactString(value.String()))
+	case value.Kind() == slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return slog.String(a.Key, redactString(err.Error()))
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: value}
+}
+
+// redactString masks the local part of every email address in s, keeping
+// its first character and the domain, e.g. j***@example.com.
+func redactString(s string) string {
+	return emailPattern.ReplaceAllStringFunc(s, func(email string) string {
+		at := strings.LastIndexByte(email, '@')
+		return email[:1] + "***" + email[at:]
+	})
+}
@@ -0,0 +1,157 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTestLogger returns a logger writing JSON records without their time to
+// the returned buffer.
+func newTestLogger() (*slog.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	next := slog.NewJSONHandler(&buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	return slog.New(NewHandler(next)), &buf
+}
+
+// profile logs as a group, the way a model with personal data would.
+type profile struct {
+	Email string
+	Name  string
+}
+
+func (p profile) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("email", p.Email), slog.String("name", p.Name))
+}
+
+func TestHandlerRedacts(t *testing.T) {
+	tests := []struct {
+		name string
+		log  func(logger *slog.Logger)
+		want map[string]any
+	}{
+		{
+			name: "message and top-level attributes",
+			log: func(logger *slog.Logger) {
+				logger.Info("welcome jane.doe@example.com",
+					"email", "jane.doe@example.com",
+					"first_name", "Jane",
+					"account_id", "acc-1",
+					"amount", 12.5,
+					"error", errors.New("user jane.doe@example.com already exists"),
+				)
+			},
+			want: map[string]any{
+				"level":      "INFO",
+				"msg":        "welcome j***@example.com",
+				"email":      "j***@example.com",
+				"first_name": "[REDACTED]",
+				"account_id": "acc-1",
+				"amount":     12.5,
+				"error":      "user j***@example.com already exists",
+			},
+		},
+		{
+			name: "nested groups",
+			log: func(logger *slog.Logger) {
+				logger.Info("signup",
+					slog.Group("user",
+						slog.String("Last_Name", "Doe"),
+						slog.Group("contact", slog.String("email", "jane@example.com"), slog.String("note", "cc john@example.org")),
+					),
+					slog.Any("owner", profile{Email: "jane@example.com", Name: "Jane Doe"}),
+				)
+			},
+			want: map[string]any{
+				"level": "INFO",
+				"msg":   "signup",
+				"user": map[string]any{
+					"Last_Name": "[REDACTED]",
+					"contact": map[string]any{
+						"email": "j***@example.com",
+						"note":  "cc j***@example.org",
+					},
+				},
+				"owner": map[string]any{
+					"email": "j***@example.com",
+					"name":  "[REDACTED]",
+				},
+			},
+		},
+		{
+			name: "attributes and groups added to the logger",
+			log: func(logger *slog.Logger) {
+				logger.With("name", "Jane Doe").
+					WithGroup("request").
+					With("email", "jane@example.com").
+					WithGroup("payer").
+					Info("charge", "full_name", "Jane Doe", "amount", 5)
+			},
+			want: map[string]any{
+				"level": "INFO",
+				"msg":   "charge",
+				"name":  "[REDACTED]",
+				"request": map[string]any{
+					"email": "j***@example.com",
+					"payer": map[string]any{
+						"full_name": "[REDACTED]",
+						"amount":    float64(5),
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger, buf := newTestLogger()
+			tt.log(logger)
+
+			var got map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+				t.Fatalf("got output %q: %v", buf, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerAddsContext(t *testing.T) {
+	logger, buf := newTestLogger()
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})
+	ctx := trace.ContextWithSpanContext(WithRequestID(context.Background(), "req-1"), sc)
+
+	logger.InfoContext(ctx, "charge")
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("got output %q: %v", buf, err)
+	}
+	want := map[string]any{
+		"level":      "INFO",
+		"msg":        "charge",
+		"request_id": "req-1",
+		"trace_id":   sc.TraceID().String(),
+		"span_id":    sc.SpanID().String(),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,157 @@
+// Package metrics defines the Prometheus metrics exposed on /metrics.
+package metrics
+
+import (
+	"database/sql"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+const namespace = "wallet"
+
+// Funds operations.
+const (
+	OperationTopUp      = "top_up"
+	OperationCharge     = "charge"
+	OperationAdjustment = "adjustment"
+)
+
+// Funds operation outcomes.
+const (
+	OutcomeSuccess             = "success"
+	OutcomeInsufficientBalance = "insufficient_balance"
+	OutcomeAccountFrozen       = "account_frozen"
+	OutcomeAccountNotFound     = "account_not_found"
+	OutcomeInvalid             = "invalid"
+	OutcomeConflict            = "conflict"
+	OutcomeTimeout             = "timeout"
+	OutcomeCanceled            = "canceled"
+	OutcomeError               = "error"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	fundsOperations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "funds_operations_total",
+		Help:      "Top-ups, charges and adjustments by outcome.",
+	}, []string{"operation", "outcome"})
+
+	fundsAmount = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "funds_amount_total",
+		Help:      "Sum of the requested amounts of top-ups, charges and adjustments by outcome.",
+	}, []string{"operation", "outcome"})
+)
+
+// Middleware records the count and latency of every request. Requests that
+// match no route are grouped under "unmatched" to bound the label values.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		httpRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// ObserveFunds records the outcome of a funds operation and its amount.
+// Amounts are recorded as absolute values so debits and credits both add up.
+func ObserveFunds(operation, outcome string, amount float64) {
+	if amount < 0 {
+		amount = -amount
+	}
+	fundsOperations.WithLabelValues(operation, outcome).Inc()
+	fundsAmount.WithLabelValues(operation, outcome).Add(amount)
+}
+
+// RegisterDatabase exposes the connection pool statistics of db and the
+// ledger gauges computed from its tables.
+func RegisterDatabase(db *gorm.DB) {
+	sqlDB, err := db.DB()
+	if err != nil {
+		slog.Error("database metrics disabled", "error", err)
+		return
+	}
+	prometheus.MustRegister(
+		collectors.NewDBStatsCollector(sqlDB, namespace),
+		newLedgerCollector(db),
+	)
+}
+
+// ledgerCollector reports business gauges, queried on every scrape.
+type ledgerCollector struct {
+	db          *gorm.DB
+	liabilities *prometheus.Desc
+	accounts    *prometheus.Desc
+	scrapeError *prometheus.Desc
+}
+
+func newLedgerCollector(db *gorm.DB) prometheus.Collector {
+	return &ledgerCollector{
+		db: db,
+		liabilities: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "liabilities"),
+			"Total balance held in all accounts, owed to their users.", nil, nil),
+		accounts: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "accounts"),
+			"Number of accounts by status.", []string{"status"}, nil),
+		scrapeError: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "ledger_scrape_error"),
+			"1 if the ledger gauges could not be queried on the last scrape.", nil, nil),
+	}
+}
+
+func (c *ledgerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.liabilities
+	ch <- c.accounts
+	ch <- c.scrapeError
+}
+
+func (c *ledgerCollector) Collect(ch chan<- prometheus.Metric) {
+	var rows []struct {
+		Status  string
+		Count   int64
+		Balance sql.NullFloat64
+	}
+	err := c.db.Table("accounts").
+		Select("status, COUNT(*) AS count, SUM(balance) AS balance").
+		Where("deleted_at IS NULL").
+		Group("status").
+		Scan(&rows).Error
+	if err != nil {
+		slog.Error("ledger metrics query failed", "error", err)
+		ch <- prometheus.MustNewConstMetric(c.scrapeError, prometheus.GaugeValue, 1)
+		return
+	}
+
+	var liabilities float64
+	for _, row := range rows {
+		liabilities += row.Balance.Float64
+		ch <- prometheus.MustNewConstMetric(c.accounts, prometheus.GaugeValue, float64(row.Count), row.Status)
+	}
+	ch <- prometheus.MustNewConstMetric(c.liabilities, prometheus.GaugeValue, liabilities)
+	ch <- prometheus.MustNewConstMetric(c.scrapeError, prometheus.GaugeValue, 0)
+}
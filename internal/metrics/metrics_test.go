@@ -0,0 +1,103 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"wallet/internal/database"
+	"wallet/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware())
+	r.GET("/accounts/:id", func(c *gin.Context) {
+		c.Status(http.StatusNotFound)
+	})
+
+	for _, path := range []string{"/accounts/1", "/accounts/2", "/nowhere"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	// Requests are counted by route, not by path
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/accounts/:id", "404")); got != 2 {
+		t.Errorf("requests to the route: got %v want 2", got)
+	}
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "unmatched", "404")); got != 1 {
+		t.Errorf("unmatched requests: got %v want 1", got)
+	}
+	if got := testutil.CollectAndCount(httpDuration); got != 2 {
+		t.Errorf("latency histograms: got %v want 2", got)
+	}
+}
+
+func TestObserveFunds(t *testing.T) {
+	ObserveFunds(OperationAdjustment, OutcomeSuccess, 25)
+	ObserveFunds(OperationAdjustment, OutcomeSuccess, -10)
+	ObserveFunds(OperationAdjustment, OutcomeInsufficientBalance, -50)
+
+	if got := testutil.ToFloat64(fundsOperations.WithLabelValues(OperationAdjustment, OutcomeSuccess)); got != 2 {
+		t.Errorf("successful adjustments: got %v want 2", got)
+	}
+	// Debits add up as well as credits
+	if got := testutil.ToFloat64(fundsAmount.WithLabelValues(OperationAdjustment, OutcomeSuccess)); got != 35 {
+		t.Errorf("successful adjustment amounts: got %v want 35", got)
+	}
+	if got := testutil.ToFloat64(fundsAmount.WithLabelValues(OperationAdjustment, OutcomeInsufficientBalance)); got != 50 {
+		t.Errorf("rejected adjustment amounts: got %v want 50", got)
+	}
+}
+
+func TestLedgerCollector(t *testing.T) {
+	db, err := database.Open(filepath.Join(t.TempDir(), "wallet.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	user := &models.User{Email: "jane@example.com", FirstName: "Jane", LastName: "Doe"}
+	if err := db.GetDB().Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, account := range []*models.Account{
+		{UserID: user.ID, Balance: 100.5},
+		{UserID: user.ID, Balance: 20},
+		{UserID: user.ID, Balance: 9.5, Status: models.AccountFrozen},
+	} {
+		if err := db.GetDB().Create(account).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := `
+# HELP wallet_accounts Number of accounts by status.
+# TYPE wallet_accounts gauge
+wallet_accounts{status="active"} 2
+wallet_accounts{status="frozen"} 1
+# HELP wallet_ledger_scrape_error 1 if the ledger gauges could not be queried on the last scrape.
+# TYPE wallet_ledger_scrape_error gauge
+wallet_ledger_scrape_error 0
+# HELP wallet_liabilities Total balance held in all accounts, owed to their users.
+# TYPE wallet_liabilities gauge
+wallet_liabilities 130
+`
+	if err := testutil.CollectAndCompare(newLedgerCollector(db.GetDB()), strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+
+	// A failed query is reported rather than dropping the gauges silently
+	db.Close()
+	want = `
+# HELP wallet_ledger_scrape_error 1 if the ledger gauges could not be queried on the last scrape.
+# TYPE wallet_ledger_scrape_error gauge
+wallet_ledger_scrape_error 1
+`
+	if err := testutil.CollectAndCompare(newLedgerCollector(db.GetDB()), strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
@@ -7,21 +7,53 @@ import (
 	"gorm.io/gorm"
 )
 
-// Account represents a user account.
+// AccountStatus is the operational state of an account.
+type AccountStatus string
+
+const (
+	AccountActive AccountStatus = "active"
+	AccountFrozen AccountStatus = "frozen"
+)
+
+// Account represents a user account. It is a projection of the account's
+// event stream; Version is the last event applied to the row.
+//
+// Balance is the posted balance, the sum of the transactions that have been
+// posted, in which a reversed transaction and its reversal cancel out.
+// AvailableBalance is what can be spent: the posted balance less the
+// pending charges held against it. Pending top-ups count towards neither
+// until they are posted.
 type Account struct {
-	ID        uuid.UUID `gorm:"type:TEXT;primaryKey"`
-	Balance   float64   `gorm:"type:decimal(10,2);not null;default:0.00"`
-	UserID    uuid.UUID `gorm:"type:uuid;not null"`
-	User      User      `gorm:"foreignKey:UserID"`
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	DeletedAt gorm.DeletedAt `gorm:"index"`
+	ID               uuid.UUID     `gorm:"type:TEXT;primaryKey"`
+	Balance          float64       `gorm:"type:decimal(10,2);not null;default:0.00"`
+	AvailableBalance float64       `gorm:"type:decimal(10,2);not null;default:0.00"`
+	Version          int           `gorm:"not null;default:0"`
+	Status           AccountStatus `gorm:"type:varchar(10);not null;default:'active'"`
+	UserID           uuid.UUID     `gorm:"type:uuid;not null"`
+	User             User          `gorm:"foreignKey:UserID"`
+	// Number is the account number people quote instead of the ID: ten
+	// digits, the last of which is a check digit.
+	Number *string `gorm:"uniqueIndex"`
+	// VirtualIBAN is the IBAN bank transfers to the account are sent to.
+	VirtualIBAN *string `gorm:"uniqueIndex"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
 }
 
-// BeforeCreate hook to generate UUID before saving to the database
+// BeforeCreate hook to generate UUID before saving to the database.
+// Accounts projected from their event stream keep the stream's ID and
+// opening time.
 func (a *Account) BeforeCreate(tx *gorm.DB) (err error) {
-	a.ID = uuid.New()
-	a.CreatedAt = time.Now()
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	if a.CreatedAt.IsZero() {
+		a.CreatedAt = time.Now()
+	}
+	if a.Status == "" {
+		a.Status = AccountActive
+	}
 	a.UpdatedAt = time.Now()
 	return
 }
@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AdjustmentReason classifies why a balance is corrected by hand.
+type AdjustmentReason string
+
+const (
+	AdjustmentGoodwill   AdjustmentReason = "goodwill"
+	AdjustmentWriteOff   AdjustmentReason = "write_off"
+	AdjustmentCorrection AdjustmentReason = "correction"
+	AdjustmentFeeRefund  AdjustmentReason = "fee_refund"
+	AdjustmentChargeback AdjustmentReason = "chargeback"
+	AdjustmentOther      AdjustmentReason = "other"
+)
+
+// AdjustmentReasons lists the reason codes an adjustment can be made for.
+var AdjustmentReasons = []AdjustmentReason{
+	AdjustmentGoodwill, AdjustmentWriteOff, AdjustmentCorrection, AdjustmentFeeRefund, AdjustmentChargeback, AdjustmentOther,
+}
+
+type AdjustmentStatus string
+
+const (
+	// AdjustmentPending adjustments wait for a second operator to approve
+	// them.
+	AdjustmentPending AdjustmentStatus = "pending"
+	// AdjustmentApproved adjustments have been posted to the account.
+	AdjustmentApproved AdjustmentStatus = "approved"
+	// AdjustmentRejected adjustments were turned down and never touched the
+	// balance.
+	AdjustmentRejected AdjustmentStatus = "rejected"
+)
+
+// Adjustment is a manual credit (positive Amount) or debit (negative
+// Amount) made by an operator. Once approved it is posted to the account by
+// the transaction TransactionID.
+type Adjustment struct {
+	ID         uuid.UUID        `gorm:"type:TEXT;primaryKey"`
+	AccountID  uuid.UUID        `gorm:"type:uuid;not null;index"`
+	Amount     float64          `gorm:"type:decimal(10,2);not null"`
+	ReasonCode AdjustmentReason `gorm:"type:varchar(20);not null;check:reason_code IN ('goodwill', 'write_off', 'correction', 'fee_refund', 'chargeback', 'other')"`
+	Note       string           `gorm:"not null"`
+	Status     AdjustmentStatus `gorm:"type:varchar(10);not null;index;check:status IN ('pending', 'approved', 'rejected')"`
+	// RequestedBy is the operator who made the adjustment and ReviewedBy the
+	// one who approved or rejected it. Adjustments approved without review
+	// have no reviewer.
+	RequestedBy   string `gorm:"not null"`
+	ReviewedBy    string
+	ReviewNote    string
+	ReviewedAt    *time.Time
+	TransactionID *uuid.UUID `gorm:"type:uuid;unique"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// BeforeCreate generates a new UUID for the ID field.
+func (a *Adjustment) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	a.CreatedAt = time.Now()
+	a.UpdatedAt = time.Now()
+	return nil
+}
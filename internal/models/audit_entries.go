@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// AuditEntry is a single record of the append-only audit log. Each entry
+// carries the hash of its predecessor, so editing, removing or reordering
+// rows breaks the chain. The hashes of keyed entries are HMACs, which cannot
+// be recomputed without the key; entries recorded before a key was
+// configured are plain SHA-256 hashes.
+type AuditEntry struct {
+	Sequence   uint64 `gorm:"primaryKey;autoIncrement:false"`
+	Action     string `gorm:"not null;index"`
+	Actor      string `gorm:"not null"`
+	RequestID  string
+	EntityType string `gorm:"not null"`
+	EntityID   string `gorm:"not null;index"`
+	Before     string `gorm:"type:text;not null"`
+	After      string `gorm:"type:text;not null"`
+	PrevHash   string `gorm:"not null"`
+	Hash       string `gorm:"not null;unique"`
+	Keyed      bool   `gorm:"not null;default:false"`
+	CreatedAt  time.Time
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BalanceSnapshot records an account's balance at the end of a day, so
+// point-in-time balances only need to replay the transactions after it.
+type BalanceSnapshot struct {
+	ID        uuid.UUID `gorm:"type:TEXT;primaryKey"`
+	AccountID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_balance_snapshots_account_closing"`
+	Account   Account   `gorm:"foreignKey:AccountID"`
+	// ClosingAt is the exclusive end of the day: the snapshot includes every
+	// transaction created strictly before it.
+	ClosingAt time.Time `gorm:"not null;uniqueIndex:idx_balance_snapshots_account_closing"`
+	Balance   float64   `gorm:"type:decimal(10,2);not null"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+// BeforeCreate generates a new UUID for the ID field.
+func (b *BalanceSnapshot) BeforeCreate(tx *gorm.DB) error {
+	b.ID = uuid.New()
+	b.CreatedAt = time.Now()
+	b.UpdatedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BatchMode decides what happens to a batch when one of its items fails.
+type BatchMode string
+
+const (
+	// BatchAtomic applies every item or, if one fails, none of them.
+	BatchAtomic BatchMode = "atomic"
+	// BatchBestEffort applies the items independently of each other.
+	BatchBestEffort BatchMode = "best_effort"
+)
+
+type BatchStatus string
+
+const (
+	BatchPending    BatchStatus = "pending"
+	BatchProcessing BatchStatus = "processing"
+	// BatchCompleted batches have processed every item, though in best
+	// effort mode some of them may have failed.
+	BatchCompleted BatchStatus = "completed"
+	// BatchFailed batches are atomic batches that were rolled back.
+	BatchFailed BatchStatus = "failed"
+)
+
+type BatchItemStatus string
+
+const (
+	BatchItemPending   BatchItemStatus = "pending"
+	BatchItemSucceeded BatchItemStatus = "succeeded"
+	BatchItemFailed    BatchItemStatus = "failed"
+	// BatchItemSkipped items were rolled back or never attempted because
+	// another item of their atomic batch failed.
+	BatchItemSkipped BatchItemStatus = "skipped"
+)
+
+// Batch is a list of top-ups and charges submitted together and processed
+// in the background. The actor who submitted it is recorded so its
+// transactions are audited on their behalf.
+type Batch struct {
+	ID          uuid.UUID   `gorm:"type:TEXT;primaryKey"`
+	Mode        BatchMode   `gorm:"type:varchar(16);not null;check:mode IN ('atomic', 'best_effort')"`
+	Status      BatchStatus `gorm:"type:varchar(16);not null;index"`
+	Actor       string      `gorm:"not null"`
+	RequestID   string
+	Items       []BatchItem `gorm:"foreignKey:BatchID"`
+	CompletedAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+// BeforeCreate generates a new UUID for the ID field.
+func (b *Batch) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == uuid.Nil {
+		b.ID = uuid.New()
+	}
+	b.CreatedAt = time.Now()
+	b.UpdatedAt = time.Now()
+	return nil
+}
+
+// BatchItem is a single top-up or charge of a batch. References are unique
+// across all batches, so a resubmitted item is never applied twice.
+type BatchItem struct {
+	ID             uuid.UUID       `gorm:"type:TEXT;primaryKey"`
+	BatchID        uuid.UUID       `gorm:"type:uuid;not null;index"`
+	Position       int             `gorm:"not null"`
+	AccountID      uuid.UUID       `gorm:"type:uuid;not null"`
+	Operation      TransactionType `gorm:"type:varchar(10);not null;check:operation IN ('top-up', 'charge')"`
+	Amount         float64         `gorm:"type:decimal(10,2);not null"`
+	Reference      string          `gorm:"not null;unique"`
+	Status         BatchItemStatus `gorm:"type:varchar(16);not null"`
+	TransactionRef string
+	Error          string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	DeletedAt      gorm.DeletedAt `gorm:"index"`
+}
+
+// BeforeCreate generates a new UUID for the ID field.
+func (i *BatchItem) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == uuid.Nil {
+		i.ID = uuid.New()
+	}
+	i.CreatedAt = time.Now()
+	i.UpdatedAt = time.Now()
+	return nil
+}
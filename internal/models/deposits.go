@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type DepositStatus string
+
+const (
+	// DepositMatched deposits were credited to the account whose virtual
+	// IBAN they were sent to.
+	DepositMatched DepositStatus = "matched"
+	// DepositUnmatched deposits wait in the suspense queue for an operator
+	// to assign them to an account.
+	DepositUnmatched DepositStatus = "unmatched"
+	// DepositAssigned deposits were credited to the account an operator
+	// assigned them to.
+	DepositAssigned DepositStatus = "assigned"
+)
+
+// Deposit is a bank transfer received by the wallet. Matched and assigned
+// deposits are credited to their account by the top-up TransactionID.
+type Deposit struct {
+	ID uuid.UUID `gorm:"type:TEXT;primaryKey"`
+	// Reference is the bank's reference of the credit, which makes ingesting
+	// the same credit twice fail.
+	Reference string  `gorm:"not null;unique"`
+	Amount    float64 `gorm:"type:decimal(10,2);not null"`
+	Currency  string  `gorm:"type:varchar(3);not null"`
+	// CreditorIBAN is the IBAN the transfer was sent to.
+	CreditorIBAN  string
+	DebtorName    string
+	DebtorIBAN    string
+	Remittance    string
+	BookedAt      *time.Time
+	Status        DepositStatus `gorm:"type:varchar(10);not null;index;check:status IN ('matched', 'unmatched', 'assigned')"`
+	AccountID     *uuid.UUID    `gorm:"type:uuid;index"`
+	TransactionID *uuid.UUID    `gorm:"type:uuid;unique"`
+	// Reason tells why an unmatched deposit could not be credited.
+	Reason    string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// BeforeCreate generates a new UUID for the ID field.
+func (d *Deposit) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	d.CreatedAt = time.Now()
+	d.UpdatedAt = time.Now()
+	return nil
+}
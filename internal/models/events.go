@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event is an immutable entry in an aggregate's event stream. Position orders
+// events globally so projections can be replayed from scratch, and Version
+// orders them within their stream.
+type Event struct {
+	Position   uint64    `gorm:"primaryKey;autoIncrement"`
+	StreamID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_events_stream_version"`
+	Version    int       `gorm:"not null;uniqueIndex:idx_events_stream_version"`
+	Type       string    `gorm:"not null;index"`
+	Data       string    `gorm:"type:text;not null"`
+	OccurredAt time.Time `gorm:"not null"`
+}
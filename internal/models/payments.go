@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PaymentStatus string
+
+const (
+	// PaymentProcessing payments wait for the provider to tell whether the
+	// money was collected.
+	PaymentProcessing PaymentStatus = "processing"
+	PaymentSucceeded  PaymentStatus = "succeeded"
+	PaymentFailed     PaymentStatus = "failed"
+	// PaymentRefundPending payments have had their top-up reversed but not
+	// yet their money returned by the provider.
+	PaymentRefundPending PaymentStatus = "refund_pending"
+	PaymentRefunded      PaymentStatus = "refunded"
+)
+
+// Payment links a top-up to the payment intent that funds it at a payment
+// provider.
+type Payment struct {
+	ID       uuid.UUID `gorm:"type:TEXT;primaryKey"`
+	Provider string    `gorm:"not null;uniqueIndex:idx_payments_provider_intent"`
+	IntentID string    `gorm:"not null;uniqueIndex:idx_payments_provider_intent"`
+	// TransactionID is the pending top-up the payment settles.
+	TransactionID uuid.UUID     `gorm:"type:uuid;not null;unique"`
+	AccountID     uuid.UUID     `gorm:"type:uuid;not null;index"`
+	Amount        float64       `gorm:"type:decimal(10,2);not null"`
+	Status        PaymentStatus `gorm:"type:varchar(16);not null;check:status IN ('processing', 'succeeded', 'failed', 'refund_pending', 'refunded')"`
+	FailureReason string
+	RefundID      string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// BeforeCreate generates a new UUID for the ID field.
+func (p *Payment) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	p.CreatedAt = time.Now()
+	p.UpdatedAt = time.Now()
+	return nil
+}
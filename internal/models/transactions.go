@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,25 +15,84 @@ type TransactionType string
 const (
 	TopUp  TransactionType = "top-up"
 	Charge TransactionType = "charge"
+	// AdjustmentTransaction is a manual credit or debit posted by a finance
+	// operator. Its amount is signed: debits are negative.
+	AdjustmentTransaction TransactionType = "adjustment"
 )
 
+// TransactionStatus is the settlement state of a transaction. Pending
+// transactions become posted or failed; posted ones can later be reversed.
+type TransactionStatus string
+
+const (
+	TransactionPending  TransactionStatus = "pending"
+	TransactionPosted   TransactionStatus = "posted"
+	TransactionFailed   TransactionStatus = "failed"
+	TransactionReversed TransactionStatus = "reversed"
+)
+
+// TransactionDetails describe what a top-up or charge was for, as told by
+// the client that made it. All of them are optional.
+type TransactionDetails struct {
+	Description string `json:"description,omitempty"`
+	// Metadata is a JSON object of the client's own keys and values.
+	Metadata json.RawMessage `json:"metadata,omitempty" gorm:"type:text"`
+	// ExternalRef is the client's own reference, such as an order ID. Unlike
+	// Ref it need not be unique.
+	ExternalRef  string `json:"external_ref,omitempty" gorm:"index"`
+	MerchantName string `json:"merchant_name,omitempty"`
+	// MerchantCategoryCode is the four-digit ISO 18245 code of the
+	// merchant's line of business, e.g. 5411 for grocery stores.
+	MerchantCategoryCode string `json:"merchant_category_code,omitempty" gorm:"type:varchar(4);index"`
+}
+
 // Transaction represents a account transactions.
 type Transaction struct {
-	ID              uuid.UUID       `gorm:"type:TEXT;primaryKey"`
-	TransactionType TransactionType `gorm:"type:varchar(10);not null;check:transaction_type IN ('top-up', 'charge')"`
-	Amount          float64         `gorm:"type:decimal(10,2);not null"`
-	Ref             string          `gorm:"not null;unique"`
-	AccountID       uuid.UUID       `gorm:"type:uuid;not null"`
-	Account         Account         `gorm:"foreignKey:AccountID"`
-	CreatedAt       time.Time
-	UpdatedAt       time.Time
-	DeletedAt       gorm.DeletedAt `gorm:"index"`
+	ID              uuid.UUID         `gorm:"type:TEXT;primaryKey"`
+	TransactionType TransactionType   `gorm:"type:varchar(10);not null;check:transaction_type IN ('top-up', 'charge', 'adjustment')"`
+	Amount          float64           `gorm:"type:decimal(10,2);not null"`
+	Ref             string            `gorm:"not null;unique"`
+	Status          TransactionStatus `gorm:"type:varchar(10);not null;default:'posted';index;check:status IN ('pending', 'posted', 'failed', 'reversed')"`
+	// PostedAt is when the transaction reached the posted balance. It stays
+	// set once the transaction is reversed, as the reversal is a transaction
+	// of its own, and is nil for pending and failed transactions.
+	PostedAt *time.Time
+	// ReversalOfID is the transaction this one reverses.
+	ReversalOfID *uuid.UUID `gorm:"type:uuid"`
+	AccountID    uuid.UUID  `gorm:"type:uuid;not null"`
+	Account      Account    `gorm:"foreignKey:AccountID"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	DeletedAt    gorm.DeletedAt `gorm:"index"`
+
+	TransactionDetails
 }
 
-// BeforeCreate generates a new UUID for the ID field.
+// BeforeCreate generates a new UUID for the ID field, unless the
+// transaction is projected from an event that already carries one.
 func (t *Transaction) BeforeCreate(tx *gorm.DB) error {
-	t.ID = uuid.New()
-	t.CreatedAt = time.Now()
+	if t.ID == uuid.Nil {
+		t.ID = uuid.New()
+	}
+	if t.CreatedAt.IsZero() {
+		t.CreatedAt = time.Now()
+	}
+	if t.Status == "" {
+		t.Status = TransactionPosted
+	}
+	if t.Status == TransactionPosted && t.PostedAt == nil {
+		postedAt := t.CreatedAt
+		t.PostedAt = &postedAt
+	}
 	t.UpdatedAt = time.Now()
 	return nil
 }
+
+// SignedAmount returns the change the transaction makes to the balance once
+// posted: positive for credits and negative for debits.
+func (t *Transaction) SignedAmount() float64 {
+	if t.TransactionType == Charge {
+		return -t.Amount
+	}
+	return t.Amount
+}
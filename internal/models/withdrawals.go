@@ -0,0 +1,142 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PayoutFormat is the bank file format withdrawals are paid out with.
+type PayoutFormat string
+
+const (
+	// PayoutPain001 files are SEPA credit transfer initiations,
+	// pain.001.001.03, paying out to IBANs.
+	PayoutPain001 PayoutFormat = "pain.001"
+	// PayoutNACHA files are ACH credit entries paying out to US routing and
+	// account numbers.
+	PayoutNACHA PayoutFormat = "nacha"
+)
+
+// Beneficiary is a bank account an account holder withdraws to. It is
+// reached through SEPA when it has an IBAN and through ACH otherwise.
+type Beneficiary struct {
+	ID        uuid.UUID `gorm:"type:TEXT;primaryKey"`
+	AccountID uuid.UUID `gorm:"type:uuid;not null;index"`
+	Name      string    `gorm:"not null"`
+	IBAN      string
+	BIC       string
+	// RoutingNumber and AccountNumber identify an ACH beneficiary, whose
+	// account is a checking account unless Savings is set.
+	RoutingNumber string
+	AccountNumber string
+	Savings       bool `gorm:"not null;default:false"`
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	DeletedAt     gorm.DeletedAt `gorm:"index"`
+}
+
+// BeforeCreate generates a new UUID for the ID field.
+func (b *Beneficiary) BeforeCreate(tx *gorm.DB) error {
+	if b.ID == uuid.Nil {
+		b.ID = uuid.New()
+	}
+	b.CreatedAt = time.Now()
+	b.UpdatedAt = time.Now()
+	return nil
+}
+
+// PayoutFormat returns the format the beneficiary is paid out with.
+func (b *Beneficiary) PayoutFormat() PayoutFormat {
+	if b.IBAN != "" {
+		return PayoutPain001
+	}
+	return PayoutNACHA
+}
+
+type WithdrawalStatus string
+
+const (
+	// WithdrawalRequested withdrawals hold their amount until the next
+	// payout file is written.
+	WithdrawalRequested WithdrawalStatus = "requested"
+	// WithdrawalSubmitted withdrawals are in a payout file and wait for the
+	// bank to confirm or return them.
+	WithdrawalSubmitted WithdrawalStatus = "submitted"
+	WithdrawalCompleted WithdrawalStatus = "completed"
+	// WithdrawalReturned withdrawals were rejected or returned by the bank,
+	// and their amount given back to the account.
+	WithdrawalReturned WithdrawalStatus = "returned"
+	// WithdrawalFailed withdrawals lost their hold, e.g. as it expired,
+	// before they were paid out, and never will be.
+	WithdrawalFailed WithdrawalStatus = "failed"
+)
+
+// Withdrawal moves money from an account to a beneficiary. Its amount is
+// held by a pending charge, which is posted once the bank confirms the
+// transfer and failed or reversed if it is returned.
+type Withdrawal struct {
+	ID            uuid.UUID `gorm:"type:TEXT;primaryKey"`
+	AccountID     uuid.UUID `gorm:"type:uuid;not null;index"`
+	BeneficiaryID uuid.UUID `gorm:"type:uuid;not null"`
+	// TransactionID is the charge holding the amount.
+	TransactionID uuid.UUID        `gorm:"type:uuid;not null;unique"`
+	Amount        float64          `gorm:"type:decimal(10,2);not null"`
+	Status        WithdrawalStatus `gorm:"type:varchar(10);not null;index;check:status IN ('requested', 'submitted', 'completed', 'returned', 'failed')"`
+	// EndToEndID identifies the transfer in pain.001 files and the bank's
+	// status reports.
+	EndToEndID string `gorm:"not null;unique"`
+	// TraceNumber identifies the entry in NACHA files and their returns. It
+	// is assigned when an ACH withdrawal is paid out.
+	TraceNumber *string    `gorm:"unique"`
+	PayoutID    *uuid.UUID `gorm:"type:uuid;index"`
+	// ReturnCode and ReturnReason are the bank's reason for returning the
+	// withdrawal, or why it failed.
+	ReturnCode   string
+	ReturnReason string
+	CompletedAt  *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// BeforeCreate generates a new UUID for the ID field and the end-to-end ID
+// derived from it, which fits the 35 characters SEPA allows.
+func (w *Withdrawal) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == uuid.Nil {
+		w.ID = uuid.New()
+	}
+	if w.EndToEndID == "" {
+		w.EndToEndID = "WD" + strings.ToUpper(strings.ReplaceAll(w.ID.String(), "-", ""))
+	}
+	if w.Status == "" {
+		w.Status = WithdrawalRequested
+	}
+	w.CreatedAt = time.Now()
+	w.UpdatedAt = time.Now()
+	return nil
+}
+
+// Payout is a payout file written to the outbox for the bank.
+type Payout struct {
+	ID     uuid.UUID    `gorm:"type:TEXT;primaryKey"`
+	Format PayoutFormat `gorm:"type:varchar(10);not null;check:format IN ('pain.001', 'nacha')"`
+	// MessageID identifies the file to the bank.
+	MessageID string  `gorm:"not null;unique"`
+	FileName  string  `gorm:"not null"`
+	Count     int     `gorm:"not null"`
+	Total     float64 `gorm:"type:decimal(12,2);not null"`
+	CreatedAt time.Time
+}
+
+// BeforeCreate generates a new UUID for the ID field.
+func (p *Payout) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == uuid.Nil {
+		p.ID = uuid.New()
+	}
+	if p.CreatedAt.IsZero() {
+		p.CreatedAt = time.Now()
+	}
+	return nil
+}
@@ -0,0 +1,265 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const FakeProviderName = "fake"
+
+// Payment methods of the fake provider. Delayed payments stay processing
+// for the provider's Delay, after which their outcome is sent as a webhook.
+const (
+	FakeCard                = "fake_card"
+	FakeCardDeclined        = "fake_card_declined"
+	FakeCardDelayed         = "fake_card_delayed"
+	FakeCardDelayedDeclined = "fake_card_delayed_declined"
+)
+
+// FakeSignatureHeader carries the signature of the fake provider's webhooks:
+// "t=<unix time>,v1=<hex HMAC-SHA256 of the time, a dot and the body>".
+const FakeSignatureHeader = "Fake-Signature"
+
+// fakeWebhookTolerance is how old a webhook may be before it is refused as
+// a possible replay.
+const fakeWebhookTolerance = 5 * time.Minute
+
+// fakeWebhookAttempts is how many times a webhook is sent before giving up,
+// waiting twice as long after each failure.
+const fakeWebhookAttempts = 5
+
+// FakeProvider is a payment provider that runs in memory, for development
+// and tests. It simulates successful and declined payments, payments that
+// take a while to complete and the signed webhooks that report them.
+type FakeProvider struct {
+	// Delay is how long delayed payments stay processing, 3 seconds by
+	// default.
+	Delay time.Duration
+	// Client sends the webhooks.
+	Client *http.Client
+
+	webhookURL string
+	secret     []byte
+
+	mu      sync.Mutex
+	intents map[string]*fakeIntent
+	// webhooks tracks the deliveries in flight for Wait
+	webhooks sync.WaitGroup
+}
+
+type fakeIntent struct {
+	Intent
+	paymentMethod string
+}
+
+// fakeWebhook is the body of the fake provider's webhooks.
+type fakeWebhook struct {
+	ID            string       `json:"id"`
+	IntentID      string       `json:"intent_id"`
+	Status        IntentStatus `json:"status"`
+	FailureReason string       `json:"failure_reason,omitempty"`
+}
+
+// NewFakeProvider returns a fake provider that posts its webhooks to
+// webhookURL, signed with secret.
+func NewFakeProvider(webhookURL string, secret []byte) *FakeProvider {
+	return &FakeProvider{
+		Delay:      3 * time.Second,
+		Client:     &http.Client{Timeout: 5 * time.Second},
+		webhookURL: webhookURL,
+		secret:     secret,
+		intents:    map[string]*fakeIntent{},
+	}
+}
+
+func (p *FakeProvider) Name() string {
+	return FakeProviderName
+}
+
+func (p *FakeProvider) CreateIntent(ctx context.Context, request IntentRequest) (*Intent, error) {
+	method := request.PaymentMethod
+	if method == "" {
+		method = FakeCard
+	}
+	switch method {
+	case FakeCard, FakeCardDeclined, FakeCardDelayed, FakeCardDelayedDeclined:
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownPaymentMethod, request.PaymentMethod)
+	}
+
+	intent := &fakeIntent{
+		Intent: Intent{
+			ID:       "fake_pi_" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+			Amount:   request.Amount,
+			Currency: request.Currency,
+			Status:   IntentRequiresConfirmation,
+		},
+		paymentMethod: method,
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.intents[intent.ID] = intent
+	return &intent.Intent, nil
+}
+
+func (p *FakeProvider) Confirm(ctx context.Context, intentID string) (*Intent, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	intent, ok := p.intents[intentID]
+	if !ok {
+		return nil, ErrUnknownIntent
+	}
+	if intent.Status != IntentRequiresConfirmation {
+		return nil, fmt.Errorf("fake payment intent %s is already %s", intentID, intent.Status)
+	}
+
+	switch intent.paymentMethod {
+	case FakeCard:
+		intent.Status = IntentSucceeded
+	case FakeCardDeclined:
+		intent.Status = IntentFailed
+		intent.FailureReason = "card declined"
+	case FakeCardDelayed:
+		intent.Status = IntentProcessing
+		p.complete(intent.ID, IntentSucceeded, "")
+	case FakeCardDelayedDeclined:
+		intent.Status = IntentProcessing
+		p.complete(intent.ID, IntentFailed, "insufficient funds on card")
+	}
+	confirmed := intent.Intent
+	return &confirmed, nil
+}
+
+// Refund refunds an intent in full. Intents of an earlier run of the
+// server are forgotten, so they are refunded without being checked.
+func (p *FakeProvider) Refund(ctx context.Context, intentID string, amount float64) (*Refund, error) {
+	if !strings.HasPrefix(intentID, "fake_pi_") {
+		return nil, ErrUnknownIntent
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if intent, ok := p.intents[intentID]; ok {
+		if intent.Status != IntentSucceeded {
+			return nil, fmt.Errorf("fake payment intent %s is %s and cannot be refunded", intentID, intent.Status)
+		}
+		intent.Status = IntentRefunded
+	}
+	return &Refund{ID: "fake_re_" + strings.ReplaceAll(uuid.NewString(), "-", ""), IntentID: intentID, Amount: amount}, nil
+}
+
+func (p *FakeProvider) ParseWebhook(header http.Header, body []byte) (*WebhookEvent, error) {
+	var timestamp, signature string
+	for _, part := range strings.Split(header.Get(FakeSignatureHeader), ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing signature timestamp", ErrInvalidWebhook)
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > fakeWebhookTolerance || age < -fakeWebhookTolerance {
+		return nil, fmt.Errorf("%w: signature timestamp is too old", ErrInvalidWebhook)
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(got, p.sign(timestamp, body)) {
+		return nil, fmt.Errorf("%w: signature mismatch", ErrInvalidWebhook)
+	}
+
+	var webhook fakeWebhook
+	if err := json.Unmarshal(body, &webhook); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+	return &WebhookEvent{ID: webhook.ID, IntentID: webhook.IntentID, Status: webhook.Status, FailureReason: webhook.FailureReason}, nil
+}
+
+// Wait blocks until the webhooks of every delayed payment have been sent.
+func (p *FakeProvider) Wait() {
+	p.webhooks.Wait()
+}
+
+// complete settles an intent once the delay has passed and sends a webhook
+// with its outcome. It is called with p.mu held.
+func (p *FakeProvider) complete(intentID string, status IntentStatus, reason string) {
+	p.webhooks.Add(1)
+	go func() {
+		defer p.webhooks.Done()
+		time.Sleep(p.Delay)
+
+		p.mu.Lock()
+		intent := p.intents[intentID]
+		intent.Status = status
+		intent.FailureReason = reason
+		p.mu.Unlock()
+
+		body, _ := json.Marshal(fakeWebhook{
+			ID:            "fake_evt_" + strings.ReplaceAll(uuid.NewString(), "-", ""),
+			IntentID:      intentID,
+			Status:        status,
+			FailureReason: reason,
+		})
+		p.deliver(body)
+	}()
+}
+
+// deliver posts a webhook until it is accepted or the attempts run out.
+func (p *FakeProvider) deliver(body []byte) {
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err := p.post(body)
+		if err == nil {
+			return
+		}
+		if attempt == fakeWebhookAttempts {
+			slog.Error("fake payment webhook not delivered", "url", p.webhookURL, "attempts", attempt, "error", err)
+			return
+		}
+		slog.Warn("fake payment webhook failed, retrying", "url", p.webhookURL, "attempt", attempt, "error", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (p *FakeProvider) post(body []byte) error {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	request, err := http.NewRequest(http.MethodPost, p.webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(FakeSignatureHeader, "t="+timestamp+",v1="+hex.EncodeToString(p.sign(timestamp, body)))
+
+	response, err := p.Client.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", response.Status)
+	}
+	return nil
+}
+
+func (p *FakeProvider) sign(timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
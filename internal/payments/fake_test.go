@@ -0,0 +1,56 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestFakeProviderWebhooks(t *testing.T) {
+	received := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	webhooks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+	}))
+	defer webhooks.Close()
+
+	provider := NewFakeProvider(webhooks.URL, []byte("secret"))
+	provider.Delay = time.Millisecond
+	intent, err := provider.CreateIntent(context.Background(), IntentRequest{Amount: 10, Currency: "USD", PaymentMethod: FakeCardDelayedDeclined})
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirmed, err := provider.Confirm(context.Background(), intent.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if confirmed.Status != IntentProcessing {
+		t.Errorf("confirmed intent: got %s want %s", confirmed.Status, IntentProcessing)
+	}
+	provider.Wait()
+
+	request, body := <-received, <-bodies
+	event, err := provider.ParseWebhook(request.Header, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.IntentID != intent.ID || event.Status != IntentFailed {
+		t.Errorf("event: got %s %s want %s %s", event.IntentID, event.Status, intent.ID, IntentFailed)
+	}
+
+	tampered := append([]byte(nil), body...)
+	tampered[len(tampered)-2] = ' '
+	if _, err := provider.ParseWebhook(request.Header, tampered); !errors.Is(err, ErrInvalidWebhook) {
+		t.Errorf("tampered body: got error %v want %v", err, ErrInvalidWebhook)
+	}
+	other := NewFakeProvider(webhooks.URL, []byte("other secret"))
+	if _, err := other.ParseWebhook(request.Header, body); !errors.Is(err, ErrInvalidWebhook) {
+		t.Errorf("other secret: got error %v want %v", err, ErrInvalidWebhook)
+	}
+}
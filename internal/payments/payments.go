@@ -0,0 +1,133 @@
+// Package payments connects the wallet to the payment processors that fund
+// top-ups. Each processor is a Provider; New returns the one the server is
+// configured with.
+package payments
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// IntentStatus is the state of a payment intent at its provider.
+type IntentStatus string
+
+const (
+	IntentRequiresConfirmation IntentStatus = "requires_confirmation"
+	// IntentProcessing intents are confirmed but their outcome is only
+	// known once the provider sends a webhook.
+	IntentProcessing IntentStatus = "processing"
+	IntentSucceeded  IntentStatus = "succeeded"
+	IntentFailed     IntentStatus = "failed"
+	IntentRefunded   IntentStatus = "refunded"
+)
+
+var (
+	ErrUnknownPaymentMethod = errors.New("unknown payment method")
+	ErrUnknownIntent        = errors.New("unknown payment intent")
+	// ErrInvalidWebhook is returned for a webhook that is malformed or not
+	// signed by the provider.
+	ErrInvalidWebhook = errors.New("invalid webhook")
+)
+
+// IntentRequest asks a provider to collect an amount.
+type IntentRequest struct {
+	Amount   float64
+	Currency string
+	// PaymentMethod identifies the card, account or wallet to collect from,
+	// in the provider's terms. Empty means the provider's default.
+	PaymentMethod string
+	// Reference is the wallet's reference for the payment, e.g. the ref of
+	// the top-up it funds.
+	Reference string
+}
+
+// Intent is a payment a provider was asked to collect.
+type Intent struct {
+	ID            string
+	Amount        float64
+	Currency      string
+	Status        IntentStatus
+	FailureReason string
+}
+
+// Refund is money a provider returned for a succeeded intent.
+type Refund struct {
+	ID       string
+	IntentID string
+	Amount   float64
+}
+
+// WebhookEvent is a notification from a provider that an intent changed.
+type WebhookEvent struct {
+	ID            string
+	IntentID      string
+	Status        IntentStatus
+	FailureReason string
+}
+
+// Provider is a payment processor.
+type Provider interface {
+	// Name identifies the provider, e.g. in the URL of its webhooks.
+	Name() string
+	// CreateIntent prepares the collection of an amount, which starts once
+	// the intent is confirmed.
+	CreateIntent(ctx context.Context, request IntentRequest) (*Intent, error)
+	// Confirm starts collecting an intent. The intent it returns has
+	// succeeded or failed, or is processing when the outcome is sent later
+	// with a webhook.
+	Confirm(ctx context.Context, intentID string) (*Intent, error)
+	// Refund returns the given amount of a succeeded intent.
+	Refund(ctx context.Context, intentID string, amount float64) (*Refund, error)
+	// ParseWebhook verifies that a webhook request comes from the provider
+	// and returns the event it carries.
+	ParseWebhook(header http.Header, body []byte) (*WebhookEvent, error)
+}
+
+var (
+	providerOnce     sync.Once
+	providerInstance Provider
+)
+
+// New returns the provider named by PAYMENT_PROVIDER, which is shared by
+// every caller. Only "fake" (the default) is available.
+func New() Provider {
+	providerOnce.Do(func() {
+		switch name := os.Getenv("PAYMENT_PROVIDER"); name {
+		case "", FakeProviderName:
+			providerInstance = newFakeProviderFromEnv()
+		default:
+			log.Fatalf("unknown payment provider %q", name)
+		}
+	})
+	return providerInstance
+}
+
+// newFakeProviderFromEnv configures the fake provider to send its webhooks
+// to this server, unless FAKE_PAYMENT_WEBHOOK_URL says otherwise.
+func newFakeProviderFromEnv() *FakeProvider {
+	webhookURL := os.Getenv("FAKE_PAYMENT_WEBHOOK_URL")
+	if webhookURL == "" {
+		port, _ := strconv.Atoi(os.Getenv("PORT"))
+		webhookURL = "http://localhost:" + strconv.Itoa(port) + "/api/v1/payments/webhooks/" + FakeProviderName
+	}
+	secret := os.Getenv("PAYMENT_WEBHOOK_SECRET")
+	if secret == "" {
+		secret = "fake-webhook-secret"
+	}
+
+	provider := NewFakeProvider(webhookURL, []byte(secret))
+	if raw := os.Getenv("FAKE_PAYMENT_DELAY"); raw != "" {
+		delay, err := time.ParseDuration(raw)
+		if err != nil {
+			log.Fatalf("invalid FAKE_PAYMENT_DELAY %q: %v", raw, err)
+		}
+		provider.Delay = delay
+	}
+	return provider
+}
@@ -0,0 +1,123 @@
+package payout
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallet/internal/banking"
+)
+
+// NACHA files are made of 94 character records, in blocks of ten.
+const (
+	nachaRecordLength   = 94
+	nachaBlockingFactor = 10
+)
+
+// NACHA transaction codes of credits to checking and savings accounts.
+const (
+	nachaCheckingCredit = "22"
+	nachaSavingsCredit  = "32"
+)
+
+// nachaServiceClass marks a batch that only holds credits.
+const nachaServiceClass = "220"
+
+// TraceNumber returns the trace number of the entry with the given
+// sequence number, which is unique for an originating bank while the
+// sequence is.
+func TraceNumber(originator Originator, sequence int) string {
+	return fmt.Sprintf("%.8s%07d", originator.RoutingNumber, sequence%10_000_000)
+}
+
+// WriteNACHA writes a NACHA file with a single PPD batch crediting each
+// entry's account, effective on the day the file was created. The entries'
+// references must be trace numbers given by TraceNumber.
+func WriteNACHA(w io.Writer, originator Originator, file *File) error {
+	err := missing("NACHA", map[string]string{
+		"PAYOUT_NAME":               originator.Name,
+		"PAYOUT_ACH_ROUTING_NUMBER": originator.RoutingNumber,
+		"PAYOUT_ACH_COMPANY_ID":     originator.CompanyID,
+	})
+	if err != nil {
+		return err
+	}
+	if err := banking.ValidateRoutingNumber(originator.RoutingNumber); err != nil {
+		return fmt.Errorf("PAYOUT_ACH_ROUTING_NUMBER: %w", err)
+	}
+	destination, destinationName := originator.DestinationRoutingNumber, originator.DestinationName
+	if destination == "" {
+		destination = originator.RoutingNumber
+	}
+	created := file.CreatedAt.In(time.Local)
+	odfi := originator.RoutingNumber[:8]
+
+	bw := bufio.NewWriter(w)
+	records := 0
+	write := func(fields ...string) {
+		record := strings.Join(fields, "")
+		fmt.Fprintf(bw, "%-*.*s\n", nachaRecordLength, nachaRecordLength, record)
+		records++
+	}
+
+	// File header, the routing numbers preceded by a blank
+	write("1", "01",
+		fmt.Sprintf("%10.10s", destination), fmt.Sprintf("%10.10s", originator.RoutingNumber),
+		created.Format("060102"), created.Format("1504"), "A", "094", "10", "1",
+		nachaField(destinationName, 23), nachaField(originator.Name, 23), nachaField(file.MessageID, 8))
+
+	// Batch header
+	write("5", nachaServiceClass,
+		nachaField(originator.Name, 16), nachaField("", 20), nachaField(originator.CompanyID, 10),
+		"PPD", nachaField("WITHDRAWAL", 10), nachaField("", 6), created.Format("060102"),
+		"   ", "1", odfi, "0000001")
+
+	var hash, credits int64
+	for _, e := range file.Entries {
+		code := nachaCheckingCredit
+		if e.Savings {
+			code = nachaSavingsCredit
+		}
+		amount := cents(e.Amount)
+		if len(e.RoutingNumber) != 9 {
+			return fmt.Errorf("entry %s: invalid routing number %q", e.Reference, e.RoutingNumber)
+		}
+		rdfi, err := strconv.ParseInt(e.RoutingNumber[:8], 10, 64)
+		if err != nil {
+			return fmt.Errorf("entry %s: invalid routing number %q", e.Reference, e.RoutingNumber)
+		}
+		hash += rdfi
+		credits += amount
+
+		write("6", code, e.RoutingNumber,
+			nachaField(e.AccountNumber, 17), fmt.Sprintf("%010d", amount),
+			nachaField(e.Remittance, 15), nachaField(e.Name, 22), "  ", "0", e.Reference)
+	}
+	hash %= 10_000_000_000
+
+	// Batch control
+	write("8", nachaServiceClass,
+		fmt.Sprintf("%06d", len(file.Entries)), fmt.Sprintf("%010d", hash),
+		fmt.Sprintf("%012d", 0), fmt.Sprintf("%012d", credits), nachaField(originator.CompanyID, 10),
+		nachaField("", 19), nachaField("", 6), odfi, "0000001")
+
+	// File control, counting the blocks the file fills once padded
+	blocks := (records + 1 + nachaBlockingFactor - 1) / nachaBlockingFactor
+	write("9", "000001", fmt.Sprintf("%06d", blocks), fmt.Sprintf("%08d", len(file.Entries)),
+		fmt.Sprintf("%010d", hash), fmt.Sprintf("%012d", 0), fmt.Sprintf("%012d", credits), nachaField("", 39))
+
+	for records%nachaBlockingFactor != 0 {
+		write(strings.Repeat("9", nachaRecordLength))
+	}
+	return bw.Flush()
+}
+
+// nachaField upper-cases text, replaces the characters NACHA does not
+// allow, and pads or cuts it to width.
+func nachaField(text string, width int) string {
+	text = strings.ToUpper(latinText(text, width))
+	return fmt.Sprintf("%-*s", width, text)
+}
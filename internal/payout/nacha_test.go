@@ -0,0 +1,53 @@
+package payout
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWriteNACHA(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteNACHA(&b, testOriginator, newTestFile()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The entry hash adds up the beneficiaries' banks, 01100001 and
+	// 12100024, and the file is padded to a block of ten records
+	want := strings.Join([]string{
+		"101 021000021 0210000212503031630A094101                       WALLET LTD             PAYOUT-2",
+		"5220WALLET LTD                          1234567890PPDWITHDRAWAL      250303   1021000020000001",
+		"62201100001512345678         0000010010WITHDRAWAL 1   ZO? M?LLER              0021000020000001",
+		"632121000248987654321        0000002500WITHDRAWAL 2   JOHN SMITH              0021000020000002",
+		"822000000200132000250000000000000000000125101234567890                         021000020000001",
+		"9000001000001000000020013200025000000000000000000012510                                       ",
+		strings.Repeat("9", nachaRecordLength),
+		strings.Repeat("9", nachaRecordLength),
+		strings.Repeat("9", nachaRecordLength),
+		strings.Repeat("9", nachaRecordLength),
+	}, "\n") + "\n"
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteNACHAErrors(t *testing.T) {
+	var b bytes.Buffer
+	err := WriteNACHA(&b, Originator{Name: "Wallet Ltd"}, newTestFile())
+	if !errors.Is(err, ErrNotConfigured) || !strings.Contains(err.Error(), "PAYOUT_ACH_COMPANY_ID, PAYOUT_ACH_ROUTING_NUMBER") {
+		t.Errorf("unconfigured originator: got error %v want %v naming the settings", err, ErrNotConfigured)
+	}
+
+	originator := testOriginator
+	originator.RoutingNumber = "021000022"
+	if err := WriteNACHA(&b, originator, newTestFile()); err == nil || !strings.HasPrefix(err.Error(), "PAYOUT_ACH_ROUTING_NUMBER") {
+		t.Errorf("invalid originating bank: got error %v", err)
+	}
+
+	file := newTestFile()
+	file.Entries[1].RoutingNumber = "12100024"
+	if err := WriteNACHA(&b, testOriginator, file); err == nil || !strings.Contains(err.Error(), file.Entries[1].Reference) {
+		t.Errorf("invalid beneficiary bank: got error %v want it to name the entry", err)
+	}
+}
@@ -0,0 +1,134 @@
+package payout
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"wallet/internal/banking"
+)
+
+const painNamespace = "urn:iso:std:iso:20022:tech:xsd:pain.001.001.03"
+
+// The pain.001.001.03 elements of a SEPA credit transfer, in schema order.
+type painDocument struct {
+	XMLName    xml.Name `xml:"Document"`
+	Namespace  string   `xml:"xmlns,attr"`
+	Initiation struct {
+		GroupHeader struct {
+			MessageID    string `xml:"MsgId"`
+			CreatedAt    string `xml:"CreDtTm"`
+			Transactions int    `xml:"NbOfTxs"`
+			ControlSum   string `xml:"CtrlSum"`
+			Initiator    string `xml:"InitgPty>Nm"`
+		} `xml:"GrpHdr"`
+		PaymentInfo struct {
+			ID            string         `xml:"PmtInfId"`
+			Method        string         `xml:"PmtMtd"`
+			Transactions  int            `xml:"NbOfTxs"`
+			ControlSum    string         `xml:"CtrlSum"`
+			ServiceLevel  string         `xml:"PmtTpInf>SvcLvl>Cd"`
+			ExecutionDate string         `xml:"ReqdExctnDt"`
+			Debtor        string         `xml:"Dbtr>Nm"`
+			DebtorIBAN    string         `xml:"DbtrAcct>Id>IBAN"`
+			DebtorBIC     string         `xml:"DbtrAgt>FinInstnId>BIC"`
+			ChargeBearer  string         `xml:"ChrgBr"`
+			Transfers     []painTransfer `xml:"CdtTrfTxInf"`
+		} `xml:"PmtInf"`
+	} `xml:"CstmrCdtTrfInitn"`
+}
+
+type painTransfer struct {
+	EndToEndID string     `xml:"PmtId>EndToEndId"`
+	Amount     painAmount `xml:"Amt>InstdAmt"`
+	// The creditor's BIC is optional within the EEA
+	CreditorAgent *painAgent `xml:"CdtrAgt,omitempty"`
+	Creditor      string     `xml:"Cdtr>Nm"`
+	CreditorIBAN  string     `xml:"CdtrAcct>Id>IBAN"`
+	Remittance    string     `xml:"RmtInf>Ustrd,omitempty"`
+}
+
+type painAgent struct {
+	BIC string `xml:"FinInstnId>BIC"`
+}
+
+type painAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// Lengths of the pain.001 text fields the wallet fills in.
+const (
+	painMaxID         = 35
+	painMaxName       = 70
+	painMaxRemittance = 140
+)
+
+// WritePain001 writes a SEPA credit transfer initiation, pain.001.001.03,
+// with one payment information block debiting the originator's account and
+// a transfer per entry, to be executed on the day the file was created.
+func WritePain001(w io.Writer, originator Originator, file *File) error {
+	err := missing("pain.001", map[string]string{
+		"PAYOUT_NAME": originator.Name,
+		"PAYOUT_IBAN": originator.IBAN,
+		"PAYOUT_BIC":  originator.BIC,
+	})
+	if err != nil {
+		return err
+	}
+	debtorIBAN, err := banking.ValidateIBAN(originator.IBAN)
+	if err != nil {
+		return fmt.Errorf("PAYOUT_IBAN: %w", err)
+	}
+
+	doc := painDocument{Namespace: painNamespace}
+	total := painAmountValue(file.Total())
+	header := &doc.Initiation.GroupHeader
+	header.MessageID = latinText(file.MessageID, painMaxID)
+	header.CreatedAt = file.CreatedAt.In(time.Local).Format("2006-01-02T15:04:05")
+	header.Transactions = len(file.Entries)
+	header.ControlSum = total
+	header.Initiator = latinText(originator.Name, painMaxName)
+
+	info := &doc.Initiation.PaymentInfo
+	info.ID = header.MessageID
+	info.Method = "TRF"
+	info.Transactions = len(file.Entries)
+	info.ControlSum = total
+	info.ServiceLevel = "SEPA"
+	info.ExecutionDate = file.CreatedAt.In(time.Local).Format(time.DateOnly)
+	info.Debtor = header.Initiator
+	info.DebtorIBAN = debtorIBAN
+	info.DebtorBIC = originator.BIC
+	info.ChargeBearer = "SLEV"
+	info.Transfers = make([]painTransfer, len(file.Entries))
+	for i, e := range file.Entries {
+		info.Transfers[i] = painTransfer{
+			EndToEndID:   latinText(e.Reference, painMaxID),
+			Amount:       painAmount{Currency: file.Currency, Value: painAmountValue(e.Amount)},
+			Creditor:     latinText(e.Name, painMaxName),
+			CreditorIBAN: e.IBAN,
+			Remittance:   latinText(e.Remittance, painMaxRemittance),
+		}
+		if e.BIC != "" {
+			info.Transfers[i].CreditorAgent = &painAgent{BIC: e.BIC}
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+func painAmountValue(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
@@ -0,0 +1,59 @@
+package payout
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestWritePain001(t *testing.T) {
+	var b bytes.Buffer
+	if err := WritePain001(&b, testOriginator, newTestFile()); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc painDocument
+	if err := xml.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatalf("decode %s: %v", b.String(), err)
+	}
+	if doc.Namespace != painNamespace {
+		t.Errorf("got namespace %q want %q", doc.Namespace, painNamespace)
+	}
+	header := doc.Initiation.GroupHeader
+	if header.MessageID != "PAYOUT-20250303-1" || header.CreatedAt != "2025-03-03T16:30:00" || header.Transactions != 2 || header.ControlSum != "125.10" {
+		t.Errorf("got group header %+v want 2 transactions of 125.10", header)
+	}
+	info := doc.Initiation.PaymentInfo
+	if info.ExecutionDate != "2025-03-03" || info.DebtorIBAN != "DE89370400440532013000" || info.DebtorBIC != "COBADEFFXXX" || info.ControlSum != "125.10" {
+		t.Errorf("got payment information %+v want 125.10 debited from DE89370400440532013000 on 2025-03-03", info)
+	}
+	if len(info.Transfers) != 2 {
+		t.Fatalf("got %d transfers want 2", len(info.Transfers))
+	}
+
+	// Names are cut down to the Latin character set, and the creditor's
+	// agent is left out when the BIC is not known
+	first, second := info.Transfers[0], info.Transfers[1]
+	if first.EndToEndID != "021000020000001" || first.Amount != (painAmount{Currency: "EUR", Value: "100.10"}) || first.Creditor != "Zo? M?ller" || first.CreditorAgent != nil {
+		t.Errorf("got first transfer %+v", first)
+	}
+	if second.CreditorAgent == nil || second.CreditorAgent.BIC != "NWBKGB2L" || second.CreditorIBAN != "GB82WEST12345698765432" || second.Remittance != "Withdrawal 2" {
+		t.Errorf("got second transfer %+v", second)
+	}
+}
+
+func TestWritePain001Errors(t *testing.T) {
+	var b bytes.Buffer
+	err := WritePain001(&b, Originator{Name: "Wallet Ltd", RoutingNumber: "021000021"}, newTestFile())
+	if !errors.Is(err, ErrNotConfigured) || !strings.Contains(err.Error(), "PAYOUT_BIC, PAYOUT_IBAN") {
+		t.Errorf("unconfigured originator: got error %v want %v naming the settings", err, ErrNotConfigured)
+	}
+
+	originator := testOriginator
+	originator.IBAN = "DE88370400440532013000"
+	if err := WritePain001(&b, originator, newTestFile()); err == nil || !strings.HasPrefix(err.Error(), "PAYOUT_IBAN") {
+		t.Errorf("invalid debtor IBAN: got error %v", err)
+	}
+}
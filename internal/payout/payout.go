@@ -0,0 +1,124 @@
+// Package payout writes the files that instruct the bank to pay withdrawals
+// out, SEPA pain.001 credit transfers and NACHA ACH credits, and reads the
+// status reports and returns the bank sends back.
+package payout
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ErrNotConfigured is returned for a file whose originator details are not
+// configured.
+var ErrNotConfigured = errors.New("payout originator is not configured")
+
+// Originator is the wallet operator's account the withdrawals are paid
+// from, and how its bank identifies it.
+type Originator struct {
+	Name string
+	// IBAN and BIC are the account pain.001 transfers are debited from.
+	IBAN string
+	BIC  string
+	// RoutingNumber is the originating bank of ACH entries and CompanyID
+	// the operator's ACH company identification, e.g. "1" and its EIN.
+	RoutingNumber string
+	CompanyID     string
+	// DestinationRoutingNumber and DestinationName identify the bank or
+	// ACH operator NACHA files are sent to, by default the originating bank.
+	DestinationRoutingNumber string
+	DestinationName          string
+}
+
+// OriginatorFromEnv reads the originator from PAYOUT_NAME, PAYOUT_IBAN,
+// PAYOUT_BIC, PAYOUT_ACH_ROUTING_NUMBER, PAYOUT_ACH_COMPANY_ID,
+// PAYOUT_ACH_DESTINATION and PAYOUT_ACH_DESTINATION_NAME.
+func OriginatorFromEnv() Originator {
+	return Originator{
+		Name:                     os.Getenv("PAYOUT_NAME"),
+		IBAN:                     os.Getenv("PAYOUT_IBAN"),
+		BIC:                      os.Getenv("PAYOUT_BIC"),
+		RoutingNumber:            os.Getenv("PAYOUT_ACH_ROUTING_NUMBER"),
+		CompanyID:                os.Getenv("PAYOUT_ACH_COMPANY_ID"),
+		DestinationRoutingNumber: os.Getenv("PAYOUT_ACH_DESTINATION"),
+		DestinationName:          os.Getenv("PAYOUT_ACH_DESTINATION_NAME"),
+	}
+}
+
+// File is a payout file: a set of credit transfers to send the bank
+// together.
+type File struct {
+	// MessageID identifies the file to the bank, in at most 35 characters.
+	MessageID string
+	CreatedAt time.Time
+	Currency  string
+	Entries   []Entry
+}
+
+// Entry is a credit transfer to a beneficiary.
+type Entry struct {
+	// Reference identifies the transfer in the bank's reports: the
+	// end-to-end ID in pain.001 files and the trace number in NACHA files.
+	Reference string
+	Amount    float64
+	Name      string
+	IBAN      string
+	BIC       string
+	// RoutingNumber, AccountNumber and Savings identify ACH beneficiaries.
+	RoutingNumber string
+	AccountNumber string
+	Savings       bool
+	// Remittance is the text the beneficiary sees with the transfer.
+	Remittance string
+}
+
+// Total returns the sum of the entries' amounts.
+func (f *File) Total() float64 {
+	total := 0.0
+	for _, e := range f.Entries {
+		total += e.Amount
+	}
+	return total
+}
+
+// cents returns an amount as a whole number of cents.
+func cents(amount float64) int64 {
+	if amount < 0 {
+		return int64(amount*100 - 0.5)
+	}
+	return int64(amount*100 + 0.5)
+}
+
+// latinText replaces the characters outside the Latin character set SEPA
+// and SWIFT messages allow with a question mark, and cuts text to max
+// characters.
+func latinText(text string, max int) string {
+	text = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case strings.ContainsRune("/-?:().,'+ ", r):
+			return r
+		}
+		return '?'
+	}, strings.TrimSpace(text))
+	return text[:min(len(text), max)]
+}
+
+// missing returns ErrNotConfigured naming the unset settings, or nil.
+func missing(format string, settings map[string]string) error {
+	var unset []string
+	for name, value := range settings {
+		if value == "" {
+			unset = append(unset, name)
+		}
+	}
+	if len(unset) == 0 {
+		return nil
+	}
+	slices.Sort(unset)
+	return fmt.Errorf("%w for %s files: set %s", ErrNotConfigured, format, strings.Join(unset, ", "))
+}
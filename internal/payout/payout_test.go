@@ -0,0 +1,80 @@
+package payout
+
+import (
+	"testing"
+	"time"
+)
+
+// testOriginator is configured for both pain.001 and NACHA files.
+var testOriginator = Originator{
+	Name:          "Wallet Ltd",
+	IBAN:          "DE89 3704 0044 0532 0130 00",
+	BIC:           "COBADEFFXXX",
+	RoutingNumber: "021000021",
+	CompanyID:     "1234567890",
+}
+
+// newTestFile returns a file of two withdrawals created on 3 March 2025.
+func newTestFile() *File {
+	return &File{
+		MessageID: "PAYOUT-20250303-1",
+		CreatedAt: time.Date(2025, time.March, 3, 16, 30, 0, 0, time.Local),
+		Currency:  "EUR",
+		Entries: []Entry{
+			{
+				Reference:     TraceNumber(testOriginator, 1),
+				Amount:        100.1,
+				Name:          "Zoë Müller",
+				IBAN:          "FR1420041010050500013M02606",
+				RoutingNumber: "011000015",
+				AccountNumber: "12345678",
+				Remittance:    "Withdrawal 1",
+			},
+			{
+				Reference:     TraceNumber(testOriginator, 2),
+				Amount:        25,
+				Name:          "John Smith",
+				IBAN:          "GB82WEST12345698765432",
+				BIC:           "NWBKGB2L",
+				RoutingNumber: "121000248",
+				AccountNumber: "987654321",
+				Savings:       true,
+				Remittance:    "Withdrawal 2",
+			},
+		},
+	}
+}
+
+func TestCents(t *testing.T) {
+	for amount, want := range map[float64]int64{0.29: 29, 100.1: 10010, -30.5: -3050, 0: 0} {
+		if got := cents(amount); got != want {
+			t.Errorf("cents(%v): got %d want %d", amount, got, want)
+		}
+	}
+}
+
+func TestLatinText(t *testing.T) {
+	for _, tt := range []struct {
+		text string
+		max  int
+		want string
+	}{
+		{" Zoë Müller ", 70, "Zo? M?ller"},
+		{"Withdrawal #1 & co", 70, "Withdrawal ?1 ? co"},
+		{"Wallet Ltd", 6, "Wallet"},
+	} {
+		if got := latinText(tt.text, tt.max); got != tt.want {
+			t.Errorf("latinText(%q, %d): got %q want %q", tt.text, tt.max, got, tt.want)
+		}
+	}
+}
+
+func TestTraceNumber(t *testing.T) {
+	if got := TraceNumber(testOriginator, 42); got != "021000020000042" {
+		t.Errorf("got %q want 021000020000042", got)
+	}
+	// The sequence wraps around rather than overflowing into the bank's
+	if got := TraceNumber(testOriginator, 10_000_001); got != "021000020000001" {
+		t.Errorf("got %q want 021000020000001", got)
+	}
+}
@@ -0,0 +1,235 @@
+package payout
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"wallet/internal/models"
+)
+
+// ErrInvalidReturns is returned for a file that is neither a pain.002 status
+// report nor a NACHA file.
+var ErrInvalidReturns = errors.New("invalid return file")
+
+// Outcome is what the bank did with a transfer.
+type Outcome string
+
+const (
+	// Accepted transfers were paid to the beneficiary's bank.
+	Accepted Outcome = "accepted"
+	// Returned transfers were rejected or sent back.
+	Returned Outcome = "returned"
+)
+
+// Status is the bank's answer for a transfer of a payout file.
+type Status struct {
+	// Reference is the end-to-end ID or trace number of the transfer. It is
+	// empty when the status is for every transfer of the file MessageID.
+	Reference string
+	MessageID string
+	Outcome   Outcome
+	// Code and Reason explain a return, e.g. "AC04" and "Closed account
+	// number".
+	Code   string
+	Reason string
+}
+
+// maxReturnsBytes bounds the size of a return file.
+const maxReturnsBytes = 32 << 20
+
+// ParseReturns reads a pain.002 status report answering pain.001 files, or
+// a NACHA file answering NACHA ones, and returns the format it answers with
+// the statuses it carries. Statuses that are not final, such as pending
+// transfers, are left out.
+func ParseReturns(r io.Reader) (models.PayoutFormat, []Status, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxReturnsBytes+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(data) > maxReturnsBytes {
+		return "", nil, fmt.Errorf("%w: larger than %d bytes", ErrInvalidReturns, maxReturnsBytes)
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		statuses, err := parsePain002(trimmed)
+		return models.PayoutPain001, statuses, err
+	case bytes.HasPrefix(trimmed, []byte("1")):
+		statuses, err := parseNACHAReturns(trimmed)
+		return models.PayoutNACHA, statuses, err
+	}
+	return "", nil, ErrInvalidReturns
+}
+
+// The pain.002.001.03 elements the wallet reads.
+type pain002Document struct {
+	Report struct {
+		Group struct {
+			MessageID string          `xml:"OrgnlMsgId"`
+			Status    string          `xml:"GrpSts"`
+			Reasons   []pain002Reason `xml:"StsRsnInf"`
+		} `xml:"OrgnlGrpInfAndSts"`
+		Payments []struct {
+			Transactions []struct {
+				EndToEndID string          `xml:"OrgnlEndToEndId"`
+				Status     string          `xml:"TxSts"`
+				Reasons    []pain002Reason `xml:"StsRsnInf"`
+			} `xml:"TxInfAndSts"`
+		} `xml:"OrgnlPmtInfAndSts"`
+	} `xml:"CstmrPmtStsRpt"`
+}
+
+type pain002Reason struct {
+	Code        string   `xml:"Rsn>Cd"`
+	Proprietary string   `xml:"Rsn>Prtry"`
+	Info        []string `xml:"AddtlInf"`
+}
+
+func parsePain002(data []byte) ([]Status, error) {
+	var doc pain002Document
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidReturns, err)
+	}
+	report := doc.Report
+	if report.Group.MessageID == "" {
+		return nil, fmt.Errorf("%w: not a pain.002 status report", ErrInvalidReturns)
+	}
+
+	statuses := []Status{}
+	for _, payment := range report.Payments {
+		for _, tx := range payment.Transactions {
+			if status, ok := pain002Status(tx.Status, tx.Reasons); ok {
+				status.Reference = tx.EndToEndID
+				status.MessageID = report.Group.MessageID
+				statuses = append(statuses, status)
+			}
+		}
+	}
+	// A report without transactions answers for the whole file
+	if len(statuses) == 0 {
+		if status, ok := pain002Status(report.Group.Status, report.Group.Reasons); ok {
+			status.MessageID = report.Group.MessageID
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses, nil
+}
+
+// pain002Status translates an ISO 20022 status code. Only accepted and
+// settled transfers, and rejected ones, are final.
+func pain002Status(code string, reasons []pain002Reason) (Status, bool) {
+	switch code {
+	case "ACCP", "ACSP", "ACSC", "ACWC":
+		return Status{Outcome: Accepted}, true
+	case "RJCT":
+		status := Status{Outcome: Returned}
+		if len(reasons) > 0 {
+			status.Code = reasons[0].Code
+			if status.Code == "" {
+				status.Code = reasons[0].Proprietary
+			}
+			status.Reason = strings.Join(reasons[0].Info, " ")
+		}
+		if status.Reason == "" {
+			status.Reason = returnReasons[status.Code]
+		}
+		return status, true
+	}
+	return Status{}, false
+}
+
+// parseNACHAReturns reads the entries of a NACHA file. An entry with a
+// return addenda record is a return of the original entry it names; any
+// other entry confirms the entry with its trace number. Notifications of
+// change correct a beneficiary's details for later entries, which are not
+// kept, and are left out.
+func parseNACHAReturns(data []byte) ([]Status, error) {
+	statuses := []Status{}
+	var entry *Status
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		record := strings.TrimRight(scanner.Text(), "\r")
+		if record == "" || strings.Trim(record, "9") == "" {
+			continue
+		}
+		if len(record) != nachaRecordLength {
+			return nil, fmt.Errorf("%w: line %d has %d characters, not %d", ErrInvalidReturns, line, len(record), nachaRecordLength)
+		}
+
+		switch record[0] {
+		case '6':
+			if entry != nil {
+				statuses = append(statuses, *entry)
+			}
+			entry = &Status{Reference: record[79:94], Outcome: Accepted}
+		case '7':
+			if entry == nil {
+				return nil, fmt.Errorf("%w: line %d is an addenda record without an entry", ErrInvalidReturns, line)
+			}
+			switch record[1:3] {
+			case "99":
+				entry.Outcome = Returned
+				entry.Reference = record[6:21]
+				entry.Code = record[3:6]
+				entry.Reason = returnReasons[entry.Code]
+				if info := strings.TrimSpace(record[35:79]); info != "" && entry.Reason == "" {
+					entry.Reason = info
+				}
+			case "98":
+				entry = nil
+			}
+		case '8', '9':
+			if entry != nil {
+				statuses = append(statuses, *entry)
+				entry = nil
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if entry != nil {
+		statuses = append(statuses, *entry)
+	}
+	return statuses, nil
+}
+
+// returnReasons describes the return codes banks send most often, for
+// returns that do not explain themselves.
+var returnReasons = map[string]string{
+	// ISO 20022 reason codes
+	"AC01": "Incorrect account number",
+	"AC04": "Closed account number",
+	"AC06": "Blocked account",
+	"AG01": "Transaction forbidden",
+	"AM04": "Insufficient funds",
+	"AM05": "Duplication",
+	"BE04": "Missing creditor address",
+	"MD07": "End customer deceased",
+	"MS02": "Not specified reason customer generated",
+	"MS03": "Not specified reason agent generated",
+	"RC01": "Bank identifier incorrect",
+	"RR01": "Missing debtor account or identification",
+	// NACHA return reason codes
+	"R01": "Insufficient funds",
+	"R02": "Account closed",
+	"R03": "No account/unable to locate account",
+	"R04": "Invalid account number",
+	"R06": "Returned per ODFI's request",
+	"R07": "Authorization revoked by customer",
+	"R08": "Payment stopped",
+	"R10": "Customer advises not authorized",
+	"R14": "Representative payee deceased",
+	"R15": "Beneficiary or account holder deceased",
+	"R16": "Account frozen",
+	"R17": "File record edit criteria",
+	"R20": "Non-transaction account",
+	"R23": "Credit entry refused by receiver",
+	"R29": "Corporate customer advises not authorized",
+}
@@ -0,0 +1,101 @@
+package payout
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"wallet/internal/models"
+)
+
+func TestParseReturnsPain002(t *testing.T) {
+	report := `<?xml version="1.0"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.002.001.03"><CstmrPmtStsRpt>
+<OrgnlGrpInfAndSts><OrgnlMsgId>PAYOUT-1</OrgnlMsgId><GrpSts>PART</GrpSts></OrgnlGrpInfAndSts>
+<OrgnlPmtInfAndSts>
+<TxInfAndSts><OrgnlEndToEndId>E2E-1</OrgnlEndToEndId><TxSts>ACSC</TxSts></TxInfAndSts>
+<TxInfAndSts><OrgnlEndToEndId>E2E-2</OrgnlEndToEndId><TxSts>RJCT</TxSts><StsRsnInf><Rsn><Cd>AC04</Cd></Rsn></StsRsnInf></TxInfAndSts>
+<TxInfAndSts><OrgnlEndToEndId>E2E-3</OrgnlEndToEndId><TxSts>RJCT</TxSts><StsRsnInf><Rsn><Prtry>X1</Prtry></Rsn><AddtlInf>Beneficiary</AddtlInf><AddtlInf>unknown</AddtlInf></StsRsnInf></TxInfAndSts>
+<TxInfAndSts><OrgnlEndToEndId>E2E-4</OrgnlEndToEndId><TxSts>PDNG</TxSts></TxInfAndSts>
+</OrgnlPmtInfAndSts>
+</CstmrPmtStsRpt></Document>`
+
+	format, statuses, err := ParseReturns(strings.NewReader(report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != models.PayoutPain001 {
+		t.Errorf("got format %q want %q", format, models.PayoutPain001)
+	}
+	// Pending transfers are left out, and reasons are described from the
+	// code when the report does not
+	want := []Status{
+		{Reference: "E2E-1", MessageID: "PAYOUT-1", Outcome: Accepted},
+		{Reference: "E2E-2", MessageID: "PAYOUT-1", Outcome: Returned, Code: "AC04", Reason: "Closed account number"},
+		{Reference: "E2E-3", MessageID: "PAYOUT-1", Outcome: Returned, Code: "X1", Reason: "Beneficiary unknown"},
+	}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("got %+v want %+v", statuses, want)
+	}
+}
+
+func TestParseReturnsPain002Group(t *testing.T) {
+	report := `<Document><CstmrPmtStsRpt><OrgnlGrpInfAndSts><OrgnlMsgId>PAYOUT-1</OrgnlMsgId><GrpSts>RJCT</GrpSts>
+<StsRsnInf><Rsn><Cd>AM05</Cd></Rsn></StsRsnInf></OrgnlGrpInfAndSts></CstmrPmtStsRpt></Document>`
+
+	_, statuses, err := ParseReturns(strings.NewReader(report))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Status{{MessageID: "PAYOUT-1", Outcome: Returned, Code: "AM05", Reason: "Duplication"}}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("got %+v want the whole file returned: %+v", statuses, want)
+	}
+}
+
+func TestParseReturnsNACHA(t *testing.T) {
+	var b bytes.Buffer
+	file := newTestFile()
+	if err := WriteNACHA(&b, testOriginator, file); err != nil {
+		t.Fatal(err)
+	}
+	// The bank sends the second entry back with a return addenda record
+	// naming it by its original trace number
+	returned := file.Entries[1].Reference
+	addenda := fmt.Sprintf("%-94s", "799R03"+returned+"      12100024"+strings.Repeat(" ", 44)+"091000010000001")
+	records := strings.SplitAfter(b.String(), "\n")
+	nacha := strings.Join(records[:4], "") + addenda + "\r\n" + strings.Join(records[4:], "")
+
+	format, statuses, err := ParseReturns(strings.NewReader(nacha))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format != models.PayoutNACHA {
+		t.Errorf("got format %q want %q", format, models.PayoutNACHA)
+	}
+	want := []Status{
+		{Reference: file.Entries[0].Reference, Outcome: Accepted},
+		{Reference: returned, Outcome: Returned, Code: "R03", Reason: "No account/unable to locate account"},
+	}
+	if !reflect.DeepEqual(statuses, want) {
+		t.Errorf("got %+v want %+v", statuses, want)
+	}
+}
+
+func TestParseReturnsInvalid(t *testing.T) {
+	for name, input := range map[string]string{
+		"empty":        "",
+		"CSV":          "reference,status\n",
+		"XML":          "<Document><CstmrPmtStsRpt></CstmrPmtStsRpt></Document>",
+		"short record": "101 021000021\n",
+		"addenda":      "1" + strings.Repeat(" ", 93) + "\n799R03" + strings.Repeat(" ", 88) + "\n",
+		"size":         "<" + strings.Repeat(" ", maxReturnsBytes),
+	} {
+		if _, _, err := ParseReturns(strings.NewReader(input)); !errors.Is(err, ErrInvalidReturns) {
+			t.Errorf("%s: got error %v want %v", name, err, ErrInvalidReturns)
+		}
+	}
+}
@@ -0,0 +1,687 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"wallet/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+// TimestampLayout is the UTC text form timestamps are compared in.
+const TimestampLayout = "2006-01-02 15:04:05.000"
+
+// UTCTimestampSQL normalises a timestamp column to UTC text. Timestamps are
+// stored as text with the writer's zone offset, so they only compare
+// correctly once normalised.
+func UTCTimestampSQL(column string) string {
+	return "strftime('%Y-%m-%d %H:%M:%f', " + column + ")"
+}
+
+// UTCTimestamp formats t for comparison against UTCTimestampSQL.
+func UTCTimestamp(t time.Time) string {
+	return t.UTC().Format(TimestampLayout)
+}
+
+type gormStore struct {
+	db *gorm.DB
+}
+
+// NewGormStore returns a Store backed by db, which may itself be a
+// transaction.
+func NewGormStore(db *gorm.DB) Store {
+	return &gormStore{db: db}
+}
+
+func (s *gormStore) Users() UserRepository                { return gormUsers{s.db} }
+func (s *gormStore) Accounts() AccountRepository          { return gormAccounts{s.db} }
+func (s *gormStore) Transactions() TransactionRepository  { return gormTransactions{s.db} }
+func (s *gormStore) Events() EventRepository              { return gormEvents{s.db} }
+func (s *gormStore) Audit() AuditRepository               { return gormAudit{s.db} }
+func (s *gormStore) Batches() BatchRepository             { return gormBatches{s.db} }
+func (s *gormStore) Payments() PaymentRepository          { return gormPayments{s.db} }
+func (s *gormStore) Beneficiaries() BeneficiaryRepository { return gormBeneficiaries{s.db} }
+func (s *gormStore) Withdrawals() WithdrawalRepository    { return gormWithdrawals{s.db} }
+func (s *gormStore) Deposits() DepositRepository          { return gormDeposits{s.db} }
+func (s *gormStore) Adjustments() AdjustmentRepository    { return gormAdjustments{s.db} }
+
+// BusyTimeout is how long a write waits for another writer to commit before
+// failing with SQLITE_BUSY. The database opens its connections with it.
+const BusyTimeout = 5 * time.Second
+
+func (s *gormStore) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	db := s.db.WithContext(ctx)
+	// SQLite waits for another writer's lock out of reach of ctx, so a
+	// transaction whose deadline comes before the busy timeout waits on a
+	// connection of its own, for no longer than the deadline
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < BusyTimeout {
+		if sqlDB, ok := db.Statement.ConnPool.(*sql.DB); ok {
+			conn, err := limitBusyTimeout(ctx, sqlDB, time.Until(deadline))
+			if err != nil {
+				return gormError(err)
+			}
+			defer releaseConn(conn)
+			db.Statement.ConnPool = conn
+		}
+	}
+	return gormError(db.Transaction(func(tx *gorm.DB) error {
+		return fn(&gormStore{db: tx})
+	}))
+}
+
+// limitBusyTimeout reserves a connection from sqlDB that waits at most
+// timeout for the write lock. It must be given back with releaseConn.
+func limitBusyTimeout(ctx context.Context, sqlDB *sql.DB, timeout time.Duration) (*sql.Conn, error) {
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Rounded up, so the wait ends just after the deadline rather than before
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("PRAGMA busy_timeout = %d", timeout.Milliseconds()+1)); err != nil {
+		releaseConn(conn)
+		return nil, err
+	}
+	return conn, nil
+}
+
+// releaseConn restores the busy timeout of a connection reserved by
+// limitBusyTimeout and returns it to the pool.
+func releaseConn(conn *sql.Conn) {
+	// The caller's context may have expired by now
+	if _, err := conn.ExecContext(context.Background(), fmt.Sprintf("PRAGMA busy_timeout = %d", BusyTimeout.Milliseconds())); err != nil {
+		// Keep the connection out of the pool rather than return it with
+		// the lowered timeout
+		conn.Raw(func(any) error { return driver.ErrBadConn })
+	}
+	conn.Close()
+}
+
+// gormError translates gorm errors to the repository's.
+func gormError(err error) error {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrNotFound
+	case errors.Is(err, gorm.ErrDuplicatedKey):
+		return ErrDuplicate
+	}
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked) {
+		return fmt.Errorf("%w: %v", ErrBusy, err)
+	}
+	return err
+}
+
+type gormUsers struct{ db *gorm.DB }
+
+func (r gormUsers) Create(ctx context.Context, user *models.User) error {
+	return gormError(r.db.WithContext(ctx).Create(user).Error)
+}
+
+func (r gormUsers) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, "id = ?", id).Error; err != nil {
+		return nil, gormError(err)
+	}
+	return &user, nil
+}
+
+func (r gormUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user models.User
+	if err := r.db.WithContext(ctx).First(&user, "email = ?", email).Error; err != nil {
+		return nil, gormError(err)
+	}
+	return &user, nil
+}
+
+type gormAccounts struct{ db *gorm.DB }
+
+func (r gormAccounts) Create(ctx context.Context, account *models.Account) error {
+	return gormError(r.db.WithContext(ctx).Create(account).Error)
+}
+
+func (r gormAccounts) GetByID(ctx context.Context, id uuid.UUID) (*models.Account, error) {
+	var account models.Account
+	if err := r.db.WithContext(ctx).First(&account, "id = ?", id).Error; err != nil {
+		return nil, gormError(err)
+	}
+	return &account, nil
+}
+
+func (r gormAccounts) GetByNumber(ctx context.Context, number string) (*models.Account, error) {
+	var account models.Account
+	if err := r.db.WithContext(ctx).First(&account, "number = ?", number).Error; err != nil {
+		return nil, gormError(err)
+	}
+	return &account, nil
+}
+
+func (r gormAccounts) GetByVirtualIBAN(ctx context.Context, iban string) (*models.Account, error) {
+	var account models.Account
+	if err := r.db.WithContext(ctx).First(&account, "virtual_iban = ?", iban).Error; err != nil {
+		return nil, gormError(err)
+	}
+	return &account, nil
+}
+
+func (r gormAccounts) ListByUserID(ctx context.Context, userID uuid.UUID) ([]models.Account, error) {
+	var accounts []models.Account
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at ASC").Find(&accounts).Error
+	if err != nil {
+		return nil, gormError(err)
+	}
+	return accounts, nil
+}
+
+func (r gormAccounts) ListWithoutNumber(ctx context.Context, limit int) ([]models.Account, error) {
+	var accounts []models.Account
+	err := r.db.WithContext(ctx).Where("number IS NULL").Order("created_at ASC").Limit(limit).Find(&accounts).Error
+	if err != nil {
+		return nil, gormError(err)
+	}
+	return accounts, nil
+}
+
+func (r gormAccounts) ListWithoutVirtualIBAN(ctx context.Context, limit int) ([]models.Account, error) {
+	var accounts []models.Account
+	err := r.db.WithContext(ctx).Where("virtual_iban IS NULL").Order("created_at ASC").Limit(limit).Find(&accounts).Error
+	if err != nil {
+		return nil, gormError(err)
+	}
+	return accounts, nil
+}
+
+func (r gormAccounts) Update(ctx context.Context, account *models.Account) error {
+	result := r.db.WithContext(ctx).Model(&models.Account{}).
+		Where("id = ?", account.ID).
+		Updates(map[string]any{"balance": account.Balance, "available_balance": account.AvailableBalance, "version": account.Version, "status": account.Status})
+	if result.Error != nil {
+		return gormError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r gormAccounts) SetNumber(ctx context.Context, id uuid.UUID, number string) error {
+	result := r.db.WithContext(ctx).Model(&models.Account{}).Where("id = ?", id).Update("number", number)
+	if result.Error != nil {
+		return gormError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r gormAccounts) SetVirtualIBAN(ctx context.Context, id uuid.UUID, iban string) error {
+	result := r.db.WithContext(ctx).Model(&models.Account{}).Where("id = ?", id).Update("virtual_iban", iban)
+	if result.Error != nil {
+		return gormError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type gormTransactions struct{ db *gorm.DB }
+
+func (r gormTransactions) Create(ctx context.Context, transaction *models.Transaction) error {
+	return gormError(r.db.WithContext(ctx).Create(transaction).Error)
+}
+
+func (r gormTransactions) GetByID(ctx context.Context, id uuid.UUID) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := r.db.WithContext(ctx).First(&transaction, "id = ?", id).Error; err != nil {
+		return nil, gormError(err)
+	}
+	return &transaction, nil
+}
+
+func (r gormTransactions) GetByRef(ctx context.Context, ref string) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := r.db.WithContext(ctx).First(&transaction, "ref = ?", ref).Error; err != nil {
+		return nil, gormError(err)
+	}
+	return &transaction, nil
+}
+
+func (r gormTransactions) ListByAccountID(ctx context.Context, accountID uuid.UUID) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	err := r.db.WithContext(ctx).
+		Where("account_id = ?", accountID).
+		Order(UTCTimestampSQL("created_at") + " ASC, id ASC").
+		Find(&transactions).Error
+	if err != nil {
+		return nil, gormError(err)
+	}
+	return transactions, nil
+}
+
+func (r gormTransactions) ListPostedBetween(ctx context.Context, accountID uuid.UUID, from, to time.Time) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	ts := UTCTimestampSQL("posted_at")
+	err := r.db.WithContext(ctx).
+		Where("account_id = ? AND "+ts+" >= ? AND "+ts+" < ?", accountID, UTCTimestamp(from), UTCTimestamp(to)).
+		Order(ts + " ASC, id ASC").
+		Find(&transactions).Error
+	if err != nil {
+		return nil, gormError(err)
+	}
+	return transactions, nil
+}
+
+func (r gormTransactions) ListPending(ctx context.Context, createdBefore time.Time, limit int) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	ts := UTCTimestampSQL("created_at")
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND "+ts+" < ?", models.TransactionPending, UTCTimestamp(createdBefore)).
+		Order(ts + " ASC, id ASC").
+		Limit(limit).
+		Find(&transactions).Error
+	if err != nil {
+		return nil, gormError(err)
+	}
+	return transactions, nil
+}
+
+func (r gormTransactions) Update(ctx context.Context, transaction *models.Transaction) error {
+	result := r.db.WithContext(ctx).Model(&models.Transaction{}).
+		Where("id = ?", transaction.ID).
+		Updates(map[string]any{"status": transaction.Status, "posted_at": transaction.PostedAt})
+	if result.Error != nil {
+		return gormError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r gormTransactions) ListPage(ctx context.Context, accountID uuid.UUID, filter TransactionFilter, after *TransactionKey, limit int) ([]models.Transaction, error) {
+	query := r.db.WithContext(ctx).Where("account_id = ?", accountID)
+	if filter.Query != "" {
+		// LIKE ignores the case of ASCII letters in SQLite
+		pattern := "%" + likeEscaper.Replace(filter.Query) + "%"
+		query = query.Where(`(ref LIKE @q ESCAPE '\' OR description LIKE @q ESCAPE '\' OR external_ref LIKE @q ESCAPE '\' OR merchant_name LIKE @q ESCAPE '\' OR metadata LIKE @q ESCAPE '\')`, sql.Named("q", pattern))
+	}
+	if filter.ExternalRef != "" {
+		query = query.Where("external_ref = ?", filter.ExternalRef)
+	}
+	if filter.MerchantCategoryCode != "" {
+		query = query.Where("merchant_category_code = ?", filter.MerchantCategoryCode)
+	}
+	if after != nil {
+		ts, createdAt := UTCTimestampSQL("created_at"), UTCTimestamp(after.CreatedAt)
+		query = query.Where(ts+" < ? OR ("+ts+" = ? AND id < ?)", createdAt, createdAt, after.ID.String())
+	}
+
+	var transactions []models.Transaction
+	err := query.Order(UTCTimestampSQL("created_at") + " DESC, id DESC").
+		Limit(limit).
+		Find(&transactions).Error
+	if err != nil {
+		return nil, gormError(err)
+	}
+	return transactions, nil
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern, for ESCAPE '\'.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+type gormEvents struct{ db *gorm.DB }
+
+func (r gormEvents) Stream(ctx context.Context, streamID uuid.UUID) ([]models.Event, error) {
+	var events []models.Event
+	if err := r.db.WithContext(ctx).Where("stream_id = ?", streamID).Order("version ASC").Find(&events).Error; err != nil {
+		return nil, gormError(err)
+	}
+	return events, nil
+}
+
+func (r gormEvents) Version(ctx context.Context, streamID uuid.UUID) (int, error) {
+	var version int
+	err := r.db.WithContext(ctx).Model(&models.Event{}).
+		Where("stream_id = ?", streamID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&version).Error
+	return version, gormError(err)
+}
+
+func (r gormEvents) Append(ctx context.Context, events []models.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return gormError(r.db.WithContext(ctx).Create(&events).Error)
+}
+
+type gormAudit struct{ db *gorm.DB }
+
+func (r gormAudit) Last(ctx context.Context) (*models.AuditEntry, error) {
+	var last []models.AuditEntry
+	if err := r.db.WithContext(ctx).Order("sequence DESC").Limit(1).Find(&last).Error; err != nil {
+		return nil, gormError(err)
+	}
+	if len(last) == 0 {
+		return nil, nil
+	}
+	return &last[0], nil
+}
+
+func (r gormAudit) Append(ctx context.Context, entry *models.AuditEntry) error {
+	return gormError(r.db.WithContext(ctx).Create(entry).Error)
+}
+
+type gormBatches struct{ db *gorm.DB }
+
+// batchChunkSize bounds the rows inserted, and the values looked up, per
+// statement so large batches stay under SQLite's variable limit.
+const batchChunkSize = 500
+
+func (r gormBatches) Create(ctx context.Context, batch *models.Batch) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Items").Create(batch).Error; err != nil {
+			return err
+		}
+		for i := range batch.Items {
+			batch.Items[i].BatchID = batch.ID
+		}
+		if len(batch.Items) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&batch.Items, batchChunkSize).Error
+	})
+	return gormError(err)
+}
+
+func (r gormBatches) GetByID(ctx context.Context, id uuid.UUID) (*models.Batch, error) {
+	var batch models.Batch
+	err := r.db.WithContext(ctx).
+		Preload("Items", func(db *gorm.DB) *gorm.DB { return db.Order("position ASC") }).
+		First(&batch, "id = ?", id).Error
+	if err != nil {
+		return nil, gormError(err)
+	}
+	return &batch, nil
+}
+
+func (r gormBatches) ListByStatus(ctx context.Context, statuses ...models.BatchStatus) ([]models.Batch, error) {
+	var batches []models.Batch
+	err := r.db.WithContext(ctx).Where("status IN ?", statuses).Order("created_at ASC").Find(&batches).Error
+	if err != nil {
+		return nil, gormError(err)
+	}
+	return batches, nil
+}
+
+func (r gormBatches) TakenReferences(ctx context.Context, references []string) ([]string, error) {
+	taken := []string{}
+	for chunk := range slices.Chunk(references, batchChunkSize) {
+		var found []string
+		err := r.db.WithContext(ctx).Model(&models.BatchItem{}).
+			Where("reference IN ?", chunk).
+			Pluck("reference", &found).Error
+		if err != nil {
+			return nil, gormError(err)
+		}
+		taken = append(taken, found...)
+	}
+	return taken, nil
+}
+
+func (r gormBatches) Update(ctx context.Context, batch *models.Batch) error {
+	result := r.db.WithContext(ctx).Model(&models.Batch{}).
+		Where("id = ?", batch.ID).
+		Updates(map[string]any{"status": batch.Status, "completed_at": batch.CompletedAt})
+	if result.Error != nil {
+		return gormError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r gormBatches) UpdateItem(ctx context.Context, item *models.BatchItem) error {
+	result := r.db.WithContext(ctx).Model(&models.BatchItem{}).
+		Where("id = ?", item.ID).
+		Updates(map[string]any{"status": item.Status, "transaction_ref": item.TransactionRef, "error": item.Error})
+	if result.Error != nil {
+		return gormError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type gormPayments struct{ db *gorm.DB }
+
+func (r gormPayments) Create(ctx context.Context, payment *models.Payment) error {
+	return gormError(r.db.WithContext(ctx).Create(payment).Error)
+}
+
+func (r gormPayments) GetByIntent(ctx context.Context, provider, intentID string) (*models.Payment, error) {
+	var payment models.Payment
+	if err := r.db.WithContext(ctx).First(&payment, "provider = ? AND intent_id = ?", provider, intentID).Error; err != nil {
+		return nil, gormError(err)
+	}
+	return &payment, nil
+}
+
+func (r gormPayments) GetByTransactionID(ctx context.Context, transactionID uuid.UUID) (*models.Payment, error) {
+	var payment models.Payment
+	if err := r.db.WithContext(ctx).First(&payment, "transaction_id = ?", transactionID).Error; err != nil {
+		return nil, gormError(err)
+	}
+	return &payment, nil
+}
+
+func (r gormPayments) Update(ctx context.Context, payment *models.Payment) error {
+	result := r.db.WithContext(ctx).Model(&models.Payment{}).
+		Where("id = ?", payment.ID).
+		Updates(map[string]any{"status": payment.Status, "failure_reason": payment.FailureReason, "refund_id": payment.RefundID})
+	if result.Error != nil {
+		return gormError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type gormBeneficiaries struct{ db *gorm.DB }
+
+func (r gormBeneficiaries) Create(ctx context.Context, beneficiary *models.Beneficiary) error {
+	return gormError(r.db.WithContext(ctx).Create(beneficiary).Error)
+}
+
+func (r gormBeneficiaries) GetByID(ctx context.Context, id uuid.UUID) (*models.Beneficiary, error) {
+	var beneficiary models.Beneficiary
+	if err := r.db.WithContext(ctx).First(&beneficiary, "id = ?", id).Error; err != nil {
+		return nil, gormError(err)
+	}
+	return &beneficiary, nil
+}
+
+func (r gormBeneficiaries) ListByAccountID(ctx context.Context, accountID uuid.UUID) ([]models.Beneficiary, error) {
+	var beneficiaries []models.Beneficiary
+	err := r.db.WithContext(ctx).Where("account_id = ?", accountID).Order("created_at ASC").Find(&beneficiaries).Error
+	if err != nil {
+		return nil, gormError(err)
+	}
+	return beneficiaries, nil
+}
+
+type gormWithdrawals struct{ db *gorm.DB }
+
+func (r gormWithdrawals) Create(ctx context.Context, withdrawal *models.Withdrawal) error {
+	return gormError(r.db.WithContext(ctx).Create(withdrawal).Error)
+}
+
+func (r gormWithdrawals) GetByID(ctx context.Context, id uuid.UUID) (*models.Withdrawal, error) {
+	return r.first(ctx, "id = ?", id)
+}
+
+func (r gormWithdrawals) GetByEndToEndID(ctx context.Context, endToEndID string) (*models.Withdrawal, error) {
+	return r.first(ctx, "end_to_end_id = ?", endToEndID)
+}
+
+func (r gormWithdrawals) GetByTraceNumber(ctx context.Context, traceNumber string) (*models.Withdrawal, error) {
+	return r.first(ctx, "trace_number = ?", traceNumber)
+}
+
+func (r gormWithdrawals) GetByTransactionID(ctx context.Context, transactionID uuid.UUID) (*models.Withdrawal, error) {
+	return r.first(ctx, "transaction_id = ?", transactionID)
+}
+
+func (r gormWithdrawals) first(ctx context.Context, query string, arg any) (*models.Withdrawal, error) {
+	var withdrawal models.Withdrawal
+	if err := r.db.WithContext(ctx).First(&withdrawal, query, arg).Error; err != nil {
+		return nil, gormError(err)
+	}
+	return &withdrawal, nil
+}
+
+func (r gormWithdrawals) ListByStatus(ctx context.Context, status models.WithdrawalStatus, limit int) ([]models.Withdrawal, error) {
+	var withdrawals []models.Withdrawal
+	err := r.db.WithContext(ctx).Where("status = ?", status).Order("created_at ASC").Limit(limit).Find(&withdrawals).Error
+	if err != nil {
+		return nil, gormError(err)
+	}
+	return withdrawals, nil
+}
+
+func (r gormWithdrawals) ListByPayoutID(ctx context.Context, payoutID uuid.UUID) ([]models.Withdrawal, error) {
+	var withdrawals []models.Withdrawal
+	err := r.db.WithContext(ctx).Where("payout_id = ?", payoutID).Order("created_at ASC").Find(&withdrawals).Error
+	if err != nil {
+		return nil, gormError(err)
+	}
+	return withdrawals, nil
+}
+
+func (r gormWithdrawals) Update(ctx context.Context, withdrawal *models.Withdrawal) error {
+	result := r.db.WithContext(ctx).Model(&models.Withdrawal{}).
+		Where("id = ?", withdrawal.ID).
+		Updates(map[string]any{
+			"status":        withdrawal.Status,
+			"payout_id":     withdrawal.PayoutID,
+			"trace_number":  withdrawal.TraceNumber,
+			"return_code":   withdrawal.ReturnCode,
+			"return_reason": withdrawal.ReturnReason,
+			"completed_at":  withdrawal.CompletedAt,
+		})
+	if result.Error != nil {
+		return gormError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r gormWithdrawals) CreatePayout(ctx context.Context, payout *models.Payout) error {
+	return gormError(r.db.WithContext(ctx).Create(payout).Error)
+}
+
+func (r gormWithdrawals) PayoutEntries(ctx context.Context, format models.PayoutFormat) (int, error) {
+	var entries int
+	err := r.db.WithContext(ctx).Model(&models.Payout{}).
+		Where("format = ?", format).
+		Select("COALESCE(SUM(count), 0)").
+		Scan(&entries).Error
+	return entries, gormError(err)
+}
+
+type gormDeposits struct{ db *gorm.DB }
+
+func (r gormDeposits) Create(ctx context.Context, deposit *models.Deposit) error {
+	return gormError(r.db.WithContext(ctx).Create(deposit).Error)
+}
+
+func (r gormDeposits) GetByID(ctx context.Context, id uuid.UUID) (*models.Deposit, error) {
+	var deposit models.Deposit
+	if err := r.db.WithContext(ctx).First(&deposit, "id = ?", id).Error; err != nil {
+		return nil, gormError(err)
+	}
+	return &deposit, nil
+}
+
+func (r gormDeposits) ListByStatus(ctx context.Context, status models.DepositStatus) ([]models.Deposit, error) {
+	var deposits []models.Deposit
+	err := r.db.WithContext(ctx).Where("status = ?", status).Order("created_at ASC").Find(&deposits).Error
+	if err != nil {
+		return nil, gormError(err)
+	}
+	return deposits, nil
+}
+
+func (r gormDeposits) Update(ctx context.Context, deposit *models.Deposit) error {
+	result := r.db.WithContext(ctx).Model(&models.Deposit{}).
+		Where("id = ?", deposit.ID).
+		Updates(map[string]any{
+			"status":         deposit.Status,
+			"account_id":     deposit.AccountID,
+			"transaction_id": deposit.TransactionID,
+			"reason":         deposit.Reason,
+		})
+	if result.Error != nil {
+		return gormError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type gormAdjustments struct{ db *gorm.DB }
+
+func (r gormAdjustments) Create(ctx context.Context, adjustment *models.Adjustment) error {
+	return gormError(r.db.WithContext(ctx).Create(adjustment).Error)
+}
+
+func (r gormAdjustments) GetByID(ctx context.Context, id uuid.UUID) (*models.Adjustment, error) {
+	var adjustment models.Adjustment
+	if err := r.db.WithContext(ctx).First(&adjustment, "id = ?", id).Error; err != nil {
+		return nil, gormError(err)
+	}
+	return &adjustment, nil
+}
+
+func (r gormAdjustments) ListByStatus(ctx context.Context, status models.AdjustmentStatus) ([]models.Adjustment, error) {
+	var adjustments []models.Adjustment
+	err := r.db.WithContext(ctx).Where("status = ?", status).Order("created_at ASC").Find(&adjustments).Error
+	if err != nil {
+		return nil, gormError(err)
+	}
+	return adjustments, nil
+}
+
+func (r gormAdjustments) Update(ctx context.Context, adjustment *models.Adjustment) error {
+	result := r.db.WithContext(ctx).Model(&models.Adjustment{}).
+		Where("id = ?", adjustment.ID).
+		Updates(map[string]any{
+			"status":         adjustment.Status,
+			"reviewed_by":    adjustment.ReviewedBy,
+			"review_note":    adjustment.ReviewNote,
+			"reviewed_at":    adjustment.ReviewedAt,
+			"transaction_id": adjustment.TransactionID,
+		})
+	if result.Error != nil {
+		return gormError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
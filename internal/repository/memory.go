@@ -0,0 +1,1110 @@
+package repository
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"wallet/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// memoryState is the content of an in-memory store. Records are kept
+// without their associations.
+type memoryState struct {
+	users         map[uuid.UUID]models.User
+	accounts      map[uuid.UUID]models.Account
+	transactions  map[uuid.UUID]models.Transaction
+	events        []models.Event
+	audit         []models.AuditEntry
+	batches       map[uuid.UUID]models.Batch
+	batchItems    map[uuid.UUID]models.BatchItem
+	payments      map[uuid.UUID]models.Payment
+	beneficiaries map[uuid.UUID]models.Beneficiary
+	withdrawals   map[uuid.UUID]models.Withdrawal
+	payouts       map[uuid.UUID]models.Payout
+	deposits      map[uuid.UUID]models.Deposit
+	adjustments   map[uuid.UUID]models.Adjustment
+}
+
+func (s *memoryState) clone() *memoryState {
+	return &memoryState{
+		users:         maps.Clone(s.users),
+		accounts:      maps.Clone(s.accounts),
+		transactions:  maps.Clone(s.transactions),
+		events:        slices.Clone(s.events),
+		audit:         slices.Clone(s.audit),
+		batches:       maps.Clone(s.batches),
+		batchItems:    maps.Clone(s.batchItems),
+		payments:      maps.Clone(s.payments),
+		beneficiaries: maps.Clone(s.beneficiaries),
+		withdrawals:   maps.Clone(s.withdrawals),
+		payouts:       maps.Clone(s.payouts),
+		deposits:      maps.Clone(s.deposits),
+		adjustments:   maps.Clone(s.adjustments),
+	}
+}
+
+// memoryDB is the committed state shared by a memory store and its
+// transactions.
+type memoryDB struct {
+	// writeMu serialises writers, like SQLite's database lock
+	writeMu sync.Mutex
+	mu      sync.RWMutex
+	state   *memoryState
+}
+
+type memoryStore struct {
+	db *memoryDB
+	// tx is the working copy of a transaction, nil outside of one
+	tx *memoryState
+}
+
+// NewMemoryStore returns an empty, thread-safe Store that keeps its records
+// in memory. Transactions work on a copy of the data that replaces it on
+// commit, so readers never see uncommitted changes and a failed transaction
+// leaves no trace. Writers are serialised.
+func NewMemoryStore() Store {
+	return &memoryStore{db: &memoryDB{state: &memoryState{
+		users:         map[uuid.UUID]models.User{},
+		accounts:      map[uuid.UUID]models.Account{},
+		transactions:  map[uuid.UUID]models.Transaction{},
+		batches:       map[uuid.UUID]models.Batch{},
+		batchItems:    map[uuid.UUID]models.BatchItem{},
+		payments:      map[uuid.UUID]models.Payment{},
+		beneficiaries: map[uuid.UUID]models.Beneficiary{},
+		withdrawals:   map[uuid.UUID]models.Withdrawal{},
+		payouts:       map[uuid.UUID]models.Payout{},
+		deposits:      map[uuid.UUID]models.Deposit{},
+		adjustments:   map[uuid.UUID]models.Adjustment{},
+	}}}
+}
+
+func (s *memoryStore) Users() UserRepository                { return memoryUsers{s} }
+func (s *memoryStore) Accounts() AccountRepository          { return memoryAccounts{s} }
+func (s *memoryStore) Transactions() TransactionRepository  { return memoryTransactions{s} }
+func (s *memoryStore) Events() EventRepository              { return memoryEvents{s} }
+func (s *memoryStore) Audit() AuditRepository               { return memoryAudit{s} }
+func (s *memoryStore) Batches() BatchRepository             { return memoryBatches{s} }
+func (s *memoryStore) Payments() PaymentRepository          { return memoryPayments{s} }
+func (s *memoryStore) Beneficiaries() BeneficiaryRepository { return memoryBeneficiaries{s} }
+func (s *memoryStore) Withdrawals() WithdrawalRepository    { return memoryWithdrawals{s} }
+func (s *memoryStore) Deposits() DepositRepository          { return memoryDeposits{s} }
+func (s *memoryStore) Adjustments() AdjustmentRepository    { return memoryAdjustments{s} }
+
+func (s *memoryStore) Transaction(ctx context.Context, fn func(tx Store) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// A nested transaction rolls back to the state it started from
+	if s.tx != nil {
+		savepoint := s.tx.clone()
+		if err := fn(s); err != nil {
+			*s.tx = *savepoint
+			return err
+		}
+		return nil
+	}
+
+	s.db.writeMu.Lock()
+	defer s.db.writeMu.Unlock()
+
+	s.db.mu.RLock()
+	work := s.db.state.clone()
+	s.db.mu.RUnlock()
+
+	if err := fn(&memoryStore{db: s.db, tx: work}); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.db.mu.Lock()
+	s.db.state = work
+	s.db.mu.Unlock()
+	return nil
+}
+
+// read runs fn against the transaction's working copy or the committed state.
+func (s *memoryStore) read(ctx context.Context, fn func(state *memoryState) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	return fn(s.db.state)
+}
+
+// write runs fn against the transaction's working copy, or commits its
+// change right away outside of a transaction. fn must not change the state
+// when it fails.
+func (s *memoryStore) write(ctx context.Context, fn func(state *memoryState) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.tx != nil {
+		return fn(s.tx)
+	}
+	s.db.writeMu.Lock()
+	defer s.db.writeMu.Unlock()
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	return fn(s.db.state)
+}
+
+type memoryUsers struct{ s *memoryStore }
+
+func (r memoryUsers) Create(ctx context.Context, user *models.User) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		for _, u := range state.users {
+			if u.Email == user.Email {
+				return ErrDuplicate
+			}
+		}
+		// Run the hook gorm would, to fill in the ID and timestamps
+		if err := user.BeforeCreate(nil); err != nil {
+			return err
+		}
+		stored := *user
+		stored.Accounts = nil
+		state.users[user.ID] = stored
+		return nil
+	})
+}
+
+func (r memoryUsers) GetByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	var user *models.User
+	err := r.s.read(ctx, func(state *memoryState) error {
+		u, ok := state.users[id]
+		if !ok {
+			return ErrNotFound
+		}
+		user = &u
+		return nil
+	})
+	return user, err
+}
+
+func (r memoryUsers) GetByEmail(ctx context.Context, email string) (*models.User, error) {
+	var user *models.User
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, u := range state.users {
+			if u.Email == email {
+				user = &u
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	return user, err
+}
+
+type memoryAccounts struct{ s *memoryStore }
+
+func (r memoryAccounts) Create(ctx context.Context, account *models.Account) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		if _, ok := state.accounts[account.ID]; ok && account.ID != uuid.Nil {
+			return ErrDuplicate
+		}
+		if account.Number != nil && hasNumber(state, uuid.Nil, *account.Number) {
+			return ErrDuplicate
+		}
+		if account.VirtualIBAN != nil && hasVirtualIBAN(state, uuid.Nil, *account.VirtualIBAN) {
+			return ErrDuplicate
+		}
+		if err := account.BeforeCreate(nil); err != nil {
+			return err
+		}
+		stored := *account
+		stored.User = models.User{}
+		state.accounts[account.ID] = stored
+		return nil
+	})
+}
+
+func (r memoryAccounts) GetByID(ctx context.Context, id uuid.UUID) (*models.Account, error) {
+	var account *models.Account
+	err := r.s.read(ctx, func(state *memoryState) error {
+		a, ok := state.accounts[id]
+		if !ok {
+			return ErrNotFound
+		}
+		account = &a
+		return nil
+	})
+	return account, err
+}
+
+func (r memoryAccounts) GetByNumber(ctx context.Context, number string) (*models.Account, error) {
+	var account *models.Account
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, a := range state.accounts {
+			if a.Number != nil && *a.Number == number {
+				account = &a
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	return account, err
+}
+
+func (r memoryAccounts) GetByVirtualIBAN(ctx context.Context, iban string) (*models.Account, error) {
+	var account *models.Account
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, a := range state.accounts {
+			if a.VirtualIBAN != nil && *a.VirtualIBAN == iban {
+				account = &a
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	return account, err
+}
+
+func (r memoryAccounts) ListByUserID(ctx context.Context, userID uuid.UUID) ([]models.Account, error) {
+	accounts := []models.Account{}
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, a := range state.accounts {
+			if a.UserID == userID {
+				accounts = append(accounts, a)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(accounts, func(a, b models.Account) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return accounts, err
+}
+
+func (r memoryAccounts) ListWithoutNumber(ctx context.Context, limit int) ([]models.Account, error) {
+	accounts := []models.Account{}
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, a := range state.accounts {
+			if a.Number == nil {
+				accounts = append(accounts, a)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(accounts, func(a, b models.Account) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return accounts[:min(limit, len(accounts))], err
+}
+
+func (r memoryAccounts) ListWithoutVirtualIBAN(ctx context.Context, limit int) ([]models.Account, error) {
+	accounts := []models.Account{}
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, a := range state.accounts {
+			if a.VirtualIBAN == nil {
+				accounts = append(accounts, a)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(accounts, func(a, b models.Account) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return accounts[:min(limit, len(accounts))], err
+}
+
+func (r memoryAccounts) Update(ctx context.Context, account *models.Account) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		stored, ok := state.accounts[account.ID]
+		if !ok {
+			return ErrNotFound
+		}
+		stored.Balance = account.Balance
+		stored.AvailableBalance = account.AvailableBalance
+		stored.Version = account.Version
+		stored.Status = account.Status
+		stored.UpdatedAt = time.Now()
+		state.accounts[account.ID] = stored
+		return nil
+	})
+}
+
+func (r memoryAccounts) SetNumber(ctx context.Context, id uuid.UUID, number string) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		stored, ok := state.accounts[id]
+		if !ok {
+			return ErrNotFound
+		}
+		if hasNumber(state, id, number) {
+			return ErrDuplicate
+		}
+		stored.Number = &number
+		stored.UpdatedAt = time.Now()
+		state.accounts[id] = stored
+		return nil
+	})
+}
+
+func (r memoryAccounts) SetVirtualIBAN(ctx context.Context, id uuid.UUID, iban string) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		stored, ok := state.accounts[id]
+		if !ok {
+			return ErrNotFound
+		}
+		if hasVirtualIBAN(state, id, iban) {
+			return ErrDuplicate
+		}
+		stored.VirtualIBAN = &iban
+		stored.UpdatedAt = time.Now()
+		state.accounts[id] = stored
+		return nil
+	})
+}
+
+// hasNumber reports whether an account other than except has the given
+// account number.
+func hasNumber(state *memoryState, except uuid.UUID, number string) bool {
+	for id, a := range state.accounts {
+		if id != except && a.Number != nil && *a.Number == number {
+			return true
+		}
+	}
+	return false
+}
+
+// hasVirtualIBAN reports whether an account other than except has the given
+// virtual IBAN.
+func hasVirtualIBAN(state *memoryState, except uuid.UUID, iban string) bool {
+	for id, a := range state.accounts {
+		if id != except && a.VirtualIBAN != nil && *a.VirtualIBAN == iban {
+			return true
+		}
+	}
+	return false
+}
+
+type memoryTransactions struct{ s *memoryStore }
+
+func (r memoryTransactions) Create(ctx context.Context, transaction *models.Transaction) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		if _, ok := state.transactions[transaction.ID]; ok && transaction.ID != uuid.Nil {
+			return ErrDuplicate
+		}
+		for _, t := range state.transactions {
+			if t.Ref == transaction.Ref {
+				return ErrDuplicate
+			}
+		}
+		if err := transaction.BeforeCreate(nil); err != nil {
+			return err
+		}
+		stored := *transaction
+		stored.Account = models.Account{}
+		state.transactions[transaction.ID] = stored
+		return nil
+	})
+}
+
+func (r memoryTransactions) GetByID(ctx context.Context, id uuid.UUID) (*models.Transaction, error) {
+	var transaction *models.Transaction
+	err := r.s.read(ctx, func(state *memoryState) error {
+		t, ok := state.transactions[id]
+		if !ok {
+			return ErrNotFound
+		}
+		transaction = &t
+		return nil
+	})
+	return transaction, err
+}
+
+func (r memoryTransactions) GetByRef(ctx context.Context, ref string) (*models.Transaction, error) {
+	var transaction *models.Transaction
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, t := range state.transactions {
+			if t.Ref == ref {
+				transaction = &t
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	return transaction, err
+}
+
+// compareTransactions orders transactions like the database does: by
+// creation time to the millisecond, then by ID.
+func compareTransactions(a, b TransactionKey) int {
+	if c := a.CreatedAt.Truncate(time.Millisecond).Compare(b.CreatedAt.Truncate(time.Millisecond)); c != 0 {
+		return c
+	}
+	return strings.Compare(a.ID.String(), b.ID.String())
+}
+
+func transactionKey(t models.Transaction) TransactionKey {
+	return TransactionKey{CreatedAt: t.CreatedAt, ID: t.ID}
+}
+
+func (r memoryTransactions) ListByAccountID(ctx context.Context, accountID uuid.UUID) ([]models.Transaction, error) {
+	transactions := []models.Transaction{}
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, t := range state.transactions {
+			if t.AccountID == accountID {
+				transactions = append(transactions, t)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(transactions, func(a, b models.Transaction) int {
+		return compareTransactions(transactionKey(a), transactionKey(b))
+	})
+	return transactions, err
+}
+
+func (r memoryTransactions) ListPostedBetween(ctx context.Context, accountID uuid.UUID, from, to time.Time) ([]models.Transaction, error) {
+	all, err := r.ListByAccountID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := []models.Transaction{}
+	for _, t := range all {
+		if t.PostedAt == nil {
+			continue
+		}
+		postedAt := t.PostedAt.Truncate(time.Millisecond)
+		if !postedAt.Before(from.Truncate(time.Millisecond)) && postedAt.Before(to.Truncate(time.Millisecond)) {
+			transactions = append(transactions, t)
+		}
+	}
+	slices.SortStableFunc(transactions, func(a, b models.Transaction) int {
+		return compareTransactions(TransactionKey{CreatedAt: *a.PostedAt, ID: a.ID}, TransactionKey{CreatedAt: *b.PostedAt, ID: b.ID})
+	})
+	return transactions, nil
+}
+
+func (r memoryTransactions) ListPending(ctx context.Context, createdBefore time.Time, limit int) ([]models.Transaction, error) {
+	transactions := []models.Transaction{}
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, t := range state.transactions {
+			if t.Status == models.TransactionPending && t.CreatedAt.Truncate(time.Millisecond).Before(createdBefore.Truncate(time.Millisecond)) {
+				transactions = append(transactions, t)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(transactions, func(a, b models.Transaction) int {
+		return compareTransactions(transactionKey(a), transactionKey(b))
+	})
+	return transactions[:min(limit, len(transactions))], err
+}
+
+func (r memoryTransactions) Update(ctx context.Context, transaction *models.Transaction) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		stored, ok := state.transactions[transaction.ID]
+		if !ok {
+			return ErrNotFound
+		}
+		stored.Status = transaction.Status
+		stored.PostedAt = transaction.PostedAt
+		stored.UpdatedAt = time.Now()
+		state.transactions[transaction.ID] = stored
+		return nil
+	})
+}
+
+func (r memoryTransactions) ListPage(ctx context.Context, accountID uuid.UUID, filter TransactionFilter, after *TransactionKey, limit int) ([]models.Transaction, error) {
+	all, err := r.ListByAccountID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(all)
+
+	page := []models.Transaction{}
+	for _, t := range all {
+		if len(page) == limit {
+			break
+		}
+		if (after == nil || compareTransactions(transactionKey(t), *after) < 0) && matchesFilter(t, filter) {
+			page = append(page, t)
+		}
+	}
+	return page, nil
+}
+
+func matchesFilter(t models.Transaction, filter TransactionFilter) bool {
+	if filter.ExternalRef != "" && t.ExternalRef != filter.ExternalRef {
+		return false
+	}
+	if filter.MerchantCategoryCode != "" && t.MerchantCategoryCode != filter.MerchantCategoryCode {
+		return false
+	}
+	if filter.Query == "" {
+		return true
+	}
+	query := strings.ToLower(filter.Query)
+	for _, field := range []string{t.Ref, t.Description, t.ExternalRef, t.MerchantName, string(t.Metadata)} {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
+}
+
+type memoryEvents struct{ s *memoryStore }
+
+func (r memoryEvents) Stream(ctx context.Context, streamID uuid.UUID) ([]models.Event, error) {
+	events := []models.Event{}
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, e := range state.events {
+			if e.StreamID == streamID {
+				events = append(events, e)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(events, func(a, b models.Event) int { return a.Version - b.Version })
+	return events, err
+}
+
+func (r memoryEvents) Version(ctx context.Context, streamID uuid.UUID) (int, error) {
+	var version int
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, e := range state.events {
+			if e.StreamID == streamID {
+				version = max(version, e.Version)
+			}
+		}
+		return nil
+	})
+	return version, err
+}
+
+func (r memoryEvents) Append(ctx context.Context, events []models.Event) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		taken := map[uuid.UUID]map[int]bool{}
+		for _, e := range state.events {
+			if taken[e.StreamID] == nil {
+				taken[e.StreamID] = map[int]bool{}
+			}
+			taken[e.StreamID][e.Version] = true
+		}
+		for _, e := range events {
+			if taken[e.StreamID][e.Version] {
+				return ErrDuplicate
+			}
+			if taken[e.StreamID] == nil {
+				taken[e.StreamID] = map[int]bool{}
+			}
+			taken[e.StreamID][e.Version] = true
+		}
+		for i := range events {
+			events[i].Position = uint64(len(state.events) + 1)
+			state.events = append(state.events, events[i])
+		}
+		return nil
+	})
+}
+
+type memoryAudit struct{ s *memoryStore }
+
+func (r memoryAudit) Last(ctx context.Context) (*models.AuditEntry, error) {
+	var last *models.AuditEntry
+	err := r.s.read(ctx, func(state *memoryState) error {
+		if n := len(state.audit); n > 0 {
+			entry := state.audit[n-1]
+			last = &entry
+		}
+		return nil
+	})
+	return last, err
+}
+
+func (r memoryAudit) Append(ctx context.Context, entry *models.AuditEntry) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		for _, e := range state.audit {
+			if e.Sequence == entry.Sequence || e.Hash == entry.Hash {
+				return ErrDuplicate
+			}
+		}
+		state.audit = append(state.audit, *entry)
+		return nil
+	})
+}
+
+type memoryBatches struct{ s *memoryStore }
+
+func (r memoryBatches) Create(ctx context.Context, batch *models.Batch) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		if _, ok := state.batches[batch.ID]; ok && batch.ID != uuid.Nil {
+			return ErrDuplicate
+		}
+		references := map[string]bool{}
+		for _, item := range state.batchItems {
+			references[item.Reference] = true
+		}
+		for _, item := range batch.Items {
+			if references[item.Reference] {
+				return ErrDuplicate
+			}
+			references[item.Reference] = true
+		}
+
+		if err := batch.BeforeCreate(nil); err != nil {
+			return err
+		}
+		for i := range batch.Items {
+			item := &batch.Items[i]
+			item.BatchID = batch.ID
+			if err := item.BeforeCreate(nil); err != nil {
+				return err
+			}
+			state.batchItems[item.ID] = *item
+		}
+		stored := *batch
+		stored.Items = nil
+		state.batches[batch.ID] = stored
+		return nil
+	})
+}
+
+func (r memoryBatches) GetByID(ctx context.Context, id uuid.UUID) (*models.Batch, error) {
+	var batch *models.Batch
+	err := r.s.read(ctx, func(state *memoryState) error {
+		b, ok := state.batches[id]
+		if !ok {
+			return ErrNotFound
+		}
+		for _, item := range state.batchItems {
+			if item.BatchID == id {
+				b.Items = append(b.Items, item)
+			}
+		}
+		slices.SortFunc(b.Items, func(a, b models.BatchItem) int { return a.Position - b.Position })
+		batch = &b
+		return nil
+	})
+	return batch, err
+}
+
+func (r memoryBatches) ListByStatus(ctx context.Context, statuses ...models.BatchStatus) ([]models.Batch, error) {
+	batches := []models.Batch{}
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, b := range state.batches {
+			if slices.Contains(statuses, b.Status) {
+				batches = append(batches, b)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(batches, func(a, b models.Batch) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return batches, err
+}
+
+func (r memoryBatches) TakenReferences(ctx context.Context, references []string) ([]string, error) {
+	taken := []string{}
+	err := r.s.read(ctx, func(state *memoryState) error {
+		wanted := map[string]bool{}
+		for _, reference := range references {
+			wanted[reference] = true
+		}
+		for _, item := range state.batchItems {
+			if wanted[item.Reference] {
+				taken = append(taken, item.Reference)
+			}
+		}
+		return nil
+	})
+	return taken, err
+}
+
+func (r memoryBatches) Update(ctx context.Context, batch *models.Batch) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		stored, ok := state.batches[batch.ID]
+		if !ok {
+			return ErrNotFound
+		}
+		stored.Status = batch.Status
+		stored.CompletedAt = batch.CompletedAt
+		stored.UpdatedAt = time.Now()
+		state.batches[batch.ID] = stored
+		return nil
+	})
+}
+
+func (r memoryBatches) UpdateItem(ctx context.Context, item *models.BatchItem) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		stored, ok := state.batchItems[item.ID]
+		if !ok {
+			return ErrNotFound
+		}
+		stored.Status = item.Status
+		stored.TransactionRef = item.TransactionRef
+		stored.Error = item.Error
+		stored.UpdatedAt = time.Now()
+		state.batchItems[item.ID] = stored
+		return nil
+	})
+}
+
+type memoryPayments struct{ s *memoryStore }
+
+func (r memoryPayments) Create(ctx context.Context, payment *models.Payment) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		for _, p := range state.payments {
+			if p.ID == payment.ID || p.TransactionID == payment.TransactionID || (p.Provider == payment.Provider && p.IntentID == payment.IntentID) {
+				return ErrDuplicate
+			}
+		}
+		if err := payment.BeforeCreate(nil); err != nil {
+			return err
+		}
+		state.payments[payment.ID] = *payment
+		return nil
+	})
+}
+
+func (r memoryPayments) GetByIntent(ctx context.Context, provider, intentID string) (*models.Payment, error) {
+	return r.find(ctx, func(p models.Payment) bool { return p.Provider == provider && p.IntentID == intentID })
+}
+
+func (r memoryPayments) GetByTransactionID(ctx context.Context, transactionID uuid.UUID) (*models.Payment, error) {
+	return r.find(ctx, func(p models.Payment) bool { return p.TransactionID == transactionID })
+}
+
+func (r memoryPayments) find(ctx context.Context, match func(models.Payment) bool) (*models.Payment, error) {
+	var payment *models.Payment
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, p := range state.payments {
+			if match(p) {
+				payment = &p
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	return payment, err
+}
+
+func (r memoryPayments) Update(ctx context.Context, payment *models.Payment) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		stored, ok := state.payments[payment.ID]
+		if !ok {
+			return ErrNotFound
+		}
+		stored.Status = payment.Status
+		stored.FailureReason = payment.FailureReason
+		stored.RefundID = payment.RefundID
+		stored.UpdatedAt = time.Now()
+		state.payments[payment.ID] = stored
+		return nil
+	})
+}
+
+type memoryBeneficiaries struct{ s *memoryStore }
+
+func (r memoryBeneficiaries) Create(ctx context.Context, beneficiary *models.Beneficiary) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		if _, ok := state.beneficiaries[beneficiary.ID]; ok && beneficiary.ID != uuid.Nil {
+			return ErrDuplicate
+		}
+		if err := beneficiary.BeforeCreate(nil); err != nil {
+			return err
+		}
+		state.beneficiaries[beneficiary.ID] = *beneficiary
+		return nil
+	})
+}
+
+func (r memoryBeneficiaries) GetByID(ctx context.Context, id uuid.UUID) (*models.Beneficiary, error) {
+	var beneficiary *models.Beneficiary
+	err := r.s.read(ctx, func(state *memoryState) error {
+		b, ok := state.beneficiaries[id]
+		if !ok {
+			return ErrNotFound
+		}
+		beneficiary = &b
+		return nil
+	})
+	return beneficiary, err
+}
+
+func (r memoryBeneficiaries) ListByAccountID(ctx context.Context, accountID uuid.UUID) ([]models.Beneficiary, error) {
+	beneficiaries := []models.Beneficiary{}
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, b := range state.beneficiaries {
+			if b.AccountID == accountID {
+				beneficiaries = append(beneficiaries, b)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(beneficiaries, func(a, b models.Beneficiary) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return beneficiaries, err
+}
+
+type memoryWithdrawals struct{ s *memoryStore }
+
+func (r memoryWithdrawals) Create(ctx context.Context, withdrawal *models.Withdrawal) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		if err := withdrawal.BeforeCreate(nil); err != nil {
+			return err
+		}
+		for _, w := range state.withdrawals {
+			if w.ID == withdrawal.ID || w.TransactionID == withdrawal.TransactionID || w.EndToEndID == withdrawal.EndToEndID {
+				return ErrDuplicate
+			}
+		}
+		state.withdrawals[withdrawal.ID] = *withdrawal
+		return nil
+	})
+}
+
+func (r memoryWithdrawals) GetByID(ctx context.Context, id uuid.UUID) (*models.Withdrawal, error) {
+	return r.find(ctx, func(w models.Withdrawal) bool { return w.ID == id })
+}
+
+func (r memoryWithdrawals) GetByEndToEndID(ctx context.Context, endToEndID string) (*models.Withdrawal, error) {
+	return r.find(ctx, func(w models.Withdrawal) bool { return w.EndToEndID == endToEndID })
+}
+
+func (r memoryWithdrawals) GetByTraceNumber(ctx context.Context, traceNumber string) (*models.Withdrawal, error) {
+	return r.find(ctx, func(w models.Withdrawal) bool { return w.TraceNumber != nil && *w.TraceNumber == traceNumber })
+}
+
+func (r memoryWithdrawals) GetByTransactionID(ctx context.Context, transactionID uuid.UUID) (*models.Withdrawal, error) {
+	return r.find(ctx, func(w models.Withdrawal) bool { return w.TransactionID == transactionID })
+}
+
+func (r memoryWithdrawals) find(ctx context.Context, match func(models.Withdrawal) bool) (*models.Withdrawal, error) {
+	var withdrawal *models.Withdrawal
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, w := range state.withdrawals {
+			if match(w) {
+				withdrawal = &w
+				return nil
+			}
+		}
+		return ErrNotFound
+	})
+	return withdrawal, err
+}
+
+func (r memoryWithdrawals) ListByStatus(ctx context.Context, status models.WithdrawalStatus, limit int) ([]models.Withdrawal, error) {
+	withdrawals, err := r.list(ctx, func(w models.Withdrawal) bool { return w.Status == status })
+	if len(withdrawals) > limit {
+		withdrawals = withdrawals[:limit]
+	}
+	return withdrawals, err
+}
+
+func (r memoryWithdrawals) ListByPayoutID(ctx context.Context, payoutID uuid.UUID) ([]models.Withdrawal, error) {
+	return r.list(ctx, func(w models.Withdrawal) bool { return w.PayoutID != nil && *w.PayoutID == payoutID })
+}
+
+// list returns the matching withdrawals, oldest first.
+func (r memoryWithdrawals) list(ctx context.Context, match func(models.Withdrawal) bool) ([]models.Withdrawal, error) {
+	withdrawals := []models.Withdrawal{}
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, w := range state.withdrawals {
+			if match(w) {
+				withdrawals = append(withdrawals, w)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(withdrawals, func(a, b models.Withdrawal) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return withdrawals, err
+}
+
+func (r memoryWithdrawals) Update(ctx context.Context, withdrawal *models.Withdrawal) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		stored, ok := state.withdrawals[withdrawal.ID]
+		if !ok {
+			return ErrNotFound
+		}
+		if withdrawal.TraceNumber != nil {
+			for _, w := range state.withdrawals {
+				if w.ID != withdrawal.ID && w.TraceNumber != nil && *w.TraceNumber == *withdrawal.TraceNumber {
+					return ErrDuplicate
+				}
+			}
+		}
+		stored.Status = withdrawal.Status
+		stored.PayoutID = withdrawal.PayoutID
+		stored.TraceNumber = withdrawal.TraceNumber
+		stored.ReturnCode = withdrawal.ReturnCode
+		stored.ReturnReason = withdrawal.ReturnReason
+		stored.CompletedAt = withdrawal.CompletedAt
+		stored.UpdatedAt = time.Now()
+		state.withdrawals[withdrawal.ID] = stored
+		return nil
+	})
+}
+
+func (r memoryWithdrawals) CreatePayout(ctx context.Context, payout *models.Payout) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		for _, p := range state.payouts {
+			if p.ID == payout.ID || p.MessageID == payout.MessageID {
+				return ErrDuplicate
+			}
+		}
+		if err := payout.BeforeCreate(nil); err != nil {
+			return err
+		}
+		state.payouts[payout.ID] = *payout
+		return nil
+	})
+}
+
+func (r memoryWithdrawals) PayoutEntries(ctx context.Context, format models.PayoutFormat) (int, error) {
+	entries := 0
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, p := range state.payouts {
+			if p.Format == format {
+				entries += p.Count
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+type memoryDeposits struct{ s *memoryStore }
+
+func (r memoryDeposits) Create(ctx context.Context, deposit *models.Deposit) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		for _, d := range state.deposits {
+			if d.ID == deposit.ID || d.Reference == deposit.Reference ||
+				(deposit.TransactionID != nil && d.TransactionID != nil && *d.TransactionID == *deposit.TransactionID) {
+				return ErrDuplicate
+			}
+		}
+		if err := deposit.BeforeCreate(nil); err != nil {
+			return err
+		}
+		state.deposits[deposit.ID] = *deposit
+		return nil
+	})
+}
+
+func (r memoryDeposits) GetByID(ctx context.Context, id uuid.UUID) (*models.Deposit, error) {
+	var deposit *models.Deposit
+	err := r.s.read(ctx, func(state *memoryState) error {
+		d, ok := state.deposits[id]
+		if !ok {
+			return ErrNotFound
+		}
+		deposit = &d
+		return nil
+	})
+	return deposit, err
+}
+
+func (r memoryDeposits) ListByStatus(ctx context.Context, status models.DepositStatus) ([]models.Deposit, error) {
+	deposits := []models.Deposit{}
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, d := range state.deposits {
+			if d.Status == status {
+				deposits = append(deposits, d)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(deposits, func(a, b models.Deposit) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return deposits, err
+}
+
+func (r memoryDeposits) Update(ctx context.Context, deposit *models.Deposit) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		stored, ok := state.deposits[deposit.ID]
+		if !ok {
+			return ErrNotFound
+		}
+		stored.Status = deposit.Status
+		stored.AccountID = deposit.AccountID
+		stored.TransactionID = deposit.TransactionID
+		stored.Reason = deposit.Reason
+		stored.UpdatedAt = time.Now()
+		state.deposits[deposit.ID] = stored
+		return nil
+	})
+}
+
+type memoryAdjustments struct{ s *memoryStore }
+
+func (r memoryAdjustments) Create(ctx context.Context, adjustment *models.Adjustment) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		for _, a := range state.adjustments {
+			if a.ID == adjustment.ID ||
+				(adjustment.TransactionID != nil && a.TransactionID != nil && *a.TransactionID == *adjustment.TransactionID) {
+				return ErrDuplicate
+			}
+		}
+		if err := adjustment.BeforeCreate(nil); err != nil {
+			return err
+		}
+		state.adjustments[adjustment.ID] = *adjustment
+		return nil
+	})
+}
+
+func (r memoryAdjustments) GetByID(ctx context.Context, id uuid.UUID) (*models.Adjustment, error) {
+	var adjustment *models.Adjustment
+	err := r.s.read(ctx, func(state *memoryState) error {
+		a, ok := state.adjustments[id]
+		if !ok {
+			return ErrNotFound
+		}
+		adjustment = &a
+		return nil
+	})
+	return adjustment, err
+}
+
+func (r memoryAdjustments) ListByStatus(ctx context.Context, status models.AdjustmentStatus) ([]models.Adjustment, error) {
+	adjustments := []models.Adjustment{}
+	err := r.s.read(ctx, func(state *memoryState) error {
+		for _, a := range state.adjustments {
+			if a.Status == status {
+				adjustments = append(adjustments, a)
+			}
+		}
+		return nil
+	})
+	slices.SortFunc(adjustments, func(a, b models.Adjustment) int {
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return adjustments, err
+}
+
+func (r memoryAdjustments) Update(ctx context.Context, adjustment *models.Adjustment) error {
+	return r.s.write(ctx, func(state *memoryState) error {
+		stored, ok := state.adjustments[adjustment.ID]
+		if !ok {
+			return ErrNotFound
+		}
+		stored.Status = adjustment.Status
+		stored.ReviewedBy = adjustment.ReviewedBy
+		stored.ReviewNote = adjustment.ReviewNote
+		stored.ReviewedAt = adjustment.ReviewedAt
+		stored.TransactionID = adjustment.TransactionID
+		stored.UpdatedAt = time.Now()
+		state.adjustments[adjustment.ID] = stored
+		return nil
+	})
+}
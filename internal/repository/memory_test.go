@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"wallet/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func newAccount(t *testing.T, store Store) *models.Account {
+	t.Helper()
+	account := &models.Account{UserID: uuid.New()}
+	if err := store.Accounts().Create(context.Background(), account); err != nil {
+		t.Fatal(err)
+	}
+	return account
+}
+
+func TestMemoryTransactionCommits(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	account := newAccount(t, store)
+
+	err := store.Transaction(ctx, func(tx Store) error {
+		account.Balance = 10
+		return tx.Accounts().Update(ctx, account)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.Accounts().GetByID(ctx, account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Balance != 10 {
+		t.Errorf("balance after commit: got %v want %v", got.Balance, 10)
+	}
+}
+
+func TestMemoryTransactionRollsBack(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	account := newAccount(t, store)
+	errFailed := errors.New("failed")
+
+	err := store.Transaction(ctx, func(tx Store) error {
+		if err := tx.Users().Create(ctx, &models.User{Email: "jane@example.com"}); err != nil {
+			return err
+		}
+		account.Balance = 10
+		if err := tx.Accounts().Update(ctx, account); err != nil {
+			return err
+		}
+
+		// Changes are visible inside the transaction only
+		inside, _ := tx.Accounts().GetByID(ctx, account.ID)
+		outside, _ := store.Accounts().GetByID(ctx, account.ID)
+		if inside.Balance != 10 || outside.Balance != 0 {
+			t.Errorf("balance inside/outside the transaction: got %v/%v want 10/0", inside.Balance, outside.Balance)
+		}
+		return errFailed
+	})
+	if !errors.Is(err, errFailed) {
+		t.Fatalf("got error %v want %v", err, errFailed)
+	}
+
+	got, _ := store.Accounts().GetByID(ctx, account.ID)
+	if got.Balance != 0 {
+		t.Errorf("balance after rollback: got %v want 0", got.Balance)
+	}
+	if _, err := store.Users().GetByEmail(ctx, "jane@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("user after rollback: got error %v want %v", err, ErrNotFound)
+	}
+}
+
+func TestMemoryNestedTransactionRollsBackToSavepoint(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	account := newAccount(t, store)
+
+	err := store.Transaction(ctx, func(tx Store) error {
+		account.Balance = 5
+		if err := tx.Accounts().Update(ctx, account); err != nil {
+			return err
+		}
+		nestedErr := tx.Transaction(ctx, func(nested Store) error {
+			account.Balance = 50
+			if err := nested.Accounts().Update(ctx, account); err != nil {
+				return err
+			}
+			return errors.New("failed")
+		})
+		if nestedErr == nil {
+			t.Error("nested transaction: got nil error")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := store.Accounts().GetByID(ctx, account.ID)
+	if got.Balance != 5 {
+		t.Errorf("balance: got %v want %v", got.Balance, 5)
+	}
+}
+
+func TestMemoryTransactionsAreSerialised(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	account := newAccount(t, store)
+
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.Transaction(ctx, func(tx Store) error {
+				current, err := tx.Accounts().GetByID(ctx, account.ID)
+				if err != nil {
+					return err
+				}
+				current.Balance++
+				return tx.Accounts().Update(ctx, current)
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, _ := store.Accounts().GetByID(ctx, account.ID)
+	if got.Balance != 50 {
+		t.Errorf("balance after concurrent increments: got %v want %v", got.Balance, 50)
+	}
+}
+
+func TestMemoryUniqueConstraints(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	streamID := uuid.New()
+
+	if err := store.Users().Create(ctx, &models.User{Email: "jane@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Users().Create(ctx, &models.User{Email: "jane@example.com"}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("duplicate email: got error %v want %v", err, ErrDuplicate)
+	}
+
+	if err := store.Events().Append(ctx, []models.Event{{StreamID: streamID, Version: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	err := store.Events().Append(ctx, []models.Event{{StreamID: streamID, Version: 2}, {StreamID: streamID, Version: 1}})
+	if !errors.Is(err, ErrDuplicate) {
+		t.Errorf("duplicate stream version: got error %v want %v", err, ErrDuplicate)
+	}
+	if version, _ := store.Events().Version(ctx, streamID); version != 1 {
+		t.Errorf("stream version after a failed append: got %v want %v", version, 1)
+	}
+}
+
+func TestMemoryCanceledContext(t *testing.T) {
+	store := NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := store.Accounts().GetByID(ctx, uuid.New()); !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v want %v", err, context.Canceled)
+	}
+}
@@ -0,0 +1,216 @@
+// Package repository provides the persistence layer of the wallet services:
+// a repository per table, grouped in a Store that can run them inside a
+// transaction. NewGormStore keeps them in the database; NewMemoryStore keeps
+// them in memory so services and handlers can be tested without one.
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+	"wallet/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotFound  = errors.New("record not found")
+	ErrDuplicate = errors.New("duplicate record")
+	// ErrBusy is returned when the database stayed locked by another writer
+	// for longer than it waits.
+	ErrBusy = errors.New("database is busy")
+)
+
+type UserRepository interface {
+	// Create inserts a user, failing with ErrDuplicate if the email is taken.
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetByEmail(ctx context.Context, email string) (*models.User, error)
+}
+
+type AccountRepository interface {
+	Create(ctx context.Context, account *models.Account) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Account, error)
+	GetByNumber(ctx context.Context, number string) (*models.Account, error)
+	GetByVirtualIBAN(ctx context.Context, iban string) (*models.Account, error)
+	// ListByUserID returns a user's accounts, oldest first.
+	ListByUserID(ctx context.Context, userID uuid.UUID) ([]models.Account, error)
+	// ListWithoutNumber returns up to limit accounts that have no account
+	// number yet, oldest first.
+	ListWithoutNumber(ctx context.Context, limit int) ([]models.Account, error)
+	// ListWithoutVirtualIBAN returns up to limit accounts that have no
+	// virtual IBAN yet, oldest first.
+	ListWithoutVirtualIBAN(ctx context.Context, limit int) ([]models.Account, error)
+	// Update saves the balances, version and status of an existing account.
+	Update(ctx context.Context, account *models.Account) error
+	// SetNumber gives an account its account number, failing with
+	// ErrDuplicate if another account has it.
+	SetNumber(ctx context.Context, id uuid.UUID, number string) error
+	// SetVirtualIBAN gives an account its virtual IBAN, failing with
+	// ErrDuplicate if another account has it.
+	SetVirtualIBAN(ctx context.Context, id uuid.UUID, iban string) error
+}
+
+// TransactionKey is the position of a transaction in an account's history,
+// which is ordered by creation time (to the millisecond) and then by ID.
+type TransactionKey struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// TransactionFilter narrows a listing of transactions. Empty fields match
+// every transaction.
+type TransactionFilter struct {
+	// Query matches the transactions whose ref, description, external
+	// reference, merchant name or metadata contain it, ignoring case.
+	Query                string
+	ExternalRef          string
+	MerchantCategoryCode string
+}
+
+type TransactionRepository interface {
+	// Create inserts a transaction, failing with ErrDuplicate if its ID or
+	// ref is taken.
+	Create(ctx context.Context, transaction *models.Transaction) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Transaction, error)
+	GetByRef(ctx context.Context, ref string) (*models.Transaction, error)
+	// ListByAccountID returns all of an account's transactions, oldest first.
+	ListByAccountID(ctx context.Context, accountID uuid.UUID) ([]models.Transaction, error)
+	// ListPostedBetween returns an account's transactions posted from from
+	// up to but excluding to, in the order they were posted.
+	ListPostedBetween(ctx context.Context, accountID uuid.UUID, from, to time.Time) ([]models.Transaction, error)
+	// ListPending returns up to limit pending transactions of any account
+	// created before the given time, oldest first.
+	ListPending(ctx context.Context, createdBefore time.Time, limit int) ([]models.Transaction, error)
+	// Update saves the status and posting time of an existing transaction.
+	Update(ctx context.Context, transaction *models.Transaction) error
+	// ListPage returns up to limit of an account's transactions matching
+	// filter, newest first, starting after the given key or from the newest
+	// if it is nil.
+	ListPage(ctx context.Context, accountID uuid.UUID, filter TransactionFilter, after *TransactionKey, limit int) ([]models.Transaction, error)
+}
+
+type EventRepository interface {
+	// Stream returns the events of a stream in version order.
+	Stream(ctx context.Context, streamID uuid.UUID) ([]models.Event, error)
+	// Version returns the latest version of a stream, or 0 if it is empty.
+	Version(ctx context.Context, streamID uuid.UUID) (int, error)
+	// Append stores events and assigns their positions. It fails with
+	// ErrDuplicate if one of the stream versions is taken.
+	Append(ctx context.Context, events []models.Event) error
+}
+
+type AuditRepository interface {
+	// Last returns the latest entry of the audit log, or nil if it is empty.
+	Last(ctx context.Context) (*models.AuditEntry, error)
+	// Append stores an entry, failing with ErrDuplicate if its sequence or
+	// hash is taken.
+	Append(ctx context.Context, entry *models.AuditEntry) error
+}
+
+type BatchRepository interface {
+	// Create inserts a batch with its items, failing with ErrDuplicate if
+	// one of their references is taken.
+	Create(ctx context.Context, batch *models.Batch) error
+	// GetByID returns a batch with its items in submission order.
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Batch, error)
+	// ListByStatus returns the batches in one of the given statuses, oldest
+	// first and without their items.
+	ListByStatus(ctx context.Context, statuses ...models.BatchStatus) ([]models.Batch, error)
+	// TakenReferences returns those of the given item references that are
+	// already taken.
+	TakenReferences(ctx context.Context, references []string) ([]string, error)
+	// Update saves the status and completion time of an existing batch.
+	Update(ctx context.Context, batch *models.Batch) error
+	// UpdateItem saves the status, transaction ref and error of an existing
+	// item.
+	UpdateItem(ctx context.Context, item *models.BatchItem) error
+}
+
+type PaymentRepository interface {
+	// Create inserts a payment, failing with ErrDuplicate if its intent or
+	// transaction already has one.
+	Create(ctx context.Context, payment *models.Payment) error
+	GetByIntent(ctx context.Context, provider, intentID string) (*models.Payment, error)
+	GetByTransactionID(ctx context.Context, transactionID uuid.UUID) (*models.Payment, error)
+	// Update saves the status, failure reason and refund ID of an existing
+	// payment.
+	Update(ctx context.Context, payment *models.Payment) error
+}
+
+type DepositRepository interface {
+	// Create inserts a deposit, failing with ErrDuplicate if its reference or
+	// transaction already has one.
+	Create(ctx context.Context, deposit *models.Deposit) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Deposit, error)
+	// ListByStatus returns the deposits in the given status, oldest first.
+	ListByStatus(ctx context.Context, status models.DepositStatus) ([]models.Deposit, error)
+	// Update saves the status, account, transaction and reason of an
+	// existing deposit.
+	Update(ctx context.Context, deposit *models.Deposit) error
+}
+
+type AdjustmentRepository interface {
+	// Create inserts an adjustment, failing with ErrDuplicate if its
+	// transaction already has one.
+	Create(ctx context.Context, adjustment *models.Adjustment) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Adjustment, error)
+	// ListByStatus returns the adjustments in the given status, oldest
+	// first.
+	ListByStatus(ctx context.Context, status models.AdjustmentStatus) ([]models.Adjustment, error)
+	// Update saves the status, review and transaction of an existing
+	// adjustment.
+	Update(ctx context.Context, adjustment *models.Adjustment) error
+}
+
+type BeneficiaryRepository interface {
+	Create(ctx context.Context, beneficiary *models.Beneficiary) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Beneficiary, error)
+	// ListByAccountID returns an account's beneficiaries, oldest first.
+	ListByAccountID(ctx context.Context, accountID uuid.UUID) ([]models.Beneficiary, error)
+}
+
+type WithdrawalRepository interface {
+	// Create inserts a withdrawal, failing with ErrDuplicate if its
+	// transaction or end-to-end ID already has one.
+	Create(ctx context.Context, withdrawal *models.Withdrawal) error
+	GetByID(ctx context.Context, id uuid.UUID) (*models.Withdrawal, error)
+	GetByEndToEndID(ctx context.Context, endToEndID string) (*models.Withdrawal, error)
+	GetByTraceNumber(ctx context.Context, traceNumber string) (*models.Withdrawal, error)
+	// GetByTransactionID returns the withdrawal whose amount a transaction
+	// holds.
+	GetByTransactionID(ctx context.Context, transactionID uuid.UUID) (*models.Withdrawal, error)
+	// ListByStatus returns up to limit withdrawals in the given status,
+	// oldest first.
+	ListByStatus(ctx context.Context, status models.WithdrawalStatus, limit int) ([]models.Withdrawal, error)
+	// ListByPayoutID returns the withdrawals paid out with a payout file.
+	ListByPayoutID(ctx context.Context, payoutID uuid.UUID) ([]models.Withdrawal, error)
+	// Update saves the status, payout, trace number, return reason and
+	// completion time of an existing withdrawal.
+	Update(ctx context.Context, withdrawal *models.Withdrawal) error
+	// CreatePayout inserts a payout file, failing with ErrDuplicate if its
+	// message ID is taken.
+	CreatePayout(ctx context.Context, payout *models.Payout) error
+	// PayoutEntries returns how many withdrawals the payouts of a format
+	// have carried so far.
+	PayoutEntries(ctx context.Context, format models.PayoutFormat) (int, error)
+}
+
+// Store groups the repositories.
+type Store interface {
+	Users() UserRepository
+	Accounts() AccountRepository
+	Transactions() TransactionRepository
+	Events() EventRepository
+	Audit() AuditRepository
+	Batches() BatchRepository
+	Payments() PaymentRepository
+	Beneficiaries() BeneficiaryRepository
+	Withdrawals() WithdrawalRepository
+	Deposits() DepositRepository
+	Adjustments() AdjustmentRepository
+
+	// Transaction runs fn with a store whose changes are committed together
+	// if fn returns nil and discarded otherwise. Transactions nest.
+	Transaction(ctx context.Context, fn func(tx Store) error) error
+}
@@ -2,7 +2,9 @@ package server
 
 import (
 	"net/http"
+	"time"
 
+	"wallet/internal/models"
 	"wallet/internal/server/dto"
 
 	"github.com/gin-gonic/gin"
@@ -17,71 +19,78 @@ import (
 // @Produce json
 // @Param request body dto.CreateAccountRequest true "Account details"
 // @Success 201 {object} dto.CreateAccountResponse "Account created successfully"
-// @Failure 400 {object} dto.AccountBadRequestResponse  "Bad request"
-// @Failure 500 {object} string "Internal server error"
+// @Failure 400 {object} dto.Problem "Malformed request"
+// @Failure 409 {object} dto.Problem "User already exists"
+// @Failure 422 {object} dto.Problem "Validation failed"
+// @Failure 500 {object} dto.Problem "Internal server error"
 // @Router /accounts [post]
 func (s *Server) CreateAccountHandler(c *gin.Context) {
 	var request dto.CreateAccountRequest
 
-	// Check if the request body is empty
-	if c.Request.Body == nil || c.Request.ContentLength == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "request body cannot be empty"})
-		return
-	}
 	// Bind the request body to the request struct
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindingError(c, err)
 		return
 	}
 
 	// Create the user and account with 0 balance
-	account, err := s.AccountService.CreateAccountWithUser(request.Email, request.FirstName, request.LastName)
-
-	if err != nil && err.Error() == "user already exists" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user already exists"})
-		return
-	}
-
+	account, err := s.AccountService.WithActor(requestActor(c)).CreateAccountWithUser(c.Request.Context(), request.Email, request.FirstName, request.LastName)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusCreated, account)
 }
 
+// accountIDParam resolves the id path parameter, which is either an account
+// ID or an account number.
+func (s *Server) accountIDParam(c *gin.Context) (uuid.UUID, error) {
+	return s.AccountService.ResolveAccountID(c.Request.Context(), c.Param("id"))
+}
+
 // TopUpHandler tops up the account with the given amount
 // @Summary Top up an account
-// @Description Top up an account with the given amount
+// @Description Top up an account with the given amount, collected from the payment method by the payment provider. The top-up is returned posted once the payment succeeds, or pending while the provider processes it; its webhook then settles or fails the top-up.
+// @Description A pending top-up, such as a bank transfer, is not collected and only credits the account once it is settled.
+// @Description A description, an external reference and a JSON object of metadata may be attached to the top-up.
 // @Tags accounts
 // @Accept json
 // @Produce json
-// @Param id path string true "Account ID"
+// @Param id path string true "Account ID or account number"
 // @Param request body dto.TopUpRequest true "Top up details"
 // @Success 200 {object} dto.TopUpResponse "Top up successful"
-// @Failure 400 {object} dto.AccountBadRequestResponse  "Bad request"
-// @Failure 500 {object} string "Internal server error"
+// @Failure 400 {object} dto.Problem "Malformed request or invalid account ID"
+// @Failure 402 {object} dto.Problem "Payment declined"
+// @Failure 404 {object} dto.Problem "Account not found"
+// @Failure 409 {object} dto.Problem "Account is frozen"
+// @Failure 422 {object} dto.Problem "Validation failed"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Failure 502 {object} dto.Problem "Payment provider error"
 // @Router /accounts/{id}/top-up [post]
 func (s *Server) TopUpHandler(c *gin.Context) {
-	accountID, err := uuid.Parse(c.Param("id"))
+	accountID, err := s.accountIDParam(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account ID"})
+		respondError(c, err)
 		return
 	}
 
-	var request struct {
-		Amount float64 `json:"amount" binding:"required,gt=0"`
-	}
-
+	var request dto.TopUpRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindingError(c, err)
 		return
 	}
 
-	// Call the account service to top up the account
-	transaction, err := s.AccountService.TopUp(accountID, request.Amount)
+	// Collect the top-up through the payment provider, unless it is settled
+	// later
+	var transaction *models.Transaction
+	if request.Pending {
+		transaction, err = s.AccountService.WithActor(requestActor(c)).PendingTopUp(c.Request.Context(), accountID, request.Amount, models.TransactionDetails(request.TransactionDetails))
+	} else {
+		transaction, err = s.FundingService.WithActor(requestActor(c)).TopUp(c.Request.Context(), accountID, request.Amount, request.PaymentMethod, models.TransactionDetails(request.TransactionDetails))
+	}
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
@@ -90,42 +99,98 @@ func (s *Server) TopUpHandler(c *gin.Context) {
 
 // ChargeHandler charges the account with the given amount
 // @Summary Charge an account
-// @Description Charge an account with the given amount
+// @Description Charge an account with the given amount, which must not exceed its available balance. A pending charge, such as a card authorisation, holds the amount until it is settled or fails.
+// @Description A description, an external reference, the merchant's name and category code and a JSON object of metadata may be attached to the charge.
 // @Tags accounts
 // @Accept json
 // @Produce json
-// @Param id path string true "Account ID"
+// @Param id path string true "Account ID or account number"
 // @Param request body dto.ChargeRequest true "Charge details"
 // @Success 200 {object} dto.ChargeResponse "Charge successful"
-// @Failure 400 {object} dto.AccountBadRequestResponse  "Bad request"
-// @Failure 500 {object} string "Internal server error"
+// @Failure 400 {object} dto.Problem "Malformed request or invalid account ID"
+// @Failure 404 {object} dto.Problem "Account not found"
+// @Failure 409 {object} dto.Problem "Account is frozen"
+// @Failure 422 {object} dto.Problem "Validation failed or insufficient funds"
+// @Failure 500 {object} dto.Problem "Internal server error"
 // @Router /accounts/{id}/charge [post]
 func (s *Server) ChargeHandler(c *gin.Context) {
-	accountID, err := uuid.Parse(c.Param("id"))
+	accountID, err := s.accountIDParam(c)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid account ID"})
+		respondError(c, err)
 		return
 	}
 
-	var request struct {
-		Amount float64 `json:"amount" binding:"required,gt=0"`
-	}
-
+	var request dto.ChargeRequest
 	if err := c.ShouldBindJSON(&request); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondBindingError(c, err)
 		return
 	}
 
 	// Call the account service to charge the account
-	transaction, err := s.AccountService.Charge(accountID, request.Amount)
-	if err != nil && err.Error() == "insufficient balance" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+	accounts := s.AccountService.WithActor(requestActor(c))
+	charge := accounts.Charge
+	if request.Pending {
+		charge = accounts.PendingCharge
 	}
+	transaction, err := charge(c.Request.Context(), accountID, request.Amount, models.TransactionDetails(request.TransactionDetails))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		respondError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, transaction)
 }
+
+// BalanceHandler returns the balance of the account at a point in time
+// @Summary Get an account balance
+// @Description Get the balance of an account as it stood at the given time, defaulting to now
+// @Tags accounts
+// @Produce json
+// @Param id path string true "Account ID or account number"
+// @Param as_of query string false "RFC 3339 timestamp, e.g. 2025-01-31T23:59:59Z"
+// @Success 200 {object} dto.BalanceResponse "Balance at the requested time"
+// @Failure 400 {object} dto.Problem "Invalid account ID"
+// @Failure 404 {object} dto.Problem "Account not found"
+// @Failure 422 {object} dto.Problem "Invalid as_of"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /accounts/{id}/balance [get]
+func (s *Server) BalanceHandler(c *gin.Context) {
+	accountID, err := s.accountIDParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	asOf := time.Now()
+	if raw := c.Query("as_of"); raw != "" {
+		asOf, err = time.Parse(time.RFC3339Nano, raw)
+		if err != nil {
+			writeProblem(c, dto.Problem{
+				Title:  validationProblem.title,
+				Status: validationProblem.status,
+				Detail: "as_of must be an RFC 3339 timestamp",
+				Code:   validationProblem.code,
+				Errors: []dto.FieldError{{Field: "as_of", Message: "must be an RFC 3339 timestamp"}},
+			})
+			return
+		}
+	}
+
+	balance, err := s.BalanceService.BalanceAsOf(c.Request.Context(), accountID, asOf)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	response := dto.BalanceResponse{
+		AccountID:           balance.AccountID.String(),
+		AsOf:                balance.AsOf.Format(time.RFC3339Nano),
+		Balance:             balance.Balance,
+		TransactionsApplied: balance.TransactionsApplied,
+	}
+	if balance.SnapshotClosingAt != nil {
+		response.SnapshotClosingAt = balance.SnapshotClosingAt.Format(time.RFC3339)
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"wallet/internal/models"
+	"wallet/internal/payments"
+	"wallet/internal/repository"
+	"wallet/internal/server/dto"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// newTestServer returns a server whose services keep their data in memory.
+func newTestServer() *Server {
+	store := repository.NewMemoryStore()
+	return &Server{
+		UserService:        services.NewUserServiceWithStore(store),
+		AccountService:     services.NewAccountServiceWithStore(store),
+		TransactionService: services.NewTransactionServiceWithStore(store),
+		FundingService:     services.NewFundingServiceWithStore(store, payments.NewFakeProvider("", nil)),
+	}
+}
+
+func TestTopUpHandler(t *testing.T) {
+	s := newTestServer()
+	account, err := s.AccountService.CreateAccountWithUser(context.Background(), "jane@example.com", "Jane", "Doe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.POST("/accounts/:id/top-up", s.TopUpHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "/accounts/"+account.ID.String()+"/top-up", strings.NewReader(`{"amount": 12.5}`))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	var transaction models.Transaction
+	if err := json.Unmarshal(rr.Body.Bytes(), &transaction); err != nil {
+		t.Fatal(err)
+	}
+	if transaction.Account.Balance != 12.5 {
+		t.Errorf("Handler returned unexpected balance: got %v want %v", transaction.Account.Balance, 12.5)
+	}
+}
+
+func TestTopUpHandlerUnknownAccount(t *testing.T) {
+	s := newTestServer()
+	r := gin.New()
+	r.POST("/accounts/:id/top-up", s.TopUpHandler)
+
+	req := httptest.NewRequest(http.MethodPost, "/accounts/"+uuid.NewString()+"/top-up", strings.NewReader(`{"amount": 1}`))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", rr.Code, http.StatusNotFound)
+	}
+	var problem dto.Problem
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Code != CodeAccountNotFound {
+		t.Errorf("Handler returned unexpected problem code: got %v want %v", problem.Code, CodeAccountNotFound)
+	}
+}
+
+func TestTopUpHandlerByAccountNumber(t *testing.T) {
+	s := newTestServer()
+	account, err := s.AccountService.CreateAccountWithUser(context.Background(), "jane@example.com", "Jane", "Doe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := gin.New()
+	r.POST("/accounts/:id/top-up", s.TopUpHandler)
+
+	number := *account.Number
+	tests := []struct {
+		name   string
+		id     string
+		status int
+		code   string
+	}{
+		{"account number", number, http.StatusOK, ""},
+		{"account number with dashes", number[:5] + "-" + number[5:], http.StatusOK, ""},
+		{"wrong check digit", number[:9] + string('0'+(number[9]-'0'+1)%10), http.StatusBadRequest, CodeInvalidAccountID},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/accounts/"+tt.id+"/top-up", strings.NewReader(`{"amount": 1}`))
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("Handler returned wrong status code: got %v want %v: %s", rr.Code, tt.status, rr.Body)
+			}
+			var problem dto.Problem
+			if tt.code != "" && (json.Unmarshal(rr.Body.Bytes(), &problem) != nil || problem.Code != tt.code) {
+				t.Errorf("Handler returned unexpected problem code: got %v want %v", problem.Code, tt.code)
+			}
+		})
+	}
+}
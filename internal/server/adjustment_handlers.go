@@ -0,0 +1,176 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"wallet/internal/models"
+	"wallet/internal/server/dto"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// RequestAdjustmentHandler makes a manual credit or debit
+// @Summary Adjust a balance
+// @Description Credit (positive amount) or debit (negative amount) an account by hand with a reason code and a note. Adjustments up to ADJUSTMENT_APPROVAL_THRESHOLD are posted straight away; larger ones stay pending until an operator other than the one who made them approves them. The operator is the one the bearer token belongs to.
+// @Tags adjustments
+// @Accept json
+// @Produce json
+// @Param id path string true "Account ID or account number"
+// @Security OperatorToken
+// @Param request body dto.AdjustmentRequest true "Amount, reason code and note"
+// @Success 201 {object} dto.AdjustmentResponse "Adjustment posted or pending approval"
+// @Failure 400 {object} dto.Problem "Malformed request or invalid account ID"
+// @Failure 401 {object} dto.Problem "Operator token missing or unknown"
+// @Failure 404 {object} dto.Problem "Account not found"
+// @Failure 409 {object} dto.Problem "Account is not open"
+// @Failure 422 {object} dto.Problem "Validation failed or insufficient funds"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /accounts/{id}/adjustments [post]
+func (s *Server) RequestAdjustmentHandler(c *gin.Context) {
+	accountID, err := s.accountIDParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	var request dto.AdjustmentRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	adjustment, err := s.AdjustmentService.WithActor(requestActor(c)).Request(c.Request.Context(), accountID, request.Amount, models.AdjustmentReason(request.ReasonCode), request.Note)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, adjustment)
+}
+
+// ListAdjustmentsHandler lists the adjustments in a status
+// @Summary List adjustments
+// @Description List the adjustments in a status, oldest first. The pending adjustments, listed by default, are the ones waiting for approval.
+// @Tags adjustments
+// @Produce json
+// @Param status query string false "pending, approved or rejected" default(pending)
+// @Success 200 {array} dto.AdjustmentResponse "Adjustments"
+// @Failure 422 {object} dto.Problem "Unknown status"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /adjustments [get]
+func (s *Server) ListAdjustmentsHandler(c *gin.Context) {
+	status := models.AdjustmentStatus(c.DefaultQuery("status", string(models.AdjustmentPending)))
+	switch status {
+	case models.AdjustmentPending, models.AdjustmentApproved, models.AdjustmentRejected:
+	default:
+		respondError(c, &services.ValidationError{Field: "status", Message: "status must be one of pending, approved or rejected"})
+		return
+	}
+
+	adjustments, err := s.AdjustmentService.ListAdjustments(c.Request.Context(), status)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, adjustments)
+}
+
+// GetAdjustmentHandler returns an adjustment
+// @Summary Get an adjustment
+// @Description Get an adjustment with its status, who made and reviewed it, and once posted the transaction it was posted as
+// @Tags adjustments
+// @Produce json
+// @Param id path string true "Adjustment ID"
+// @Success 200 {object} dto.AdjustmentResponse "Adjustment"
+// @Failure 400 {object} dto.Problem "Invalid adjustment ID"
+// @Failure 404 {object} dto.Problem "Adjustment not found"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /adjustments/{id} [get]
+func (s *Server) GetAdjustmentHandler(c *gin.Context) {
+	adjustmentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondProblem(c, invalidAdjustmentIDProblem, "invalid adjustment ID")
+		return
+	}
+
+	adjustment, err := s.AdjustmentService.GetAdjustment(c.Request.Context(), adjustmentID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, adjustment)
+}
+
+// ApproveAdjustmentHandler approves and posts a pending adjustment
+// @Summary Approve an adjustment
+// @Description Approve a pending adjustment and post it to the account. The operator, authenticated by their bearer token, must not be the one who made the adjustment.
+// @Tags adjustments
+// @Accept json
+// @Produce json
+// @Param id path string true "Adjustment ID"
+// @Security OperatorToken
+// @Param request body dto.ReviewAdjustmentRequest false "Review note"
+// @Success 200 {object} dto.AdjustmentResponse "Adjustment approved and posted"
+// @Failure 400 {object} dto.Problem "Malformed request or invalid adjustment ID"
+// @Failure 401 {object} dto.Problem "Operator token missing or unknown"
+// @Failure 403 {object} dto.Problem "Operator made the adjustment"
+// @Failure 404 {object} dto.Problem "Adjustment or account not found"
+// @Failure 409 {object} dto.Problem "Adjustment already reviewed, or account not open"
+// @Failure 422 {object} dto.Problem "Insufficient funds"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /adjustments/{id}/approve [post]
+func (s *Server) ApproveAdjustmentHandler(c *gin.Context) {
+	s.reviewAdjustment(c, services.AdjustmentService.Approve)
+}
+
+// RejectAdjustmentHandler rejects a pending adjustment
+// @Summary Reject an adjustment
+// @Description Reject a pending adjustment with a note saying why. The operator who made the adjustment may reject it to withdraw it.
+// @Tags adjustments
+// @Accept json
+// @Produce json
+// @Param id path string true "Adjustment ID"
+// @Security OperatorToken
+// @Param request body dto.ReviewAdjustmentRequest true "Reason for the rejection"
+// @Success 200 {object} dto.AdjustmentResponse "Adjustment rejected"
+// @Failure 400 {object} dto.Problem "Malformed request or invalid adjustment ID"
+// @Failure 401 {object} dto.Problem "Operator token missing or unknown"
+// @Failure 404 {object} dto.Problem "Adjustment not found"
+// @Failure 409 {object} dto.Problem "Adjustment already reviewed"
+// @Failure 422 {object} dto.Problem "Note missing"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /adjustments/{id}/reject [post]
+func (s *Server) RejectAdjustmentHandler(c *gin.Context) {
+	s.reviewAdjustment(c, services.AdjustmentService.Reject)
+}
+
+// reviewAdjustment approves or rejects the adjustment in the path with the
+// note in the body, which may be empty.
+func (s *Server) reviewAdjustment(c *gin.Context, review func(services.AdjustmentService, context.Context, uuid.UUID, string) (*models.Adjustment, error)) {
+	adjustmentID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondProblem(c, invalidAdjustmentIDProblem, "invalid adjustment ID")
+		return
+	}
+
+	var request dto.ReviewAdjustmentRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			respondBindingError(c, err)
+			return
+		}
+	}
+
+	adjustment, err := review(s.AdjustmentService.WithActor(requestActor(c)), c.Request.Context(), adjustmentID, request.Note)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, adjustment)
+}
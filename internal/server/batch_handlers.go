@@ -0,0 +1,234 @@
+package server
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/server/dto"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxBatchBodyBytes bounds the size of a submitted batch, which leaves
+// plenty of room for services.MaxBatchItems items.
+const maxBatchBodyBytes = 8 << 20
+
+// batchCSVColumns are the columns a CSV batch must have, in any order.
+var batchCSVColumns = []string{"account_id", "operation", "amount", "reference"}
+
+// CreateBatchHandler submits a batch of top-ups and charges
+// @Summary Submit a batch of top-ups and charges
+// @Description Submit up to 10000 top-ups and charges, e.g. a payroll run, to be processed in the background. The batch is sent either as JSON or as CSV, in a text/csv body or the "file" field of a multipart/form-data upload, with the header account_id,operation,amount,reference; CSV batches pass their mode as a query parameter.
+// @Description In atomic mode, limited to 1000 items, every item is applied or, if one fails, none are; in best_effort mode (the default) items are applied independently. Item references are unique across all batches, so a batch that is submitted twice is refused.
+// @Tags batches
+// @Accept json
+// @Accept text/csv
+// @Accept mpfd
+// @Produce json
+// @Security OperatorToken
+// @Param mode query string false "Mode of a CSV batch" Enums(atomic, best_effort)
+// @Param request body dto.CreateBatchRequest true "Batch of items"
+// @Success 202 {object} dto.BatchResponse "Batch accepted for processing"
+// @Header 202 {string} Location "URL of the batch's status"
+// @Failure 400 {object} dto.Problem "Malformed request"
+// @Failure 401 {object} dto.Problem "Operator token missing or unknown"
+// @Failure 409 {object} dto.Problem "Reference already submitted"
+// @Failure 413 {object} dto.Problem "Batch too large"
+// @Failure 422 {object} dto.Problem "Validation failed"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /batches [post]
+func (s *Server) CreateBatchHandler(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxBatchBodyBytes)
+
+	var request dto.CreateBatchRequest
+	var err error
+	switch c.ContentType() {
+	case "text/csv":
+		request.Mode = c.Query("mode")
+		request.Items, err = parseBatchCSV(c.Request.Body)
+	case "multipart/form-data":
+		request.Mode = c.DefaultPostForm("mode", c.Query("mode"))
+		request.Items, err = parseBatchUpload(c)
+	default:
+		err = c.ShouldBindJSON(&request)
+	}
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondProblem(c, problemType{http.StatusRequestEntityTooLarge, CodeRequestTooLarge, "Request too large"}, fmt.Sprintf("a batch must be at most %d bytes", maxBatchBodyBytes))
+			return
+		}
+		respondBindingError(c, err)
+		return
+	}
+
+	items, err := batchItems(request.Items)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	batch, err := s.BatchService.WithActor(requestActor(c)).Submit(c.Request.Context(), models.BatchMode(request.Mode), items)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Header("Location", "/api/v1/batches/"+batch.ID.String())
+	c.JSON(http.StatusAccepted, batchResponse(batch))
+}
+
+// GetBatchHandler returns the status of a batch and the results of its items
+// @Summary Get a batch
+// @Description Get the status of a batch and the result of each of its items
+// @Tags batches
+// @Produce json
+// @Param id path string true "Batch ID"
+// @Success 200 {object} dto.BatchResponse "Batch status"
+// @Failure 400 {object} dto.Problem "Invalid batch ID"
+// @Failure 404 {object} dto.Problem "Batch not found"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /batches/{id} [get]
+func (s *Server) GetBatchHandler(c *gin.Context) {
+	batchID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondProblem(c, invalidBatchIDProblem, "invalid batch ID")
+		return
+	}
+
+	batch, err := s.BatchService.GetBatch(c.Request.Context(), batchID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, batchResponse(batch))
+}
+
+// parseBatchUpload reads the CSV batch uploaded in the "file" field of a
+// multipart form.
+func parseBatchUpload(c *gin.Context) ([]dto.BatchItemRequest, error) {
+	header, err := c.FormFile("file")
+	if err != nil {
+		return nil, fmt.Errorf("the CSV batch must be uploaded in the file field: %w", err)
+	}
+	file, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return parseBatchCSV(file)
+}
+
+// parseBatchCSV reads batch items from CSV with a header row naming the
+// batchCSVColumns. Other columns are ignored.
+func parseBatchCSV(r io.Reader) ([]dto.BatchItemRequest, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("the CSV batch is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range batchCSVColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("the CSV batch has no %s column", name)
+		}
+	}
+
+	items := []dto.BatchItemRequest{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return items, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string { return strings.TrimSpace(record[columns[name]]) }
+		amount, err := strconv.ParseFloat(field("amount"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: amount %q is not a number", line, field("amount"))
+		}
+		items = append(items, dto.BatchItemRequest{
+			AccountID: field("account_id"),
+			Operation: field("operation"),
+			Amount:    amount,
+			Reference: field("reference"),
+		})
+	}
+}
+
+// batchItems converts the items of a request for the batch service, which
+// validates everything but the account IDs.
+func batchItems(requests []dto.BatchItemRequest) ([]models.BatchItem, error) {
+	items := make([]models.BatchItem, len(requests))
+	for i, request := range requests {
+		accountID, err := uuid.Parse(request.AccountID)
+		if err != nil {
+			return nil, &services.ValidationError{Field: fmt.Sprintf("items[%d].account_id", i), Message: "account_id must be a UUID"}
+		}
+		items[i] = models.BatchItem{
+			AccountID: accountID,
+			Operation: models.TransactionType(request.Operation),
+			Amount:    request.Amount,
+			Reference: request.Reference,
+		}
+	}
+	return items, nil
+}
+
+func batchResponse(batch *models.Batch) dto.BatchResponse {
+	response := dto.BatchResponse{
+		ID:        batch.ID.String(),
+		Mode:      string(batch.Mode),
+		Status:    string(batch.Status),
+		CreatedAt: batch.CreatedAt.Format(time.RFC3339Nano),
+		Total:     len(batch.Items),
+		Items:     make([]dto.BatchItemResult, len(batch.Items)),
+	}
+	if batch.CompletedAt != nil {
+		response.CompletedAt = batch.CompletedAt.Format(time.RFC3339Nano)
+	}
+	for i, item := range batch.Items {
+		switch item.Status {
+		case models.BatchItemPending:
+			response.Pending++
+		case models.BatchItemSucceeded:
+			response.Succeeded++
+		case models.BatchItemFailed:
+			response.Failed++
+		case models.BatchItemSkipped:
+			response.Skipped++
+		}
+		response.Items[i] = dto.BatchItemResult{
+			Index:          item.Position,
+			AccountID:      item.AccountID.String(),
+			Operation:      string(item.Operation),
+			Amount:         item.Amount,
+			Reference:      item.Reference,
+			Status:         string(item.Status),
+			TransactionRef: item.TransactionRef,
+			Error:          item.Error,
+		}
+	}
+	return response
+}
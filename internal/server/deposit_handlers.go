@@ -0,0 +1,152 @@
+package server
+
+import (
+	"net/http"
+
+	"wallet/internal/inbound"
+	"wallet/internal/models"
+	"wallet/internal/server/dto"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ReceiveDepositHandler records a bank transfer received by the wallet
+// @Summary Receive a bank transfer
+// @Description Record a bank transfer reported by the bank. It is credited as a top-up to the account whose virtual IBAN it was sent to, or whose virtual IBAN its remittance information quotes. Transfers that match no open account are left unmatched in the suspense queue.
+// @Tags deposits
+// @Accept json
+// @Produce json
+// @Security OperatorToken
+// @Param request body dto.DepositRequest true "Bank transfer"
+// @Success 201 {object} dto.DepositResponse "Deposit recorded, matched or unmatched"
+// @Failure 400 {object} dto.Problem "Malformed request"
+// @Failure 401 {object} dto.Problem "Operator token missing or unknown"
+// @Failure 409 {object} dto.Problem "Transfer already received"
+// @Failure 422 {object} dto.Problem "Validation failed"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /deposits [post]
+func (s *Server) ReceiveDepositHandler(c *gin.Context) {
+	var request dto.DepositRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	deposit, err := s.DepositService.WithActor(requestActor(c)).Receive(c.Request.Context(), inbound.Credit{
+		Reference:    request.Reference,
+		Amount:       request.Amount,
+		Currency:     request.Currency,
+		CreditorIBAN: request.CreditorIBAN,
+		DebtorName:   request.DebtorName,
+		DebtorIBAN:   request.DebtorIBAN,
+		Remittance:   request.Remittance,
+		BookedAt:     request.BookedAt,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, deposit)
+}
+
+// ListDepositsHandler lists the deposits in a status
+// @Summary List deposits
+// @Description List the deposits in a status, oldest first. The unmatched deposits, listed by default, are the suspense queue.
+// @Tags deposits
+// @Produce json
+// @Param status query string false "matched, unmatched or assigned" default(unmatched)
+// @Success 200 {array} dto.DepositResponse "Deposits"
+// @Failure 422 {object} dto.Problem "Unknown status"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /deposits [get]
+func (s *Server) ListDepositsHandler(c *gin.Context) {
+	status := models.DepositStatus(c.DefaultQuery("status", string(models.DepositUnmatched)))
+	switch status {
+	case models.DepositMatched, models.DepositUnmatched, models.DepositAssigned:
+	default:
+		respondError(c, &services.ValidationError{Field: "status", Message: "status must be one of matched, unmatched or assigned"})
+		return
+	}
+
+	deposits, err := s.DepositService.ListDeposits(c.Request.Context(), status)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deposits)
+}
+
+// GetDepositHandler returns a deposit
+// @Summary Get a deposit
+// @Description Get a deposit with its status and, once credited, the account and top-up it was credited by
+// @Tags deposits
+// @Produce json
+// @Param id path string true "Deposit ID"
+// @Success 200 {object} dto.DepositResponse "Deposit"
+// @Failure 400 {object} dto.Problem "Invalid deposit ID"
+// @Failure 404 {object} dto.Problem "Deposit not found"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /deposits/{id} [get]
+func (s *Server) GetDepositHandler(c *gin.Context) {
+	depositID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondProblem(c, invalidDepositIDProblem, "invalid deposit ID")
+		return
+	}
+
+	deposit, err := s.DepositService.GetDeposit(c.Request.Context(), depositID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deposit)
+}
+
+// AssignDepositHandler credits an unmatched deposit to an account
+// @Summary Assign a deposit
+// @Description Take an unmatched deposit out of the suspense queue by crediting it to an account as a top-up
+// @Tags deposits
+// @Accept json
+// @Produce json
+// @Param id path string true "Deposit ID"
+// @Security OperatorToken
+// @Param request body dto.AssignDepositRequest true "Account to credit"
+// @Success 200 {object} dto.DepositResponse "Deposit assigned"
+// @Failure 400 {object} dto.Problem "Malformed request or invalid deposit ID"
+// @Failure 401 {object} dto.Problem "Operator token missing or unknown"
+// @Failure 404 {object} dto.Problem "Deposit or account not found"
+// @Failure 409 {object} dto.Problem "Deposit not in the suspense queue, or account frozen or not open"
+// @Failure 422 {object} dto.Problem "Validation failed"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /deposits/{id}/assign [post]
+func (s *Server) AssignDepositHandler(c *gin.Context) {
+	depositID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondProblem(c, invalidDepositIDProblem, "invalid deposit ID")
+		return
+	}
+
+	var request dto.AssignDepositRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+	accountID, err := uuid.Parse(request.AccountID)
+	if err != nil {
+		respondError(c, &services.ValidationError{Field: "account_id", Message: "account_id must be a UUID"})
+		return
+	}
+
+	deposit, err := s.DepositService.WithActor(requestActor(c)).Assign(c.Request.Context(), depositID, accountID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deposit)
+}
@@ -1,5 +1,7 @@
 package dto
 
+import "encoding/json"
+
 type CreateAccountRequest struct {
 	Email     string `json:"email" binding:"required,email"`
 	FirstName string `json:"first_name" binding:"required"`
@@ -12,10 +14,35 @@ type CreateAccountResponse struct {
 	FirstName string  `json:"first_name"`
 	LastName  string  `json:"last_name"`
 	Balance   float64 `json:"balance"`
+	// Number is the account number, which routes accept in place of the ID.
+	Number string `json:"number" example:"1234567897"`
+	// VirtualIBAN is the IBAN to send bank transfers to the account to.
+	VirtualIBAN string `json:"virtual_iban" example:"DE02000000000123456789"`
+}
+
+// TransactionDetails describe what a top-up or charge was for. All of them
+// are optional and returned with the transaction.
+type TransactionDetails struct {
+	Description string `json:"description" example:"Weekly groceries"`
+	// Metadata is a JSON object of the client's own keys and values.
+	Metadata json.RawMessage `json:"metadata" swaggertype:"object"`
+	// ExternalRef is the client's own reference, such as an order ID.
+	ExternalRef  string `json:"external_ref" example:"order-1042"`
+	MerchantName string `json:"merchant_name" example:"Corner Shop"`
+	// MerchantCategoryCode is the four-digit ISO 18245 merchant category
+	// code.
+	MerchantCategoryCode string `json:"merchant_category_code" example:"5411"`
 }
 
 type TopUpRequest struct {
 	Amount float64 `json:"amount" binding:"required,gt=0"`
+	// Pending leaves the top-up pending until it is settled, e.g. for a
+	// bank transfer, instead of collecting it with PaymentMethod.
+	Pending bool `json:"pending"`
+	// PaymentMethod is what the payment provider collects the top-up from,
+	// e.g. fake_card or fake_card_delayed with the fake provider.
+	PaymentMethod string `json:"payment_method" example:"fake_card"`
+	TransactionDetails
 }
 
 type TopUpResponse struct {
@@ -23,10 +50,14 @@ type TopUpResponse struct {
 	AccountID     string  `json:"account_id"`
 	Amount        float64 `json:"amount"`
 	NewBalance    float64 `json:"new_balance"`
+	TransactionDetails
 }
 
 type ChargeRequest struct {
 	Amount float64 `json:"amount" binding:"required,gt=0"`
+	// Pending holds the amount until the charge is settled or fails.
+	Pending bool `json:"pending"`
+	TransactionDetails
 }
 
 type ChargeResponse struct {
@@ -34,8 +65,13 @@ type ChargeResponse struct {
 	AccountID     string  `json:"account_id"`
 	Amount        float64 `json:"amount"`
 	NewBalance    float64 `json:"new_balance"`
+	TransactionDetails
 }
 
-type AccountBadRequestResponse struct {
-	Error string `json:"error" default:"Invalid request"`
+type BalanceResponse struct {
+	AccountID           string  `json:"account_id"`
+	AsOf                string  `json:"as_of"`
+	Balance             float64 `json:"balance"`
+	SnapshotClosingAt   string  `json:"snapshot_closing_at,omitempty"`
+	TransactionsApplied int64   `json:"transactions_applied"`
 }
@@ -0,0 +1,28 @@
+package dto
+
+// AdjustmentRequest is a manual credit or debit made by a finance operator.
+type AdjustmentRequest struct {
+	// Amount credits the account when positive and debits it when negative.
+	Amount     float64 `json:"amount" binding:"required" example:"-25.00"`
+	ReasonCode string  `json:"reason_code" binding:"required" example:"write_off" enums:"goodwill,write_off,correction,fee_refund,chargeback,other"`
+	Note       string  `json:"note" binding:"required" example:"Unrecoverable negative balance"`
+}
+
+type ReviewAdjustmentRequest struct {
+	// Note is optional when approving and required when rejecting.
+	Note string `json:"note" example:"Checked against the bank statement"`
+}
+
+type AdjustmentResponse struct {
+	ID            string  `json:"id"`
+	AccountID     string  `json:"account_id"`
+	Amount        float64 `json:"amount" example:"-25.00"`
+	ReasonCode    string  `json:"reason_code" example:"write_off"`
+	Note          string  `json:"note"`
+	Status        string  `json:"status" example:"pending"`
+	RequestedBy   string  `json:"requested_by" example:"alice"`
+	ReviewedBy    string  `json:"reviewed_by,omitempty" example:"bob"`
+	ReviewNote    string  `json:"review_note,omitempty"`
+	ReviewedAt    string  `json:"reviewed_at,omitempty"`
+	TransactionID string  `json:"transaction_id,omitempty"`
+}
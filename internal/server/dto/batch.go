@@ -0,0 +1,40 @@
+package dto
+
+type CreateBatchRequest struct {
+	// Mode is atomic or best_effort, the default.
+	Mode  string             `json:"mode" example:"best_effort"`
+	Items []BatchItemRequest `json:"items" binding:"required"`
+}
+
+type BatchItemRequest struct {
+	AccountID string  `json:"account_id" example:"0b9e7c3a-5b1e-4d8e-9a57-3f2c1d0e9b8a"`
+	Operation string  `json:"operation" example:"top-up"`
+	Amount    float64 `json:"amount" example:"1250.00"`
+	Reference string  `json:"reference" example:"PAYROLL-2025-01-0042"`
+}
+
+type BatchResponse struct {
+	ID          string `json:"id"`
+	Mode        string `json:"mode" example:"best_effort"`
+	Status      string `json:"status" example:"completed"`
+	CreatedAt   string `json:"created_at"`
+	CompletedAt string `json:"completed_at,omitempty"`
+	Total       int    `json:"total"`
+	Pending     int    `json:"pending"`
+	Succeeded   int    `json:"succeeded"`
+	Failed      int    `json:"failed"`
+	Skipped     int    `json:"skipped"`
+	// Items are the per-item results, in submission order.
+	Items []BatchItemResult `json:"items"`
+}
+
+type BatchItemResult struct {
+	Index          int     `json:"index"`
+	AccountID      string  `json:"account_id"`
+	Operation      string  `json:"operation"`
+	Amount         float64 `json:"amount"`
+	Reference      string  `json:"reference"`
+	Status         string  `json:"status" example:"succeeded"`
+	TransactionRef string  `json:"transaction_ref,omitempty"`
+	Error          string  `json:"error,omitempty" example:"insufficient balance"`
+}
@@ -0,0 +1,39 @@
+package dto
+
+import "time"
+
+// DepositRequest is a bank transfer received by the wallet, as the bank
+// reports it.
+type DepositRequest struct {
+	// Reference is the bank's unique reference of the transfer.
+	Reference string  `json:"reference" binding:"required" example:"2025030300012345"`
+	Amount    float64 `json:"amount" binding:"required,gt=0" example:"120.00"`
+	// Currency defaults to the wallet's.
+	Currency string `json:"currency" example:"USD"`
+	// CreditorIBAN is the virtual IBAN the transfer was sent to.
+	CreditorIBAN string     `json:"creditor_iban" example:"DE02000000000123456789"`
+	DebtorName   string     `json:"debtor_name" example:"Jane Doe"`
+	DebtorIBAN   string     `json:"debtor_iban" example:"DE89370400440532013000"`
+	Remittance   string     `json:"remittance" example:"Wallet top-up"`
+	BookedAt     *time.Time `json:"booked_at"`
+}
+
+type AssignDepositRequest struct {
+	AccountID string `json:"account_id" binding:"required" example:"3f0c1e8a-2b7d-4c59-9e61-7a8b5d4c3e21"`
+}
+
+type DepositResponse struct {
+	ID            string  `json:"id"`
+	Reference     string  `json:"reference"`
+	Amount        float64 `json:"amount"`
+	Currency      string  `json:"currency"`
+	CreditorIBAN  string  `json:"creditor_iban"`
+	DebtorName    string  `json:"debtor_name"`
+	DebtorIBAN    string  `json:"debtor_iban"`
+	Remittance    string  `json:"remittance"`
+	BookedAt      string  `json:"booked_at,omitempty"`
+	Status        string  `json:"status" example:"unmatched"`
+	AccountID     string  `json:"account_id,omitempty"`
+	TransactionID string  `json:"transaction_id,omitempty"`
+	Reason        string  `json:"reason,omitempty" example:"no account has the virtual IBAN"`
+}
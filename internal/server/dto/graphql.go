@@ -0,0 +1,16 @@
+package dto
+
+type GraphQLRequest struct {
+	Query         string         `json:"query" binding:"required"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+type GraphQLError struct {
+	Message string `json:"message"`
+}
+
+type GraphQLResponse struct {
+	Data   map[string]any `json:"data,omitempty"`
+	Errors []GraphQLError `json:"errors,omitempty"`
+}
@@ -0,0 +1,17 @@
+package dto
+
+type RefundRequest struct {
+	Reason string `json:"reason" binding:"required" example:"customer request"`
+}
+
+type PaymentResponse struct {
+	ID            string  `json:"id"`
+	Provider      string  `json:"provider" example:"fake"`
+	IntentID      string  `json:"intent_id"`
+	TransactionID string  `json:"transaction_id"`
+	AccountID     string  `json:"account_id"`
+	Amount        float64 `json:"amount"`
+	Status        string  `json:"status" example:"refunded"`
+	FailureReason string  `json:"failure_reason,omitempty"`
+	RefundID      string  `json:"refund_id,omitempty"`
+}
@@ -0,0 +1,19 @@
+package dto
+
+// Problem is an RFC 7807 problem details response, served as
+// application/problem+json. Code is a stable, machine-readable error code.
+type Problem struct {
+	Type     string `json:"type" example:"urn:wallet:problem:insufficient_funds"`
+	Title    string `json:"title" example:"Insufficient funds"`
+	Status   int    `json:"status" example:"422"`
+	Detail   string `json:"detail,omitempty" example:"insufficient balance"`
+	Instance string `json:"instance,omitempty" example:"/api/v1/accounts/0b9e.../charge"`
+	Code     string `json:"code" example:"insufficient_funds"`
+	// Errors lists the invalid fields of a validation_failed problem.
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+type FieldError struct {
+	Field   string `json:"field" example:"amount"`
+	Message string `json:"message" example:"must be greater than 0"`
+}
@@ -0,0 +1,26 @@
+package dto
+
+type TransactionStatusRequest struct {
+	Reason string `json:"reason" example:"card authorisation expired"`
+}
+
+type ReverseTransactionRequest struct {
+	Reason string `json:"reason" binding:"required" example:"chargeback"`
+}
+
+type TransactionResponse struct {
+	TransactionID string `json:"transaction_id"`
+	AccountID     string `json:"account_id"`
+	// Type is top-up, charge or adjustment. The amounts of adjustments are
+	// signed, with debits negative.
+	Type   string  `json:"type" enums:"top-up,charge,adjustment" example:"charge"`
+	Amount float64 `json:"amount"`
+	Status string  `json:"status" example:"posted"`
+	TransactionDetails
+}
+
+type TransactionListResponse struct {
+	Transactions []TransactionResponse `json:"transactions"`
+	// NextCursor continues the listing; it is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
@@ -0,0 +1,45 @@
+package dto
+
+// CreateBeneficiaryRequest gives either an IBAN, for SEPA transfers, or a
+// routing and account number, for ACH transfers.
+type CreateBeneficiaryRequest struct {
+	Name          string `json:"name" binding:"required" example:"Jane Doe"`
+	IBAN          string `json:"iban" example:"DE89 3704 0044 0532 0130 00"`
+	BIC           string `json:"bic" example:"COBADEFFXXX"`
+	RoutingNumber string `json:"routing_number" example:"021000021"`
+	AccountNumber string `json:"account_number" example:"123456789"`
+	// Savings marks an ACH account as a savings rather than a checking
+	// account.
+	Savings bool `json:"savings"`
+}
+
+type BeneficiaryResponse struct {
+	ID            string `json:"id"`
+	AccountID     string `json:"account_id"`
+	Name          string `json:"name"`
+	IBAN          string `json:"iban,omitempty" example:"DE89370400440532013000"`
+	BIC           string `json:"bic,omitempty"`
+	RoutingNumber string `json:"routing_number,omitempty"`
+	AccountNumber string `json:"account_number,omitempty"`
+	Savings       bool   `json:"savings"`
+}
+
+type WithdrawalRequest struct {
+	BeneficiaryID string  `json:"beneficiary_id" binding:"required" example:"0b9e7c3a-5b1e-4d8e-9a57-3f2c1d0e9b8a"`
+	Amount        float64 `json:"amount" binding:"required,gt=0" example:"250.00"`
+}
+
+type WithdrawalResponse struct {
+	ID            string  `json:"id"`
+	AccountID     string  `json:"account_id"`
+	BeneficiaryID string  `json:"beneficiary_id"`
+	TransactionID string  `json:"transaction_id"`
+	Amount        float64 `json:"amount"`
+	Status        string  `json:"status" example:"submitted"`
+	EndToEndID    string  `json:"end_to_end_id" example:"WD0B9E7C3A5B1E4D8E9A573F2C1D0E9B8A"`
+	TraceNumber   string  `json:"trace_number,omitempty" example:"021000020000001"`
+	PayoutID      string  `json:"payout_id,omitempty"`
+	ReturnCode    string  `json:"return_code,omitempty" example:"AC04"`
+	ReturnReason  string  `json:"return_reason,omitempty" example:"Closed account number"`
+	CompletedAt   string  `json:"completed_at,omitempty"`
+}
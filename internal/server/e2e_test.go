@@ -0,0 +1,822 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"wallet/internal/cli"
+	"wallet/internal/database"
+	"wallet/internal/models"
+	"wallet/internal/payments"
+	"wallet/internal/server/dto"
+	"wallet/internal/services"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMain(m *testing.M) {
+	// Run a wallet command instead, for tests that work over the command
+	// line as well as the API
+	if command := os.Getenv("WALLET_TEST_COMMAND"); command != "" {
+		os.Exit(cli.Run(strings.Fields(command)))
+	}
+	os.Exit(m.Run())
+}
+
+// testAPI serves the full route table against its own temporary database.
+// Top-ups are collected by a fake payment provider that sends its webhooks
+// back to the API.
+type testAPI struct {
+	t        *testing.T
+	path     string
+	handler  http.Handler
+	payments *payments.FakeProvider
+}
+
+func newTestAPI(t *testing.T) *testAPI {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "wallet.db")
+	db, err := database.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	api := &testAPI{t: t, path: path}
+	webhooks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(webhooks.Close)
+	api.payments = payments.NewFakeProvider(webhooks.URL+"/api/v1/payments/webhooks/fake", []byte("test-secret"))
+	api.payments.Delay = 10 * time.Millisecond
+	// Deliver the last webhooks before the database is closed
+	t.Cleanup(api.payments.Wait)
+
+	s, err := newServer(db, api.payments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.operators = []services.Operator{{Name: "alice", Token: "alice-token"}, {Name: "bob", Token: "bob-token"}}
+	api.handler = s.RegisterRoutes()
+	return api
+}
+
+// forTest returns a copy of the API that reports failures to t, for subtests.
+func (a *testAPI) forTest(t *testing.T) *testAPI {
+	return &testAPI{t: t, path: a.path, handler: a.handler, payments: a.payments}
+}
+
+// do sends a request with an optional JSON body and returns the response.
+func (a *testAPI) do(method, path, body string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	return a.doWithHeaders(nil, method, path, body)
+}
+
+// doAs sends a request on behalf of one of the test operators, alice or
+// bob, authenticated by their token.
+func (a *testAPI) doAs(operator, method, path, body string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	return a.doWithHeaders(http.Header{"Authorization": {"Bearer " + operator + "-token"}}, method, path, body)
+}
+
+// doWithHeaders sends a request with the given headers.
+func (a *testAPI) doWithHeaders(header http.Header, method, path, body string) *httptest.ResponseRecorder {
+	a.t.Helper()
+	req := httptest.NewRequest(method, "/api/v1"+path, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rr := httptest.NewRecorder()
+	a.handler.ServeHTTP(rr, req)
+	return rr
+}
+
+// runCommand runs a wallet command against the API's database as the test
+// operator, alice or bob, whose token is given, and returns its output and
+// exit code.
+func (a *testAPI) runCommand(operator, command string) (string, int) {
+	a.t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(),
+		"WALLET_TEST_COMMAND="+command,
+		"WALLET_DB_URL="+a.path,
+		"OPERATOR_TOKENS=alice:alice-token,bob:bob-token",
+		"WALLET_OPERATOR_TOKEN="+operator+"-token",
+	)
+	output, err := cmd.CombinedOutput()
+	var exit *exec.ExitError
+	switch {
+	case errors.As(err, &exit):
+		return string(output), exit.ExitCode()
+	case err != nil:
+		a.t.Fatal(err)
+	}
+	return string(output), 0
+}
+
+// decode unmarshals the response body into v after checking the status.
+func (a *testAPI) decode(rr *httptest.ResponseRecorder, status int, v any) {
+	a.t.Helper()
+	if rr.Code != status {
+		a.t.Fatalf("got status %d want %d: %s", rr.Code, status, rr.Body)
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), v); err != nil {
+		a.t.Fatalf("decode %s: %v", rr.Body, err)
+	}
+}
+
+// expectProblem checks that the response is a problem with the given status
+// and code.
+func (a *testAPI) expectProblem(rr *httptest.ResponseRecorder, status int, code string) dto.Problem {
+	a.t.Helper()
+	var problem dto.Problem
+	a.decode(rr, status, &problem)
+	if problem.Code != code {
+		a.t.Errorf("got problem code %q want %q", problem.Code, code)
+	}
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/problem+json") {
+		a.t.Errorf("got content type %q want application/problem+json", ct)
+	}
+	return problem
+}
+
+func (a *testAPI) createAccount(email string) models.Account {
+	a.t.Helper()
+	var account models.Account
+	body := fmt.Sprintf(`{"email": %q, "first_name": "Jane", "last_name": "Doe"}`, email)
+	a.decode(a.do(http.MethodPost, "/accounts", body), http.StatusCreated, &account)
+	return account
+}
+
+func (a *testAPI) topUp(accountID uuid.UUID, amount float64) models.Transaction {
+	a.t.Helper()
+	return a.moveFunds("top-up", accountID, amount)
+}
+
+func (a *testAPI) charge(accountID uuid.UUID, amount float64) models.Transaction {
+	a.t.Helper()
+	return a.moveFunds("charge", accountID, amount)
+}
+
+func (a *testAPI) moveFunds(operation string, accountID uuid.UUID, amount float64) models.Transaction {
+	a.t.Helper()
+	var transaction models.Transaction
+	path := "/accounts/" + accountID.String() + "/" + operation
+	a.decode(a.do(http.MethodPost, path, fmt.Sprintf(`{"amount": %v}`, amount)), http.StatusOK, &transaction)
+	return transaction
+}
+
+func (a *testAPI) balance(accountID uuid.UUID) float64 {
+	a.t.Helper()
+	var balance dto.BalanceResponse
+	a.decode(a.do(http.MethodGet, "/accounts/"+accountID.String()+"/balance", ""), http.StatusOK, &balance)
+	return balance.Balance
+}
+
+func TestAPICreateAccount(t *testing.T) {
+	api := newTestAPI(t)
+
+	account := api.createAccount("jane@example.com")
+
+	if account.ID == uuid.Nil || account.Balance != 0 || account.Status != models.AccountActive {
+		t.Errorf("got account %s with balance %v and status %q, want a new active account with balance 0", account.ID, account.Balance, account.Status)
+	}
+	if account.User.Email != "jane@example.com" || account.User.FirstName != "Jane" {
+		t.Errorf("got user %q (%s) want jane@example.com (Jane)", account.User.Email, account.User.FirstName)
+	}
+	if got := api.balance(account.ID); got != 0 {
+		t.Errorf("got balance %v want 0", got)
+	}
+}
+
+func TestAPIDuplicateEmail(t *testing.T) {
+	api := newTestAPI(t)
+	api.createAccount("jane@example.com")
+
+	rr := api.do(http.MethodPost, "/accounts", `{"email": "jane@example.com", "first_name": "Jane", "last_name": "Roe"}`)
+
+	api.expectProblem(rr, http.StatusConflict, CodeDuplicateUser)
+}
+
+func TestAPITopUpAndCharge(t *testing.T) {
+	api := newTestAPI(t)
+	account := api.createAccount("jane@example.com")
+
+	topUp := api.topUp(account.ID, 100)
+	if topUp.TransactionType != models.TopUp || topUp.Amount != 100 || topUp.Account.Balance != 100 {
+		t.Errorf("got %s of %v with balance %v want top-up of 100 with balance 100", topUp.TransactionType, topUp.Amount, topUp.Account.Balance)
+	}
+
+	charge := api.charge(account.ID, 30.25)
+	if charge.TransactionType != models.Charge || charge.Amount != 30.25 || charge.Account.Balance != 69.75 {
+		t.Errorf("got %s of %v with balance %v want charge of 30.25 with balance 69.75", charge.TransactionType, charge.Amount, charge.Account.Balance)
+	}
+	if topUp.Ref == charge.Ref {
+		t.Errorf("transactions share the ref %s", topUp.Ref)
+	}
+
+	if got := api.balance(account.ID); got != 69.75 {
+		t.Errorf("got balance %v want 69.75", got)
+	}
+}
+
+func TestAPITracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	propagator := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTextMapPropagator(propagator) })
+
+	api := newTestAPI(t)
+	account := api.createAccount("jane@example.com")
+	api.topUp(account.ID, 100)
+
+	// A charge made by a caller that is tracing it already
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	callerSpanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	header := http.Header{"Traceparent": {"00-" + traceID.String() + "-" + callerSpanID.String() + "-01"}}
+	rr := api.doWithHeaders(header, http.MethodPost, "/accounts/"+account.ID.String()+"/charge", `{"amount": 30}`)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d want %d: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+
+	// Index the charge's spans and check each layer nests in the one above
+	spans := map[trace.SpanID]sdktrace.ReadOnlySpan{}
+	var request, charge sdktrace.ReadOnlySpan
+	var queries []sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		if span.SpanContext().TraceID() != traceID {
+			continue
+		}
+		spans[span.SpanContext().SpanID()] = span
+		switch {
+		case span.SpanKind() == trace.SpanKindServer:
+			request = span
+		case span.Name() == "AccountService.Charge":
+			charge = span
+		case strings.HasPrefix(span.Name(), "gorm."):
+			queries = append(queries, span)
+		}
+	}
+	if request == nil || charge == nil || len(queries) == 0 {
+		t.Fatalf("got spans %v want the request, the charge and its queries in the caller's trace", spans)
+	}
+	if request.Parent().SpanID() != callerSpanID {
+		t.Errorf("request span: got parent %v want the caller's span %v", request.Parent().SpanID(), callerSpanID)
+	}
+	if !descendsFrom(spans, charge, request) {
+		t.Errorf("charge span %q does not nest under the request span", charge.Name())
+	}
+	for _, query := range queries {
+		if !descendsFrom(spans, query, charge) {
+			t.Errorf("query span %q does not nest under the charge span", query.Name())
+		}
+	}
+}
+
+// descendsFrom reports whether span is a descendant of ancestor.
+func descendsFrom(spans map[trace.SpanID]sdktrace.ReadOnlySpan, span, ancestor sdktrace.ReadOnlySpan) bool {
+	for parent := spans[span.Parent().SpanID()]; parent != nil; parent = spans[parent.Parent().SpanID()] {
+		if parent.SpanContext().SpanID() == ancestor.SpanContext().SpanID() {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAPIInsufficientBalance(t *testing.T) {
+	api := newTestAPI(t)
+	account := api.createAccount("jane@example.com")
+	api.topUp(account.ID, 10)
+
+	rr := api.do(http.MethodPost, "/accounts/"+account.ID.String()+"/charge", `{"amount": 10.01}`)
+
+	api.expectProblem(rr, http.StatusUnprocessableEntity, CodeInsufficientFunds)
+	if got := api.balance(account.ID); got != 10 {
+		t.Errorf("got balance %v after a rejected charge want 10", got)
+	}
+}
+
+func TestAPIUnknownAccount(t *testing.T) {
+	api := newTestAPI(t)
+	id := uuid.NewString()
+
+	api.expectProblem(api.do(http.MethodPost, "/accounts/"+id+"/top-up", `{"amount": 1}`), http.StatusNotFound, CodeAccountNotFound)
+	api.expectProblem(api.do(http.MethodPost, "/accounts/"+id+"/charge", `{"amount": 1}`), http.StatusNotFound, CodeAccountNotFound)
+	api.expectProblem(api.do(http.MethodGet, "/accounts/"+id+"/balance", ""), http.StatusNotFound, CodeAccountNotFound)
+}
+
+func TestAPIInvalidAccountID(t *testing.T) {
+	api := newTestAPI(t)
+
+	tests := []struct {
+		method, path, body string
+	}{
+		{http.MethodPost, "/accounts/not-a-uuid/top-up", `{"amount": 1}`},
+		{http.MethodPost, "/accounts/123/charge", `{"amount": 1}`},
+		{http.MethodGet, "/accounts/" + strings.Repeat("x", 36) + "/balance", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			api := api.forTest(t)
+			api.expectProblem(api.do(tt.method, tt.path, tt.body), http.StatusBadRequest, CodeInvalidAccountID)
+		})
+	}
+}
+
+func TestAPIMalformedBodies(t *testing.T) {
+	api := newTestAPI(t)
+	account := api.createAccount("jane@example.com")
+	topUp := "/accounts/" + account.ID.String() + "/top-up"
+
+	tests := []struct {
+		name   string
+		path   string
+		body   string
+		status int
+		code   string
+		field  string
+	}{
+		{"empty body", topUp, "", http.StatusBadRequest, CodeMalformedRequest, ""},
+		{"invalid JSON", topUp, `{"amount": `, http.StatusBadRequest, CodeMalformedRequest, ""},
+		{"amount as string", topUp, `{"amount": "ten"}`, http.StatusBadRequest, CodeMalformedRequest, ""},
+		{"missing amount", topUp, `{}`, http.StatusUnprocessableEntity, CodeValidationFailed, "amount"},
+		{"negative amount", topUp, `{"amount": -5}`, http.StatusUnprocessableEntity, CodeValidationFailed, "amount"},
+		{"missing email", "/accounts", `{"first_name": "Jane", "last_name": "Doe"}`, http.StatusUnprocessableEntity, CodeValidationFailed, "email"},
+		{"invalid email", "/accounts", `{"email": "jane", "first_name": "Jane", "last_name": "Doe"}`, http.StatusUnprocessableEntity, CodeValidationFailed, "email"},
+		{"missing names", "/accounts", `{"email": "john@example.com"}`, http.StatusUnprocessableEntity, CodeValidationFailed, "first_name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := api.forTest(t)
+			problem := api.expectProblem(api.do(http.MethodPost, tt.path, tt.body), tt.status, tt.code)
+			if tt.field != "" && (len(problem.Errors) == 0 || problem.Errors[0].Field != tt.field) {
+				t.Errorf("got field errors %+v want one for %q", problem.Errors, tt.field)
+			}
+		})
+	}
+
+	// Nothing was written by the rejected requests
+	if got := api.balance(account.ID); got != 0 {
+		t.Errorf("got balance %v want 0", got)
+	}
+}
+
+func TestAPIPendingTransactions(t *testing.T) {
+	api := newTestAPI(t)
+	account := api.createAccount("jane@example.com")
+	accountPath := "/accounts/" + account.ID.String()
+	api.topUp(account.ID, 100)
+
+	var hold models.Transaction
+	api.decode(api.do(http.MethodPost, accountPath+"/charge", `{"amount": 40, "pending": true}`), http.StatusOK, &hold)
+	if hold.Status != models.TransactionPending || hold.Account.Balance != 100 || hold.Account.AvailableBalance != 60 {
+		t.Errorf("got %s charge with balance %v and %v available want pending with 100 and 60 available", hold.Status, hold.Account.Balance, hold.Account.AvailableBalance)
+	}
+	api.expectProblem(api.do(http.MethodPost, accountPath+"/charge", `{"amount": 70}`), http.StatusUnprocessableEntity, CodeInsufficientFunds)
+
+	// Only operators change the status of transactions
+	settlePath := "/transactions/" + hold.ID.String() + "/settle"
+	api.expectProblem(api.do(http.MethodPost, settlePath, ""), http.StatusUnauthorized, CodeUnauthorized)
+	api.expectProblem(api.doWithHeaders(http.Header{"X-Actor": {"alice"}}, http.MethodPost, settlePath, ""), http.StatusUnauthorized, CodeUnauthorized)
+	var settled models.Transaction
+	api.decode(api.doAs("alice", http.MethodPost, settlePath, ""), http.StatusOK, &settled)
+	if settled.Status != models.TransactionPosted || settled.PostedAt == nil {
+		t.Errorf("got %s posted at %v want posted", settled.Status, settled.PostedAt)
+	}
+	if got := api.balance(account.ID); got != 60 {
+		t.Errorf("got balance %v after settling want 60", got)
+	}
+	api.expectProblem(api.doAs("alice", http.MethodPost, "/transactions/"+hold.ID.String()+"/fail", ""), http.StatusConflict, CodeInvalidTransition)
+
+	var reversal models.Transaction
+	api.expectProblem(api.doAs("alice", http.MethodPost, "/transactions/"+hold.ID.String()+"/reverse", `{}`), http.StatusUnprocessableEntity, CodeValidationFailed)
+	api.decode(api.doAs("alice", http.MethodPost, "/transactions/"+hold.ID.String()+"/reverse", `{"reason": "chargeback"}`), http.StatusOK, &reversal)
+	if reversal.TransactionType != models.TopUp || reversal.ReversalOfID == nil || *reversal.ReversalOfID != hold.ID {
+		t.Errorf("got %s reversing %v want a top-up reversing %s", reversal.TransactionType, reversal.ReversalOfID, hold.ID)
+	}
+	if got := api.balance(account.ID); got != 100 {
+		t.Errorf("got balance %v after reversing want 100", got)
+	}
+
+	var topUp models.Transaction
+	api.decode(api.do(http.MethodPost, accountPath+"/top-up", `{"amount": 5, "pending": true}`), http.StatusOK, &topUp)
+	var failed models.Transaction
+	api.decode(api.doAs("alice", http.MethodPost, "/transactions/"+topUp.ID.String()+"/fail", `{"reason": "bounced"}`), http.StatusOK, &failed)
+	if failed.Status != models.TransactionFailed || failed.Account.Balance != 100 {
+		t.Errorf("got %s top-up with balance %v want failed with 100", failed.Status, failed.Account.Balance)
+	}
+
+	api.expectProblem(api.doAs("alice", http.MethodPost, "/transactions/nope/settle", ""), http.StatusBadRequest, CodeInvalidTransactionID)
+	api.expectProblem(api.doAs("alice", http.MethodPost, "/transactions/"+uuid.NewString()+"/settle", ""), http.StatusNotFound, CodeTransactionNotFound)
+}
+
+func TestAPIAdjustments(t *testing.T) {
+	api := newTestAPI(t)
+	account := api.createAccount("jane@example.com")
+	adjustmentsPath := "/accounts/" + account.ID.String() + "/adjustments"
+
+	var goodwill models.Adjustment
+	api.decode(api.doAs("alice", http.MethodPost, adjustmentsPath, `{"amount": 20, "reason_code": "goodwill", "note": "late delivery"}`), http.StatusCreated, &goodwill)
+	if goodwill.Status != models.AdjustmentApproved || goodwill.TransactionID == nil {
+		t.Errorf("got %s adjustment with transaction %v want approved and posted", goodwill.Status, goodwill.TransactionID)
+	}
+	api.expectProblem(api.doAs("alice", http.MethodPost, adjustmentsPath, `{"amount": 20, "reason_code": "bonus", "note": "promo"}`), http.StatusUnprocessableEntity, CodeValidationFailed)
+
+	var correction models.Adjustment
+	api.decode(api.doAs("alice", http.MethodPost, adjustmentsPath, `{"amount": 250, "reason_code": "correction", "note": "missed top-up"}`), http.StatusCreated, &correction)
+	if correction.Status != models.AdjustmentPending || correction.RequestedBy != "alice" {
+		t.Errorf("got %s adjustment requested by %q want pending requested by alice", correction.Status, correction.RequestedBy)
+	}
+	if got := api.balance(account.ID); got != 20 {
+		t.Errorf("got balance %v with the correction pending want 20", got)
+	}
+
+	var pending []models.Adjustment
+	api.decode(api.do(http.MethodGet, "/adjustments", ""), http.StatusOK, &pending)
+	if len(pending) != 1 || pending[0].ID != correction.ID {
+		t.Errorf("got %d pending adjustments want the correction only", len(pending))
+	}
+
+	approvePath := "/adjustments/" + correction.ID.String() + "/approve"
+	api.expectProblem(api.doAs("alice", http.MethodPost, approvePath, ""), http.StatusForbidden, CodeSelfApproval)
+	// Operators are told apart by their token, whatever X-Actor says
+	asBob := http.Header{"Authorization": {"Bearer alice-token"}, "X-Actor": {"bob"}}
+	api.expectProblem(api.doWithHeaders(asBob, http.MethodPost, approvePath, ""), http.StatusForbidden, CodeSelfApproval)
+	api.expectProblem(api.doWithHeaders(http.Header{"X-Actor": {"bob"}}, http.MethodPost, approvePath, ""), http.StatusUnauthorized, CodeUnauthorized)
+	api.expectProblem(api.doWithHeaders(http.Header{"Authorization": {"Bearer carol-token"}}, http.MethodPost, approvePath, ""), http.StatusUnauthorized, CodeUnauthorized)
+	var approved models.Adjustment
+	api.decode(api.doAs("bob", http.MethodPost, approvePath, `{"note": "matches the bank statement"}`), http.StatusOK, &approved)
+	if approved.Status != models.AdjustmentApproved || approved.ReviewedBy != "bob" {
+		t.Errorf("got %s adjustment reviewed by %q want approved by bob", approved.Status, approved.ReviewedBy)
+	}
+	if got := api.balance(account.ID); got != 270 {
+		t.Errorf("got balance %v after approving want 270", got)
+	}
+	var page services.TransactionPage
+	api.decode(api.do(http.MethodGet, "/accounts/"+account.ID.String()+"/transactions", ""), http.StatusOK, &page)
+	for _, transaction := range page.Transactions {
+		if transaction.TransactionType != models.AdjustmentTransaction {
+			t.Errorf("got %s transaction of %v want adjustments only", transaction.TransactionType, transaction.Amount)
+		}
+	}
+	api.expectProblem(api.doAs("bob", http.MethodPost, "/adjustments/"+correction.ID.String()+"/reject", `{"note": "too late"}`), http.StatusConflict, CodeAdjustmentNotPending)
+
+	api.expectProblem(api.do(http.MethodGet, "/adjustments/nope", ""), http.StatusBadRequest, CodeInvalidAdjustmentID)
+	api.expectProblem(api.do(http.MethodGet, "/adjustments/"+uuid.NewString(), ""), http.StatusNotFound, CodeAdjustmentNotFound)
+}
+
+func TestAdjustmentReviewAcrossChannels(t *testing.T) {
+	api := newTestAPI(t)
+	account := api.createAccount("jane@example.com")
+	var correction models.Adjustment
+	api.decode(api.doAs("alice", http.MethodPost, "/accounts/"+account.ID.String()+"/adjustments", `{"amount": 250, "reason_code": "correction", "note": "missed top-up"}`), http.StatusCreated, &correction)
+
+	// An operator is the same on the command line as over the API
+	approve := "adjustments approve " + correction.ID.String()
+	if output, code := api.runCommand("alice", approve); code != 1 || !strings.Contains(output, services.ErrSelfApproval.Error()) {
+		t.Errorf("approving on the command line: got exit code %d and output %q want a self-approval error", code, output)
+	}
+	if output, code := api.runCommand("carol", approve); code != 1 || !strings.Contains(output, "unknown operator token") {
+		t.Errorf("approving with an unknown token: got exit code %d and output %q want 1", code, output)
+	}
+	if got := api.balance(account.ID); got != 0 {
+		t.Errorf("got balance %v with the correction pending want 0", got)
+	}
+	if output, code := api.runCommand("bob", approve); code != 0 || !strings.Contains(output, "is now approved") {
+		t.Errorf("approving as another operator: got exit code %d and output %q want 0", code, output)
+	}
+	if got := api.balance(account.ID); got != 250 {
+		t.Errorf("got balance %v after approving want 250", got)
+	}
+}
+
+func TestAPIDeposits(t *testing.T) {
+	api := newTestAPI(t)
+	account := api.createAccount("jane@example.com")
+	if account.VirtualIBAN == nil {
+		t.Fatal("got an account without a virtual IBAN")
+	}
+
+	// Deposits credit accounts, so only operators may record them
+	matched := fmt.Sprintf(`{"reference": "REF-1", "amount": 120, "creditor_iban": %q}`, *account.VirtualIBAN)
+	api.expectProblem(api.do(http.MethodPost, "/deposits", matched), http.StatusUnauthorized, CodeUnauthorized)
+	api.expectProblem(api.doWithHeaders(http.Header{"X-Actor": {"alice"}}, http.MethodPost, "/deposits", matched), http.StatusUnauthorized, CodeUnauthorized)
+	var deposit models.Deposit
+	api.decode(api.doAs("alice", http.MethodPost, "/deposits", matched), http.StatusCreated, &deposit)
+	if deposit.Status != models.DepositMatched || deposit.AccountID == nil || *deposit.AccountID != account.ID {
+		t.Errorf("got %s deposit for account %v want matched to %s", deposit.Status, deposit.AccountID, account.ID)
+	}
+
+	var unmatched models.Deposit
+	api.decode(api.doAs("alice", http.MethodPost, "/deposits", `{"reference": "REF-2", "amount": 30, "creditor_iban": "DE89370400440532013000"}`), http.StatusCreated, &unmatched)
+	if unmatched.Status != models.DepositUnmatched {
+		t.Errorf("got %s deposit to an unknown IBAN want unmatched", unmatched.Status)
+	}
+	assignPath := "/deposits/" + unmatched.ID.String() + "/assign"
+	assign := fmt.Sprintf(`{"account_id": %q}`, account.ID)
+	api.expectProblem(api.do(http.MethodPost, assignPath, assign), http.StatusUnauthorized, CodeUnauthorized)
+	api.decode(api.doAs("bob", http.MethodPost, assignPath, assign), http.StatusOK, &unmatched)
+	if unmatched.Status != models.DepositAssigned {
+		t.Errorf("got %s deposit after assigning it want assigned", unmatched.Status)
+	}
+
+	if got := api.balance(account.ID); got != 150 {
+		t.Errorf("got balance %v want 150", got)
+	}
+}
+
+func TestAPITransactionDetails(t *testing.T) {
+	api := newTestAPI(t)
+	account := api.createAccount("jane@example.com")
+	accountPath := "/accounts/" + account.ID.String()
+
+	var topUp models.Transaction
+	api.decode(api.do(http.MethodPost, accountPath+"/top-up", `{"amount": 50, "description": "Salary", "external_ref": "payroll-03", "metadata": {"employer": "Acme"}}`), http.StatusOK, &topUp)
+	if topUp.Description != "Salary" || topUp.ExternalRef != "payroll-03" || string(topUp.Metadata) != `{"employer":"Acme"}` {
+		t.Errorf("got top-up %q %q %s want the details it was made with", topUp.Description, topUp.ExternalRef, topUp.Metadata)
+	}
+	api.decode(api.do(http.MethodPost, accountPath+"/charge", `{"amount": 12, "description": "Weekly groceries", "merchant_name": "Corner Shop", "merchant_category_code": "5411"}`), http.StatusOK, &models.Transaction{})
+	api.charge(account.ID, 3)
+
+	problem := api.expectProblem(api.do(http.MethodPost, accountPath+"/charge", `{"amount": 1, "metadata": [1, 2]}`), http.StatusUnprocessableEntity, CodeValidationFailed)
+	if len(problem.Errors) == 0 || problem.Errors[0].Field != "metadata" {
+		t.Errorf("got problem errors %v want one on metadata", problem.Errors)
+	}
+
+	for query, want := range map[string]int{
+		"":                                      3,
+		"?q=corner":                             1,
+		"?external_ref=payroll-03":              1,
+		"?merchant_category_code=5411":          1,
+		"?q=salary&merchant_category_code=5411": 0,
+	} {
+		var page dto.TransactionListResponse
+		api.decode(api.do(http.MethodGet, accountPath+"/transactions"+query, ""), http.StatusOK, &page)
+		if len(page.Transactions) != want {
+			t.Errorf("transactions%s: got %d want %d", query, len(page.Transactions), want)
+		}
+	}
+	api.expectProblem(api.do(http.MethodGet, accountPath+"/transactions?merchant_category_code=shop", ""), http.StatusUnprocessableEntity, CodeValidationFailed)
+}
+
+func TestAPIPaymentProvider(t *testing.T) {
+	api := newTestAPI(t)
+	account := api.createAccount("jane@example.com")
+	topUp := "/accounts/" + account.ID.String() + "/top-up"
+
+	var delayed models.Transaction
+	api.decode(api.do(http.MethodPost, topUp, `{"amount": 25, "payment_method": "fake_card_delayed"}`), http.StatusOK, &delayed)
+	if delayed.Status != models.TransactionPending || delayed.Account.Balance != 0 {
+		t.Errorf("got %s top-up with balance %v want pending with 0", delayed.Status, delayed.Account.Balance)
+	}
+	api.expectProblem(api.doAs("alice", http.MethodPost, "/transactions/"+delayed.ID.String()+"/settle", ""), http.StatusConflict, CodeInvalidTransition)
+	api.payments.Wait()
+	if got := api.balance(account.ID); got != 25 {
+		t.Errorf("got balance %v after the webhook want 25", got)
+	}
+
+	api.expectProblem(api.do(http.MethodPost, topUp, `{"amount": 10, "payment_method": "fake_card_declined"}`), http.StatusPaymentRequired, CodePaymentDeclined)
+	problem := api.expectProblem(api.do(http.MethodPost, topUp, `{"amount": 10, "payment_method": "cash"}`), http.StatusUnprocessableEntity, CodeValidationFailed)
+	if len(problem.Errors) == 0 || problem.Errors[0].Field != "payment_method" {
+		t.Errorf("got field errors %+v want one for payment_method", problem.Errors)
+	}
+
+	var payment models.Payment
+	api.decode(api.doAs("alice", http.MethodPost, "/transactions/"+delayed.ID.String()+"/refund", `{"reason": "customer request"}`), http.StatusOK, &payment)
+	if payment.Status != models.PaymentRefunded || payment.TransactionID != delayed.ID {
+		t.Errorf("got %s payment of %s want the refunded payment of %s", payment.Status, payment.TransactionID, delayed.ID)
+	}
+	if got := api.balance(account.ID); got != 0 {
+		t.Errorf("got balance %v after the refund want 0", got)
+	}
+
+	webhook := httptest.NewRequest(http.MethodPost, "/api/v1/payments/webhooks/fake", strings.NewReader(`{"intent_id": "fake_pi_1", "status": "succeeded"}`))
+	webhook.Header.Set(payments.FakeSignatureHeader, fmt.Sprintf("t=%d,v1=00", time.Now().Unix()))
+	rr := httptest.NewRecorder()
+	api.handler.ServeHTTP(rr, webhook)
+	api.expectProblem(rr, http.StatusBadRequest, CodeInvalidWebhook)
+}
+
+// waitForBatch polls a batch until it has been processed.
+func (a *testAPI) waitForBatch(id string) dto.BatchResponse {
+	a.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var batch dto.BatchResponse
+		a.decode(a.do(http.MethodGet, "/batches/"+id, ""), http.StatusOK, &batch)
+		if batch.Status != string(models.BatchPending) && batch.Status != string(models.BatchProcessing) {
+			return batch
+		}
+		if time.Now().After(deadline) {
+			a.t.Fatalf("batch %s still %s", id, batch.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAPIBatch(t *testing.T) {
+	api := newTestAPI(t)
+	jane := api.createAccount("jane@example.com")
+	john := api.createAccount("john@example.com")
+
+	body := fmt.Sprintf(`{"mode": "best_effort", "items": [
+		{"account_id": %q, "operation": "top-up", "amount": 1200, "reference": "PAYROLL-1"},
+		{"account_id": %q, "operation": "top-up", "amount": 950.5, "reference": "PAYROLL-2"},
+		{"account_id": %q, "operation": "charge", "amount": 5000, "reference": "PAYROLL-3"}
+	]}`, jane.ID, john.ID, john.ID)
+	// Batches credit the ledger directly, so only operators may submit them
+	api.expectProblem(api.do(http.MethodPost, "/batches", body), http.StatusUnauthorized, CodeUnauthorized)
+	rr := api.doAs("alice", http.MethodPost, "/batches", body)
+
+	var accepted dto.BatchResponse
+	api.decode(rr, http.StatusAccepted, &accepted)
+	if location := rr.Header().Get("Location"); location != "/api/v1/batches/"+accepted.ID {
+		t.Errorf("got location %q want /api/v1/batches/%s", location, accepted.ID)
+	}
+	if accepted.Total != 3 {
+		t.Errorf("got %d items want 3", accepted.Total)
+	}
+
+	batch := api.waitForBatch(accepted.ID)
+	if batch.Status != "completed" || batch.Succeeded != 2 || batch.Failed != 1 {
+		t.Errorf("got %s batch with %d succeeded and %d failed want completed with 2 and 1", batch.Status, batch.Succeeded, batch.Failed)
+	}
+	if item := batch.Items[2]; item.Status != "failed" || item.Error != "insufficient balance" {
+		t.Errorf("got items[2] %s with error %q want failed with insufficient balance", item.Status, item.Error)
+	}
+	if got := api.balance(jane.ID); got != 1200 {
+		t.Errorf("got balance %v want 1200", got)
+	}
+	if got := api.balance(john.ID); got != 950.5 {
+		t.Errorf("got balance %v want 950.5", got)
+	}
+
+	// Submitting the same payroll run again is refused
+	api.expectProblem(api.doAs("alice", http.MethodPost, "/batches", body), http.StatusConflict, CodeDuplicateReference)
+}
+
+func TestAPIBatchCSVUpload(t *testing.T) {
+	api := newTestAPI(t)
+	account := api.createAccount("jane@example.com")
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	part, err := writer.CreateFormFile("file", "payroll.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprintf(part, "reference,account_id,amount,operation\nCSV-1,%s,10,top-up\nCSV-2,%[1]s,25,charge\n", account.ID)
+	writer.WriteField("mode", "atomic")
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/batches", &form)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer alice-token")
+	rr := httptest.NewRecorder()
+	api.handler.ServeHTTP(rr, req)
+
+	var accepted dto.BatchResponse
+	api.decode(rr, http.StatusAccepted, &accepted)
+	if accepted.Mode != "atomic" || accepted.Total != 2 {
+		t.Errorf("got %s batch of %d items want atomic batch of 2", accepted.Mode, accepted.Total)
+	}
+
+	// The charge fails, so the top-up is rolled back with it
+	batch := api.waitForBatch(accepted.ID)
+	if batch.Status != "failed" || batch.Failed != 1 || batch.Skipped != 1 {
+		t.Errorf("got %s batch with %d failed and %d skipped want failed with 1 and 1", batch.Status, batch.Failed, batch.Skipped)
+	}
+	if got := api.balance(account.ID); got != 0 {
+		t.Errorf("got balance %v want 0", got)
+	}
+}
+
+func TestAPIBatchInvalid(t *testing.T) {
+	api := newTestAPI(t)
+	account := api.createAccount("jane@example.com")
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+		field  string
+	}{
+		{"no items", `{"items": []}`, http.StatusUnprocessableEntity, CodeValidationFailed, "items"},
+		{"unknown mode", fmt.Sprintf(`{"mode": "eventually", "items": [{"account_id": %q, "operation": "top-up", "amount": 1, "reference": "A"}]}`, account.ID), http.StatusUnprocessableEntity, CodeValidationFailed, "mode"},
+		{"invalid account ID", `{"items": [{"account_id": "nope", "operation": "top-up", "amount": 1, "reference": "A"}]}`, http.StatusUnprocessableEntity, CodeValidationFailed, "items[0].account_id"},
+		{"unknown operation", fmt.Sprintf(`{"items": [{"account_id": %q, "operation": "refund", "amount": 1, "reference": "A"}]}`, account.ID), http.StatusUnprocessableEntity, CodeValidationFailed, "items[0].operation"},
+		{"duplicate reference", fmt.Sprintf(`{"items": [{"account_id": %[1]q, "operation": "top-up", "amount": 1, "reference": "A"}, {"account_id": %[1]q, "operation": "top-up", "amount": 2, "reference": "A"}]}`, account.ID), http.StatusUnprocessableEntity, CodeValidationFailed, "items[1].reference"},
+		{"invalid JSON", `{"items": [`, http.StatusBadRequest, CodeMalformedRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := api.forTest(t)
+			problem := api.expectProblem(api.doAs("alice", http.MethodPost, "/batches", tt.body), tt.status, tt.code)
+			if tt.field != "" && (len(problem.Errors) == 0 || problem.Errors[0].Field != tt.field) {
+				t.Errorf("got field errors %+v want one for %q", problem.Errors, tt.field)
+			}
+		})
+	}
+
+	api.expectProblem(api.do(http.MethodGet, "/batches/nope", ""), http.StatusBadRequest, CodeInvalidBatchID)
+	api.expectProblem(api.do(http.MethodGet, "/batches/"+uuid.NewString(), ""), http.StatusNotFound, CodeBatchNotFound)
+}
+
+func TestAPIStatements(t *testing.T) {
+	api := newTestAPI(t)
+	account := api.createAccount("jane@example.com")
+	api.topUp(account.ID, 100)
+	charge := api.charge(account.ID, 30.25)
+
+	today := time.Now().Format(time.DateOnly)
+	path := "/accounts/" + account.ID.String() + "/statements?from=" + today + "&to=" + today
+
+	rr := api.do(http.MethodGet, path, "")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("got status %d want %d: %s", rr.Code, http.StatusOK, rr.Body)
+	}
+	if got := rr.Header().Get("Content-Disposition"); !strings.Contains(got, ".csv") {
+		t.Errorf("got Content-Disposition %q want a .csv attachment", got)
+	}
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	want := []string{",opening_balance,Opening balance,,0.00,USD", ",top-up,Top-up,100.00,100.00,USD", charge.Ref + ",charge,Charge,-30.25,69.75,USD", ",closing_balance,Closing balance,,69.75,USD"}
+	if len(lines) != len(want)+1 {
+		t.Fatalf("got %d lines want %d:\n%s", len(lines), len(want)+1, rr.Body)
+	}
+	for i, suffix := range want {
+		if !strings.HasSuffix(lines[i+1], suffix) {
+			t.Errorf("got line %q want it to end with %q", lines[i+1], suffix)
+		}
+	}
+
+	rr = api.do(http.MethodGet, path+"&format=ofx", "")
+	if body := rr.Body.String(); strings.Count(body, "<STMTTRN>") != 2 || !strings.Contains(body, "<LEDGERBAL><BALAMT>69.75") {
+		t.Errorf("got OFX statement:\n%s\nwant two transactions and a closing balance of 69.75", body)
+	}
+
+	rr = api.do(http.MethodGet, path+"&format=pdf", "")
+	if body := rr.Body.String(); rr.Header().Get("Content-Type") != "application/pdf" || !strings.HasPrefix(body, "%PDF-") || !strings.HasSuffix(body, "%%EOF\n") {
+		t.Errorf("got %s response %.20q want a PDF document", rr.Header().Get("Content-Type"), body)
+	}
+
+	rr = api.do(http.MethodGet, path+"&format=mt940", "")
+	for _, want := range []string{":60F:C", "USD0,00\r\n", "C100,00NTRF", "D30,25NMSC", "\r\n" + charge.Ref + "\r\n", ":62F:C", "USD69,75\r\n-\r\n"} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("got MT940 statement:\n%s\nwant it to contain %q", rr.Body, want)
+		}
+	}
+
+	var camt struct {
+		Balances []struct {
+			Code   string `xml:"Tp>CdOrPrtry>Cd"`
+			Amount string `xml:"Amt"`
+		} `xml:"BkToCstmrStmt>Stmt>Bal"`
+		Entries []struct {
+			Indicator  string `xml:"CdtDbtInd"`
+			Remittance string `xml:"NtryDtls>TxDtls>RmtInf>Ustrd"`
+		} `xml:"BkToCstmrStmt>Stmt>Ntry"`
+	}
+	rr = api.do(http.MethodGet, path+"&format=camt053", "")
+	if err := xml.Unmarshal(rr.Body.Bytes(), &camt); err != nil {
+		t.Fatalf("got invalid camt.053 statement: %v\n%s", err, rr.Body)
+	}
+	if len(camt.Balances) != 2 || camt.Balances[0].Code != "OPBD" || camt.Balances[1].Amount != "69.75" {
+		t.Errorf("got balances %+v want OPBD 0.00 and CLBD 69.75", camt.Balances)
+	}
+	if len(camt.Entries) != 2 || camt.Entries[1].Indicator != "DBIT" || camt.Entries[1].Remittance != charge.Ref {
+		t.Errorf("got entries %+v want a credit and a debit of %s", camt.Entries, charge.Ref)
+	}
+
+	// A period after the transactions opens and closes on the final balance
+	later := url.Values{"from": {time.Now().Add(time.Hour).Format(time.RFC3339)}, "to": {time.Now().Add(2 * time.Hour).Format(time.RFC3339)}}
+	rr = api.do(http.MethodGet, "/accounts/"+account.ID.String()+"/statements?"+later.Encode(), "")
+	if got := strings.Count(rr.Body.String(), "69.75"); got != 2 {
+		t.Errorf("got statement:\n%s\nwant opening and closing balances of 69.75", rr.Body)
+	}
+
+	api.expectProblem(api.do(http.MethodGet, path+"&format=xls", ""), http.StatusUnprocessableEntity, CodeValidationFailed)
+	api.expectProblem(api.do(http.MethodGet, "/accounts/"+account.ID.String()+"/statements?from=2025-02-01&to=2025-01-01", ""), http.StatusUnprocessableEntity, CodeValidationFailed)
+	api.expectProblem(api.do(http.MethodGet, "/accounts/"+uuid.NewString()+"/statements", ""), http.StatusNotFound, CodeAccountNotFound)
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"wallet/internal/server/dto"
+
+	"github.com/gin-gonic/gin"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// GraphQLHandler executes a GraphQL query or mutation
+// @Summary Execute a GraphQL query
+// @Description Query users, accounts and transactions, or top up and charge accounts, in a single request. Queries deeper than 8 levels or with a complexity above 1000 are rejected.
+// @Tags graphql
+// @Accept json
+// @Produce json
+// @Param request body dto.GraphQLRequest true "GraphQL request"
+// @Success 200 {object} dto.GraphQLResponse "Query result"
+// @Failure 400 {object} dto.GraphQLResponse "Invalid or too expensive query"
+// @Router /graphql [post]
+func (s *Server) GraphQLHandler(c *gin.Context) {
+	var request dto.GraphQLRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, graphQLError(err))
+		return
+	}
+
+	// Reject malformed and expensive queries before resolving anything
+	doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(request.Query), Name: "GraphQL request"})})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, graphQLError(err))
+		return
+	}
+	if err := checkQueryLimits(doc, request.OperationName, request.Variables); err != nil {
+		c.JSON(http.StatusBadRequest, graphQLError(err))
+		return
+	}
+
+	result := graphql.Do(graphql.Params{
+		Schema:         s.graphqlSchema,
+		RequestString:  request.Query,
+		OperationName:  request.OperationName,
+		VariableValues: request.Variables,
+		Context:        context.WithValue(c.Request.Context(), actorContextKey{}, requestActor(c)),
+	})
+
+	c.JSON(http.StatusOK, result)
+}
+
+func graphQLError(err error) *graphql.Result {
+	return &graphql.Result{Errors: []gqlerrors.FormattedError{gqlerrors.FormatError(err)}}
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"wallet/internal/services"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// Limits on the shape of GraphQL queries, checked before execution.
+const (
+	maxQueryDepth      = 8
+	maxQueryComplexity = 1000
+)
+
+// pagedFields are list fields whose cost scales with their page size.
+var pagedFields = map[string]bool{"transactions": true}
+
+// queryCost walks the operation to execute and returns its depth and
+// complexity. Every field costs one, and the selections of a paged field are
+// charged once per requested item. Introspection fields are not charged.
+type queryCost struct {
+	fragments map[string]*ast.FragmentDefinition
+	variables map[string]any
+	// costs holds the depth and complexity of each fragment walked so far.
+	// A fragment costs the same wherever it is spread, so a query spreading
+	// fragments into one another many times over is still walked once per
+	// fragment.
+	costs map[string]selectionCost
+}
+
+type selectionCost struct {
+	depth, complexity int
+}
+
+// checkQueryLimits rejects a query that is nested too deeply or would
+// resolve too many fields.
+func checkQueryLimits(doc *ast.Document, operationName string, variables map[string]any) error {
+	cost := queryCost{fragments: map[string]*ast.FragmentDefinition{}, variables: variables, costs: map[string]selectionCost{}}
+	var operations []*ast.OperationDefinition
+	for _, def := range doc.Definitions {
+		switch d := def.(type) {
+		case *ast.FragmentDefinition:
+			cost.fragments[d.Name.Value] = d
+		case *ast.OperationDefinition:
+			if operationName == "" || (d.Name != nil && d.Name.Value == operationName) {
+				operations = append(operations, d)
+			}
+		}
+	}
+
+	for _, op := range operations {
+		if _, err := cost.selectionSet(op.SelectionSet, map[string]bool{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectionSet returns the cost of a selection set. It gives up as soon as
+// part of it exceeds a limit, as the whole query then does too.
+func (c queryCost) selectionSet(set *ast.SelectionSet, visiting map[string]bool) (cost selectionCost, err error) {
+	if set == nil {
+		return selectionCost{}, nil
+	}
+
+	for _, selection := range set.Selections {
+		var sub selectionCost
+		switch sel := selection.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(sel.Name.Value, "__") {
+				continue
+			}
+			if sub, err = c.selectionSet(sel.SelectionSet, visiting); err != nil {
+				return selectionCost{}, err
+			}
+			sub.depth++
+			sub.complexity = 1 + sub.complexity*c.multiplier(sel)
+		case *ast.InlineFragment:
+			sub, err = c.selectionSet(sel.SelectionSet, visiting)
+		case *ast.FragmentSpread:
+			sub, err = c.fragment(sel.Name.Value, visiting)
+		}
+		if err != nil {
+			return selectionCost{}, err
+		}
+
+		cost.depth = max(cost.depth, sub.depth)
+		cost.complexity += sub.complexity
+		if cost.depth > maxQueryDepth {
+			return selectionCost{}, fmt.Errorf("query depth exceeds the maximum of %d", maxQueryDepth)
+		}
+		if cost.complexity > maxQueryComplexity {
+			return selectionCost{}, fmt.Errorf("query complexity exceeds the maximum of %d", maxQueryComplexity)
+		}
+	}
+	return cost, nil
+}
+
+// fragment returns the cost of the named fragment's selections.
+func (c queryCost) fragment(name string, visiting map[string]bool) (selectionCost, error) {
+	if cost, ok := c.costs[name]; ok {
+		return cost, nil
+	}
+	fragment, ok := c.fragments[name]
+	if !ok {
+		return selectionCost{}, fmt.Errorf("unknown fragment %q", name)
+	}
+	if visiting[name] {
+		return selectionCost{}, fmt.Errorf("fragment %q spreads itself", name)
+	}
+
+	visiting[name] = true
+	defer delete(visiting, name)
+	cost, err := c.selectionSet(fragment.SelectionSet, visiting)
+	if err != nil {
+		return selectionCost{}, err
+	}
+	c.costs[name] = cost
+	return cost, nil
+}
+
+// multiplier is the number of items a field can return.
+func (c queryCost) multiplier(field *ast.Field) int {
+	if !pagedFields[field.Name.Value] {
+		return 1
+	}
+
+	for _, arg := range field.Arguments {
+		if arg.Name.Value != "first" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil && n > 0 {
+				return min(n, services.MaxPageSize)
+			}
+		case *ast.Variable:
+			if n, ok := c.variables[v.Name.Value].(float64); ok && n > 0 {
+				return min(int(n), services.MaxPageSize)
+			}
+		}
+	}
+	return services.DefaultPageSize
+}
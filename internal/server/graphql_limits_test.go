@@ -0,0 +1,85 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// fanOutQuery spreads each of levels fragments twice into the one before,
+// so walking the spreads one by one visits the last fragment 2^levels times.
+func fanOutQuery(levels int, leaf string) string {
+	var b strings.Builder
+	b.WriteString("query { ...F0 }\n")
+	for i := range levels {
+		fmt.Fprintf(&b, "fragment F%d on Query { __typename ...F%d ...F%d }\n", i, i+1, i+1)
+	}
+	fmt.Fprintf(&b, "fragment F%d on Query { %s }\n", levels, leaf)
+	return b.String()
+}
+
+func TestCheckQueryLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{
+			name:  "shallow query",
+			query: `{ account(id: "1") { id balance transactions(first: 10) { edges { node { id amount } } } } }`,
+		},
+		{
+			name:  "too deep",
+			query: `{ account(id: "1") { user { accounts { user { accounts { user { accounts { user { id } } } } } } } } }`,
+			want:  "query depth exceeds the maximum of 8",
+		},
+		{
+			name:  "too many transactions",
+			query: `{ user(id: "1") { accounts { transactions(first: 100) { edges { node { id amount ref status createdAt postedAt description externalRef merchantName } } } } } }`,
+			want:  "query complexity exceeds the maximum of 1000",
+		},
+		{
+			name:  "fan-out of free fragments",
+			query: fanOutQuery(60, "__typename"),
+		},
+		{
+			name:  "fan-out of charged fragments",
+			query: fanOutQuery(60, `account(id: "1") { id }`),
+			want:  "query complexity exceeds the maximum of 1000",
+		},
+		{
+			name:  "fragment cycle",
+			query: "query { ...A }\nfragment A on Query { ...B }\nfragment B on Query { ...A }",
+			want:  `fragment "A" spreads itself`,
+		},
+		{
+			name:  "unknown fragment",
+			query: "query { ...A }",
+			want:  `unknown fragment "A"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parser.Parse(parser.ParseParams{Source: source.NewSource(&source.Source{Body: []byte(tt.query)})})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			start := time.Now()
+			err = checkQueryLimits(doc, "", nil)
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("checking the query took %v", elapsed)
+			}
+			switch {
+			case tt.want == "" && err != nil:
+				t.Errorf("got error %v want none", err)
+			case tt.want != "" && (err == nil || err.Error() != tt.want):
+				t.Errorf("got error %v want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,404 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"wallet/internal/models"
+	"wallet/internal/repository"
+	"wallet/internal/services"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+)
+
+// actorContextKey carries the request's services.Actor to the resolvers.
+type actorContextKey struct{}
+
+// newGraphQLSchema builds the schema over users, accounts and transactions.
+// Queries read through the services; mutations delegate to AccountService.
+func (s *Server) newGraphQLSchema() (graphql.Schema, error) {
+	var userType, accountType, transactionType *graphql.Object
+
+	pageInfoType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "PageInfo",
+		Fields: graphql.Fields{
+			"hasNextPage": &graphql.Field{Type: graphql.NewNonNull(graphql.Boolean)},
+			"endCursor":   &graphql.Field{Type: graphql.String},
+		},
+	})
+
+	transactionEdgeType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "TransactionEdge",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"cursor": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+				"node":   &graphql.Field{Type: graphql.NewNonNull(transactionType)},
+			}
+		}),
+	})
+
+	transactionConnectionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "TransactionConnection",
+		Fields: graphql.Fields{
+			"edges":    &graphql.Field{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(transactionEdgeType)))},
+			"pageInfo": &graphql.Field{Type: graphql.NewNonNull(pageInfoType)},
+		},
+	})
+
+	userType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: resolveUser(func(u *models.User) any { return u.ID.String() })},
+				"email":     &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: resolveUser(func(u *models.User) any { return u.Email })},
+				"firstName": &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: resolveUser(func(u *models.User) any { return u.FirstName })},
+				"lastName":  &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: resolveUser(func(u *models.User) any { return u.LastName })},
+				"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: resolveUser(func(u *models.User) any { return u.CreatedAt })},
+				"accounts": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(accountType))),
+					Resolve: resolveErrors(func(p graphql.ResolveParams) (any, error) {
+						accounts, err := s.AccountService.GetAccountsByUserID(p.Context, p.Source.(*models.User).ID)
+						if err != nil {
+							return nil, err
+						}
+						result := make([]*models.Account, len(accounts))
+						for i := range accounts {
+							result[i] = &accounts[i]
+						}
+						return result, nil
+					}),
+				},
+			}
+		}),
+	})
+
+	accountType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Account",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":      &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: resolveAccount(func(a *models.Account) any { return a.ID.String() })},
+				"balance": &graphql.Field{Type: graphql.NewNonNull(graphql.Float), Resolve: resolveAccount(func(a *models.Account) any { return a.Balance })},
+				"availableBalance": &graphql.Field{
+					Type:        graphql.NewNonNull(graphql.Float),
+					Description: "The balance less pending charges",
+					Resolve:     resolveAccount(func(a *models.Account) any { return a.AvailableBalance }),
+				},
+				"number": &graphql.Field{
+					Type:        graphql.String,
+					Description: "The account number, which can be used in place of the ID",
+					Resolve:     resolveAccount(func(a *models.Account) any { return a.Number }),
+				},
+				"virtualIban": &graphql.Field{
+					Type:        graphql.String,
+					Description: "The IBAN to send bank transfers to the account to",
+					Resolve:     resolveAccount(func(a *models.Account) any { return a.VirtualIBAN }),
+				},
+				"version":   &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: resolveAccount(func(a *models.Account) any { return a.Version })},
+				"status":    &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: resolveAccount(func(a *models.Account) any { return string(a.Status) })},
+				"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: resolveAccount(func(a *models.Account) any { return a.CreatedAt })},
+				"updatedAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: resolveAccount(func(a *models.Account) any { return a.UpdatedAt })},
+				"user": &graphql.Field{
+					Type: graphql.NewNonNull(userType),
+					Resolve: resolveErrors(func(p graphql.ResolveParams) (any, error) {
+						account := p.Source.(*models.Account)
+						if account.User.ID != uuid.Nil {
+							return &account.User, nil
+						}
+						return s.UserService.GetUserByID(p.Context, account.UserID)
+					}),
+				},
+				"transactions": &graphql.Field{
+					Type: graphql.NewNonNull(transactionConnectionType),
+					Args: graphql.FieldConfigArgument{
+						"first":                &graphql.ArgumentConfig{Type: graphql.Int, Description: "Page size, at most 100"},
+						"after":                &graphql.ArgumentConfig{Type: graphql.String, Description: "endCursor of the previous page"},
+						"search":               &graphql.ArgumentConfig{Type: graphql.String, Description: "Text the ref, description, external reference, merchant name or metadata contain"},
+						"externalRef":          &graphql.ArgumentConfig{Type: graphql.String},
+						"merchantCategoryCode": &graphql.ArgumentConfig{Type: graphql.String},
+					},
+					Resolve: resolveErrors(func(p graphql.ResolveParams) (any, error) {
+						first, _ := p.Args["first"].(int)
+						after, _ := p.Args["after"].(string)
+						if first < 0 {
+							return nil, newResolverError(validationProblem, "first must not be negative")
+						}
+						var filter repository.TransactionFilter
+						filter.Query, _ = p.Args["search"].(string)
+						filter.ExternalRef, _ = p.Args["externalRef"].(string)
+						filter.MerchantCategoryCode, _ = p.Args["merchantCategoryCode"].(string)
+
+						page, err := s.TransactionService.SearchTransactions(p.Context, p.Source.(*models.Account).ID, filter, after, first)
+						if err != nil {
+							return nil, err
+						}
+
+						edges := make([]map[string]any, len(page.Transactions))
+						for i := range page.Transactions {
+							edges[i] = map[string]any{
+								"cursor": services.TransactionCursor(page.Transactions[i]),
+								"node":   &page.Transactions[i],
+							}
+						}
+						pageInfo := map[string]any{"hasNextPage": page.NextCursor != ""}
+						if len(edges) > 0 {
+							pageInfo["endCursor"] = edges[len(edges)-1]["cursor"]
+						}
+						return map[string]any{"edges": edges, "pageInfo": pageInfo}, nil
+					}),
+				},
+			}
+		}),
+	})
+
+	transactionType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Transaction",
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID), Resolve: resolveTransaction(func(t *models.Transaction) any { return t.ID.String() })},
+				"type":      &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: resolveTransaction(func(t *models.Transaction) any { return string(t.TransactionType) })},
+				"amount":    &graphql.Field{Type: graphql.NewNonNull(graphql.Float), Resolve: resolveTransaction(func(t *models.Transaction) any { return t.Amount })},
+				"ref":       &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: resolveTransaction(func(t *models.Transaction) any { return t.Ref })},
+				"status":    &graphql.Field{Type: graphql.NewNonNull(graphql.String), Resolve: resolveTransaction(func(t *models.Transaction) any { return string(t.Status) })},
+				"createdAt": &graphql.Field{Type: graphql.NewNonNull(graphql.DateTime), Resolve: resolveTransaction(func(t *models.Transaction) any { return t.CreatedAt })},
+				"postedAt": &graphql.Field{Type: graphql.DateTime, Resolve: resolveTransaction(func(t *models.Transaction) any {
+					if t.PostedAt == nil {
+						return nil
+					}
+					return *t.PostedAt
+				})},
+				"description":          &graphql.Field{Type: graphql.String, Resolve: resolveTransaction(func(t *models.Transaction) any { return optionalString(t.Description) })},
+				"metadata":             &graphql.Field{Type: graphql.String, Description: "The client's JSON object", Resolve: resolveTransaction(func(t *models.Transaction) any { return optionalString(string(t.Metadata)) })},
+				"externalRef":          &graphql.Field{Type: graphql.String, Resolve: resolveTransaction(func(t *models.Transaction) any { return optionalString(t.ExternalRef) })},
+				"merchantName":         &graphql.Field{Type: graphql.String, Resolve: resolveTransaction(func(t *models.Transaction) any { return optionalString(t.MerchantName) })},
+				"merchantCategoryCode": &graphql.Field{Type: graphql.String, Resolve: resolveTransaction(func(t *models.Transaction) any { return optionalString(t.MerchantCategoryCode) })},
+				"account": &graphql.Field{
+					Type: graphql.NewNonNull(accountType),
+					Resolve: resolveErrors(func(p graphql.ResolveParams) (any, error) {
+						transaction := p.Source.(*models.Transaction)
+						if transaction.Account.ID != uuid.Nil {
+							return &transaction.Account, nil
+						}
+						return s.AccountService.GetAccountByID(p.Context, transaction.AccountID)
+					}),
+				},
+			}
+		}),
+	})
+
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"user": &graphql.Field{
+				Type:        userType,
+				Description: "Look up a user by id or email",
+				Args: graphql.FieldConfigArgument{
+					"id":    &graphql.ArgumentConfig{Type: graphql.ID},
+					"email": &graphql.ArgumentConfig{Type: graphql.String},
+				},
+				Resolve: resolveErrors(func(p graphql.ResolveParams) (any, error) {
+					if id, ok := p.Args["id"].(string); ok {
+						userID, err := uuid.Parse(id)
+						if err != nil {
+							return nil, newResolverError(validationProblem, "invalid user ID")
+						}
+						return nullIfNotFound(s.UserService.GetUserByID(p.Context, userID))
+					}
+					if email, ok := p.Args["email"].(string); ok {
+						return nullIfNotFound(s.UserService.GetUserByEmail(p.Context, email))
+					}
+					return nil, newResolverError(validationProblem, "either id or email is required")
+				}),
+			},
+			"account": &graphql.Field{
+				Type: accountType,
+				Args: graphql.FieldConfigArgument{
+					"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID), Description: "Account ID or account number"},
+				},
+				Resolve: resolveErrors(func(p graphql.ResolveParams) (any, error) {
+					accountID, err := s.AccountService.ResolveAccountID(p.Context, p.Args["id"].(string))
+					if err != nil {
+						return nullIfNotFound[models.Account](nil, err)
+					}
+					return nullIfNotFound(s.AccountService.GetAccountByID(p.Context, accountID))
+				}),
+			},
+		},
+	})
+
+	moneyArgs := graphql.FieldConfigArgument{
+		"accountId":            &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID), Description: "Account ID or account number"},
+		"amount":               &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.Float)},
+		"description":          &graphql.ArgumentConfig{Type: graphql.String},
+		"metadata":             &graphql.ArgumentConfig{Type: graphql.String, Description: "A JSON object of your own keys and values"},
+		"externalRef":          &graphql.ArgumentConfig{Type: graphql.String, Description: "Your own reference, such as an order ID"},
+		"merchantName":         &graphql.ArgumentConfig{Type: graphql.String},
+		"merchantCategoryCode": &graphql.ArgumentConfig{Type: graphql.String, Description: "Four-digit ISO 18245 merchant category code"},
+	}
+	topUpArgs := graphql.FieldConfigArgument{
+		"paymentMethod": &graphql.ArgumentConfig{Type: graphql.String, Description: "What the payment provider collects the amount from, e.g. fake_card"},
+	}
+	for name, arg := range moneyArgs {
+		topUpArgs[name] = arg
+	}
+
+	mutationType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Mutation",
+		Fields: graphql.Fields{
+			"topUp": &graphql.Field{
+				Type:        graphql.NewNonNull(transactionType),
+				Description: "Top up an account with an amount collected by the payment provider",
+				Args:        topUpArgs,
+				Resolve: resolveErrors(func(p graphql.ResolveParams) (any, error) {
+					accountID, amount, err := s.moneyArguments(p)
+					if err != nil {
+						return nil, err
+					}
+					paymentMethod, _ := p.Args["paymentMethod"].(string)
+					return s.FundingService.WithActor(actorFromContext(p.Context)).TopUp(p.Context, accountID, amount, paymentMethod, transactionDetailsArguments(p))
+				}),
+			},
+			"charge": &graphql.Field{
+				Type:    graphql.NewNonNull(transactionType),
+				Args:    moneyArgs,
+				Resolve: s.resolveMoneyMutation(services.AccountService.Charge),
+			},
+			"pendingTopUp": &graphql.Field{
+				Type:        graphql.NewNonNull(transactionType),
+				Description: "Start a top-up that credits the account once settled",
+				Args:        moneyArgs,
+				Resolve:     s.resolveMoneyMutation(services.AccountService.PendingTopUp),
+			},
+			"pendingCharge": &graphql.Field{
+				Type:        graphql.NewNonNull(transactionType),
+				Description: "Hold an amount against the available balance until the charge is settled",
+				Args:        moneyArgs,
+				Resolve:     s.resolveMoneyMutation(services.AccountService.PendingCharge),
+			},
+		},
+	})
+
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType, Mutation: mutationType})
+}
+
+// resolveMoneyMutation validates the accountId and amount arguments like the
+// REST handlers do and runs the operation as the requesting actor.
+func (s *Server) resolveMoneyMutation(operation func(svc services.AccountService, ctx context.Context, accountID uuid.UUID, amount float64, details models.TransactionDetails) (*models.Transaction, error)) graphql.FieldResolveFn {
+	return resolveErrors(func(p graphql.ResolveParams) (any, error) {
+		accountID, amount, err := s.moneyArguments(p)
+		if err != nil {
+			return nil, err
+		}
+
+		return operation(s.AccountService.WithActor(actorFromContext(p.Context)), p.Context, accountID, amount, transactionDetailsArguments(p))
+	})
+}
+
+// transactionDetailsArguments returns the optional details arguments of a
+// mutation. The services validate them, including the metadata JSON.
+func transactionDetailsArguments(p graphql.ResolveParams) models.TransactionDetails {
+	var details models.TransactionDetails
+	details.Description, _ = p.Args["description"].(string)
+	details.ExternalRef, _ = p.Args["externalRef"].(string)
+	details.MerchantName, _ = p.Args["merchantName"].(string)
+	details.MerchantCategoryCode, _ = p.Args["merchantCategoryCode"].(string)
+	if metadata, ok := p.Args["metadata"].(string); ok {
+		details.Metadata = json.RawMessage(metadata)
+	}
+	return details
+}
+
+// optionalString resolves an empty string to null.
+func optionalString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// moneyArguments returns the validated accountId and amount arguments of a
+// mutation. The account may be given by its ID or its account number.
+func (s *Server) moneyArguments(p graphql.ResolveParams) (uuid.UUID, float64, error) {
+	accountID, err := s.AccountService.ResolveAccountID(p.Context, p.Args["accountId"].(string))
+	if err != nil {
+		return uuid.Nil, 0, err
+	}
+	amount := p.Args["amount"].(float64)
+	if amount <= 0 {
+		return uuid.Nil, 0, services.ErrInvalidAmount
+	}
+	return accountID, amount, nil
+}
+
+func actorFromContext(ctx context.Context) services.Actor {
+	if actor, ok := ctx.Value(actorContextKey{}).(services.Actor); ok {
+		return actor
+	}
+	return services.SystemActor
+}
+
+// nullIfNotFound turns a missing record into a null result rather than an error.
+func nullIfNotFound[T any](record *T, err error) (any, error) {
+	if errors.Is(err, services.ErrUserNotFound) || errors.Is(err, services.ErrAccountNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// resolverError is a resolver error that carries its problem code in the
+// error's extensions, matching the codes of the REST API.
+type resolverError struct {
+	message string
+	code    string
+}
+
+func newResolverError(p problemType, message string) error {
+	return &resolverError{message: message, code: p.code}
+}
+
+func (e *resolverError) Error() string {
+	return e.message
+}
+
+func (e *resolverError) Extensions() map[string]any {
+	return map[string]any{"code": e.code}
+}
+
+// resolveErrors attaches the problem code to the errors returned by resolve.
+// Unexpected errors are logged and reported without their details.
+func resolveErrors(resolve graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		result, err := resolve(p)
+		if err == nil {
+			return result, nil
+		}
+		var coded *resolverError
+		if errors.As(err, &coded) {
+			return nil, err
+		}
+
+		problem := classifyError(err)
+		if problem == internalProblem {
+			slog.ErrorContext(p.Context, "graphql resolver failed", "error", err)
+			return nil, newResolverError(problem, "an unexpected error occurred")
+		}
+		return nil, newResolverError(problem, err.Error())
+	}
+}
+
+func resolveUser(field func(u *models.User) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) { return field(p.Source.(*models.User)), nil }
+}
+
+func resolveAccount(field func(a *models.Account) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) { return field(p.Source.(*models.Account)), nil }
+}
+
+func resolveTransaction(field func(t *models.Transaction) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) { return field(p.Source.(*models.Transaction)), nil }
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"strings"
+
+	"wallet/internal/logging"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// operatorKey is the gin context key of the authenticated operator's name.
+const operatorKey = "operator"
+
+// requireOperator authenticates the finance operator calling a route by
+// their bearer token, and makes them the request's actor, as the
+// maker-checker principle relies on telling operators apart.
+func (s *Server) requireOperator(c *gin.Context) {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		respondProblem(c, unauthorizedProblem, "an operator bearer token is required")
+		return
+	}
+	name, ok := services.AuthenticateOperator(s.operators, token)
+	if !ok {
+		respondProblem(c, unauthorizedProblem, "unknown operator token")
+		return
+	}
+	c.Set(operatorKey, name)
+	c.Next()
+}
+
+// requestActor identifies the caller of a request for the audit log: the
+// operator authenticated by requireOperator, or else the client IP. Callers
+// cannot name themselves, so the log cannot be made to blame someone else.
+func requestActor(c *gin.Context) services.Actor {
+	name := c.GetString(operatorKey)
+	if name == "" {
+		name = "api:" + c.ClientIP()
+	}
+	return services.Actor{Name: name, RequestID: logging.RequestID(c.Request.Context())}
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestActor(t *testing.T) {
+	tests := []struct {
+		name     string
+		operator string
+		want     string
+	}{
+		{name: "authenticated operator", operator: "alice", want: "alice"},
+		// Callers cannot name themselves with a header
+		{name: "anonymous caller", want: "api:192.0.2.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/accounts", nil)
+			c.Request.Header.Set("X-Actor", "bob")
+			if tt.operator != "" {
+				c.Set(operatorKey, tt.operator)
+			}
+
+			if got := requestActor(c); got.Name != tt.want {
+				t.Errorf("got actor %q want %q", got.Name, tt.want)
+			}
+		})
+	}
+}
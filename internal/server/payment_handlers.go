@@ -0,0 +1,92 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"wallet/internal/logging"
+	"wallet/internal/server/dto"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// maxWebhookBodyBytes bounds the size of a payment provider's webhook.
+const maxWebhookBodyBytes = 1 << 20
+
+// PaymentWebhookHandler receives the webhooks of a payment provider
+// @Summary Receive a payment provider webhook
+// @Description Apply the outcome of a payment sent by its provider, which settles or fails the top-up it funds. Webhooks must carry the provider's signature; those sent more than once change nothing.
+// @Tags payments
+// @Accept json
+// @Param provider path string true "Payment provider" Enums(fake)
+// @Success 204 "Webhook applied"
+// @Failure 400 {object} dto.Problem "Invalid webhook"
+// @Failure 404 {object} dto.Problem "Payment not found"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /payments/webhooks/{provider} [post]
+func (s *Server) PaymentWebhookHandler(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBodyBytes)
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondProblem(c, problemType{http.StatusRequestEntityTooLarge, CodeRequestTooLarge, "Request too large"}, fmt.Sprintf("a webhook must be at most %d bytes", maxWebhookBodyBytes))
+			return
+		}
+		respondBindingError(c, err)
+		return
+	}
+
+	provider := c.Param("provider")
+	actor := services.Actor{Name: "payments:" + provider, RequestID: logging.RequestID(c.Request.Context())}
+	if err := s.FundingService.WithActor(actor).HandleWebhook(c.Request.Context(), provider, c.Request.Header, body); err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RefundHandler refunds a top-up funded through a payment provider
+// @Summary Refund a top-up
+// @Description Reverse a top-up that was collected through the payment provider and return its money. The amount must still be available. If the provider fails, the top-up stays reversed and refunding it again retries the provider.
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Security OperatorToken
+// @Param id path string true "Transaction ID of the top-up"
+// @Param request body dto.RefundRequest true "Reason"
+// @Success 200 {object} dto.PaymentResponse "Payment refunded"
+// @Failure 400 {object} dto.Problem "Malformed request or invalid transaction ID"
+// @Failure 401 {object} dto.Problem "Operator token missing or unknown"
+// @Failure 404 {object} dto.Problem "Payment not found"
+// @Failure 409 {object} dto.Problem "Payment cannot be refunded"
+// @Failure 422 {object} dto.Problem "Validation failed or insufficient funds"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Failure 502 {object} dto.Problem "Payment provider error"
+// @Router /transactions/{id}/refund [post]
+func (s *Server) RefundHandler(c *gin.Context) {
+	transactionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondProblem(c, invalidTransactionIDProblem, "invalid transaction ID")
+		return
+	}
+
+	var request dto.RefundRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	payment, err := s.FundingService.WithActor(requestActor(c)).Refund(c.Request.Context(), transactionID, request.Reason)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, payment)
+}
@@ -0,0 +1,228 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"wallet/internal/server/dto"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// Stable error codes of the problems the API responds with.
+const (
+	CodeMalformedRequest       = "malformed_request"
+	CodeUnauthorized           = "unauthorized"
+	CodeValidationFailed       = "validation_failed"
+	CodeInvalidAccountID       = "invalid_account_id"
+	CodeInvalidCursor          = "invalid_cursor"
+	CodeInvalidBatchID         = "invalid_batch_id"
+	CodeInvalidTransactionID   = "invalid_transaction_id"
+	CodeInvalidWithdrawalID    = "invalid_withdrawal_id"
+	CodeInvalidDepositID       = "invalid_deposit_id"
+	CodeInvalidAdjustmentID    = "invalid_adjustment_id"
+	CodeAccountNotFound        = "account_not_found"
+	CodeUserNotFound           = "user_not_found"
+	CodeTransactionNotFound    = "transaction_not_found"
+	CodeBatchNotFound          = "batch_not_found"
+	CodeDuplicateUser          = "duplicate_user"
+	CodeDuplicateTransaction   = "duplicate_transaction"
+	CodeDuplicateReference     = "duplicate_reference"
+	CodeInsufficientFunds      = "insufficient_funds"
+	CodeAccountNotOpen         = "account_not_open"
+	CodeAccountFrozen          = "account_frozen"
+	CodeAccountAlreadyFrozen   = "account_already_frozen"
+	CodeAccountNotFrozen       = "account_not_frozen"
+	CodeInvalidTransition      = "invalid_transition"
+	CodePaymentNotFound        = "payment_not_found"
+	CodePaymentDeclined        = "payment_declined"
+	CodePaymentProviderError   = "payment_provider_error"
+	CodeInvalidWebhook         = "invalid_webhook"
+	CodeBeneficiaryNotFound    = "beneficiary_not_found"
+	CodeWithdrawalNotFound     = "withdrawal_not_found"
+	CodeDepositNotFound        = "deposit_not_found"
+	CodeDepositNotUnmatched    = "deposit_not_unmatched"
+	CodeAdjustmentNotFound     = "adjustment_not_found"
+	CodeAdjustmentNotPending   = "adjustment_not_pending"
+	CodeSelfApproval           = "self_approval"
+	CodeConcurrentModification = "concurrent_modification"
+	CodeRouteNotFound          = "route_not_found"
+	CodeRequestTooLarge        = "request_too_large"
+	CodeTimeout                = "timeout"
+	CodeRequestCanceled        = "request_canceled"
+	CodeInternal               = "internal_error"
+)
+
+// problemType describes how a class of errors is reported.
+type problemType struct {
+	status int
+	code   string
+	title  string
+}
+
+// domainProblems maps the services' domain errors to problem types.
+var domainProblems = []struct {
+	err error
+	problemType
+}{
+	{services.ErrInvalidAccountID, invalidAccountIDProblem},
+	{services.ErrAccountNotFound, problemType{http.StatusNotFound, CodeAccountNotFound, "Account not found"}},
+	{services.ErrUserNotFound, problemType{http.StatusNotFound, CodeUserNotFound, "User not found"}},
+	{services.ErrTransactionNotFound, problemType{http.StatusNotFound, CodeTransactionNotFound, "Transaction not found"}},
+	{services.ErrBatchNotFound, problemType{http.StatusNotFound, CodeBatchNotFound, "Batch not found"}},
+	{services.ErrDuplicateUser, problemType{http.StatusConflict, CodeDuplicateUser, "User already exists"}},
+	{services.ErrDuplicateTransaction, problemType{http.StatusConflict, CodeDuplicateTransaction, "Duplicate transaction"}},
+	{services.ErrDuplicateReference, problemType{http.StatusConflict, CodeDuplicateReference, "Duplicate reference"}},
+	{services.ErrInsufficientFunds, problemType{http.StatusUnprocessableEntity, CodeInsufficientFunds, "Insufficient funds"}},
+	{services.ErrAccountNotOpen, problemType{http.StatusConflict, CodeAccountNotOpen, "Account is not open"}},
+	{services.ErrAccountFrozen, problemType{http.StatusConflict, CodeAccountFrozen, "Account is frozen"}},
+	{services.ErrAccountAlreadyFrozen, problemType{http.StatusConflict, CodeAccountAlreadyFrozen, "Account is already frozen"}},
+	{services.ErrAccountNotFrozen, problemType{http.StatusConflict, CodeAccountNotFrozen, "Account is not frozen"}},
+	{services.ErrInvalidTransition, problemType{http.StatusConflict, CodeInvalidTransition, "Invalid transaction status change"}},
+	{services.ErrPaymentNotFound, problemType{http.StatusNotFound, CodePaymentNotFound, "Payment not found"}},
+	{services.ErrPaymentDeclined, problemType{http.StatusPaymentRequired, CodePaymentDeclined, "Payment declined"}},
+	{services.ErrPaymentProvider, problemType{http.StatusBadGateway, CodePaymentProviderError, "Payment provider error"}},
+	{services.ErrInvalidWebhook, problemType{http.StatusBadRequest, CodeInvalidWebhook, "Invalid webhook"}},
+	{services.ErrBeneficiaryNotFound, problemType{http.StatusNotFound, CodeBeneficiaryNotFound, "Beneficiary not found"}},
+	{services.ErrWithdrawalNotFound, problemType{http.StatusNotFound, CodeWithdrawalNotFound, "Withdrawal not found"}},
+	{services.ErrDepositNotFound, problemType{http.StatusNotFound, CodeDepositNotFound, "Deposit not found"}},
+	{services.ErrDepositNotUnmatched, problemType{http.StatusConflict, CodeDepositNotUnmatched, "Deposit not in the suspense queue"}},
+	{services.ErrAdjustmentNotFound, problemType{http.StatusNotFound, CodeAdjustmentNotFound, "Adjustment not found"}},
+	{services.ErrAdjustmentNotPending, problemType{http.StatusConflict, CodeAdjustmentNotPending, "Adjustment is not pending"}},
+	{services.ErrSelfApproval, problemType{http.StatusForbidden, CodeSelfApproval, "Self-approval not allowed"}},
+	{services.ErrConcurrentModification, problemType{http.StatusConflict, CodeConcurrentModification, "Concurrent modification"}},
+	{services.ErrInvalidCursor, problemType{http.StatusBadRequest, CodeInvalidCursor, "Invalid cursor"}},
+	{context.DeadlineExceeded, problemType{http.StatusGatewayTimeout, CodeTimeout, "Operation timed out"}},
+	// The client has gone away; the status is only seen in logs and metrics
+	{context.Canceled, problemType{statusClientClosedRequest, CodeRequestCanceled, "Request canceled"}},
+}
+
+// statusClientClosedRequest is the non-standard status nginx uses for
+// requests the client abandoned before a response was sent.
+const statusClientClosedRequest = 499
+
+var (
+	validationProblem   = problemType{http.StatusUnprocessableEntity, CodeValidationFailed, "Validation failed"}
+	internalProblem     = problemType{http.StatusInternalServerError, CodeInternal, "Internal server error"}
+	unauthorizedProblem = problemType{http.StatusUnauthorized, CodeUnauthorized, "Unauthorized"}
+
+	invalidAccountIDProblem     = problemType{http.StatusBadRequest, CodeInvalidAccountID, "Invalid account ID"}
+	invalidBatchIDProblem       = problemType{http.StatusBadRequest, CodeInvalidBatchID, "Invalid batch ID"}
+	invalidTransactionIDProblem = problemType{http.StatusBadRequest, CodeInvalidTransactionID, "Invalid transaction ID"}
+	invalidWithdrawalIDProblem  = problemType{http.StatusBadRequest, CodeInvalidWithdrawalID, "Invalid withdrawal ID"}
+	invalidDepositIDProblem     = problemType{http.StatusBadRequest, CodeInvalidDepositID, "Invalid deposit ID"}
+	invalidAdjustmentIDProblem  = problemType{http.StatusBadRequest, CodeInvalidAdjustmentID, "Invalid adjustment ID"}
+)
+
+// classifyError returns the problem type of a service error.
+func classifyError(err error) problemType {
+	for _, p := range domainProblems {
+		if errors.Is(err, p.err) {
+			return p.problemType
+		}
+	}
+	var invalid *services.ValidationError
+	if errors.As(err, &invalid) {
+		return validationProblem
+	}
+	return internalProblem
+}
+
+// writeProblem responds with an application/problem+json body.
+func writeProblem(c *gin.Context, problem dto.Problem) {
+	problem.Type = "urn:wallet:problem:" + problem.Code
+	if problem.Instance == "" {
+		problem.Instance = c.Request.URL.Path
+	}
+	c.Header("Content-Type", "application/problem+json")
+	c.AbortWithStatusJSON(problem.Status, problem)
+}
+
+func respondProblem(c *gin.Context, p problemType, detail string) {
+	writeProblem(c, dto.Problem{Title: p.title, Status: p.status, Detail: detail, Code: p.code})
+}
+
+// respondError maps a service error to its problem response. Unexpected
+// errors are logged and reported without their details.
+func respondError(c *gin.Context, err error) {
+	p := classifyError(err)
+	if p == internalProblem {
+		slog.ErrorContext(c.Request.Context(), "request failed", "error", err)
+		respondProblem(c, p, "an unexpected error occurred")
+		return
+	}
+
+	problem := dto.Problem{Title: p.title, Status: p.status, Detail: err.Error(), Code: p.code}
+	var invalid *services.ValidationError
+	if errors.As(err, &invalid) {
+		problem.Errors = []dto.FieldError{{Field: invalid.Field, Message: invalid.Message}}
+	}
+	writeProblem(c, problem)
+}
+
+// respondBindingError reports a request body that could not be decoded as
+// malformed_request and one that failed validation as validation_failed.
+func respondBindingError(c *gin.Context, err error) {
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) {
+		detail := err.Error()
+		if errors.Is(err, io.EOF) {
+			detail = "request body cannot be empty"
+		}
+		respondProblem(c, problemType{http.StatusBadRequest, CodeMalformedRequest, "Malformed request"}, detail)
+		return
+	}
+
+	problem := dto.Problem{
+		Title:  validationProblem.title,
+		Status: validationProblem.status,
+		Detail: "the request has invalid fields",
+		Code:   validationProblem.code,
+	}
+	for _, fe := range fieldErrors {
+		problem.Errors = append(problem.Errors, dto.FieldError{Field: jsonFieldName(fe), Message: validationMessage(fe)})
+	}
+	writeProblem(c, problem)
+}
+
+// jsonFieldName converts a validator field name to the snake_case name used
+// in request bodies, e.g. FirstName to first_name.
+func jsonFieldName(fe validator.FieldError) string {
+	var b strings.Builder
+	for i, r := range fe.Field() {
+		if r >= 'A' && r <= 'Z' {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func validationMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "email":
+		return "must be a valid email address"
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "gte":
+		return "must be at least " + fe.Param()
+	case "lte":
+		return "must be at most " + fe.Param()
+	case "max":
+		return "must be at most " + fe.Param() + " characters long"
+	case "min":
+		return "must be at least " + fe.Param() + " characters long"
+	}
+	return "failed the " + fe.Tag() + " check"
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"wallet/internal/server/dto"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRespondError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want dto.Problem
+	}{
+		{
+			name: "wrapped domain error",
+			err:  fmt.Errorf("charge: %w", services.ErrInsufficientFunds),
+			want: dto.Problem{Title: "Insufficient funds", Status: http.StatusUnprocessableEntity, Detail: "charge: insufficient balance", Code: CodeInsufficientFunds},
+		},
+		{
+			name: "not found",
+			err:  services.ErrAccountNotFound,
+			want: dto.Problem{Title: "Account not found", Status: http.StatusNotFound, Detail: "account not found", Code: CodeAccountNotFound},
+		},
+		{
+			name: "invalid field",
+			err:  &services.ValidationError{Field: "amount", Message: "amount must be positive"},
+			want: dto.Problem{
+				Title:  "Validation failed",
+				Status: http.StatusUnprocessableEntity,
+				Detail: "amount must be positive",
+				Code:   CodeValidationFailed,
+				Errors: []dto.FieldError{{Field: "amount", Message: "amount must be positive"}},
+			},
+		},
+		{
+			name: "client gone",
+			err:  fmt.Errorf("charge: %w", context.Canceled),
+			want: dto.Problem{Title: "Request canceled", Status: statusClientClosedRequest, Detail: "charge: context canceled", Code: CodeRequestCanceled},
+		},
+		{
+			// The details of unexpected errors stay in the logs
+			name: "unexpected error",
+			err:  errors.New("no such table: accounts"),
+			want: dto.Problem{Title: "Internal server error", Status: http.StatusInternalServerError, Detail: "an unexpected error occurred", Code: CodeInternal},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(rr)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/accounts/1/charge", nil)
+
+			respondError(c, tt.err)
+
+			if rr.Code != tt.want.Status {
+				t.Errorf("got status %d want %d", rr.Code, tt.want.Status)
+			}
+			if ct := rr.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("got content type %q want application/problem+json", ct)
+			}
+			var got dto.Problem
+			if err := json.Unmarshal(rr.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			want.Type = "urn:wallet:problem:" + want.Code
+			want.Instance = "/api/v1/accounts/1/charge"
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %+v want %+v", got, want)
+			}
+		})
+	}
+}
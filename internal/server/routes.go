@@ -3,25 +3,40 @@ package server
 import (
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 	"wallet/docs"
+	"wallet/internal/logging"
+	"wallet/internal/metrics"
+	"wallet/internal/tracing"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 func (s *Server) RegisterRoutes() http.Handler {
-	r := gin.Default()
+	r := gin.New()
+	r.Use(gin.Recovery(), logging.RequestIDMiddleware())
+	// Start a span per request, continuing the caller's traceparent if any
+	r.Use(otelgin.Middleware(tracing.ServiceName))
+	r.Use(metrics.Middleware(), logging.AccessLogMiddleware())
 
 	// CORS middleware
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
-		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type"},
+		AllowHeaders:     []string{"Accept", "Authorization", "Content-Type", "X-Request-ID", "traceparent", "tracestate"},
+		ExposeHeaders:    []string{"X-Request-ID"},
 		AllowCredentials: true, // Enable cookies/auth
 	}))
 
+	// Prometheus scrape endpoint
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	// Serve the Swagger API documentation
 	docs.SwaggerInfo.Host = os.Getenv("HOST")
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler,
@@ -35,8 +50,41 @@ func (s *Server) RegisterRoutes() http.Handler {
 		api.POST("/accounts", s.CreateAccountHandler)
 		api.POST("/accounts/:id/top-up", s.TopUpHandler)
 		api.POST("/accounts/:id/charge", s.ChargeHandler)
+		api.GET("/accounts/:id/balance", s.BalanceHandler)
+		api.GET("/accounts/:id/statements", s.StatementHandler)
+		api.GET("/accounts/:id/transactions", s.ListTransactionsHandler)
+		api.POST("/accounts/:id/beneficiaries", s.CreateBeneficiaryHandler)
+		api.GET("/accounts/:id/beneficiaries", s.ListBeneficiariesHandler)
+		api.POST("/accounts/:id/withdrawals", s.WithdrawHandler)
+		api.GET("/withdrawals/:id", s.GetWithdrawalHandler)
+		api.GET("/deposits", s.ListDepositsHandler)
+		api.GET("/deposits/:id", s.GetDepositHandler)
+		api.GET("/adjustments", s.ListAdjustmentsHandler)
+		api.GET("/adjustments/:id", s.GetAdjustmentHandler)
+		api.POST("/payments/webhooks/:provider", s.PaymentWebhookHandler)
+		api.GET("/batches/:id", s.GetBatchHandler)
+		api.POST("/graphql", s.GraphQLHandler)
+
+		// Adjustments, deposits and batches that credit accounts and
+		// changes to the status of transactions are made and reviewed by
+		// authenticated operators
+		operators := api.Group("", s.requireOperator)
+		operators.POST("/batches", s.CreateBatchHandler)
+		operators.POST("/transactions/:id/settle", s.SettleTransactionHandler)
+		operators.POST("/transactions/:id/fail", s.FailTransactionHandler)
+		operators.POST("/transactions/:id/reverse", s.ReverseTransactionHandler)
+		operators.POST("/transactions/:id/refund", s.RefundHandler)
+		operators.POST("/deposits", s.ReceiveDepositHandler)
+		operators.POST("/deposits/:id/assign", s.AssignDepositHandler)
+		operators.POST("/accounts/:id/adjustments", s.RequestAdjustmentHandler)
+		operators.POST("/adjustments/:id/approve", s.ApproveAdjustmentHandler)
+		operators.POST("/adjustments/:id/reject", s.RejectAdjustmentHandler)
 	}
 
+	r.NoRoute(func(c *gin.Context) {
+		respondProblem(c, problemType{http.StatusNotFound, CodeRouteNotFound, "Not found"}, "no route matches "+c.Request.Method+" "+c.Request.URL.Path)
+	})
+
 	return r
 }
 
@@ -48,5 +96,20 @@ func (s *Server) HelloWorldHandler(c *gin.Context) {
 }
 
 func (s *Server) healthHandler(c *gin.Context) {
-	c.JSON(http.StatusOK, s.db.Health())
+	stats := s.db.Health()
+
+	// Attach the outcome of the last ledger reconciliation, if any
+	stats["reconciliation_status"] = "not_run"
+	if report := s.ReconciliationService.LastReport(); report != nil {
+		stats["reconciliation_status"] = "balanced"
+		if !report.Balanced() {
+			stats["reconciliation_status"] = "mismatched"
+		}
+		stats["reconciliation_last_run"] = report.FinishedAt.Format(time.RFC3339)
+		stats["reconciliation_accounts_checked"] = strconv.Itoa(report.AccountsChecked)
+		stats["reconciliation_mismatches"] = strconv.Itoa(len(report.Mismatches))
+		stats["reconciliation_unfunded_top_ups"] = strconv.Itoa(len(report.UnfundedTopUps))
+	}
+
+	c.JSON(http.StatusOK, stats)
 }
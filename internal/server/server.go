@@ -1,7 +1,10 @@
 package server
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strconv"
@@ -10,26 +13,80 @@ import (
 	_ "github.com/joho/godotenv/autoload"
 
 	"wallet/internal/database"
+	"wallet/internal/metrics"
+	"wallet/internal/payments"
 	"wallet/internal/services"
+
+	"github.com/graphql-go/graphql"
 )
 
 type Server struct {
 	port int
 
-	db                 database.Service
-	AccountService     services.AccountService
-	TransactionService services.TransactionService
+	db                    database.Service
+	graphqlSchema         graphql.Schema
+	operators             []services.Operator
+	UserService           services.UserService
+	AccountService        services.AccountService
+	TransactionService    services.TransactionService
+	ReconciliationService services.ReconciliationService
+	BalanceService        services.BalanceService
+	BatchService          services.BatchService
+	StatementService      services.StatementService
+	FundingService        services.FundingService
+	WithdrawalService     services.WithdrawalService
+	DepositService        services.DepositService
+	AdjustmentService     services.AdjustmentService
 }
 
 func NewServer() *http.Server {
-	port, _ := strconv.Atoi(os.Getenv("PORT"))
 	db := database.New()
 
-	NewServer := &Server{
-		port:               port,
-		db:                 db,
-		AccountService:     services.NewAccountService(db.GetDB()),
-		TransactionService: services.NewTransactionService(db.GetDB()),
+	NewServer, err := newServer(db, payments.New())
+	if err != nil {
+		log.Fatal(err)
+	}
+	NewServer.port, _ = strconv.Atoi(os.Getenv("PORT"))
+
+	// Expose the connection pool and ledger totals on /metrics
+	metrics.RegisterDatabase(db.GetDB())
+
+	// Finish the batches the last shutdown interrupted
+	if n, err := NewServer.BatchService.Resume(context.Background()); err != nil {
+		slog.Error("failed to resume batches", "error", err)
+	} else if n > 0 {
+		slog.Info("resumed interrupted batches", "batches", n)
+	}
+
+	// Give the accounts opened before account numbers and virtual IBANs
+	// theirs
+	if n, err := NewServer.AccountService.AssignAccountNumbers(context.Background()); err != nil {
+		slog.Error("failed to assign account numbers", "error", err)
+	} else if n > 0 {
+		slog.Info("assigned account numbers", "accounts", n)
+	}
+	if n, err := NewServer.DepositService.AssignVirtualIBANs(context.Background()); err != nil {
+		slog.Error("failed to assign virtual IBANs", "error", err)
+	} else if n > 0 {
+		slog.Info("assigned virtual IBANs", "accounts", n)
+	}
+
+	// Record end-of-day balances so point-in-time queries stay cheap
+	go NewServer.runDailySnapshots()
+
+	// Periodically check the stored balances against the transaction history
+	if interval, err := time.ParseDuration(os.Getenv("RECONCILIATION_INTERVAL")); err == nil && interval > 0 {
+		go NewServer.runReconciliation(interval)
+	}
+
+	// Fail the pending transactions that were never settled
+	if ttl, err := time.ParseDuration(os.Getenv("PENDING_TRANSACTION_TTL")); err == nil && ttl > 0 {
+		go NewServer.runPendingExpiry(ttl)
+	}
+
+	// Pay the requested withdrawals out into the outbox
+	if interval, err := time.ParseDuration(os.Getenv("PAYOUT_INTERVAL")); err == nil && interval > 0 {
+		go NewServer.runPayouts(interval)
 	}
 
 	// Declare Server config
@@ -43,3 +100,94 @@ func NewServer() *http.Server {
 
 	return server
 }
+
+// newServer wires the services and the GraphQL schema on top of db and the
+// payment provider, without starting any background jobs.
+func newServer(db database.Service, provider payments.Provider) (*Server, error) {
+	s := &Server{
+		db:                    db,
+		UserService:           services.NewUserService(db.GetDB()),
+		AccountService:        services.NewAccountService(db.GetDB()),
+		TransactionService:    services.NewTransactionService(db.GetDB()),
+		ReconciliationService: services.NewReconciliationService(db.GetDB()),
+		BalanceService:        services.NewBalanceService(db.GetDB()),
+		BatchService:          services.NewBatchService(db.GetDB()),
+		StatementService:      services.NewStatementService(db.GetDB()),
+		FundingService:        services.NewFundingService(db.GetDB(), provider),
+		WithdrawalService:     services.NewWithdrawalService(db.GetDB(), services.PayoutConfigFromEnv()),
+		DepositService:        services.NewDepositService(db.GetDB()),
+		AdjustmentService:     services.NewAdjustmentService(db.GetDB(), services.AdjustmentApprovalThresholdFromEnv()),
+		operators:             services.OperatorsFromEnv(),
+	}
+
+	schema, err := s.newGraphQLSchema()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build the GraphQL schema: %w", err)
+	}
+	s.graphqlSchema = schema
+	return s, nil
+}
+
+// runReconciliation reconciles the ledger immediately and then on every tick
+// of the given interval. The result is exposed through the health endpoint.
+func (s *Server) runReconciliation(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := s.ReconciliationService.Reconcile(context.Background())
+		if err != nil {
+			slog.Error("reconciliation failed", "error", err)
+		} else if !report.Balanced() {
+			slog.Warn("reconciliation found mismatched accounts", "mismatches", len(report.Mismatches), "unfunded_top_ups", len(report.UnfundedTopUps), "accounts_checked", report.AccountsChecked)
+		}
+		<-ticker.C
+	}
+}
+
+// runPendingExpiry fails the transactions pending for longer than ttl
+// immediately and then on every tick, at least hourly.
+func (s *Server) runPendingExpiry(ttl time.Duration) {
+	ticker := time.NewTicker(min(ttl, time.Hour))
+	defer ticker.Stop()
+
+	for {
+		n, err := s.AccountService.ExpirePending(context.Background(), time.Now().Add(-ttl))
+		if err != nil {
+			slog.Error("pending transaction expiry failed", "expired", n, "error", err)
+		} else if n > 0 {
+			slog.Info("expired pending transactions", "expired", n)
+		}
+		<-ticker.C
+	}
+}
+
+// runPayouts writes the requested withdrawals into payout files on every
+// tick of the given interval.
+func (s *Server) runPayouts(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		payouts, err := s.WithdrawalService.RunPayouts(context.Background())
+		if err != nil {
+			slog.Error("payout run failed", "payouts", len(payouts), "error", err)
+		}
+	}
+}
+
+// runDailySnapshots snapshots the previous day on start-up and then again
+// shortly after every midnight.
+func (s *Server) runDailySnapshots() {
+	for {
+		yesterday := services.StartOfDay(time.Now()).AddDate(0, 0, -1)
+		if n, err := s.BalanceService.SnapshotDay(context.Background(), yesterday); err != nil {
+			slog.Error("balance snapshot failed", "day", yesterday.Format(time.DateOnly), "error", err)
+		} else {
+			slog.Info("balance snapshots recorded", "day", yesterday.Format(time.DateOnly), "snapshots", n)
+		}
+
+		nextRun := services.StartOfDay(time.Now()).AddDate(0, 0, 1).Add(time.Minute)
+		time.Sleep(time.Until(nextRun))
+	}
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"time"
+
+	"wallet/internal/services"
+	"wallet/internal/statement"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StatementHandler exports an account statement
+// @Summary Export an account statement
+// @Description Download the transactions of an account over a period, between its opening and closing balances, with the balance after each transaction.
+// @Description from and to are dates (YYYY-MM-DD, in server time) or RFC 3339 timestamps; a date as to includes that whole day.
+// @Description The period defaults to the current month up to now.
+// @Tags accounts
+// @Produce text/csv
+// @Produce application/pdf
+// @Produce application/x-ofx
+// @Produce application/vnd.intu.qfx
+// @Produce text/plain
+// @Produce application/xml
+// @Param id path string true "Account ID or account number"
+// @Param from query string false "Start of the period, inclusive"
+// @Param to query string false "End of the period"
+// @Param format query string false "Statement format" Enums(csv, pdf, ofx, qfx, mt940, camt053) default(csv)
+// @Success 200 {file} file "The statement"
+// @Failure 400 {object} dto.Problem "Invalid account ID"
+// @Failure 404 {object} dto.Problem "Account not found"
+// @Failure 422 {object} dto.Problem "Invalid period or format"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /accounts/{id}/statements [get]
+func (s *Server) StatementHandler(c *gin.Context) {
+	accountID, err := s.accountIDParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	format := statement.CSV
+	if raw := c.Query("format"); raw != "" {
+		if format, err = statement.ParseFormat(raw); err != nil {
+			respondError(c, &services.ValidationError{Field: "format", Message: "format must be one of csv, pdf, ofx, qfx, mt940 or camt053"})
+			return
+		}
+	}
+
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := now
+	if raw := c.Query("from"); raw != "" {
+		if from, err = parseStatementTime(raw, false); err != nil {
+			respondError(c, &services.ValidationError{Field: "from", Message: "from must be a date or an RFC 3339 timestamp"})
+			return
+		}
+	}
+	if raw := c.Query("to"); raw != "" {
+		if to, err = parseStatementTime(raw, true); err != nil {
+			respondError(c, &services.ValidationError{Field: "to", Message: "to must be a date or an RFC 3339 timestamp"})
+			return
+		}
+	}
+
+	st, err := s.StatementService.Statement(c.Request.Context(), accountID, from, to)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	// Render fully before responding so a failure can still be reported
+	var body bytes.Buffer
+	if err := statement.Write(&body, format, st); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+format.Filename(st)+`"`)
+	c.Data(http.StatusOK, format.ContentType(), body.Bytes())
+}
+
+// parseStatementTime parses a statement bound given as an RFC 3339 timestamp
+// or a date in server time. A date that ends a period covers the whole day,
+// so it stands for the start of the next one.
+func parseStatementTime(raw string, end bool) (time.Time, error) {
+	if !strings.Contains(raw, "T") {
+		day, err := time.ParseInLocation(time.DateOnly, raw, time.Local)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if end {
+			day = day.AddDate(0, 0, 1)
+		}
+		return day, nil
+	}
+	return time.Parse(time.RFC3339Nano, raw)
+}
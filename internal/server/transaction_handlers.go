@@ -0,0 +1,169 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"wallet/internal/repository"
+	"wallet/internal/server/dto"
+	"wallet/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// ListTransactionsHandler lists and searches an account's transactions
+// @Summary List an account's transactions
+// @Description Page through an account's transactions, newest first, optionally only those matching a search. q matches the ref, description, external reference, merchant name or metadata, ignoring case.
+// @Tags transactions
+// @Produce json
+// @Param id path string true "Account ID or account number"
+// @Param q query string false "Text to search for"
+// @Param external_ref query string false "External reference"
+// @Param merchant_category_code query string false "Four-digit merchant category code"
+// @Param limit query int false "Page size, at most 100" default(50)
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} dto.TransactionListResponse "Transactions"
+// @Failure 400 {object} dto.Problem "Invalid account ID or cursor"
+// @Failure 404 {object} dto.Problem "Account not found"
+// @Failure 422 {object} dto.Problem "Validation failed"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /accounts/{id}/transactions [get]
+func (s *Server) ListTransactionsHandler(c *gin.Context) {
+	accountID, err := s.accountIDParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	limit := 0
+	if raw := c.Query("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 {
+			respondError(c, &services.ValidationError{Field: "limit", Message: "limit must be a positive integer"})
+			return
+		}
+	}
+	filter := repository.TransactionFilter{
+		Query:                c.Query("q"),
+		ExternalRef:          c.Query("external_ref"),
+		MerchantCategoryCode: c.Query("merchant_category_code"),
+	}
+
+	page, err := s.TransactionService.SearchTransactions(c.Request.Context(), accountID, filter, c.Query("cursor"), limit)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// SettleTransactionHandler posts a pending transaction
+// @Summary Settle a pending transaction
+// @Description Post a pending top-up or charge. A settled top-up credits the account; a settled charge turns its hold into a debit.
+// @Description Top-ups funded through the payment provider are settled by their payment's outcome and cannot be settled here.
+// @Tags transactions
+// @Produce json
+// @Security OperatorToken
+// @Param id path string true "Transaction ID"
+// @Success 200 {object} dto.TransactionResponse "Transaction settled"
+// @Failure 400 {object} dto.Problem "Invalid transaction ID"
+// @Failure 401 {object} dto.Problem "Operator token missing or unknown"
+// @Failure 404 {object} dto.Problem "Transaction not found"
+// @Failure 409 {object} dto.Problem "Transaction is not pending or is settled by its payment"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /transactions/{id}/settle [post]
+func (s *Server) SettleTransactionHandler(c *gin.Context) {
+	transactionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondProblem(c, invalidTransactionIDProblem, "invalid transaction ID")
+		return
+	}
+
+	transaction, err := s.AccountService.WithActor(requestActor(c)).SettleTransaction(c.Request.Context(), transactionID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, transaction)
+}
+
+// FailTransactionHandler fails a pending transaction
+// @Summary Fail a pending transaction
+// @Description Fail a pending top-up or charge, releasing the amount a pending charge holds. The body, giving a reason, is optional.
+// @Tags transactions
+// @Accept json
+// @Produce json
+// @Security OperatorToken
+// @Param id path string true "Transaction ID"
+// @Param request body dto.TransactionStatusRequest false "Reason"
+// @Success 200 {object} dto.TransactionResponse "Transaction failed"
+// @Failure 400 {object} dto.Problem "Malformed request or invalid transaction ID"
+// @Failure 401 {object} dto.Problem "Operator token missing or unknown"
+// @Failure 404 {object} dto.Problem "Transaction not found"
+// @Failure 409 {object} dto.Problem "Transaction is not pending"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /transactions/{id}/fail [post]
+func (s *Server) FailTransactionHandler(c *gin.Context) {
+	transactionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondProblem(c, invalidTransactionIDProblem, "invalid transaction ID")
+		return
+	}
+
+	var request dto.TransactionStatusRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			respondBindingError(c, err)
+			return
+		}
+	}
+
+	transaction, err := s.AccountService.WithActor(requestActor(c)).FailTransaction(c.Request.Context(), transactionID, request.Reason)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, transaction)
+}
+
+// ReverseTransactionHandler reverses a posted transaction
+// @Summary Reverse a posted transaction
+// @Description Undo a posted top-up or charge, e.g. for a chargeback, with a new posted transaction of the opposite type, which is returned. The original is marked reversed. Reversing a top-up needs its amount to be available.
+// @Tags transactions
+// @Accept json
+// @Produce json
+// @Security OperatorToken
+// @Param id path string true "Transaction ID"
+// @Param request body dto.ReverseTransactionRequest true "Reason"
+// @Success 200 {object} dto.TransactionResponse "The reversing transaction"
+// @Failure 400 {object} dto.Problem "Malformed request or invalid transaction ID"
+// @Failure 401 {object} dto.Problem "Operator token missing or unknown"
+// @Failure 404 {object} dto.Problem "Transaction not found"
+// @Failure 409 {object} dto.Problem "Transaction is not posted"
+// @Failure 422 {object} dto.Problem "Validation failed or insufficient funds"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /transactions/{id}/reverse [post]
+func (s *Server) ReverseTransactionHandler(c *gin.Context) {
+	transactionID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondProblem(c, invalidTransactionIDProblem, "invalid transaction ID")
+		return
+	}
+
+	var request dto.ReverseTransactionRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	transaction, err := s.AccountService.WithActor(requestActor(c)).ReverseTransaction(c.Request.Context(), transactionID, request.Reason)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, transaction)
+}
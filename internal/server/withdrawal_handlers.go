@@ -0,0 +1,156 @@
+package server
+
+import (
+	"net/http"
+
+	"wallet/internal/models"
+	"wallet/internal/server/dto"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// CreateBeneficiaryHandler adds a bank account an account can withdraw to
+// @Summary Add a beneficiary
+// @Description Add a bank account the account holder can withdraw to: an IBAN, whose check digits are verified, with an optional BIC for SEPA transfers, or a routing and account number for ACH transfers.
+// @Tags withdrawals
+// @Accept json
+// @Produce json
+// @Param id path string true "Account ID or account number"
+// @Param request body dto.CreateBeneficiaryRequest true "Bank account"
+// @Success 201 {object} dto.BeneficiaryResponse "Beneficiary added"
+// @Failure 400 {object} dto.Problem "Malformed request or invalid account ID"
+// @Failure 404 {object} dto.Problem "Account not found"
+// @Failure 422 {object} dto.Problem "Validation failed"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /accounts/{id}/beneficiaries [post]
+func (s *Server) CreateBeneficiaryHandler(c *gin.Context) {
+	accountID, err := s.accountIDParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	var request dto.CreateBeneficiaryRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+
+	beneficiary, err := s.WithdrawalService.WithActor(requestActor(c)).AddBeneficiary(c.Request.Context(), accountID, models.Beneficiary{
+		Name:          request.Name,
+		IBAN:          request.IBAN,
+		BIC:           request.BIC,
+		RoutingNumber: request.RoutingNumber,
+		AccountNumber: request.AccountNumber,
+		Savings:       request.Savings,
+	})
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, beneficiary)
+}
+
+// ListBeneficiariesHandler lists the bank accounts an account can withdraw to
+// @Summary List beneficiaries
+// @Description List the bank accounts the account holder can withdraw to, oldest first
+// @Tags withdrawals
+// @Produce json
+// @Param id path string true "Account ID or account number"
+// @Success 200 {array} dto.BeneficiaryResponse "Beneficiaries"
+// @Failure 400 {object} dto.Problem "Invalid account ID"
+// @Failure 404 {object} dto.Problem "Account not found"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /accounts/{id}/beneficiaries [get]
+func (s *Server) ListBeneficiariesHandler(c *gin.Context) {
+	accountID, err := s.accountIDParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	beneficiaries, err := s.WithdrawalService.ListBeneficiaries(c.Request.Context(), accountID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, beneficiaries)
+}
+
+// WithdrawHandler requests a withdrawal to a beneficiary
+// @Summary Withdraw to a bank account
+// @Description Request a withdrawal to one of the account's beneficiaries. The amount is held against the available balance until the next payout run sends the withdrawal to the bank; it is debited once the bank confirms the transfer and given back if the bank returns it.
+// @Tags withdrawals
+// @Accept json
+// @Produce json
+// @Param id path string true "Account ID or account number"
+// @Param request body dto.WithdrawalRequest true "Beneficiary and amount"
+// @Success 201 {object} dto.WithdrawalResponse "Withdrawal requested"
+// @Failure 400 {object} dto.Problem "Malformed request or invalid account ID"
+// @Failure 404 {object} dto.Problem "Account or beneficiary not found"
+// @Failure 409 {object} dto.Problem "Account is frozen or not open"
+// @Failure 422 {object} dto.Problem "Validation failed or insufficient funds"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /accounts/{id}/withdrawals [post]
+func (s *Server) WithdrawHandler(c *gin.Context) {
+	accountID, err := s.accountIDParam(c)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	var request dto.WithdrawalRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		respondBindingError(c, err)
+		return
+	}
+	beneficiaryID, err := uuid.Parse(request.BeneficiaryID)
+	if err != nil {
+		writeProblem(c, dto.Problem{
+			Title:  validationProblem.title,
+			Status: validationProblem.status,
+			Detail: "beneficiary_id must be a UUID",
+			Code:   validationProblem.code,
+			Errors: []dto.FieldError{{Field: "beneficiary_id", Message: "must be a UUID"}},
+		})
+		return
+	}
+
+	withdrawal, err := s.WithdrawalService.WithActor(requestActor(c)).Withdraw(c.Request.Context(), accountID, beneficiaryID, request.Amount)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, withdrawal)
+}
+
+// GetWithdrawalHandler returns a withdrawal
+// @Summary Get a withdrawal
+// @Description Get a withdrawal with its status: requested, submitted, completed, returned with the bank's reason, or failed if its hold lapsed before it was paid out
+// @Tags withdrawals
+// @Produce json
+// @Param id path string true "Withdrawal ID"
+// @Success 200 {object} dto.WithdrawalResponse "Withdrawal"
+// @Failure 400 {object} dto.Problem "Invalid withdrawal ID"
+// @Failure 404 {object} dto.Problem "Withdrawal not found"
+// @Failure 500 {object} dto.Problem "Internal server error"
+// @Router /withdrawals/{id} [get]
+func (s *Server) GetWithdrawalHandler(c *gin.Context) {
+	withdrawalID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		respondProblem(c, invalidWithdrawalIDProblem, "invalid withdrawal ID")
+		return
+	}
+
+	withdrawal, err := s.WithdrawalService.GetWithdrawal(c.Request.Context(), withdrawalID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, withdrawal)
+}
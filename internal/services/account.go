@@ -1,229 +1,715 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"math"
+	"log/slog"
 	"time"
+	"wallet/internal/metrics"
 	"wallet/internal/models"
+	"wallet/internal/repository"
+	"wallet/internal/tracing"
 
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 )
 
+var accountTracer = tracing.Tracer("wallet/internal/services")
+
 type AccountService interface {
-	CreateAccountWithUser(email, firstName, lastName string) (*models.Account, error)
-	GetAccountByID(accountID uuid.UUID) (*models.Account, error)
-	TopUp(accountID uuid.UUID, amount float64) (*models.Transaction, error)
-	Charge(accountID uuid.UUID, amount float64) (*models.Transaction, error)
+	CreateAccountWithUser(ctx context.Context, email, firstName, lastName string) (*models.Account, error)
+	GetAccountByID(ctx context.Context, accountID uuid.UUID) (*models.Account, error)
+	// ResolveAccountID returns the ID of the account identified by either
+	// its ID or its account number. An account number's check digit is
+	// verified before it is looked up.
+	ResolveAccountID(ctx context.Context, idOrNumber string) (uuid.UUID, error)
+	GetAccountsByUserID(ctx context.Context, userID uuid.UUID) ([]models.Account, error)
+	// TopUp and Charge store the optional details with the transaction, so
+	// it can be told apart in listings and statements and searched for.
+	TopUp(ctx context.Context, accountID uuid.UUID, amount float64, details models.TransactionDetails) (*models.Transaction, error)
+	Charge(ctx context.Context, accountID uuid.UUID, amount float64, details models.TransactionDetails) (*models.Transaction, error)
+	// PendingTopUp starts a top-up, such as a bank transfer, that only
+	// credits the account once it is settled.
+	PendingTopUp(ctx context.Context, accountID uuid.UUID, amount float64, details models.TransactionDetails) (*models.Transaction, error)
+	// PendingCharge holds an amount, such as a card authorisation, against
+	// the available balance until the charge is settled or fails.
+	PendingCharge(ctx context.Context, accountID uuid.UUID, amount float64, details models.TransactionDetails) (*models.Transaction, error)
+	// SettleTransaction posts a pending transaction.
+	SettleTransaction(ctx context.Context, transactionID uuid.UUID) (*models.Transaction, error)
+	// FailTransaction fails a pending transaction, releasing any hold.
+	FailTransaction(ctx context.Context, transactionID uuid.UUID, reason string) (*models.Transaction, error)
+	// ReverseTransaction undoes a posted transaction and returns the
+	// reversing transaction.
+	ReverseTransaction(ctx context.Context, transactionID uuid.UUID, reason string) (*models.Transaction, error)
+	// ExpirePending fails the transactions still pending since before the
+	// given time and returns how many it failed.
+	ExpirePending(ctx context.Context, createdBefore time.Time) (int, error)
+	CreateAccount(ctx context.Context, userID uuid.UUID) (*models.Account, error)
+	Adjust(ctx context.Context, accountID uuid.UUID, amount float64, reason string) (*models.Transaction, error)
+	Freeze(ctx context.Context, accountID uuid.UUID, reason string) (*models.Account, error)
+	Unfreeze(ctx context.Context, accountID uuid.UUID, reason string) (*models.Account, error)
+	// AssignAccountNumbers issues account numbers to the accounts opened
+	// before they existed and returns how many it issued.
+	AssignAccountNumbers(ctx context.Context) (int, error)
+	// WithActor returns a copy of the service that attributes the changes
+	// it makes to the given actor in the audit log.
+	WithActor(actor Actor) AccountService
 }
 
 type accountService struct {
-	db *gorm.DB
+	store      repository.Store
+	audit      AuditService
+	events     EventStore
+	projection AccountProjection
+	actor      Actor
+	// settlesPayments lets SettleTransaction post top-ups funded through
+	// the payment provider, which only their payment's outcome may settle.
+	settlesPayments bool
+	// settlesWithdrawals lets SettleTransaction and FailTransaction change
+	// the holds of withdrawals, which only the withdrawal's payout, return
+	// or expiry may settle.
+	settlesWithdrawals bool
 }
 
 func NewAccountService(db *gorm.DB) AccountService {
-	return &accountService{db: db}
+	return &accountService{
+		store:      repository.NewGormStore(db),
+		audit:      NewAuditService(db),
+		events:     NewEventStore(),
+		projection: NewAccountProjection(),
+		actor:      SystemActor,
+	}
+}
+
+// NewAccountServiceWithStore returns an AccountService that keeps accounts,
+// their events and the audit log in store.
+func NewAccountServiceWithStore(store repository.Store) AccountService {
+	return &accountService{
+		store: store,
+		// Recording entries only needs the transaction's store
+		audit:      &auditService{key: auditKeyFromEnv()},
+		events:     NewEventStore(),
+		projection: NewAccountProjection(),
+		actor:      SystemActor,
+	}
+}
+
+func (s *accountService) WithActor(actor Actor) AccountService {
+	clone := *s
+	clone.actor = actor
+	return &clone
+}
+
+// startSpan bounds ctx by the deadline of an AccountService method and
+// starts its span. Queries run with the returned context nest under it; the
+// caller must call cancel once the method returns.
+func (s *accountService) startSpan(ctx context.Context, method string, accountID uuid.UUID, timeout time.Duration) (context.Context, trace.Span, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	ctx, span := accountTracer.Start(ctx, "AccountService."+method)
+	if accountID != uuid.Nil {
+		span.SetAttributes(attribute.String("account.id", accountID.String()))
+	}
+	return ctx, span, cancel
+}
+
+// endSpan ends the span of an AccountService method, reporting a canceled
+// or expired ctx rather than the driver error it caused.
+func endSpan(ctx context.Context, span trace.Span, err *error) {
+	*err = operationError(ctx, *err)
+	tracing.End(span, *err)
+}
+
+// accountAuditState is the audited view of an account.
+type accountAuditState struct {
+	Balance           float64                  `json:"balance"`
+	Status            models.AccountStatus     `json:"status,omitempty"`
+	Transaction       string                   `json:"transaction_ref,omitempty"`
+	TransactionStatus models.TransactionStatus `json:"transaction_status,omitempty"`
+	Reversal          string                   `json:"reversal_ref,omitempty"`
+	Reason            string                   `json:"reason,omitempty"`
+	Number            string                   `json:"number,omitempty"`
+	VirtualIBAN       string                   `json:"virtual_iban,omitempty"`
 }
 
 // CreateAccountWithUser creates a new user and a corresponding account with a 0.00 balance.
-func (s *accountService) CreateAccountWithUser(email, firstName, lastName string) (*models.Account, error) {
-	userService := NewUserService(s.db)
+func (s *accountService) CreateAccountWithUser(ctx context.Context, email, firstName, lastName string) (_ *models.Account, err error) {
+	ctx, span, cancel := s.startSpan(ctx, "CreateAccountWithUser", uuid.Nil, writeTimeout)
+	defer cancel()
+	defer endSpan(ctx, span, &err)
+
+	userService := NewUserServiceWithStore(s.store)
 
 	// Check if user already exists
-	_, user_err := userService.GetUserByEmail(email)
+	_, user_err := userService.GetUserByEmail(ctx, email)
 	if user_err == nil {
-		return nil, errors.New("user already exists")
+		return nil, ErrDuplicateUser
 	}
 
-	// Start a transaction
-	tx := s.db.Begin()
-
-	// Create the user
 	new_user := &models.User{
 		Email:     email,
 		FirstName: firstName,
 		LastName:  lastName,
 	}
+	var account *models.Account
+
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		// Save the new user
+		if err := tx.Users().Create(ctx, new_user); err != nil {
+			if errors.Is(err, repository.ErrDuplicate) {
+				return ErrDuplicateUser
+			}
+			return err
+		}
+
+		// Open the account stream; the projection creates the account row with
+		// an initial balance of 0.00
+		number, err := newAccountNumber(ctx, tx)
+		if err != nil {
+			return err
+		}
+		virtualIBAN, err := newVirtualIBAN(ctx, tx)
+		if err != nil {
+			return err
+		}
+		aggregate := OpenAccount(uuid.New(), new_user.ID, number, virtualIBAN)
+		if _, err := saveAccountAggregate(ctx, tx, s.events, s.projection, aggregate); err != nil {
+			return err
+		}
 
-	// Save the new user
-	if err := tx.Create(&new_user).Error; err != nil {
-		tx.Rollback()
+		if account, err = tx.Accounts().GetByID(ctx, aggregate.ID); err != nil {
+			return err
+		}
+
+		// Audit both new records as part of the same transaction
+		err = s.audit.Record(ctx, tx, s.actor, AuditEvent{
+			Action:     AuditUserCreated,
+			EntityType: "user",
+			EntityID:   new_user.ID.String(),
+			After:      map[string]string{"email": email, "first_name": firstName, "last_name": lastName},
+		})
+		if err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, tx, s.actor, AuditEvent{
+			Action:     AuditAccountCreated,
+			EntityType: "account",
+			EntityID:   account.ID.String(),
+			After:      accountAuditState{Balance: account.Balance, Number: number, VirtualIBAN: virtualIBAN},
+		})
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	// Create the account for the user with an initial balance of 0.00
-	account := &models.Account{
-		Balance: 0.00,
-		UserID:  new_user.ID,
-	}
+	// set the relationship
+	account.User = *new_user
 
-	// Save the new account
-	if err := tx.Create(&account).Error; err != nil {
-		tx.Rollback()
+	slog.InfoContext(ctx, "user created", "user_id", new_user.ID, "account_id", account.ID, "email", email, "actor", s.actor.Name)
+	return account, nil
+}
+
+// CreateAccount opens an additional account with a 0.00 balance for an existing user.
+func (s *accountService) CreateAccount(ctx context.Context, userID uuid.UUID) (_ *models.Account, err error) {
+	ctx, span, cancel := s.startSpan(ctx, "CreateAccount", uuid.Nil, writeTimeout)
+	defer cancel()
+	defer endSpan(ctx, span, &err)
+
+	user, err := NewUserServiceWithStore(s.store).GetUserByID(ctx, userID)
+	if err != nil {
 		return nil, err
 	}
 
-	// Commit the transaction
-	if err := tx.Commit().Error; err != nil {
+	var account *models.Account
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		number, err := newAccountNumber(ctx, tx)
+		if err != nil {
+			return err
+		}
+		virtualIBAN, err := newVirtualIBAN(ctx, tx)
+		if err != nil {
+			return err
+		}
+		aggregate := OpenAccount(uuid.New(), user.ID, number, virtualIBAN)
+		if _, err := saveAccountAggregate(ctx, tx, s.events, s.projection, aggregate); err != nil {
+			return err
+		}
+		if account, err = tx.Accounts().GetByID(ctx, aggregate.ID); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, tx, s.actor, AuditEvent{
+			Action:     AuditAccountCreated,
+			EntityType: "account",
+			EntityID:   account.ID.String(),
+			After:      accountAuditState{Balance: account.Balance, Status: account.Status, Number: number, VirtualIBAN: virtualIBAN},
+		})
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	// set the relationship
-	account.User = *new_user
+	account.User = *user
 
+	slog.InfoContext(ctx, "account created", "user_id", user.ID, "account_id", account.ID, "actor", s.actor.Name)
 	return account, nil
 }
 
-// GetAccountByID retrieves an account by its ID.
-func (s *accountService) GetAccountByID(accountID uuid.UUID) (*models.Account, error) {
-	var account models.Account
-	err := s.db.First(&account, "id = ?", accountID).Error
+// Freeze blocks top-ups and charges on an account.
+func (s *accountService) Freeze(ctx context.Context, accountID uuid.UUID, reason string) (_ *models.Account, err error) {
+	ctx, span, cancel := s.startSpan(ctx, "Freeze", accountID, writeTimeout)
+	defer cancel()
+	defer endSpan(ctx, span, &err)
+
+	return s.changeStatus(ctx, accountID, AuditAccountFrozen, reason, func(a *AccountAggregate) error {
+		return a.Freeze(reason)
+	})
+}
+
+// Unfreeze lifts a freeze.
+func (s *accountService) Unfreeze(ctx context.Context, accountID uuid.UUID, reason string) (_ *models.Account, err error) {
+	ctx, span, cancel := s.startSpan(ctx, "Unfreeze", accountID, writeTimeout)
+	defer cancel()
+	defer endSpan(ctx, span, &err)
+
+	return s.changeStatus(ctx, accountID, AuditAccountUnfrozen, reason, func(a *AccountAggregate) error {
+		return a.Unfreeze(reason)
+	})
+}
+
+// changeStatus applies a freeze or unfreeze command to the account aggregate
+// and persists, projects and audits the result in a single transaction.
+func (s *accountService) changeStatus(ctx context.Context, accountID uuid.UUID, action, reason string, command func(a *AccountAggregate) error) (*models.Account, error) {
+	var account *models.Account
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		aggregate, err := loadAccountAggregate(ctx, tx, s.events, accountID)
+		if err != nil {
+			return err
+		}
+		if account, err = tx.Accounts().GetByID(ctx, accountID); err != nil {
+			return err
+		}
+		before := accountAuditState{Balance: account.Balance, Status: account.Status}
+
+		if err := command(aggregate); err != nil {
+			return err
+		}
+		if _, err := saveAccountAggregate(ctx, tx, s.events, s.projection, aggregate); err != nil {
+			return err
+		}
+		if account, err = tx.Accounts().GetByID(ctx, accountID); err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, tx, s.actor, AuditEvent{
+			Action:     action,
+			EntityType: "account",
+			EntityID:   accountID.String(),
+			Before:     before,
+			After:      accountAuditState{Balance: account.Balance, Status: account.Status, Reason: reason},
+		})
+	})
 	if err != nil {
 		return nil, err
 	}
-	return &account, nil
+
+	slog.InfoContext(ctx, "account status changed", "account_id", accountID, "status", account.Status, "reason", reason, "actor", s.actor.Name)
+	return account, nil
 }
 
-// TopUp adds funds to an account.
-func (s *accountService) TopUp(accountID uuid.UUID, amount float64) (*models.Transaction, error) {
-	if amount <= 0 {
-		return nil, errors.New("amount must be greater than 0")
-	}
+// GetAccountByID retrieves an account by its ID.
+func (s *accountService) GetAccountByID(ctx context.Context, accountID uuid.UUID) (_ *models.Account, err error) {
+	ctx, span, cancel := s.startSpan(ctx, "GetAccountByID", accountID, readTimeout)
+	defer cancel()
+	defer endSpan(ctx, span, &err)
 
-	// Retrieve the account
-	account, err := s.GetAccountByID(accountID)
+	account, err := s.store.Accounts().GetByID(ctx, accountID)
 	if err != nil {
-		return nil, err
+		return nil, notFound(err, ErrAccountNotFound)
 	}
+	return account, nil
+}
 
-	// Start a transaction
-	tx := s.db.Begin()
+// GetAccountsByUserID retrieves the accounts owned by a user.
+func (s *accountService) GetAccountsByUserID(ctx context.Context, userID uuid.UUID) (_ []models.Account, err error) {
+	ctx, span, cancel := s.startSpan(ctx, "GetAccountsByUserID", uuid.Nil, readTimeout)
+	defer cancel()
+	defer endSpan(ctx, span, &err)
 
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
-		}
-	}()
+	return s.store.Accounts().ListByUserID(ctx, userID)
+}
 
-	// Add balance to the account
-	account.Balance += amount
-	account.Balance = math.Round(account.Balance*100) / 100
-	// Retrieve the user associated with the account
-	acc_u, u_err := NewUserService(s.db).GetUserByID(account.UserID)
+// TopUp adds funds to an account.
+func (s *accountService) TopUp(ctx context.Context, accountID uuid.UUID, amount float64, details models.TransactionDetails) (*models.Transaction, error) {
+	return s.topUp(ctx, "TopUp", accountID, amount, false, details)
+}
+
+// PendingTopUp records a top-up that credits the account once settled.
+func (s *accountService) PendingTopUp(ctx context.Context, accountID uuid.UUID, amount float64, details models.TransactionDetails) (*models.Transaction, error) {
+	return s.topUp(ctx, "PendingTopUp", accountID, amount, true, details)
+}
 
-	if u_err != nil {
-		tx.Rollback()
-		return nil, u_err
+func (s *accountService) topUp(ctx context.Context, method string, accountID uuid.UUID, amount float64, pending bool, details models.TransactionDetails) (transaction *models.Transaction, err error) {
+	ctx, span, cancel := s.startSpan(ctx, method, accountID, writeTimeout)
+	defer cancel()
+	defer func() { err = s.observeFunds(ctx, span, metrics.OperationTopUp, accountID, amount, transaction, err) }()
+
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	if details, err = normalizeTransactionDetails(details); err != nil {
+		return nil, err
 	}
 
-	var existingTransaction models.Transaction
+	return s.moveFunds(ctx, accountID, AuditAccountTopUp, "", func(a *AccountAggregate, transactionID uuid.UUID, ref string) error {
+		return a.Deposit(transactionID, ref, amount, pending, details)
+	})
+}
 
-	// Generate a unique Ref for each transaction
-	ref := fmt.Sprintf("TXN-%s-%d", uuid.New().String(), time.Now().UnixNano())
+// Charge deducts funds from an account.
+func (s *accountService) Charge(ctx context.Context, accountID uuid.UUID, amount float64, details models.TransactionDetails) (*models.Transaction, error) {
+	return s.charge(ctx, "Charge", accountID, amount, false, details)
+}
 
-	// Check if a transaction with the same Ref already exists
-	if err := s.db.Where("ref = ?", ref).First(&existingTransaction).Error; err == nil {
-		return nil, errors.New("duplicate transaction detected")
-	}
+// PendingCharge holds funds on an account until the charge is settled.
+func (s *accountService) PendingCharge(ctx context.Context, accountID uuid.UUID, amount float64, details models.TransactionDetails) (*models.Transaction, error) {
+	return s.charge(ctx, "PendingCharge", accountID, amount, true, details)
+}
 
-	// Create a new transaction
-	transaction := &models.Transaction{
-		TransactionType: models.TopUp,
-		Amount:          math.Round(amount*100) / 100,
-		Ref:             ref,
-		AccountID:       accountID,
-	}
+func (s *accountService) charge(ctx context.Context, method string, accountID uuid.UUID, amount float64, pending bool, details models.TransactionDetails) (transaction *models.Transaction, err error) {
+	ctx, span, cancel := s.startSpan(ctx, method, accountID, writeTimeout)
+	defer cancel()
+	defer func() { err = s.observeFunds(ctx, span, metrics.OperationCharge, accountID, amount, transaction, err) }()
 
-	// Save the transaction
-	if err := tx.Create(transaction).Error; err != nil {
-		tx.Rollback()
+	if details, err = normalizeTransactionDetails(details); err != nil {
 		return nil, err
 	}
 
-	// Update the account with the new balance
-	if err := tx.Save(account).Error; err != nil {
-		tx.Rollback()
-		return nil, err
-	}
+	return s.moveFunds(ctx, accountID, AuditAccountCharge, "", func(a *AccountAggregate, transactionID uuid.UUID, ref string) error {
+		return a.Charge(transactionID, ref, amount, pending, details)
+	})
+}
 
-	// Commit the transaction
-	if err := tx.Commit().Error; err != nil {
-		return nil, err
-	}
-	// set the relationship
-	transaction.Account = *account
-	transaction.Account.User = *acc_u
+// Adjust credits a positive or debits a negative amount on behalf of an
+// operator, recording the reason with the transaction's event and audit entry.
+func (s *accountService) Adjust(ctx context.Context, accountID uuid.UUID, amount float64, reason string) (transaction *models.Transaction, err error) {
+	ctx, span, cancel := s.startSpan(ctx, "Adjust", accountID, writeTimeout)
+	defer cancel()
+	defer func() {
+		err = s.observeFunds(ctx, span, metrics.OperationAdjustment, accountID, amount, transaction, err)
+	}()
 
-	return transaction, nil
+	return s.moveFunds(ctx, accountID, AuditAccountAdjusted, reason, func(a *AccountAggregate, transactionID uuid.UUID, ref string) error {
+		return a.Adjust(transactionID, ref, amount, reason)
+	})
 }
 
-// Charge deducts funds from an account.
-func (s *accountService) Charge(accountID uuid.UUID, amount float64) (*models.Transaction, error) {
-	// Retrieve the account
-	account, err := s.GetAccountByID(accountID)
+// observeFunds records the outcome of a funds operation in the metrics and
+// the log, and ends the operation's span. It returns err, or the context
+// error that caused it.
+func (s *accountService) observeFunds(ctx context.Context, span trace.Span, operation string, accountID uuid.UUID, amount float64, transaction *models.Transaction, err error) error {
+	err = operationError(ctx, err)
+	outcome := fundsOutcome(err)
+	metrics.ObserveFunds(operation, outcome, amount)
+
+	level := slog.LevelInfo
+	attrs := []slog.Attr{
+		slog.String("operation", operation),
+		slog.String("outcome", outcome),
+		slog.String("account_id", accountID.String()),
+		slog.Float64("amount", amount),
+		slog.String("actor", s.actor.Name),
+	}
 	if err != nil {
-		return nil, err
+		level = slog.LevelWarn
+		if outcome == metrics.OutcomeError {
+			level = slog.LevelError
+		}
+		attrs = append(attrs, slog.String("error", err.Error()))
+	} else {
+		attrs = append(attrs, slog.String("transaction_ref", transaction.Ref), slog.Float64("balance", transaction.Account.Balance))
 	}
+	slog.LogAttrs(ctx, level, "funds operation", attrs...)
+
+	tracing.End(span, err)
+	return err
+}
 
-	// Ensure there are sufficient funds
-	if account.Balance < amount {
-		return nil, errors.New("insufficient balance")
+// fundsOutcome classifies the result of a funds operation for the metrics.
+func fundsOutcome(err error) string {
+	switch {
+	case err == nil:
+		return metrics.OutcomeSuccess
+	case errors.Is(err, ErrAccountNotFound):
+		return metrics.OutcomeAccountNotFound
+	case errors.Is(err, ErrConcurrentModification):
+		return metrics.OutcomeConflict
+	case errors.Is(err, ErrInsufficientFunds):
+		return metrics.OutcomeInsufficientBalance
+	case errors.Is(err, ErrAccountFrozen):
+		return metrics.OutcomeAccountFrozen
+	case errors.Is(err, context.DeadlineExceeded):
+		return metrics.OutcomeTimeout
+	case errors.Is(err, context.Canceled):
+		return metrics.OutcomeCanceled
+	}
+	var invalid *ValidationError
+	if errors.As(err, &invalid) {
+		return metrics.OutcomeInvalid
 	}
+	return metrics.OutcomeError
+}
 
-	// Create transaction
-	tx := s.db.Begin()
-	defer func() {
-		if r := recover(); r != nil {
-			tx.Rollback()
+// moveFunds loads the account aggregate, lets command record a deposit or
+// charge against it, and persists, projects and audits the resulting event
+// in a single database transaction.
+func (s *accountService) moveFunds(ctx context.Context, accountID uuid.UUID, action, reason string, command func(a *AccountAggregate, transactionID uuid.UUID, ref string) error) (*models.Transaction, error) {
+	var transaction *models.Transaction
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		// Rebuild the account from its events
+		aggregate, err := loadAccountAggregate(ctx, tx, s.events, accountID)
+		if err != nil {
+			return err
 		}
-	}()
+		balanceBefore := aggregate.Balance
 
-	var existingTransaction models.Transaction
+		// Generate a unique Ref for each transaction
+		transactionID := uuid.New()
+		ref := newTransactionRef()
 
-	// Generate a unique Ref for each transaction
-	ref := fmt.Sprintf("TXN-%s-%d", uuid.New().String(), time.Now().UnixNano())
+		// Check if a transaction with the same Ref already exists
+		if _, err := tx.Transactions().GetByRef(ctx, ref); err == nil {
+			return ErrDuplicateTransaction
+		}
+
+		if err := command(aggregate, transactionID, ref); err != nil {
+			return err
+		}
 
-	// Check if a transaction with the same Ref already exists
-	if err := s.db.Where("ref = ?", ref).First(&existingTransaction).Error; err == nil {
-		return nil, errors.New("duplicate transaction detected")
+		// Append the event and update the accounts and transactions tables
+		if _, err := saveAccountAggregate(ctx, tx, s.events, s.projection, aggregate); err != nil {
+			return err
+		}
+		if transaction, err = loadTransaction(ctx, tx, transactionID); err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, tx, s.actor, AuditEvent{
+			Action:     action,
+			EntityType: "account",
+			EntityID:   accountID.String(),
+			Before:     accountAuditState{Balance: balanceBefore},
+			After:      accountAuditState{Balance: aggregate.Balance, Transaction: ref, TransactionStatus: transaction.Status, Reason: reason},
+		})
+	})
+	if err != nil {
+		return nil, err
 	}
+	return transaction, nil
+}
 
-	transaction := &models.Transaction{
-		TransactionType: models.Charge,
-		Amount:          math.Round(amount*100) / 100,
-		Ref:             ref,
-		AccountID:       accountID,
+// newTransactionRef generates the unique ref of a new transaction.
+func newTransactionRef() string {
+	return fmt.Sprintf("TXN-%s-%d", uuid.New().String(), time.Now().UnixNano())
+}
+
+// SettleTransaction posts a pending transaction. Top-ups funded through the
+// payment provider are refused: they are settled when their payment
+// succeeds. So are the holds of withdrawals, which the bank's confirmation
+// settles.
+func (s *accountService) SettleTransaction(ctx context.Context, transactionID uuid.UUID) (_ *models.Transaction, err error) {
+	ctx, span, cancel := s.startSpan(ctx, "SettleTransaction", uuid.Nil, writeTimeout)
+	defer cancel()
+	defer endSpan(ctx, span, &err)
+
+	if !s.settlesPayments {
+		payment, err := s.store.Payments().GetByTransactionID(ctx, transactionID)
+		if err == nil {
+			return nil, fmt.Errorf("%w: the top-up is settled when its %s payment succeeds", ErrInvalidTransition, payment.Provider)
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return nil, err
+		}
 	}
+	if err := s.checkWithdrawalHold(ctx, transactionID); err != nil {
+		return nil, err
+	}
+	return s.changeTransaction(ctx, transactionID, AuditTransactionPosted, "", func(a *AccountAggregate, t *models.Transaction) (uuid.UUID, error) {
+		return t.ID, a.Post(t.ID)
+	})
+}
 
-	// Deduct balance from the account
-	account.Balance -= amount
-	account.Balance = math.Round(account.Balance*100) / 100
+// FailTransaction fails a pending transaction. The holds of withdrawals are
+// refused: they are settled by the withdrawal's payout, return or expiry.
+func (s *accountService) FailTransaction(ctx context.Context, transactionID uuid.UUID, reason string) (_ *models.Transaction, err error) {
+	ctx, span, cancel := s.startSpan(ctx, "FailTransaction", uuid.Nil, writeTimeout)
+	defer cancel()
+	defer endSpan(ctx, span, &err)
 
-	// Save the transaction and update the account
-	if err := tx.Create(transaction).Error; err != nil {
-		tx.Rollback()
+	if err := s.checkWithdrawalHold(ctx, transactionID); err != nil {
 		return nil, err
 	}
+	return s.changeTransaction(ctx, transactionID, AuditTransactionFailed, reason, func(a *AccountAggregate, t *models.Transaction) (uuid.UUID, error) {
+		return t.ID, a.Fail(t.ID, reason)
+	})
+}
+
+// checkWithdrawalHold refuses to change the hold of a withdrawal, unless s
+// settles withdrawals: failing the hold of a withdrawal in a payout file
+// would give back funds the bank still pays out.
+func (s *accountService) checkWithdrawalHold(ctx context.Context, transactionID uuid.UUID) error {
+	if s.settlesWithdrawals {
+		return nil
+	}
+	withdrawal, err := s.store.Withdrawals().GetByTransactionID(ctx, transactionID)
+	switch {
+	case err == nil:
+		return fmt.Errorf("%w: the hold of a %s withdrawal is settled by its payout", ErrInvalidTransition, withdrawal.Status)
+	case !errors.Is(err, repository.ErrNotFound):
+		return err
+	}
+	return nil
+}
+
+// ReverseTransaction undoes a posted transaction with one of the opposite
+// type, which it returns.
+func (s *accountService) ReverseTransaction(ctx context.Context, transactionID uuid.UUID, reason string) (_ *models.Transaction, err error) {
+	ctx, span, cancel := s.startSpan(ctx, "ReverseTransaction", uuid.Nil, writeTimeout)
+	defer cancel()
+	defer endSpan(ctx, span, &err)
+
+	return s.changeTransaction(ctx, transactionID, AuditTransactionReversed, reason, func(a *AccountAggregate, t *models.Transaction) (uuid.UUID, error) {
+		reversalID := uuid.New()
+		return reversalID, a.Reverse(t.ID, reversalID, newTransactionRef(), reason)
+	})
+}
 
-	if err := tx.Save(account).Error; err != nil {
-		tx.Rollback()
+// changeTransaction loads the aggregate of a transaction's account, lets
+// command settle or reverse the transaction, and persists, projects and
+// audits the result in a single database transaction. It returns the
+// transaction whose ID command returns.
+func (s *accountService) changeTransaction(ctx context.Context, transactionID uuid.UUID, action, reason string, command func(a *AccountAggregate, t *models.Transaction) (uuid.UUID, error)) (*models.Transaction, error) {
+	var transaction *models.Transaction
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		original, err := tx.Transactions().GetByID(ctx, transactionID)
+		if err != nil {
+			return notFound(err, ErrTransactionNotFound)
+		}
+		aggregate, err := loadAccountAggregate(ctx, tx, s.events, original.AccountID)
+		if err != nil {
+			return err
+		}
+		before := accountAuditState{Balance: aggregate.Balance, Transaction: original.Ref, TransactionStatus: original.Status}
+
+		resultID, err := command(aggregate, original)
+		if err != nil {
+			return err
+		}
+		if _, err := saveAccountAggregate(ctx, tx, s.events, s.projection, aggregate); err != nil {
+			return err
+		}
+		if transaction, err = loadTransaction(ctx, tx, resultID); err != nil {
+			return err
+		}
+
+		after := accountAuditState{Balance: aggregate.Balance, Transaction: original.Ref, TransactionStatus: transaction.Status, Reason: reason}
+		if transaction.ID != original.ID {
+			after.TransactionStatus = models.TransactionReversed
+			after.Reversal = transaction.Ref
+		}
+		return s.audit.Record(ctx, tx, s.actor, AuditEvent{
+			Action:     action,
+			EntityType: "account",
+			EntityID:   original.AccountID.String(),
+			Before:     before,
+			After:      after,
+		})
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	// Commit the transaction
-	tx.Commit()
+	slog.InfoContext(ctx, "transaction status changed", "transaction_id", transactionID, "account_id", transaction.AccountID, "action", action, "reason", reason, "actor", s.actor.Name)
+	return transaction, nil
+}
 
-	// Retrieve the user associated with the account
-	acc_u, u_err := NewUserService(s.db).GetUserByID(account.UserID)
+// expiryPageSize is the number of pending transactions ExpirePending loads
+// at a time.
+const expiryPageSize = 100
+
+// ExpirePending fails the transactions pending since before createdBefore,
+// each in its own database transaction. Transactions settled in the
+// meantime are skipped, and so are the holds of withdrawals already paid
+// out, which the bank's confirmation or return settles. It runs until done
+// or ctx is canceled, without a deadline of its own.
+func (s *accountService) ExpirePending(ctx context.Context, createdBefore time.Time) (int, error) {
+	expired := 0
+	skipped := map[uuid.UUID]bool{}
+	for {
+		pending, err := s.store.Transactions().ListPending(ctx, createdBefore, expiryPageSize+len(skipped))
+		if err != nil {
+			return expired, err
+		}
 
-	if u_err != nil {
-		tx.Rollback()
-		return nil, u_err
+		progressed := false
+		for _, t := range pending {
+			if skipped[t.ID] {
+				continue
+			}
+			progressed = true
+			err := s.expire(ctx, t.ID)
+			switch {
+			case err == nil:
+				expired++
+			case errors.Is(err, ErrInvalidTransition), errors.Is(err, ErrConcurrentModification):
+				skipped[t.ID] = true
+			default:
+				return expired, err
+			}
+		}
+		if !progressed {
+			return expired, nil
+		}
 	}
+}
 
-	// set the relationship
+// expire fails a pending transaction as expired unless it holds the amount
+// of a withdrawal that has been paid out.
+func (s *accountService) expire(ctx context.Context, transactionID uuid.UUID) error {
+	return s.store.Transaction(ctx, func(tx repository.Store) error {
+		withdrawal, err := tx.Withdrawals().GetByTransactionID(ctx, transactionID)
+		switch {
+		case err == nil && withdrawal.Status != models.WithdrawalRequested:
+			return fmt.Errorf("%w: the hold of a %s withdrawal does not expire", ErrInvalidTransition, withdrawal.Status)
+		case err != nil && !errors.Is(err, repository.ErrNotFound):
+			return err
+		}
+		clone := *s
+		clone.store = tx
+		clone.settlesWithdrawals = true
+		_, err = clone.FailTransaction(ctx, transactionID, "expired")
+		return err
+	})
+}
+
+// loadTransaction returns a transaction with its account and the account's
+// user.
+func loadTransaction(ctx context.Context, tx repository.Store, transactionID uuid.UUID) (*models.Transaction, error) {
+	transaction, err := tx.Transactions().GetByID(ctx, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	account, err := tx.Accounts().GetByID(ctx, transaction.AccountID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := tx.Users().GetByID(ctx, account.UserID)
+	if err != nil {
+		return nil, err
+	}
+	account.User = *user
 	transaction.Account = *account
-	transaction.Account.User = *acc_u
 	return transaction, nil
 }
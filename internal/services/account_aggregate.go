@@ -0,0 +1,565 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"wallet/internal/models"
+	"wallet/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// transactionTransitions lists the statuses each transaction status can
+// change to.
+var transactionTransitions = map[models.TransactionStatus][]models.TransactionStatus{
+	models.TransactionPending: {models.TransactionPosted, models.TransactionFailed},
+	models.TransactionPosted:  {models.TransactionReversed},
+}
+
+// AccountAggregate is an account's state rebuilt from its event stream.
+// Commands validate against that state and record new events, which are
+// only persisted by saving the aggregate.
+type AccountAggregate struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	// Balance is the posted balance and Available what can be spent, the
+	// posted balance less pending charges.
+	Balance   float64
+	Available float64
+	// Version is the stream version the aggregate was loaded at.
+	Version int
+
+	opened       bool
+	frozen       bool
+	transactions map[uuid.UUID]*aggregateTransaction
+	changes      []any
+}
+
+// aggregateTransaction is what the aggregate knows of a transaction. The
+// amount of an adjustment is signed, as in the transactions table.
+type aggregateTransaction struct {
+	typ    models.TransactionType
+	amount float64
+	status models.TransactionStatus
+}
+
+// signed returns the change the transaction makes to the balance once
+// posted.
+func (t *aggregateTransaction) signed() float64 {
+	if t.typ == models.Charge {
+		return -t.amount
+	}
+	return t.amount
+}
+
+// NewAccountAggregate replays a stream's events into an aggregate.
+func NewAccountAggregate(id uuid.UUID, events []models.Event) (*AccountAggregate, error) {
+	a := &AccountAggregate{ID: id, transactions: map[uuid.UUID]*aggregateTransaction{}}
+	for _, e := range events {
+		payload, err := decodeEvent(e)
+		if err != nil {
+			return nil, err
+		}
+		a.apply(payload)
+		a.Version = e.Version
+	}
+	return a, nil
+}
+
+// OpenAccount starts a new account stream for the given user, with the given
+// account number and the virtual IBAN its bank transfers are sent to.
+func OpenAccount(id, userID uuid.UUID, number, virtualIBAN string) *AccountAggregate {
+	a := &AccountAggregate{ID: id, transactions: map[uuid.UUID]*aggregateTransaction{}}
+	a.record(AccountOpened{UserID: userID, Number: number, VirtualIBAN: virtualIBAN})
+	return a
+}
+
+// Deposit credits the account. A pending deposit only credits it once it is
+// posted.
+func (a *AccountAggregate) Deposit(transactionID uuid.UUID, ref string, amount float64, pending bool, details models.TransactionDetails) error {
+	if !a.opened {
+		return ErrAccountNotOpen
+	}
+	if a.frozen {
+		return ErrAccountFrozen
+	}
+	if amount <= 0 {
+		return ErrInvalidAmount
+	}
+	a.record(FundsDeposited{TransactionID: transactionID, Ref: ref, Amount: math.Round(amount*100) / 100, Pending: pending, TransactionDetails: details})
+	return nil
+}
+
+// Charge debits the account, refusing to take the available balance below
+// zero. A pending charge holds the amount until it is posted or fails.
+func (a *AccountAggregate) Charge(transactionID uuid.UUID, ref string, amount float64, pending bool, details models.TransactionDetails) error {
+	if !a.opened {
+		return ErrAccountNotOpen
+	}
+	if a.frozen {
+		return ErrAccountFrozen
+	}
+	if amount <= 0 {
+		return ErrInvalidAmount
+	}
+	if a.Available < amount {
+		return ErrInsufficientFunds
+	}
+	a.record(FundsCharged{TransactionID: transactionID, Ref: ref, Amount: math.Round(amount*100) / 100, Pending: pending, TransactionDetails: details})
+	return nil
+}
+
+// Adjust credits a positive or debits a negative amount on behalf of an
+// operator. Adjustments are allowed on frozen accounts but still cannot take
+// the balance below zero.
+func (a *AccountAggregate) Adjust(transactionID uuid.UUID, ref string, amount float64, reason string) error {
+	if !a.opened {
+		return ErrAccountNotOpen
+	}
+	if reason == "" {
+		return ErrReasonRequired
+	}
+	amount = math.Round(amount*100) / 100
+	if amount == 0 {
+		return ErrZeroAdjustment
+	}
+	if amount < 0 && a.Available < -amount {
+		return ErrInsufficientFunds
+	}
+	a.record(FundsAdjusted{TransactionID: transactionID, Ref: ref, Amount: amount, Reason: reason})
+	return nil
+}
+
+// Post settles a pending transaction. Settling is allowed on frozen
+// accounts, as the funds have already moved.
+func (a *AccountAggregate) Post(transactionID uuid.UUID) error {
+	if _, err := a.transition(transactionID, models.TransactionPosted); err != nil {
+		return err
+	}
+	a.record(TransactionPosted{TransactionID: transactionID})
+	return nil
+}
+
+// Fail settles a pending transaction without moving funds.
+func (a *AccountAggregate) Fail(transactionID uuid.UUID, reason string) error {
+	if _, err := a.transition(transactionID, models.TransactionFailed); err != nil {
+		return err
+	}
+	a.record(TransactionFailed{TransactionID: transactionID, Reason: reason})
+	return nil
+}
+
+// Reverse undoes a posted transaction with a transaction of the opposite
+// type, or an adjustment with one of the opposite sign. Reversing a credit
+// cannot take the available balance below zero.
+func (a *AccountAggregate) Reverse(transactionID, reversalID uuid.UUID, ref, reason string) error {
+	if reason == "" {
+		return ErrReasonRequired
+	}
+	t, err := a.transition(transactionID, models.TransactionReversed)
+	if err != nil {
+		return err
+	}
+	if credit := t.signed(); credit > 0 && a.Available < credit {
+		return ErrInsufficientFunds
+	}
+	a.record(TransactionReversed{TransactionID: transactionID, ReversalID: reversalID, Ref: ref, Reason: reason})
+	return nil
+}
+
+// transition returns a transaction of the account after checking that it
+// can change to the given status.
+func (a *AccountAggregate) transition(transactionID uuid.UUID, to models.TransactionStatus) (*aggregateTransaction, error) {
+	if !a.opened {
+		return nil, ErrAccountNotOpen
+	}
+	t, ok := a.transactions[transactionID]
+	if !ok {
+		return nil, ErrTransactionNotFound
+	}
+	if !slices.Contains(transactionTransitions[t.status], to) {
+		return nil, fmt.Errorf("%w: a %s transaction cannot become %s", ErrInvalidTransition, t.status, to)
+	}
+	return t, nil
+}
+
+// Freeze blocks top-ups and charges until the account is unfrozen.
+func (a *AccountAggregate) Freeze(reason string) error {
+	if !a.opened {
+		return ErrAccountNotOpen
+	}
+	if a.frozen {
+		return ErrAccountAlreadyFrozen
+	}
+	a.record(AccountFrozen{Reason: reason})
+	return nil
+}
+
+// Unfreeze lifts a freeze.
+func (a *AccountAggregate) Unfreeze(reason string) error {
+	if !a.frozen {
+		return ErrAccountNotFrozen
+	}
+	a.record(AccountUnfrozen{Reason: reason})
+	return nil
+}
+
+// Frozen reports whether the account is frozen.
+func (a *AccountAggregate) Frozen() bool {
+	return a.frozen
+}
+
+// Changes returns the events recorded since the aggregate was loaded.
+func (a *AccountAggregate) Changes() []any {
+	return a.changes
+}
+
+func (a *AccountAggregate) record(payload any) {
+	a.apply(payload)
+	a.changes = append(a.changes, payload)
+}
+
+func (a *AccountAggregate) apply(payload any) {
+	switch e := payload.(type) {
+	case AccountOpened:
+		a.opened = true
+		a.UserID = e.UserID
+	case FundsDeposited:
+		t := &aggregateTransaction{typ: models.TopUp, amount: e.Amount, status: models.TransactionPending}
+		a.transactions[e.TransactionID] = t
+		if !e.Pending {
+			a.post(t)
+		}
+	case FundsCharged:
+		t := &aggregateTransaction{typ: models.Charge, amount: e.Amount, status: models.TransactionPending}
+		a.transactions[e.TransactionID] = t
+		// The hold on the available balance is taken straight away
+		a.Available = math.Round((a.Available-e.Amount)*100) / 100
+		if !e.Pending {
+			a.post(t)
+		}
+	case FundsAdjusted:
+		t := &aggregateTransaction{typ: models.AdjustmentTransaction, amount: e.Amount, status: models.TransactionPending}
+		a.transactions[e.TransactionID] = t
+		a.post(t)
+	case TransactionPosted:
+		a.post(a.transactions[e.TransactionID])
+	case TransactionFailed:
+		t := a.transactions[e.TransactionID]
+		t.status = models.TransactionFailed
+		if t.typ == models.Charge {
+			a.Available = math.Round((a.Available+t.amount)*100) / 100
+		}
+	case TransactionReversed:
+		t := a.transactions[e.TransactionID]
+		t.status = models.TransactionReversed
+		typ, amount := reversalOf(t.typ, t.amount)
+		a.transactions[e.ReversalID] = &aggregateTransaction{typ: typ, amount: amount, status: models.TransactionPosted}
+		delta := -t.signed()
+		a.Balance = math.Round((a.Balance+delta)*100) / 100
+		a.Available = math.Round((a.Available+delta)*100) / 100
+	case AccountFrozen:
+		a.frozen = true
+	case AccountUnfrozen:
+		a.frozen = false
+	}
+}
+
+// post moves a pending transaction to the posted balance. Charges already
+// hold their amount against the available balance.
+func (a *AccountAggregate) post(t *aggregateTransaction) {
+	t.status = models.TransactionPosted
+	a.Balance = math.Round((a.Balance+t.signed())*100) / 100
+	if t.typ != models.Charge {
+		a.Available = math.Round((a.Available+t.signed())*100) / 100
+	}
+}
+
+// reversalOf returns the type and amount of the transaction that reverses
+// one of the given type and amount: a charge reverses a top-up and the other
+// way round, and an adjustment of the opposite sign reverses an adjustment.
+func reversalOf(typ models.TransactionType, amount float64) (models.TransactionType, float64) {
+	switch typ {
+	case models.TopUp:
+		return models.Charge, amount
+	case models.Charge:
+		return models.TopUp, amount
+	}
+	return typ, -amount
+}
+
+type AccountProjection interface {
+	// Project applies a stored event to the accounts and transactions tables.
+	Project(ctx context.Context, tx repository.Store, e models.Event) error
+}
+
+type accountProjection struct{}
+
+func NewAccountProjection() AccountProjection {
+	return &accountProjection{}
+}
+
+func (p *accountProjection) Project(ctx context.Context, tx repository.Store, e models.Event) error {
+	payload, err := decodeEvent(e)
+	if err != nil {
+		return err
+	}
+
+	switch ev := payload.(type) {
+	case AccountOpened:
+		// Replaying the stream keeps the existing row
+		account, err := tx.Accounts().GetByID(ctx, e.StreamID)
+		if errors.Is(err, repository.ErrNotFound) {
+			account := &models.Account{
+				ID:        e.StreamID,
+				UserID:    ev.UserID,
+				Version:   e.Version,
+				CreatedAt: e.OccurredAt,
+			}
+			if ev.Number != "" {
+				account.Number = &ev.Number
+			}
+			if ev.VirtualIBAN != "" {
+				account.VirtualIBAN = &ev.VirtualIBAN
+			}
+			return tx.Accounts().Create(ctx, account)
+		}
+		if err != nil {
+			return err
+		}
+		account.Version = e.Version
+		return tx.Accounts().Update(ctx, account)
+	case FundsDeposited:
+		return p.projectFunds(ctx, tx, e, models.TopUp, ev.TransactionID, ev.Ref, ev.Amount, ev.Pending, ev.TransactionDetails)
+	case FundsCharged:
+		return p.projectFunds(ctx, tx, e, models.Charge, ev.TransactionID, ev.Ref, ev.Amount, ev.Pending, ev.TransactionDetails)
+	case FundsAdjusted:
+		return p.projectFunds(ctx, tx, e, models.AdjustmentTransaction, ev.TransactionID, ev.Ref, ev.Amount, false, models.TransactionDetails{})
+	case TransactionPosted:
+		return p.projectSettlement(ctx, tx, e, ev.TransactionID, models.TransactionPosted)
+	case TransactionFailed:
+		return p.projectSettlement(ctx, tx, e, ev.TransactionID, models.TransactionFailed)
+	case TransactionReversed:
+		return p.projectReversal(ctx, tx, e, ev)
+	case AccountFrozen:
+		return p.projectStatus(ctx, tx, e, models.AccountFrozen)
+	case AccountUnfrozen:
+		return p.projectStatus(ctx, tx, e, models.AccountActive)
+	}
+	return nil
+}
+
+func (p *accountProjection) projectStatus(ctx context.Context, tx repository.Store, e models.Event, status models.AccountStatus) error {
+	account, err := tx.Accounts().GetByID(ctx, e.StreamID)
+	if err != nil {
+		return err
+	}
+	account.Status = status
+	account.Version = e.Version
+	return tx.Accounts().Update(ctx, account)
+}
+
+// projectBalances moves the posted and available balances of the account by
+// the given deltas.
+func (p *accountProjection) projectBalances(ctx context.Context, tx repository.Store, e models.Event, posted, available float64) error {
+	account, err := tx.Accounts().GetByID(ctx, e.StreamID)
+	if err != nil {
+		return err
+	}
+	account.Balance = math.Round((account.Balance+posted)*100) / 100
+	account.AvailableBalance = math.Round((account.AvailableBalance+available)*100) / 100
+	account.Version = e.Version
+	return tx.Accounts().Update(ctx, account)
+}
+
+// projectFunds moves the account balances by a deposit, charge or
+// adjustment and records the matching transaction row, unless it already
+// exists. Pending charges only hold their amount against the available
+// balance, and pending deposits move neither balance.
+func (p *accountProjection) projectFunds(ctx context.Context, tx repository.Store, e models.Event, typ models.TransactionType, transactionID uuid.UUID, ref string, amount float64, pending bool, details models.TransactionDetails) error {
+	transaction := &models.Transaction{
+		ID:                 transactionID,
+		TransactionType:    typ,
+		Amount:             amount,
+		Ref:                ref,
+		Status:             models.TransactionPosted,
+		PostedAt:           &e.OccurredAt,
+		AccountID:          e.StreamID,
+		CreatedAt:          e.OccurredAt,
+		TransactionDetails: details,
+	}
+	posted, available := transaction.SignedAmount(), transaction.SignedAmount()
+	if pending {
+		transaction.Status = models.TransactionPending
+		transaction.PostedAt = nil
+		posted = 0
+		if typ != models.Charge {
+			available = 0
+		}
+	}
+	if err := p.projectBalances(ctx, tx, e, posted, available); err != nil {
+		return err
+	}
+
+	if _, err := tx.Transactions().GetByID(ctx, transactionID); !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	return tx.Transactions().Create(ctx, transaction)
+}
+
+// projectSettlement posts or fails a pending transaction, moving the account
+// balances accordingly.
+func (p *accountProjection) projectSettlement(ctx context.Context, tx repository.Store, e models.Event, transactionID uuid.UUID, status models.TransactionStatus) error {
+	transaction, err := tx.Transactions().GetByID(ctx, transactionID)
+	if err != nil {
+		return err
+	}
+
+	var posted, available float64
+	switch {
+	case status == models.TransactionFailed && transaction.TransactionType == models.Charge:
+		// Release the hold
+		available = transaction.Amount
+	case status == models.TransactionPosted && transaction.TransactionType == models.Charge:
+		posted = -transaction.Amount
+	case status == models.TransactionPosted:
+		posted, available = transaction.Amount, transaction.Amount
+	}
+	if err := p.projectBalances(ctx, tx, e, posted, available); err != nil {
+		return err
+	}
+
+	transaction.Status = status
+	if status == models.TransactionPosted {
+		transaction.PostedAt = &e.OccurredAt
+	}
+	return tx.Transactions().Update(ctx, transaction)
+}
+
+// projectReversal marks a transaction reversed and records its reversal, a
+// posted transaction of the opposite type or sign, unless it already exists.
+func (p *accountProjection) projectReversal(ctx context.Context, tx repository.Store, e models.Event, ev TransactionReversed) error {
+	original, err := tx.Transactions().GetByID(ctx, ev.TransactionID)
+	if err != nil {
+		return err
+	}
+	original.Status = models.TransactionReversed
+	if err := tx.Transactions().Update(ctx, original); err != nil {
+		return err
+	}
+
+	typ, amount := reversalOf(original.TransactionType, original.Amount)
+	reversal := &models.Transaction{
+		ID:              ev.ReversalID,
+		TransactionType: typ,
+		Amount:          amount,
+		Ref:             ev.Ref,
+		Status:          models.TransactionPosted,
+		PostedAt:        &e.OccurredAt,
+		ReversalOfID:    &original.ID,
+		AccountID:       e.StreamID,
+		CreatedAt:       e.OccurredAt,
+	}
+	delta := reversal.SignedAmount()
+	if err := p.projectBalances(ctx, tx, e, delta, delta); err != nil {
+		return err
+	}
+
+	if _, err := tx.Transactions().GetByID(ctx, reversal.ID); !errors.Is(err, repository.ErrNotFound) {
+		return err
+	}
+	return tx.Transactions().Create(ctx, reversal)
+}
+
+// saveAccountAggregate appends the aggregate's new events to its stream and
+// projects them, all inside tx.
+func saveAccountAggregate(ctx context.Context, tx repository.Store, store EventStore, projection AccountProjection, a *AccountAggregate) ([]models.Event, error) {
+	events, err := store.Append(ctx, tx, a.ID, a.Version, a.changes...)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range events {
+		if err := projection.Project(ctx, tx, e); err != nil {
+			return nil, err
+		}
+	}
+
+	a.Version += len(events)
+	a.changes = nil
+	return events, nil
+}
+
+// loadAccountAggregate loads an account's aggregate inside tx. Accounts
+// created before event sourcing get their stream backfilled from the
+// transactions table first.
+func loadAccountAggregate(ctx context.Context, tx repository.Store, store EventStore, accountID uuid.UUID) (*AccountAggregate, error) {
+	events, err := store.Load(ctx, tx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(events) == 0 {
+		account, err := tx.Accounts().GetByID(ctx, accountID)
+		if err != nil {
+			return nil, notFound(err, ErrAccountNotFound)
+		}
+		if events, err = backfillAccountStream(ctx, tx, account); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewAccountAggregate(accountID, events)
+}
+
+// backfillAccountStream writes the event stream of an account that predates
+// event sourcing, from its row and its transactions. The account row already
+// reflects these events, so only its version is brought up to date.
+func backfillAccountStream(ctx context.Context, tx repository.Store, account *models.Account) ([]models.Event, error) {
+	transactions, err := tx.Transactions().ListByAccountID(ctx, account.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	opened := AccountOpened{UserID: account.UserID}
+	if account.Number != nil {
+		opened.Number = *account.Number
+	}
+	if account.VirtualIBAN != nil {
+		opened.VirtualIBAN = *account.VirtualIBAN
+	}
+	pending := []pendingEvent{{payload: opened, occurredAt: account.CreatedAt}}
+	for _, t := range transactions {
+		switch t.TransactionType {
+		case models.TopUp:
+			pending = append(pending, pendingEvent{
+				payload:    FundsDeposited{TransactionID: t.ID, Ref: t.Ref, Amount: t.Amount, TransactionDetails: t.TransactionDetails},
+				occurredAt: t.CreatedAt,
+			})
+		case models.Charge:
+			pending = append(pending, pendingEvent{
+				payload:    FundsCharged{TransactionID: t.ID, Ref: t.Ref, Amount: t.Amount, TransactionDetails: t.TransactionDetails},
+				occurredAt: t.CreatedAt,
+			})
+		case models.AdjustmentTransaction:
+			pending = append(pending, pendingEvent{
+				payload:    FundsAdjusted{TransactionID: t.ID, Ref: t.Ref, Amount: t.Amount},
+				occurredAt: t.CreatedAt,
+			})
+		}
+	}
+
+	// Keep the original timestamps rather than the time of the backfill
+	events, err := appendEvents(ctx, tx, account.ID, 0, pending)
+	if err != nil {
+		return nil, err
+	}
+
+	account.Version = len(events)
+	if err := tx.Accounts().Update(ctx, account); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
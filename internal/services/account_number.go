@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"wallet/internal/banking"
+	"wallet/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// Account numbers are drawn from the numbers without a leading zero, which
+// spreadsheets and phone keypads tend to lose.
+var (
+	minAccountNumber    = big.NewInt(100_000_000)
+	accountNumbersRange = big.NewInt(900_000_000)
+)
+
+// maxAccountNumberDraws bounds the numbers drawn for a new account before
+// giving up.
+const maxAccountNumberDraws = 10
+
+// accountNumberBatchSize is how many accounts AssignAccountNumbers loads at
+// a time.
+const accountNumberBatchSize = 500
+
+// newAccountNumber draws an account number that no account in tx has.
+func newAccountNumber(ctx context.Context, tx repository.Store) (string, error) {
+	for range maxAccountNumberDraws {
+		n, err := rand.Int(rand.Reader, accountNumbersRange)
+		if err != nil {
+			return "", err
+		}
+		number, err := banking.NewAccountNumber(n.Add(n, minAccountNumber).String())
+		if err != nil {
+			return "", err
+		}
+		_, err = tx.Accounts().GetByNumber(ctx, number)
+		if errors.Is(err, repository.ErrNotFound) {
+			return number, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", errors.New("no unused account number found")
+}
+
+// ResolveAccountID returns the ID of the account identified by either its ID
+// or its account number.
+func (s *accountService) ResolveAccountID(ctx context.Context, idOrNumber string) (_ uuid.UUID, err error) {
+	if accountID, err := uuid.Parse(idOrNumber); err == nil {
+		return accountID, nil
+	}
+	// Mistyped numbers never reach the database
+	number, err := banking.ValidateAccountNumber(idOrNumber)
+	if err != nil {
+		return uuid.Nil, ErrInvalidAccountID
+	}
+
+	ctx, span, cancel := s.startSpan(ctx, "ResolveAccountID", uuid.Nil, readTimeout)
+	defer cancel()
+	defer endSpan(ctx, span, &err)
+
+	account, err := s.store.Accounts().GetByNumber(ctx, number)
+	if err != nil {
+		return uuid.Nil, notFound(err, ErrAccountNotFound)
+	}
+	return account.ID, nil
+}
+
+// AssignAccountNumbers issues account numbers to the accounts opened before
+// they existed, recording each in the audit log.
+func (s *accountService) AssignAccountNumbers(ctx context.Context) (int, error) {
+	assigned := 0
+	for {
+		accounts, err := s.store.Accounts().ListWithoutNumber(ctx, accountNumberBatchSize)
+		if err != nil || len(accounts) == 0 {
+			return assigned, err
+		}
+		for _, account := range accounts {
+			err := s.store.Transaction(ctx, func(tx repository.Store) error {
+				number, err := newAccountNumber(ctx, tx)
+				if err != nil {
+					return err
+				}
+				if err := tx.Accounts().SetNumber(ctx, account.ID, number); err != nil {
+					return err
+				}
+				// Audit the account as it is now, not as it was listed
+				current, err := tx.Accounts().GetByID(ctx, account.ID)
+				if err != nil {
+					return err
+				}
+				return s.audit.Record(ctx, tx, s.actor, AuditEvent{
+					Action:     AuditAccountNumberAssigned,
+					EntityType: "account",
+					EntityID:   account.ID.String(),
+					After:      accountAuditState{Balance: current.Balance, Status: current.Status, Number: number},
+				})
+			})
+			if err != nil {
+				return assigned, err
+			}
+			assigned++
+		}
+	}
+}
@@ -0,0 +1,434 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"wallet/internal/metrics"
+	"wallet/internal/models"
+	"wallet/internal/repository"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newTestAccount(t *testing.T, svc AccountService) *models.Account {
+	t.Helper()
+	account, err := svc.CreateAccountWithUser(context.Background(), "jane@example.com", "Jane", "Doe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return account
+}
+
+func TestTopUpAndCharge(t *testing.T) {
+	ctx := context.Background()
+	svc := NewAccountServiceWithStore(repository.NewMemoryStore())
+	account := newTestAccount(t, svc)
+
+	if _, err := svc.TopUp(ctx, account.ID, 20, models.TransactionDetails{}); err != nil {
+		t.Fatal(err)
+	}
+	transaction, err := svc.Charge(ctx, account.ID, 7.5, models.TransactionDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transaction.TransactionType != models.Charge || transaction.Amount != 7.5 {
+		t.Errorf("transaction: got %s of %v want charge of 7.5", transaction.TransactionType, transaction.Amount)
+	}
+	if transaction.Account.Balance != 12.5 || transaction.Account.User.Email != "jane@example.com" {
+		t.Errorf("transaction account: got balance %v of %q want 12.5 of jane@example.com", transaction.Account.Balance, transaction.Account.User.Email)
+	}
+
+	if _, err := svc.Charge(ctx, account.ID, 100, models.TransactionDetails{}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("overdraft: got error %v want %v", err, ErrInsufficientFunds)
+	}
+	got, err := svc.GetAccountByID(ctx, account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Balance != 12.5 {
+		t.Errorf("balance: got %v want %v", got.Balance, 12.5)
+	}
+}
+
+func TestCreateAccountWithUserRejectsDuplicates(t *testing.T) {
+	svc := NewAccountServiceWithStore(repository.NewMemoryStore())
+	newTestAccount(t, svc)
+
+	_, err := svc.CreateAccountWithUser(context.Background(), "jane@example.com", "Jane", "Doe")
+	if !errors.Is(err, ErrDuplicateUser) {
+		t.Errorf("got error %v want %v", err, ErrDuplicateUser)
+	}
+}
+
+func TestFrozenAccountRejectsFunds(t *testing.T) {
+	ctx := context.Background()
+	svc := NewAccountServiceWithStore(repository.NewMemoryStore())
+	account := newTestAccount(t, svc)
+
+	frozen, err := svc.Freeze(ctx, account.ID, "chargeback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frozen.Status != models.AccountFrozen {
+		t.Errorf("status: got %v want %v", frozen.Status, models.AccountFrozen)
+	}
+	if _, err := svc.TopUp(ctx, account.ID, 10, models.TransactionDetails{}); !errors.Is(err, ErrAccountFrozen) {
+		t.Errorf("top-up of a frozen account: got error %v want %v", err, ErrAccountFrozen)
+	}
+	if _, err := svc.Adjust(ctx, account.ID, 10, "goodwill"); err != nil {
+		t.Errorf("adjustment of a frozen account: got error %v", err)
+	}
+}
+
+// fundsOperations returns the number of funds operations counted so far
+// with the given operation and outcome.
+func fundsOperations(t *testing.T, operation, outcome string) float64 {
+	t.Helper()
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "wallet_funds_operations_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["operation"] == operation && labels["outcome"] == outcome {
+				return metric.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+func TestFundsMetrics(t *testing.T) {
+	ctx := context.Background()
+	svc := NewAccountServiceWithStore(repository.NewMemoryStore())
+	account := newTestAccount(t, svc)
+
+	tests := []struct {
+		name      string
+		operation string
+		outcome   string
+		call      func() error
+	}{
+		{
+			name:      "top-up",
+			operation: metrics.OperationTopUp,
+			outcome:   metrics.OutcomeSuccess,
+			call: func() error {
+				_, err := svc.TopUp(ctx, account.ID, 10, models.TransactionDetails{})
+				return err
+			},
+		},
+		{
+			name:      "charge over the balance",
+			operation: metrics.OperationCharge,
+			outcome:   metrics.OutcomeInsufficientBalance,
+			call: func() error {
+				_, err := svc.Charge(ctx, account.ID, 20, models.TransactionDetails{})
+				return err
+			},
+		},
+		{
+			name:      "charge to an unknown account",
+			operation: metrics.OperationCharge,
+			outcome:   metrics.OutcomeAccountNotFound,
+			call: func() error {
+				_, err := svc.Charge(ctx, uuid.New(), 5, models.TransactionDetails{})
+				return err
+			},
+		},
+		{
+			name:      "top-up of a frozen account",
+			operation: metrics.OperationTopUp,
+			outcome:   metrics.OutcomeAccountFrozen,
+			call: func() error {
+				if _, err := svc.Freeze(ctx, account.ID, "investigation"); err != nil {
+					t.Fatal(err)
+				}
+				defer svc.Unfreeze(ctx, account.ID, "investigation closed")
+				_, err := svc.TopUp(ctx, account.ID, 10, models.TransactionDetails{})
+				return err
+			},
+		},
+		{
+			name:      "canceled charge",
+			operation: metrics.OperationCharge,
+			outcome:   metrics.OutcomeCanceled,
+			call: func() error {
+				canceled, cancel := context.WithCancel(ctx)
+				cancel()
+				_, err := svc.Charge(canceled, account.ID, 5, models.TransactionDetails{})
+				return err
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := fundsOperations(t, tt.operation, tt.outcome)
+			err := tt.call()
+			if (err == nil) != (tt.outcome == metrics.OutcomeSuccess) {
+				t.Errorf("got error %v for outcome %s", err, tt.outcome)
+			}
+			if got := fundsOperations(t, tt.operation, tt.outcome) - before; got != 1 {
+				t.Errorf("got %v %s operations counted as %s want 1", got, tt.operation, tt.outcome)
+			}
+		})
+	}
+}
+
+func TestPendingTransactions(t *testing.T) {
+	ctx := context.Background()
+	svc := NewAccountServiceWithStore(repository.NewMemoryStore())
+	account := newTestAccount(t, svc)
+	balances := func(wantBalance, wantAvailable float64) {
+		t.Helper()
+		got, err := svc.GetAccountByID(ctx, account.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Balance != wantBalance || got.AvailableBalance != wantAvailable {
+			t.Errorf("got balance %v with %v available want %v with %v available", got.Balance, got.AvailableBalance, wantBalance, wantAvailable)
+		}
+	}
+
+	topUp, err := svc.PendingTopUp(ctx, account.ID, 50, models.TransactionDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topUp.Status != models.TransactionPending || topUp.PostedAt != nil {
+		t.Errorf("pending top-up: got status %s posted at %v want pending and unposted", topUp.Status, topUp.PostedAt)
+	}
+	balances(0, 0)
+	if _, err := svc.SettleTransaction(ctx, topUp.ID); err != nil {
+		t.Fatal(err)
+	}
+	balances(50, 50)
+
+	hold, err := svc.PendingCharge(ctx, account.ID, 30, models.TransactionDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	balances(50, 20)
+	if _, err := svc.Charge(ctx, account.ID, 25, models.TransactionDetails{}); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("charge over the available balance: got error %v want %v", err, ErrInsufficientFunds)
+	}
+	failed, err := svc.FailTransaction(ctx, hold.ID, "declined")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed.Status != models.TransactionFailed {
+		t.Errorf("failed charge: got status %s want %s", failed.Status, models.TransactionFailed)
+	}
+	balances(50, 50)
+	if _, err := svc.SettleTransaction(ctx, hold.ID); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("settling a failed charge: got error %v want %v", err, ErrInvalidTransition)
+	}
+
+	reversal, err := svc.ReverseTransaction(ctx, topUp.ID, "chargeback")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reversal.TransactionType != models.Charge || reversal.ReversalOfID == nil || *reversal.ReversalOfID != topUp.ID {
+		t.Errorf("reversal: got %s reversing %v want a charge reversing %s", reversal.TransactionType, reversal.ReversalOfID, topUp.ID)
+	}
+	balances(0, 0)
+	if _, err := svc.ReverseTransaction(ctx, topUp.ID, "chargeback"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("reversing twice: got error %v want %v", err, ErrInvalidTransition)
+	}
+}
+
+func TestExpirePending(t *testing.T) {
+	ctx := context.Background()
+	svc := NewAccountServiceWithStore(repository.NewMemoryStore())
+	account := newTestAccount(t, svc)
+	if _, err := svc.TopUp(ctx, account.ID, 10, models.TransactionDetails{}); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if _, err := svc.PendingCharge(ctx, account.ID, 2, models.TransactionDetails{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n, err := svc.ExpirePending(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("expiring recent transactions: got %d, %v want 0", n, err)
+	}
+	if n, err := svc.ExpirePending(ctx, time.Now().Add(time.Second)); err != nil || n != 3 {
+		t.Errorf("got %d expired, %v want 3", n, err)
+	}
+	got, err := svc.GetAccountByID(ctx, account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.AvailableBalance != 10 {
+		t.Errorf("available balance: got %v want 10", got.AvailableBalance)
+	}
+}
+
+func TestConcurrentTopUps(t *testing.T) {
+	ctx := context.Background()
+	svc := NewAccountServiceWithStore(repository.NewMemoryStore())
+	account := newTestAccount(t, svc)
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := svc.TopUp(ctx, account.ID, 1.5, models.TransactionDetails{}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got, err := svc.GetAccountByID(ctx, account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Balance != 30 || got.Version != 21 {
+		t.Errorf("account: got balance %v at version %d want 30 at version 21", got.Balance, got.Version)
+	}
+}
+
+func TestListTransactionsPages(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	svc := NewAccountServiceWithStore(store)
+	account := newTestAccount(t, svc)
+	for range 5 {
+		if _, err := svc.TopUp(ctx, account.ID, 1, models.TransactionDetails{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	transactions := NewTransactionServiceWithStore(store)
+	seen := map[string]bool{}
+	cursor := ""
+	for pages := 1; ; pages++ {
+		page, err := transactions.ListTransactions(ctx, account.ID, cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, tr := range page.Transactions {
+			if seen[tr.Ref] {
+				t.Errorf("transaction %s listed twice", tr.Ref)
+			}
+			seen[tr.Ref] = true
+		}
+		if page.NextCursor == "" {
+			if pages != 3 {
+				t.Errorf("pages: got %d want 3", pages)
+			}
+			break
+		}
+		cursor = page.NextCursor
+	}
+	if len(seen) != 5 {
+		t.Errorf("transactions listed: got %d want 5", len(seen))
+	}
+}
+
+func TestTransactionDetails(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	svc := NewAccountServiceWithStore(store)
+	account := newTestAccount(t, svc)
+
+	topUp, err := svc.TopUp(ctx, account.ID, 50, models.TransactionDetails{Description: " Salary ", ExternalRef: "payroll-03", Metadata: []byte(`{ "employer": "Acme" }`)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topUp.Description != "Salary" || string(topUp.Metadata) != `{"employer":"Acme"}` {
+		t.Errorf("top-up details: got %q with metadata %s want Salary with compacted metadata", topUp.Description, topUp.Metadata)
+	}
+	if _, err := svc.Charge(ctx, account.ID, 12, models.TransactionDetails{Description: "Weekly groceries", MerchantName: "Corner Shop", MerchantCategoryCode: "5411"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Charge(ctx, account.ID, 3, models.TransactionDetails{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		details models.TransactionDetails
+		field   string
+	}{
+		{models.TransactionDetails{MerchantCategoryCode: "54A1"}, "merchant_category_code"},
+		{models.TransactionDetails{Metadata: []byte(`["not", "an", "object"]`)}, "metadata"},
+		{models.TransactionDetails{Metadata: []byte(`{"open":`)}, "metadata"},
+		{models.TransactionDetails{Description: strings.Repeat("x", MaxDescriptionLength+1)}, "description"},
+	} {
+		var invalid *ValidationError
+		if _, err := svc.Charge(ctx, account.ID, 1, test.details); !errors.As(err, &invalid) || invalid.Field != test.field {
+			t.Errorf("charge with %+v: got error %v want a %s validation error", test.details, err, test.field)
+		}
+	}
+
+	transactions := NewTransactionServiceWithStore(store)
+	for _, test := range []struct {
+		filter repository.TransactionFilter
+		want   int
+	}{
+		{repository.TransactionFilter{}, 3},
+		{repository.TransactionFilter{Query: "corner"}, 1},
+		{repository.TransactionFilter{Query: "acme"}, 1},
+		{repository.TransactionFilter{ExternalRef: "payroll-03"}, 1},
+		{repository.TransactionFilter{MerchantCategoryCode: "5411"}, 1},
+		{repository.TransactionFilter{Query: "groceries", MerchantCategoryCode: "5812"}, 0},
+	} {
+		page, err := transactions.SearchTransactions(ctx, account.ID, test.filter, "", 0)
+		if err != nil || len(page.Transactions) != test.want {
+			t.Errorf("search %+v: got %d transactions, %v want %d", test.filter, len(page.Transactions), err, test.want)
+		}
+	}
+}
+
+func TestResolveAccountID(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	svc := NewAccountServiceWithStore(store)
+	account := newTestAccount(t, svc)
+	if account.Number == nil {
+		t.Fatal("new account: got no account number")
+	}
+	number := *account.Number
+
+	for _, ref := range []string{account.ID.String(), number, number[:4] + " " + number[4:8] + " " + number[8:]} {
+		if got, err := svc.ResolveAccountID(ctx, ref); err != nil || got != account.ID {
+			t.Errorf("ResolveAccountID(%q): got %v, %v want %v", ref, got, err, account.ID)
+		}
+	}
+	// A mistyped digit fails the check digit
+	mistyped := number[:9] + string('0'+(number[9]-'0'+1)%10)
+	for _, ref := range []string{mistyped, "nope", ""} {
+		if _, err := svc.ResolveAccountID(ctx, ref); !errors.Is(err, ErrInvalidAccountID) {
+			t.Errorf("ResolveAccountID(%q): got error %v want %v", ref, err, ErrInvalidAccountID)
+		}
+	}
+	if _, err := svc.ResolveAccountID(ctx, "1234567897"); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("unknown account number: got error %v want %v", err, ErrAccountNotFound)
+	}
+
+	// Accounts opened before account numbers get one
+	legacy := &models.Account{ID: uuid.New(), UserID: account.UserID}
+	if err := store.Accounts().Create(ctx, legacy); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := svc.AssignAccountNumbers(ctx); err != nil || n != 1 {
+		t.Errorf("assigning account numbers: got %d, %v want 1", n, err)
+	}
+	if legacy, _ = store.Accounts().GetByID(ctx, legacy.ID); legacy.Number == nil || *legacy.Number == number {
+		t.Errorf("legacy account: got account number %v want a new one", legacy.Number)
+	}
+}
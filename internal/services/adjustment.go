@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"wallet/internal/models"
+	"wallet/internal/repository"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+)
+
+// DefaultAdjustmentApprovalThreshold is the largest adjustment, credit or
+// debit, that is posted without a second operator's approval unless
+// ADJUSTMENT_APPROVAL_THRESHOLD says otherwise.
+const DefaultAdjustmentApprovalThreshold = 100.0
+
+// AdjustmentApprovalThresholdFromEnv reads ADJUSTMENT_APPROVAL_THRESHOLD. An
+// invalid value makes every adjustment need approval.
+func AdjustmentApprovalThresholdFromEnv() float64 {
+	raw := os.Getenv("ADJUSTMENT_APPROVAL_THRESHOLD")
+	if raw == "" {
+		return DefaultAdjustmentApprovalThreshold
+	}
+	threshold, err := strconv.ParseFloat(raw, 64)
+	if err != nil || threshold < 0 || math.IsNaN(threshold) {
+		slog.Warn("invalid ADJUSTMENT_APPROVAL_THRESHOLD, every adjustment needs approval", "value", raw)
+		return 0
+	}
+	return threshold
+}
+
+// AdjustmentService lets finance operators correct balances by hand, e.g. to
+// credit goodwill or write a debt off. Adjustments above the approval
+// threshold follow the maker-checker principle: they stay pending until an
+// operator other than the one who made them approves them.
+type AdjustmentService interface {
+	// Request makes a manual credit (positive amount) or debit (negative
+	// amount) with a reason code and a note. Adjustments up to the approval
+	// threshold are posted straight away; larger ones are left pending.
+	Request(ctx context.Context, accountID uuid.UUID, amount float64, reason models.AdjustmentReason, note string) (*models.Adjustment, error)
+	GetAdjustment(ctx context.Context, id uuid.UUID) (*models.Adjustment, error)
+	// ListAdjustments returns the adjustments in the given status, oldest
+	// first.
+	ListAdjustments(ctx context.Context, status models.AdjustmentStatus) ([]models.Adjustment, error)
+	// Approve posts a pending adjustment. The operator who made it cannot
+	// approve it.
+	Approve(ctx context.Context, id uuid.UUID, note string) (*models.Adjustment, error)
+	// Reject turns a pending adjustment down with a note saying why. The
+	// operator who made it may reject it to withdraw it.
+	Reject(ctx context.Context, id uuid.UUID, note string) (*models.Adjustment, error)
+	// WithActor returns a copy of the service that attributes the changes
+	// it makes to the given actor in the audit log.
+	WithActor(actor Actor) AdjustmentService
+}
+
+type adjustmentService struct {
+	store     repository.Store
+	audit     AuditService
+	threshold float64
+	actor     Actor
+}
+
+// NewAdjustmentService returns an AdjustmentService that posts adjustments
+// up to threshold without approval.
+func NewAdjustmentService(db *gorm.DB, threshold float64) AdjustmentService {
+	return &adjustmentService{
+		store:     repository.NewGormStore(db),
+		audit:     NewAuditService(db),
+		threshold: threshold,
+		actor:     SystemActor,
+	}
+}
+
+// NewAdjustmentServiceWithStore returns an AdjustmentService that keeps
+// adjustments and accounts in store.
+func NewAdjustmentServiceWithStore(store repository.Store, threshold float64) AdjustmentService {
+	return &adjustmentService{
+		store:     store,
+		audit:     &auditService{key: auditKeyFromEnv()},
+		threshold: threshold,
+		actor:     SystemActor,
+	}
+}
+
+func (s *adjustmentService) WithActor(actor Actor) AdjustmentService {
+	clone := *s
+	clone.actor = actor
+	return &clone
+}
+
+// adjustmentAuditState is the audited view of an adjustment.
+type adjustmentAuditState struct {
+	AccountID     string                  `json:"account_id"`
+	Amount        float64                 `json:"amount"`
+	ReasonCode    models.AdjustmentReason `json:"reason_code"`
+	Note          string                  `json:"note"`
+	Status        models.AdjustmentStatus `json:"status"`
+	RequestedBy   string                  `json:"requested_by"`
+	ReviewedBy    string                  `json:"reviewed_by,omitempty"`
+	ReviewNote    string                  `json:"review_note,omitempty"`
+	TransactionID string                  `json:"transaction_id,omitempty"`
+}
+
+func adjustmentAudit(a *models.Adjustment) adjustmentAuditState {
+	state := adjustmentAuditState{
+		AccountID:   a.AccountID.String(),
+		Amount:      a.Amount,
+		ReasonCode:  a.ReasonCode,
+		Note:        a.Note,
+		Status:      a.Status,
+		RequestedBy: a.RequestedBy,
+		ReviewedBy:  a.ReviewedBy,
+		ReviewNote:  a.ReviewNote,
+	}
+	if a.TransactionID != nil {
+		state.TransactionID = a.TransactionID.String()
+	}
+	return state
+}
+
+func (s *adjustmentService) Request(ctx context.Context, accountID uuid.UUID, amount float64, reason models.AdjustmentReason, note string) (_ *models.Adjustment, err error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	ctx, span := accountTracer.Start(ctx, "AdjustmentService.Request")
+	defer endSpan(ctx, span, &err)
+	span.SetAttributes(attribute.String("account.id", accountID.String()))
+
+	adjustment := &models.Adjustment{
+		AccountID:   accountID,
+		Amount:      math.Round(amount*100) / 100,
+		ReasonCode:  reason,
+		Note:        strings.TrimSpace(note),
+		Status:      models.AdjustmentPending,
+		RequestedBy: s.actor.Name,
+	}
+	if err := validateAdjustment(adjustment); err != nil {
+		return nil, err
+	}
+
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		if _, err := tx.Accounts().GetByID(ctx, accountID); err != nil {
+			return notFound(err, ErrAccountNotFound)
+		}
+		if math.Abs(adjustment.Amount) <= s.threshold {
+			if err := s.post(ctx, tx, adjustment); err != nil {
+				return err
+			}
+		}
+		if err := tx.Adjustments().Create(ctx, adjustment); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, tx, s.actor, AuditEvent{
+			Action:     AuditAdjustmentRequested,
+			EntityType: "adjustment",
+			EntityID:   adjustment.ID.String(),
+			After:      adjustmentAudit(adjustment),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.String("adjustment.status", string(adjustment.Status)))
+	slog.InfoContext(ctx, "adjustment requested", "adjustment_id", adjustment.ID, "account_id", accountID, "amount", adjustment.Amount, "reason_code", adjustment.ReasonCode, "status", adjustment.Status, "actor", s.actor.Name)
+	return adjustment, nil
+}
+
+// validateAdjustment checks the amount, reason code and note of a new
+// adjustment.
+func validateAdjustment(a *models.Adjustment) error {
+	if a.Amount == 0 {
+		return ErrZeroAdjustment
+	}
+	if !slices.Contains(models.AdjustmentReasons, a.ReasonCode) {
+		codes := make([]string, len(models.AdjustmentReasons))
+		for i, code := range models.AdjustmentReasons {
+			codes[i] = string(code)
+		}
+		return &ValidationError{Field: "reason_code", Message: "reason_code must be one of " + strings.Join(codes, ", ")}
+	}
+	if a.Note == "" {
+		return ErrNoteRequired
+	}
+	return nil
+}
+
+// post credits or debits the account by an adjustment and marks it
+// approved.
+func (s *adjustmentService) post(ctx context.Context, tx repository.Store, a *models.Adjustment) error {
+	reason := fmt.Sprintf("%s: %s", a.ReasonCode, a.Note)
+	transaction, err := NewAccountServiceWithStore(tx).WithActor(s.actor).Adjust(ctx, a.AccountID, a.Amount, reason)
+	if err != nil {
+		return err
+	}
+	a.Status = models.AdjustmentApproved
+	a.TransactionID = &transaction.ID
+	return nil
+}
+
+func (s *adjustmentService) GetAdjustment(ctx context.Context, id uuid.UUID) (_ *models.Adjustment, err error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+	ctx, span := accountTracer.Start(ctx, "AdjustmentService.GetAdjustment")
+	defer endSpan(ctx, span, &err)
+
+	adjustment, err := s.store.Adjustments().GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrAdjustmentNotFound)
+	}
+	return adjustment, nil
+}
+
+func (s *adjustmentService) ListAdjustments(ctx context.Context, status models.AdjustmentStatus) (_ []models.Adjustment, err error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+	ctx, span := accountTracer.Start(ctx, "AdjustmentService.ListAdjustments")
+	defer endSpan(ctx, span, &err)
+
+	return s.store.Adjustments().ListByStatus(ctx, status)
+}
+
+func (s *adjustmentService) Approve(ctx context.Context, id uuid.UUID, note string) (*models.Adjustment, error) {
+	return s.review(ctx, "Approve", AuditAdjustmentApproved, id, strings.TrimSpace(note), func(tx repository.Store, a *models.Adjustment) error {
+		if a.RequestedBy == s.actor.Name {
+			return ErrSelfApproval
+		}
+		return s.post(ctx, tx, a)
+	})
+}
+
+func (s *adjustmentService) Reject(ctx context.Context, id uuid.UUID, note string) (*models.Adjustment, error) {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return nil, ErrNoteRequired
+	}
+	return s.review(ctx, "Reject", AuditAdjustmentRejected, id, note, func(tx repository.Store, a *models.Adjustment) error {
+		a.Status = models.AdjustmentRejected
+		return nil
+	})
+}
+
+// review loads a pending adjustment, has decide approve or reject it, and
+// saves and audits the decision in a single database transaction.
+func (s *adjustmentService) review(ctx context.Context, name, action string, id uuid.UUID, note string, decide func(tx repository.Store, a *models.Adjustment) error) (_ *models.Adjustment, err error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	ctx, span := accountTracer.Start(ctx, "AdjustmentService."+name)
+	defer endSpan(ctx, span, &err)
+
+	var adjustment *models.Adjustment
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		var err error
+		if adjustment, err = tx.Adjustments().GetByID(ctx, id); err != nil {
+			return notFound(err, ErrAdjustmentNotFound)
+		}
+		if adjustment.Status != models.AdjustmentPending {
+			return ErrAdjustmentNotPending
+		}
+
+		before := adjustmentAudit(adjustment)
+		if err := decide(tx, adjustment); err != nil {
+			return err
+		}
+		reviewedAt := time.Now()
+		adjustment.ReviewedBy = s.actor.Name
+		adjustment.ReviewNote = note
+		adjustment.ReviewedAt = &reviewedAt
+		if err := tx.Adjustments().Update(ctx, adjustment); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, tx, s.actor, AuditEvent{
+			Action:     action,
+			EntityType: "adjustment",
+			EntityID:   adjustment.ID.String(),
+			Before:     before,
+			After:      adjustmentAudit(adjustment),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "adjustment reviewed", "adjustment_id", adjustment.ID, "account_id", adjustment.AccountID, "status", adjustment.Status, "requested_by", adjustment.RequestedBy, "actor", s.actor.Name)
+	return adjustment, nil
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"wallet/internal/models"
+	"wallet/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+func TestAdjustments(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	accounts := NewAccountServiceWithStore(store)
+	maker := NewAdjustmentServiceWithStore(store, 100).WithActor(Actor{Name: "alice"})
+	checker := maker.WithActor(Actor{Name: "bob"})
+	account := newTestAccount(t, accounts)
+
+	small, err := maker.Request(ctx, account.ID, 25, models.AdjustmentGoodwill, "late delivery")
+	if err != nil || small.Status != models.AdjustmentApproved || small.TransactionID == nil {
+		t.Fatalf("adjustment below the threshold: got %v, %v want approved with a transaction", small, err)
+	}
+	checkBalances(t, accounts, account, 25, 25)
+
+	large, err := maker.Request(ctx, account.ID, 500, models.AdjustmentCorrection, "missed top-up")
+	if err != nil || large.Status != models.AdjustmentPending || large.TransactionID != nil {
+		t.Fatalf("adjustment above the threshold: got %v, %v want pending", large, err)
+	}
+	checkBalances(t, accounts, account, 25, 25)
+
+	if _, err := maker.Approve(ctx, large.ID, ""); !errors.Is(err, ErrSelfApproval) {
+		t.Errorf("approving your own adjustment: got error %v want %v", err, ErrSelfApproval)
+	}
+	approved, err := checker.Approve(ctx, large.ID, "checked the bank statement")
+	if err != nil || approved.Status != models.AdjustmentApproved || approved.ReviewedBy != "bob" || approved.TransactionID == nil {
+		t.Fatalf("approving an adjustment: got %v, %v want approved by bob", approved, err)
+	}
+	if _, err := checker.Approve(ctx, large.ID, ""); !errors.Is(err, ErrAdjustmentNotPending) {
+		t.Errorf("approving an adjustment twice: got error %v want %v", err, ErrAdjustmentNotPending)
+	}
+	checkBalances(t, accounts, account, 525, 525)
+
+	writeOff, err := maker.Request(ctx, account.ID, -300, models.AdjustmentWriteOff, "duplicate credit")
+	if err != nil || writeOff.Status != models.AdjustmentPending {
+		t.Fatalf("debit above the threshold: got %v, %v want pending", writeOff, err)
+	}
+	if _, err := checker.Reject(ctx, writeOff.ID, ""); !errors.Is(err, ErrNoteRequired) {
+		t.Errorf("rejecting without a note: got error %v want %v", err, ErrNoteRequired)
+	}
+	if rejected, err := checker.Reject(ctx, writeOff.ID, "credit was not a duplicate"); err != nil || rejected.Status != models.AdjustmentRejected {
+		t.Errorf("rejecting an adjustment: got %v, %v want rejected", rejected, err)
+	}
+	checkBalances(t, accounts, account, 525, 525)
+
+	pending, err := maker.ListAdjustments(ctx, models.AdjustmentPending)
+	if err != nil || len(pending) != 0 {
+		t.Errorf("pending adjustments: got %d, %v want 0", len(pending), err)
+	}
+
+	if _, err := maker.Request(ctx, account.ID, 0, models.AdjustmentOther, "nothing"); !errors.Is(err, ErrZeroAdjustment) {
+		t.Errorf("zero adjustment: got error %v want %v", err, ErrZeroAdjustment)
+	}
+	var validation *ValidationError
+	if _, err := maker.Request(ctx, account.ID, 5, "bonus", "promo"); !errors.As(err, &validation) || validation.Field != "reason_code" {
+		t.Errorf("unknown reason code: got error %v want a reason_code validation error", err)
+	}
+	if _, err := maker.Request(ctx, account.ID, 5, models.AdjustmentOther, " "); !errors.Is(err, ErrNoteRequired) {
+		t.Errorf("adjustment without a note: got error %v want %v", err, ErrNoteRequired)
+	}
+}
+
+func TestAdjustmentTransactions(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	accounts := NewAccountService(db)
+	adjustments := NewAdjustmentService(db, 100).WithActor(Actor{Name: "alice"})
+	account := newTestAccount(t, accounts)
+
+	credit, err := adjustments.Request(ctx, account.ID, 40, models.AdjustmentGoodwill, "late delivery")
+	if err != nil {
+		t.Fatal(err)
+	}
+	debit, err := adjustments.Request(ctx, account.ID, -15, models.AdjustmentWriteOff, "duplicate credit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkBalances(t, accounts, account, 25, 25)
+
+	// Adjustments are stored with their own type and a signed amount
+	checkTransaction := func(id uuid.UUID, amount float64) {
+		t.Helper()
+		transaction, err := repository.NewGormStore(db).Transactions().GetByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if transaction.TransactionType != models.AdjustmentTransaction || transaction.Amount != amount {
+			t.Errorf("transaction: got %s of %v want adjustment of %v", transaction.TransactionType, transaction.Amount, amount)
+		}
+	}
+	checkTransaction(*credit.TransactionID, 40)
+	checkTransaction(*debit.TransactionID, -15)
+
+	// A reversal is an adjustment of the opposite sign
+	reversal, err := accounts.ReverseTransaction(ctx, *debit.TransactionID, "write-off was wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkTransaction(reversal.ID, 15)
+	checkBalances(t, accounts, account, 40, 40)
+
+	balance, err := NewBalanceService(db).BalanceAsOf(ctx, account.ID, time.Now())
+	if err != nil || balance.Balance != 40 {
+		t.Errorf("balance as of now: got %v, %v want 40", balance, err)
+	}
+	report, err := NewReconciliationService(db).Reconcile(ctx)
+	if err != nil || !report.Balanced() {
+		t.Errorf("reconciliation: got %+v, %v want balanced", report, err)
+	}
+}
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+	"wallet/internal/models"
+	"wallet/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// Actor identifies who triggered a state change, for the audit log.
+type Actor struct {
+	Name      string
+	RequestID string
+}
+
+// SystemActor is used for changes not attributed to an operator or request.
+var SystemActor = Actor{Name: "system"}
+
+// Audited actions.
+const (
+	AuditUserCreated     = "user.created"
+	AuditAccountCreated  = "account.created"
+	AuditAccountTopUp    = "account.topped_up"
+	AuditAccountCharge   = "account.charged"
+	AuditAccountAdjusted = "account.adjusted"
+	AuditAccountFrozen   = "account.frozen"
+	AuditAccountUnfrozen = "account.unfrozen"
+	AuditBatchSubmitted  = "batch.submitted"
+
+	AuditTransactionPosted   = "transaction.posted"
+	AuditTransactionFailed   = "transaction.failed"
+	AuditTransactionReversed = "transaction.reversed"
+
+	AuditBeneficiaryAdded = "beneficiary.added"
+	AuditPayoutCreated    = "payout.created"
+
+	AuditAccountNumberAssigned = "account.number_assigned"
+	AuditVirtualIBANAssigned   = "account.virtual_iban_assigned"
+	AuditDepositReceived       = "deposit.received"
+	AuditDepositAssigned       = "deposit.assigned"
+
+	AuditAdjustmentRequested = "adjustment.requested"
+	AuditAdjustmentApproved  = "adjustment.approved"
+	AuditAdjustmentRejected  = "adjustment.rejected"
+)
+
+// AuditEvent describes a state change of a single entity.
+type AuditEvent struct {
+	Action     string
+	EntityType string
+	EntityID   string
+	Before     any
+	After      any
+}
+
+// AuditProblem is an integrity violation found while verifying the log.
+type AuditProblem struct {
+	Sequence uint64 `json:"sequence"`
+	Kind     string `json:"kind"`
+	Detail   string `json:"detail"`
+}
+
+// AuditVerification is the outcome of checking the audit log's hash chain.
+type AuditVerification struct {
+	EntriesChecked int            `json:"entries_checked"`
+	Problems       []AuditProblem `json:"problems"`
+}
+
+// Intact reports whether no problems were found.
+func (v *AuditVerification) Intact() bool {
+	return len(v.Problems) == 0
+}
+
+type AuditService interface {
+	Record(ctx context.Context, tx repository.Store, actor Actor, event AuditEvent) error
+	Verify(ctx context.Context) (*AuditVerification, error)
+}
+
+type auditService struct {
+	db *gorm.DB
+	// key signs the hash chain. Without one, entries are hashed with plain
+	// SHA-256, which anyone able to write to the database can recompute.
+	key []byte
+}
+
+func NewAuditService(db *gorm.DB) AuditService {
+	return &auditService{db: db, key: auditKeyFromEnv()}
+}
+
+// warnUnkeyedAudit warns once that the audit log is not keyed.
+var warnUnkeyedAudit sync.Once
+
+// auditKeyFromEnv reads AUDIT_HMAC_KEY, the secret the audit log's hashes
+// are HMACs with.
+func auditKeyFromEnv() []byte {
+	key := os.Getenv("AUDIT_HMAC_KEY")
+	if key == "" {
+		warnUnkeyedAudit.Do(func() {
+			slog.Warn("AUDIT_HMAC_KEY is not set, the audit log's hash chain is not keyed")
+		})
+		return nil
+	}
+	return []byte(key)
+}
+
+// genesisHash is the previous hash of the first entry in the chain.
+var genesisHash = hex.EncodeToString(make([]byte, sha256.Size))
+
+// Record appends an entry to the audit log using tx, so the entry is only
+// kept if the state change it describes is committed.
+func (s *auditService) Record(ctx context.Context, tx repository.Store, actor Actor, event AuditEvent) error {
+	before, err := json.Marshal(event.Before)
+	if err != nil {
+		return err
+	}
+	after, err := json.Marshal(event.After)
+	if err != nil {
+		return err
+	}
+
+	// Chain onto the latest entry; the sequence primary key rejects a fork
+	// if two writers race for the same position
+	last, err := tx.Audit().Last(ctx)
+	if err != nil {
+		return err
+	}
+	entry := &models.AuditEntry{
+		Sequence:   1,
+		Action:     event.Action,
+		Actor:      actor.Name,
+		RequestID:  actor.RequestID,
+		EntityType: event.EntityType,
+		EntityID:   event.EntityID,
+		Before:     string(before),
+		After:      string(after),
+		PrevHash:   genesisHash,
+		Keyed:      len(s.key) > 0,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if last != nil {
+		entry.Sequence = last.Sequence + 1
+		entry.PrevHash = last.Hash
+	}
+	entry.Hash = auditHash(s.key, entry)
+
+	return tx.Audit().Append(ctx, entry)
+}
+
+// Verify walks the whole log in order and reports sequence gaps, entries
+// whose content no longer matches their hash, broken links between entries,
+// and accounts whose balance was changed outside the audited code paths.
+//
+// With a key, it also reports unkeyed entries after the first keyed one and
+// an unkeyed latest entry: anyone can rewrite the log with plain hashes, but
+// not with keyed ones.
+func (s *auditService) Verify(ctx context.Context) (*AuditVerification, error) {
+	db := s.db.WithContext(ctx)
+
+	result := &AuditVerification{Problems: []AuditProblem{}}
+	report := func(seq uint64, kind, format string, args ...any) {
+		result.Problems = append(result.Problems, AuditProblem{Sequence: seq, Kind: kind, Detail: fmt.Sprintf(format, args...)})
+	}
+
+	expectedSeq := uint64(1)
+	prevHash := genesisHash
+	keyed := false
+	var last *models.AuditEntry
+	latestBalances := make(map[string]float64)
+
+	var batch []models.AuditEntry
+	err := db.Order("sequence ASC").FindInBatches(&batch, 500, func(_ *gorm.DB, _ int) error {
+		for _, entry := range batch {
+			result.EntriesChecked++
+
+			if entry.Sequence != expectedSeq {
+				report(entry.Sequence, "gap", "expected sequence %d, found %d", expectedSeq, entry.Sequence)
+			}
+			if entry.PrevHash != prevHash {
+				report(entry.Sequence, "chain_broken", "previous hash %s does not match the preceding entry", entry.PrevHash)
+			}
+			if entry.Keyed && len(s.key) == 0 {
+				return ErrAuditKeyMissing
+			}
+			if hash := auditHash(s.key, &entry); !hmac.Equal([]byte(hash), []byte(entry.Hash)) {
+				report(entry.Sequence, "modified", "content hashes to %s, stored hash is %s", hash, entry.Hash)
+			}
+			if keyed && !entry.Keyed {
+				report(entry.Sequence, "unkeyed", "entry is not keyed although an earlier one is")
+			}
+			keyed = keyed || entry.Keyed
+
+			if entry.EntityType == "account" {
+				var state struct {
+					Balance *float64 `json:"balance"`
+				}
+				if json.Unmarshal([]byte(entry.After), &state) == nil && state.Balance != nil {
+					latestBalances[entry.EntityID] = *state.Balance
+				}
+			}
+
+			expectedSeq = entry.Sequence + 1
+			prevHash = entry.Hash
+			last = &entry
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(s.key) > 0 && last != nil && !keyed {
+		report(last.Sequence, "unkeyed", "the latest entry is not keyed; entries recorded with AUDIT_HMAC_KEY set anchor the log")
+	}
+
+	// The live balances must match the last audited value
+	accountIDs := make([]string, 0, len(latestBalances))
+	for accountID := range latestBalances {
+		accountIDs = append(accountIDs, accountID)
+	}
+	sort.Strings(accountIDs)
+	for _, accountID := range accountIDs {
+		audited := latestBalances[accountID]
+		var accounts []models.Account
+		if err := db.Unscoped().Where("id = ?", accountID).Find(&accounts).Error; err != nil {
+			return nil, err
+		}
+		switch {
+		case len(accounts) == 0:
+			report(0, "balance_drift", "account %s is audited but no longer exists", accountID)
+		case accounts[0].Balance != audited:
+			report(0, "balance_drift", "account %s has balance %.2f, last audited balance is %.2f", accountID, accounts[0].Balance, audited)
+		}
+	}
+
+	return result, nil
+}
+
+// auditHash hashes the entry's content together with the previous hash, as
+// an HMAC with key if the entry is keyed.
+func auditHash(key []byte, e *models.AuditEntry) string {
+	content := []byte(fmt.Sprintf("%d|%s|%s|%s|%s|%s|%s|%s|%s|%s",
+		e.Sequence,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		e.Action,
+		e.Actor,
+		e.RequestID,
+		e.EntityType,
+		e.EntityID,
+		e.Before,
+		e.After,
+		e.PrevHash,
+	))
+	if !e.Keyed {
+		sum := sha256.Sum256(content)
+		return hex.EncodeToString(sum[:])
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(content)
+	return hex.EncodeToString(mac.Sum(nil))
+}
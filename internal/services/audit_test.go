@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"wallet/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// newAuditedDB returns a database with an account topped up twice, and its
+// audit log with the append-only triggers dropped so tests can tamper with
+// it.
+func newAuditedDB(t *testing.T) (*gorm.DB, *models.Account) {
+	t.Helper()
+	ctx := context.Background()
+	db := newTestDB(t)
+	accounts := NewAccountService(db)
+	account := newTestAccount(t, accounts)
+	for range 2 {
+		if _, err := accounts.TopUp(ctx, account.ID, 10, models.TransactionDetails{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, trigger := range []string{"audit_entries_no_update", "audit_entries_no_delete"} {
+		if err := db.Exec("DROP TRIGGER " + trigger).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return db, account
+}
+
+func TestAuditVerify(t *testing.T) {
+	t.Setenv("AUDIT_HMAC_KEY", "audit-secret")
+
+	tests := []struct {
+		name   string
+		tamper func(t *testing.T, db *gorm.DB, account *models.Account)
+		want   []string
+	}{
+		{
+			name:   "untouched",
+			tamper: func(*testing.T, *gorm.DB, *models.Account) {},
+		},
+		{
+			name: "edited entry",
+			tamper: func(t *testing.T, db *gorm.DB, _ *models.Account) {
+				mustExec(t, db, `UPDATE audit_entries SET actor = 'mallory' WHERE sequence = 2`)
+			},
+			want: []string{"modified"},
+		},
+		{
+			name: "rehashed without the key",
+			tamper: func(t *testing.T, db *gorm.DB, _ *models.Account) {
+				// Rewrite the whole log with plain hashes, as anyone with
+				// access to the database could
+				var entries []models.AuditEntry
+				if err := db.Order("sequence").Find(&entries).Error; err != nil {
+					t.Fatal(err)
+				}
+				prevHash := genesisHash
+				for _, entry := range entries {
+					entry.Actor = "mallory"
+					entry.Keyed = false
+					entry.PrevHash = prevHash
+					entry.Hash = auditHash(nil, &entry)
+					if err := db.Save(&entry).Error; err != nil {
+						t.Fatal(err)
+					}
+					prevHash = entry.Hash
+				}
+			},
+			want: []string{"unkeyed"},
+		},
+		{
+			name: "removed entry",
+			tamper: func(t *testing.T, db *gorm.DB, _ *models.Account) {
+				mustExec(t, db, `DELETE FROM audit_entries WHERE sequence = 2`)
+			},
+			want: []string{"gap", "chain_broken"},
+		},
+		{
+			name: "balance changed outside the services",
+			tamper: func(t *testing.T, db *gorm.DB, account *models.Account) {
+				mustExec(t, db, `UPDATE accounts SET balance = 1000 WHERE id = ?`, account.ID)
+			},
+			want: []string{"balance_drift"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, account := newAuditedDB(t)
+			tt.tamper(t, db, account)
+
+			result, err := NewAuditService(db).Verify(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.EntriesChecked == 0 {
+				t.Error("got no entries checked")
+			}
+			kinds := make([]string, len(result.Problems))
+			for i, p := range result.Problems {
+				kinds[i] = p.Kind
+			}
+			if len(kinds) != len(tt.want) {
+				t.Fatalf("got problems %v want %v", kinds, tt.want)
+			}
+			for i := range kinds {
+				if kinds[i] != tt.want[i] {
+					t.Errorf("got problems %v want %v", kinds, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAuditVerifyKeys(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("AUDIT_HMAC_KEY", "")
+	db := newTestDB(t)
+	accounts := NewAccountService(db)
+	account := newTestAccount(t, accounts)
+
+	// Entries from before the key was configured leave the log unanchored
+	t.Setenv("AUDIT_HMAC_KEY", "audit-secret")
+	result, err := NewAuditService(db).Verify(ctx)
+	if err != nil || len(result.Problems) != 1 || result.Problems[0].Kind != "unkeyed" {
+		t.Errorf("unkeyed log: got %+v, %v want an unkeyed latest entry", result, err)
+	}
+
+	// ... until an entry is recorded with it
+	if _, err := NewAccountService(db).TopUp(ctx, account.ID, 10, models.TransactionDetails{}); err != nil {
+		t.Fatal(err)
+	}
+	result, err = NewAuditService(db).Verify(ctx)
+	if err != nil || !result.Intact() {
+		t.Errorf("keyed log: got %+v, %v want intact", result, err)
+	}
+
+	t.Setenv("AUDIT_HMAC_KEY", "another-secret")
+	result, err = NewAuditService(db).Verify(ctx)
+	if err != nil || len(result.Problems) != 1 || result.Problems[0].Kind != "modified" {
+		t.Errorf("wrong key: got %+v, %v want the keyed entry reported", result, err)
+	}
+
+	t.Setenv("AUDIT_HMAC_KEY", "")
+	if _, err := NewAuditService(db).Verify(ctx); !errors.Is(err, ErrAuditKeyMissing) {
+		t.Errorf("no key: got error %v want %v", err, ErrAuditKeyMissing)
+	}
+}
+
+func mustExec(t *testing.T, db *gorm.DB, sql string, values ...any) {
+	t.Helper()
+	if err := db.Exec(sql, values...).Error; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"math"
+	"time"
+	"wallet/internal/models"
+	"wallet/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// signedAmountSQL sums the amounts of posted transactions as credits minus
+// debits. Pending and failed transactions have no posting time, and the
+// amounts of adjustments are already signed.
+const signedAmountSQL = `COALESCE(SUM(CASE
+	WHEN transactions.posted_at IS NULL THEN 0
+	WHEN transactions.transaction_type = 'top-up' THEN transactions.amount
+	WHEN transactions.transaction_type = 'charge' THEN -transactions.amount
+	WHEN transactions.transaction_type = 'adjustment' THEN transactions.amount
+	ELSE 0 END), 0)`
+
+// PointInTimeBalance is an account's balance as it stood at AsOf.
+type PointInTimeBalance struct {
+	AccountID uuid.UUID `json:"account_id"`
+	AsOf      time.Time `json:"as_of"`
+	Balance   float64   `json:"balance"`
+	// SnapshotClosingAt is the end of the day snapshot the balance was built
+	// from, or nil when the whole history was replayed.
+	SnapshotClosingAt   *time.Time `json:"snapshot_closing_at,omitempty"`
+	TransactionsApplied int64      `json:"transactions_applied"`
+}
+
+type BalanceService interface {
+	BalanceAsOf(ctx context.Context, accountID uuid.UUID, asOf time.Time) (*PointInTimeBalance, error)
+	SnapshotDay(ctx context.Context, day time.Time) (int, error)
+}
+
+type balanceService struct {
+	db *gorm.DB
+}
+
+func NewBalanceService(db *gorm.DB) BalanceService {
+	return &balanceService{db: db}
+}
+
+// StartOfDay truncates t to midnight in the server's local time zone, which
+// is the zone transaction timestamps are recorded in.
+func StartOfDay(t time.Time) time.Time {
+	y, m, d := t.In(time.Local).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+// BalanceAsOf returns the posted balance of an account at the given instant
+// by taking the nearest end-of-day snapshot at or before it and applying the
+// transactions posted after that snapshot.
+func (s *balanceService) BalanceAsOf(ctx context.Context, accountID uuid.UUID, asOf time.Time) (*PointInTimeBalance, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	// Make sure the account exists so callers can tell it apart from a zero balance
+	if _, err := NewAccountService(s.db).GetAccountByID(ctx, accountID); err != nil {
+		return nil, err
+	}
+	result, err := s.balanceAt(s.db.WithContext(ctx), accountID, asOf, true)
+	return result, contextError(ctx, err)
+}
+
+// balanceAt computes the balance from the transactions posted up to t,
+// including those posted exactly at t when inclusive is set.
+func (s *balanceService) balanceAt(db *gorm.DB, accountID uuid.UUID, t time.Time, inclusive bool) (*PointInTimeBalance, error) {
+	result := &PointInTimeBalance{AccountID: accountID, AsOf: t}
+
+	cmp := "<"
+	if inclusive {
+		cmp = "<="
+	}
+	query := db.Model(&models.Transaction{}).
+		Where("account_id = ? AND "+repository.UTCTimestampSQL("posted_at")+" "+cmp+" ?", accountID, repository.UTCTimestamp(t))
+
+	var snapshots []models.BalanceSnapshot
+	err := db.Where("account_id = ? AND "+repository.UTCTimestampSQL("closing_at")+" "+cmp+" ?", accountID, repository.UTCTimestamp(t)).
+		Order("closing_at DESC").
+		Limit(1).
+		Find(&snapshots).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(snapshots) == 1 {
+		result.Balance = snapshots[0].Balance
+		result.SnapshotClosingAt = &snapshots[0].ClosingAt
+		query = query.Where(repository.UTCTimestampSQL("posted_at")+" >= ?", repository.UTCTimestamp(snapshots[0].ClosingAt))
+	}
+
+	var delta struct {
+		Sum   float64
+		Count int64
+	}
+	if err := query.Select(signedAmountSQL + " AS sum, COUNT(transactions.id) AS count").Scan(&delta).Error; err != nil {
+		return nil, err
+	}
+
+	result.Balance = math.Round((result.Balance+delta.Sum)*100) / 100
+	result.TransactionsApplied = delta.Count
+
+	return result, nil
+}
+
+// SnapshotDay records the closing balance of every account that existed at
+// the end of the given day. Existing snapshots for that day are replaced.
+// It returns the number of snapshots written. It runs until done or ctx is
+// canceled, without a deadline of its own.
+func (s *balanceService) SnapshotDay(ctx context.Context, day time.Time) (int, error) {
+	closingAt := StartOfDay(day).AddDate(0, 0, 1)
+	if closingAt.After(time.Now()) {
+		return 0, ErrFutureSnapshot
+	}
+
+	db := s.db.WithContext(ctx)
+
+	var accountIDs []uuid.UUID
+	err := db.Model(&models.Account{}).
+		Where(repository.UTCTimestampSQL("created_at")+" < ?", repository.UTCTimestamp(closingAt)).
+		Pluck("id", &accountIDs).Error
+	if err != nil {
+		return 0, err
+	}
+
+	for _, accountID := range accountIDs {
+		// The closing balance is built from the previous snapshot, if any
+		closing, err := s.balanceAt(db, accountID, closingAt, false)
+		if err != nil {
+			return 0, err
+		}
+
+		snapshot := &models.BalanceSnapshot{
+			AccountID: accountID,
+			ClosingAt: closingAt,
+			Balance:   closing.Balance,
+		}
+		err = db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "account_id"}, {Name: "closing_at"}},
+			DoUpdates: clause.AssignmentColumns([]string{"balance", "updated_at"}),
+		}).Create(snapshot).Error
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(accountIDs), nil
+}
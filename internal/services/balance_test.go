@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"wallet/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func TestBalanceAsOf(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	accounts := NewAccountService(db)
+	balances := NewBalanceService(db)
+	account := newTestAccount(t, accounts)
+
+	// The account is opened three days ago; each day ends with a snapshot
+	day0 := StartOfDay(time.Now()).AddDate(0, 0, -3)
+	day1 := day0.AddDate(0, 0, 1)
+	day2 := day0.AddDate(0, 0, 2)
+	mustExec(t, db, `UPDATE accounts SET created_at = ? WHERE id = ?`, day0.Add(9*time.Hour), account.ID)
+
+	postAt(t, db, accounts, account.ID, models.TopUp, 100, day0.Add(10*time.Hour))
+	if _, err := balances.SnapshotDay(ctx, day0); err != nil {
+		t.Fatal(err)
+	}
+	// Posted at the very instant the first snapshot closes, so it belongs
+	// to the day after
+	postAt(t, db, accounts, account.ID, models.TopUp, 50, day1)
+	postAt(t, db, accounts, account.ID, models.Charge, 30, day1.Add(12*time.Hour))
+	if _, err := balances.SnapshotDay(ctx, day1); err != nil {
+		t.Fatal(err)
+	}
+	postAt(t, db, accounts, account.ID, models.Charge, 20, day2.Add(time.Hour))
+
+	var snapshots []models.BalanceSnapshot
+	if err := db.Where("account_id = ?", account.ID).Order("closing_at").Find(&snapshots).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Balance != 100 || snapshots[1].Balance != 120 {
+		t.Fatalf("got snapshots %+v want closing balances 100 and 120", snapshots)
+	}
+
+	tests := []struct {
+		name         string
+		asOf         time.Time
+		balance      float64
+		snapshot     *time.Time
+		transactions int64
+	}{
+		{name: "before the account existed", asOf: day0.Add(8 * time.Hour)},
+		{name: "before the first snapshot", asOf: day0.Add(12 * time.Hour), balance: 100, transactions: 1},
+		{name: "exactly at a snapshot", asOf: day1, balance: 150, snapshot: &day1, transactions: 1},
+		{name: "between snapshots", asOf: day1.Add(18 * time.Hour), balance: 120, snapshot: &day1, transactions: 2},
+		{name: "exactly at the last snapshot", asOf: day2, balance: 120, snapshot: &day2},
+		{name: "after the last snapshot", asOf: day2.Add(2 * time.Hour), balance: 100, snapshot: &day2, transactions: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := balances.BalanceAsOf(ctx, account.ID, tt.asOf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Balance != tt.balance || got.TransactionsApplied != tt.transactions {
+				t.Errorf("got balance %v from %d transactions want %v from %d", got.Balance, got.TransactionsApplied, tt.balance, tt.transactions)
+			}
+			switch {
+			case tt.snapshot == nil && got.SnapshotClosingAt != nil:
+				t.Errorf("got snapshot closing at %v want none", got.SnapshotClosingAt)
+			case tt.snapshot != nil && (got.SnapshotClosingAt == nil || !got.SnapshotClosingAt.Equal(*tt.snapshot)):
+				t.Errorf("got snapshot closing at %v want %v", got.SnapshotClosingAt, tt.snapshot)
+			}
+		})
+	}
+
+	if _, err := balances.BalanceAsOf(ctx, uuid.New(), time.Now()); !errors.Is(err, ErrAccountNotFound) {
+		t.Errorf("unknown account: got error %v want %v", err, ErrAccountNotFound)
+	}
+	if _, err := balances.SnapshotDay(ctx, time.Now()); !errors.Is(err, ErrFutureSnapshot) {
+		t.Errorf("today: got error %v want %v", err, ErrFutureSnapshot)
+	}
+}
+
+// postAt records a posted transaction and backdates it to at.
+func postAt(t *testing.T, db *gorm.DB, accounts AccountService, accountID uuid.UUID, typ models.TransactionType, amount float64, at time.Time) {
+	t.Helper()
+	ctx := context.Background()
+	var transaction *models.Transaction
+	var err error
+	if typ == models.TopUp {
+		transaction, err = accounts.TopUp(ctx, accountID, amount, models.TransactionDetails{})
+	} else {
+		transaction, err = accounts.Charge(ctx, accountID, amount, models.TransactionDetails{})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustExec(t, db, `UPDATE transactions SET created_at = ?, posted_at = ? WHERE id = ?`, at, at, transaction.ID)
+}
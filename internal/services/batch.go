@@ -0,0 +1,379 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+	"wallet/internal/models"
+	"wallet/internal/repository"
+	"wallet/internal/tracing"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+)
+
+// Limits of a submitted batch. Atomic batches are smaller as they hold the
+// database's write lock until every item is applied.
+const (
+	MaxBatchItems       = 10_000
+	MaxAtomicBatchItems = 1_000
+	MaxReferenceLength  = 64
+)
+
+type BatchService interface {
+	// Submit validates and stores a batch of top-ups and charges, and
+	// starts processing it in the background. The batch is returned while
+	// still pending; its progress is read back with GetBatch.
+	Submit(ctx context.Context, mode models.BatchMode, items []models.BatchItem) (*models.Batch, error)
+	// GetBatch returns a batch with the results of its items.
+	GetBatch(ctx context.Context, id uuid.UUID) (*models.Batch, error)
+	// Resume restarts the processing of the batches a shutdown interrupted,
+	// returning how many there were.
+	Resume(ctx context.Context) (int, error)
+	// WithActor returns a copy of the service that submits batches on
+	// behalf of the given actor, who their transactions are audited as.
+	WithActor(actor Actor) BatchService
+}
+
+type batchService struct {
+	store repository.Store
+	audit AuditService
+	actor Actor
+	// runner is shared with the copies made by WithActor
+	runner *batchRunner
+}
+
+// batchRunner processes batches in the background, one at a time so that a
+// large batch does not hold up every other writer.
+type batchRunner struct {
+	mu sync.Mutex
+	wg sync.WaitGroup
+}
+
+func NewBatchService(db *gorm.DB) BatchService {
+	return NewBatchServiceWithStore(repository.NewGormStore(db))
+}
+
+// NewBatchServiceWithStore returns a BatchService that keeps batches in
+// store and applies their items to the accounts in it.
+func NewBatchServiceWithStore(store repository.Store) BatchService {
+	return &batchService{
+		store: store,
+		// Recording entries only needs the transaction's store
+		audit:  &auditService{key: auditKeyFromEnv()},
+		actor:  SystemActor,
+		runner: &batchRunner{},
+	}
+}
+
+func (s *batchService) WithActor(actor Actor) BatchService {
+	clone := *s
+	clone.actor = actor
+	return &clone
+}
+
+// batchAuditState is the audited view of a submitted batch.
+type batchAuditState struct {
+	Mode  models.BatchMode `json:"mode"`
+	Items int              `json:"items"`
+}
+
+func (s *batchService) Submit(ctx context.Context, mode models.BatchMode, items []models.BatchItem) (_ *models.Batch, err error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	ctx, span := accountTracer.Start(ctx, "BatchService.Submit")
+	defer endSpan(ctx, span, &err)
+
+	if mode == "" {
+		mode = models.BatchBestEffort
+	}
+	if err := validateBatch(mode, items); err != nil {
+		return nil, err
+	}
+
+	// Refuse references used by an earlier batch up front, so the caller
+	// learns which ones they are
+	references := make([]string, len(items))
+	for i, item := range items {
+		references[i] = item.Reference
+	}
+	taken, err := s.store.Batches().TakenReferences(ctx, references)
+	if err != nil {
+		return nil, err
+	}
+	if len(taken) > 0 {
+		return nil, duplicateReferences(taken)
+	}
+
+	batch := &models.Batch{
+		Mode:      mode,
+		Status:    models.BatchPending,
+		Actor:     s.actor.Name,
+		RequestID: s.actor.RequestID,
+		Items:     items,
+	}
+	for i := range batch.Items {
+		batch.Items[i].Position = i
+		batch.Items[i].Status = models.BatchItemPending
+	}
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.Batches().Create(ctx, batch); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, tx, s.actor, AuditEvent{
+			Action:     AuditBatchSubmitted,
+			EntityType: "batch",
+			EntityID:   batch.ID.String(),
+			After:      batchAuditState{Mode: mode, Items: len(items)},
+		})
+	})
+	if errors.Is(err, repository.ErrDuplicate) {
+		// Another batch took one of the references in the meantime
+		return nil, ErrDuplicateReference
+	}
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("batch.id", batch.ID.String()))
+
+	// Keep the request's trace and request ID, but not its deadline
+	s.start(context.WithoutCancel(ctx), batch.ID)
+	return batch, nil
+}
+
+// validateBatch checks a batch before it is stored. Errors name the
+// offending item by its index, e.g. items[3].amount.
+func validateBatch(mode models.BatchMode, items []models.BatchItem) error {
+	if mode != models.BatchAtomic && mode != models.BatchBestEffort {
+		return &ValidationError{Field: "mode", Message: "mode must be atomic or best_effort"}
+	}
+	if len(items) == 0 {
+		return &ValidationError{Field: "items", Message: "a batch needs at least one item"}
+	}
+	if len(items) > MaxBatchItems {
+		return &ValidationError{Field: "items", Message: fmt.Sprintf("a batch holds at most %d items", MaxBatchItems)}
+	}
+	if mode == models.BatchAtomic && len(items) > MaxAtomicBatchItems {
+		return &ValidationError{Field: "items", Message: fmt.Sprintf("an atomic batch holds at most %d items", MaxAtomicBatchItems)}
+	}
+
+	seen := make(map[string]int, len(items))
+	for i, item := range items {
+		field := func(name string) string { return fmt.Sprintf("items[%d].%s", i, name) }
+		switch {
+		case item.AccountID == uuid.Nil:
+			return &ValidationError{Field: field("account_id"), Message: "account_id is required"}
+		case item.Operation != models.TopUp && item.Operation != models.Charge:
+			return &ValidationError{Field: field("operation"), Message: "operation must be top-up or charge"}
+		case item.Amount <= 0:
+			return &ValidationError{Field: field("amount"), Message: "amount must be greater than 0"}
+		case item.Reference == "":
+			return &ValidationError{Field: field("reference"), Message: "reference is required"}
+		case len(item.Reference) > MaxReferenceLength:
+			return &ValidationError{Field: field("reference"), Message: fmt.Sprintf("reference must be at most %d characters long", MaxReferenceLength)}
+		}
+		if first, ok := seen[item.Reference]; ok {
+			return &ValidationError{Field: field("reference"), Message: fmt.Sprintf("reference %q is also used by items[%d]", item.Reference, first)}
+		}
+		seen[item.Reference] = i
+	}
+	return nil
+}
+
+// duplicateReferences reports the references taken by earlier batches,
+// listing the first few.
+func duplicateReferences(taken []string) error {
+	const listed = 5
+	if len(taken) > listed {
+		return fmt.Errorf("%w: %s and %d more", ErrDuplicateReference, strings.Join(taken[:listed], ", "), len(taken)-listed)
+	}
+	return fmt.Errorf("%w: %s", ErrDuplicateReference, strings.Join(taken, ", "))
+}
+
+func (s *batchService) GetBatch(ctx context.Context, id uuid.UUID) (*models.Batch, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	batch, err := s.store.Batches().GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(contextError(ctx, err), ErrBatchNotFound)
+	}
+	return batch, nil
+}
+
+func (s *batchService) Resume(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	batches, err := s.store.Batches().ListByStatus(ctx, models.BatchPending, models.BatchProcessing)
+	if err != nil {
+		return 0, contextError(ctx, err)
+	}
+	for _, batch := range batches {
+		s.start(context.WithoutCancel(ctx), batch.ID)
+	}
+	return len(batches), nil
+}
+
+// start processes a batch in the background as soon as no other batch is
+// being processed.
+func (s *batchService) start(ctx context.Context, id uuid.UUID) {
+	s.runner.wg.Add(1)
+	go func() {
+		defer s.runner.wg.Done()
+		s.runner.mu.Lock()
+		defer s.runner.mu.Unlock()
+
+		if err := s.process(ctx, id); err != nil {
+			// The batch stays in progress and is picked up by Resume
+			slog.ErrorContext(ctx, "batch processing failed", "batch_id", id, "error", err)
+		}
+	}()
+}
+
+// process applies the pending items of a batch and records the outcome.
+func (s *batchService) process(ctx context.Context, id uuid.UUID) (err error) {
+	ctx, span := accountTracer.Start(ctx, "BatchService.Process")
+	span.SetAttributes(attribute.String("batch.id", id.String()))
+	defer func() { tracing.End(span, err) }()
+
+	batch, err := s.store.Batches().GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if batch.Status == models.BatchCompleted || batch.Status == models.BatchFailed {
+		return nil
+	}
+	batch.Status = models.BatchProcessing
+	if err := s.store.Batches().Update(ctx, batch); err != nil {
+		return err
+	}
+
+	// Transactions are audited as the actor who submitted the batch
+	actor := Actor{Name: batch.Actor, RequestID: batch.RequestID}
+	if batch.Mode == models.BatchAtomic {
+		err = s.processAtomic(ctx, batch, actor)
+	} else {
+		err = s.processBestEffort(ctx, batch, actor)
+	}
+	if err != nil {
+		return err
+	}
+
+	counts := map[models.BatchItemStatus]int{}
+	for _, item := range batch.Items {
+		counts[item.Status]++
+	}
+	slog.InfoContext(ctx, "batch processed",
+		"batch_id", batch.ID,
+		"mode", batch.Mode,
+		"status", batch.Status,
+		"succeeded", counts[models.BatchItemSucceeded],
+		"failed", counts[models.BatchItemFailed],
+		"skipped", counts[models.BatchItemSkipped],
+	)
+	return nil
+}
+
+// processBestEffort applies each pending item in a transaction of its own,
+// together with its result, so an interrupted batch resumes where it
+// stopped.
+func (s *batchService) processBestEffort(ctx context.Context, batch *models.Batch, actor Actor) error {
+	for i := range batch.Items {
+		item := &batch.Items[i]
+		if item.Status != models.BatchItemPending {
+			continue
+		}
+		err := s.store.Transaction(ctx, func(tx repository.Store) error {
+			return applyBatchItem(ctx, tx, actor, item)
+		})
+		if err != nil {
+			item.Status = models.BatchItemFailed
+			item.TransactionRef = ""
+			item.Error = err.Error()
+			if err := s.store.Batches().UpdateItem(ctx, item); err != nil {
+				return err
+			}
+		}
+	}
+
+	now := time.Now()
+	batch.Status = models.BatchCompleted
+	batch.CompletedAt = &now
+	return s.store.Batches().Update(ctx, batch)
+}
+
+// processAtomic applies every item in a single transaction. If one fails,
+// the transaction is rolled back and the failure recorded against it,
+// leaving the other items skipped.
+func (s *batchService) processAtomic(ctx context.Context, batch *models.Batch, actor Actor) error {
+	var failed *models.BatchItem
+	var failure error
+	err := s.store.Transaction(ctx, func(tx repository.Store) error {
+		for i := range batch.Items {
+			item := &batch.Items[i]
+			if err := applyBatchItem(ctx, tx, actor, item); err != nil {
+				failed, failure = item, err
+				return err
+			}
+		}
+		now := time.Now()
+		batch.Status = models.BatchCompleted
+		batch.CompletedAt = &now
+		return tx.Batches().Update(ctx, batch)
+	})
+	if err == nil || failed == nil {
+		return err
+	}
+
+	now := time.Now()
+	batch.Status = models.BatchFailed
+	batch.CompletedAt = &now
+	return s.store.Transaction(ctx, func(tx repository.Store) error {
+		for i := range batch.Items {
+			item := &batch.Items[i]
+			item.TransactionRef = ""
+			if item == failed {
+				item.Status = models.BatchItemFailed
+				item.Error = failure.Error()
+			} else {
+				item.Status = models.BatchItemSkipped
+				item.Error = fmt.Sprintf("rolled back: items[%d] failed", failed.Position)
+			}
+			if err := tx.Batches().UpdateItem(ctx, item); err != nil {
+				return err
+			}
+		}
+		return tx.Batches().Update(ctx, batch)
+	})
+}
+
+// applyBatchItem tops up or charges the item's account inside tx and records
+// the resulting transaction against the item.
+func applyBatchItem(ctx context.Context, tx repository.Store, actor Actor, item *models.BatchItem) error {
+	accounts := NewAccountServiceWithStore(tx).WithActor(actor)
+
+	var transaction *models.Transaction
+	var err error
+	switch item.Operation {
+	case models.TopUp:
+		transaction, err = accounts.TopUp(ctx, item.AccountID, item.Amount, models.TransactionDetails{ExternalRef: item.Reference})
+	case models.Charge:
+		transaction, err = accounts.Charge(ctx, item.AccountID, item.Amount, models.TransactionDetails{ExternalRef: item.Reference})
+	default:
+		err = fmt.Errorf("unknown operation %q", item.Operation)
+	}
+	if err != nil {
+		return err
+	}
+
+	item.Status = models.BatchItemSucceeded
+	item.TransactionRef = transaction.Ref
+	item.Error = ""
+	return tx.Batches().UpdateItem(ctx, item)
+}
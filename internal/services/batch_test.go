@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"wallet/internal/models"
+	"wallet/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// processBatch submits a batch and waits for it to be processed.
+func processBatch(t *testing.T, svc BatchService, mode models.BatchMode, items []models.BatchItem) *models.Batch {
+	t.Helper()
+	ctx := context.Background()
+	batch, err := svc.Submit(ctx, mode, items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc.(*batchService).runner.wg.Wait()
+
+	batch, err = svc.GetBatch(ctx, batch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return batch
+}
+
+func itemStatuses(batch *models.Batch) []models.BatchItemStatus {
+	statuses := make([]models.BatchItemStatus, len(batch.Items))
+	for i, item := range batch.Items {
+		statuses[i] = item.Status
+	}
+	return statuses
+}
+
+func balanceOf(t *testing.T, accounts AccountService, id uuid.UUID) float64 {
+	t.Helper()
+	account, err := accounts.GetAccountByID(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return account.Balance
+}
+
+func TestBestEffortBatch(t *testing.T) {
+	store := repository.NewMemoryStore()
+	accounts := NewAccountServiceWithStore(store)
+	account := newTestAccount(t, accounts)
+
+	batch := processBatch(t, NewBatchServiceWithStore(store), models.BatchBestEffort, []models.BatchItem{
+		{AccountID: account.ID, Operation: models.TopUp, Amount: 100, Reference: "PAY-1"},
+		{AccountID: account.ID, Operation: models.Charge, Amount: 150, Reference: "PAY-2"},
+		{AccountID: uuid.New(), Operation: models.TopUp, Amount: 10, Reference: "PAY-3"},
+		{AccountID: account.ID, Operation: models.Charge, Amount: 40, Reference: "PAY-4"},
+	})
+
+	want := []models.BatchItemStatus{models.BatchItemSucceeded, models.BatchItemFailed, models.BatchItemFailed, models.BatchItemSucceeded}
+	if batch.Status != models.BatchCompleted || batch.CompletedAt == nil {
+		t.Errorf("batch: got status %s want %s", batch.Status, models.BatchCompleted)
+	}
+	for i, got := range itemStatuses(batch) {
+		if got != want[i] {
+			t.Errorf("items[%d]: got %s want %s (%s)", i, got, want[i], batch.Items[i].Error)
+		}
+	}
+	if batch.Items[1].Error != ErrInsufficientFunds.Error() || batch.Items[0].TransactionRef == "" {
+		t.Errorf("items: got error %q and ref %q", batch.Items[1].Error, batch.Items[0].TransactionRef)
+	}
+	if got := balanceOf(t, accounts, account.ID); got != 60 {
+		t.Errorf("balance: got %v want %v", got, 60)
+	}
+}
+
+func TestAtomicBatchRollsBack(t *testing.T) {
+	store := repository.NewMemoryStore()
+	accounts := NewAccountServiceWithStore(store)
+	account := newTestAccount(t, accounts)
+
+	batch := processBatch(t, NewBatchServiceWithStore(store), models.BatchAtomic, []models.BatchItem{
+		{AccountID: account.ID, Operation: models.TopUp, Amount: 100, Reference: "PAY-1"},
+		{AccountID: account.ID, Operation: models.Charge, Amount: 150, Reference: "PAY-2"},
+		{AccountID: account.ID, Operation: models.TopUp, Amount: 5, Reference: "PAY-3"},
+	})
+
+	want := []models.BatchItemStatus{models.BatchItemSkipped, models.BatchItemFailed, models.BatchItemSkipped}
+	if batch.Status != models.BatchFailed {
+		t.Errorf("batch: got status %s want %s", batch.Status, models.BatchFailed)
+	}
+	for i, got := range itemStatuses(batch) {
+		if got != want[i] {
+			t.Errorf("items[%d]: got %s want %s", i, got, want[i])
+		}
+		if batch.Items[i].TransactionRef != "" {
+			t.Errorf("items[%d]: got transaction %s after a rollback", i, batch.Items[i].TransactionRef)
+		}
+	}
+	if got := balanceOf(t, accounts, account.ID); got != 0 {
+		t.Errorf("balance: got %v want 0", got)
+	}
+	transactions, _ := store.Transactions().ListByAccountID(context.Background(), account.ID)
+	if len(transactions) != 0 {
+		t.Errorf("transactions: got %d want 0", len(transactions))
+	}
+}
+
+func TestAtomicBatchCommits(t *testing.T) {
+	store := repository.NewMemoryStore()
+	accounts := NewAccountServiceWithStore(store)
+	account := newTestAccount(t, accounts)
+
+	batch := processBatch(t, NewBatchServiceWithStore(store), models.BatchAtomic, []models.BatchItem{
+		{AccountID: account.ID, Operation: models.TopUp, Amount: 100, Reference: "PAY-1"},
+		{AccountID: account.ID, Operation: models.Charge, Amount: 99.99, Reference: "PAY-2"},
+	})
+
+	if batch.Status != models.BatchCompleted {
+		t.Errorf("batch: got status %s want %s", batch.Status, models.BatchCompleted)
+	}
+	for i, item := range batch.Items {
+		if item.Status != models.BatchItemSucceeded || item.TransactionRef == "" {
+			t.Errorf("items[%d]: got %s with ref %q want succeeded with a ref", i, item.Status, item.TransactionRef)
+		}
+	}
+	if got := balanceOf(t, accounts, account.ID); got != 0.01 {
+		t.Errorf("balance: got %v want %v", got, 0.01)
+	}
+}
+
+func TestBatchRejectsDuplicateReferences(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	account := newTestAccount(t, NewAccountServiceWithStore(store))
+	svc := NewBatchServiceWithStore(store)
+	item := func(reference string) models.BatchItem {
+		return models.BatchItem{AccountID: account.ID, Operation: models.TopUp, Amount: 1, Reference: reference}
+	}
+
+	_, err := svc.Submit(ctx, models.BatchBestEffort, []models.BatchItem{item("PAY-1"), item("PAY-2"), item("PAY-1")})
+	var invalid *ValidationError
+	if !errors.As(err, &invalid) || invalid.Field != "items[2].reference" {
+		t.Errorf("duplicate within a batch: got error %v want a validation error of items[2].reference", err)
+	}
+
+	processBatch(t, svc, models.BatchBestEffort, []models.BatchItem{item("PAY-1")})
+	if _, err := svc.Submit(ctx, models.BatchAtomic, []models.BatchItem{item("PAY-2"), item("PAY-1")}); !errors.Is(err, ErrDuplicateReference) {
+		t.Errorf("duplicate across batches: got error %v want %v", err, ErrDuplicateReference)
+	}
+}
@@ -0,0 +1,37 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"wallet/internal/repository"
+)
+
+// Deadlines of a single service operation, applied on top of any deadline
+// the caller's context already carries, so a stuck database cannot pin the
+// calling goroutine and its connection indefinitely.
+const (
+	readTimeout  = 5 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
+// operationError is the error a service operation reports for err: the
+// context error that caused it, or ErrConcurrentModification for a write
+// that gave up waiting for another writer to release the database.
+func operationError(ctx context.Context, err error) error {
+	err = contextError(ctx, err)
+	if errors.Is(err, repository.ErrBusy) && !errors.Is(err, ErrConcurrentModification) {
+		return fmt.Errorf("%w: %v", ErrConcurrentModification, err)
+	}
+	return err
+}
+
+// contextError reports a canceled or expired ctx instead of the driver error
+// it caused, so callers can tell timeouts and disconnects from failures.
+func contextError(ctx context.Context, err error) error {
+	if err == nil || ctx.Err() == nil || errors.Is(err, ctx.Err()) {
+		return err
+	}
+	return fmt.Errorf("%w: %v", ctx.Err(), err)
+}
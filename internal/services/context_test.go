@@ -0,0 +1,73 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+	"wallet/internal/models"
+)
+
+func TestCanceledOperations(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	accounts := NewAccountService(db)
+	account := newTestAccount(t, accounts)
+	if _, err := accounts.TopUp(ctx, account.ID, 10, models.TransactionDetails{}); err != nil {
+		t.Fatal(err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := accounts.TopUp(canceled, account.ID, 5, models.TransactionDetails{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("top-up: got error %v want %v", err, context.Canceled)
+	}
+	if _, err := accounts.GetAccountByID(canceled, account.ID); !errors.Is(err, context.Canceled) {
+		t.Errorf("get account: got error %v want %v", err, context.Canceled)
+	}
+	if _, err := NewTransactionService(db).ListTransactions(canceled, account.ID, "", 10); !errors.Is(err, context.Canceled) {
+		t.Errorf("list transactions: got error %v want %v", err, context.Canceled)
+	}
+
+	// A database held by another writer fails the caller's deadline rather
+	// than pinning it for the whole busy timeout
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		t.Fatal(err)
+	}
+	defer conn.ExecContext(ctx, "ROLLBACK")
+
+	deadline, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = accounts.Charge(deadline, account.ID, 5, models.TransactionDetails{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("charge on a locked database: got error %v want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("charge on a locked database took %v", elapsed)
+	}
+	// Reads do not wait for the writer
+	if _, err := NewStatementService(db).Statement(ctx, account.ID, start.Add(-time.Hour), start); err != nil {
+		t.Errorf("statement of a locked database: got error %v", err)
+	}
+
+	if _, err := conn.ExecContext(ctx, "ROLLBACK"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := accounts.GetAccountByID(ctx, account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Balance != 10 {
+		t.Errorf("got balance %v want 10 after the failed operations", got.Balance)
+	}
+}
@@ -0,0 +1,457 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/big"
+	"os"
+	"strings"
+	"wallet/internal/banking"
+	"wallet/internal/inbound"
+	"wallet/internal/models"
+	"wallet/internal/repository"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+)
+
+// Virtual IBANs are issued in DefaultVirtualIBANCountry under
+// DefaultVirtualIBANBankCode unless VIRTUAL_IBAN_COUNTRY and
+// VIRTUAL_IBAN_BANK_CODE say otherwise. The rest of the account number is
+// drawn at random.
+const (
+	DefaultVirtualIBANCountry  = "DE"
+	DefaultVirtualIBANBankCode = "00000000"
+)
+
+// minVirtualAccountDigits is the fewest random digits a virtual IBAN may
+// have, so that drawing an unused one stays cheap.
+const minVirtualAccountDigits = 6
+
+// maxVirtualIBANDraws bounds the numbers drawn for a new virtual IBAN before
+// giving up.
+const maxVirtualIBANDraws = 10
+
+// newVirtualIBAN draws a virtual IBAN that no account in tx has.
+func newVirtualIBAN(ctx context.Context, tx repository.Store) (string, error) {
+	country, bankCode := os.Getenv("VIRTUAL_IBAN_COUNTRY"), os.Getenv("VIRTUAL_IBAN_BANK_CODE")
+	if country == "" {
+		country = DefaultVirtualIBANCountry
+	}
+	if bankCode == "" {
+		bankCode = DefaultVirtualIBANBankCode
+	}
+	digits := banking.Length(country) - 4 - len(bankCode)
+	if digits < minVirtualAccountDigits {
+		return "", fmt.Errorf("VIRTUAL_IBAN_BANK_CODE %q leaves fewer than %d digits for account numbers in %s IBANs", bankCode, minVirtualAccountDigits, country)
+	}
+
+	for range maxVirtualIBANDraws {
+		number, err := rand.Int(rand.Reader, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil))
+		if err != nil {
+			return "", err
+		}
+		iban, err := banking.NewIBAN(country, fmt.Sprintf("%s%0*d", bankCode, digits, number))
+		if err != nil {
+			return "", err
+		}
+		_, err = tx.Accounts().GetByVirtualIBAN(ctx, iban)
+		if errors.Is(err, repository.ErrNotFound) {
+			return iban, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", errors.New("no unused virtual IBAN found")
+}
+
+// DepositService receives the bank transfers sent to the accounts' virtual
+// IBANs. A transfer is credited to its account as a top-up, or parked in the
+// suspense queue until an operator assigns it to one.
+type DepositService interface {
+	// Receive records a bank transfer and credits it to the account whose
+	// virtual IBAN it was sent to or quotes. Transfers that match no open
+	// account are left unmatched.
+	Receive(ctx context.Context, credit inbound.Credit) (*models.Deposit, error)
+	// Import receives every credit of a camt.052, camt.053 or camt.054
+	// report.
+	Import(ctx context.Context, r io.Reader) (*DepositImport, error)
+	GetDeposit(ctx context.Context, id uuid.UUID) (*models.Deposit, error)
+	// ListDeposits returns the deposits in the given status, oldest first.
+	// The unmatched deposits are the suspense queue.
+	ListDeposits(ctx context.Context, status models.DepositStatus) ([]models.Deposit, error)
+	// Assign credits an unmatched deposit to the given account.
+	Assign(ctx context.Context, depositID, accountID uuid.UUID) (*models.Deposit, error)
+	// AssignVirtualIBANs issues virtual IBANs to the accounts opened before
+	// they existed and returns how many it issued.
+	AssignVirtualIBANs(ctx context.Context) (int, error)
+	// WithActor returns a copy of the service that attributes the changes
+	// it makes to the given actor in the audit log.
+	WithActor(actor Actor) DepositService
+}
+
+// DepositImport is the outcome of importing a bank report.
+type DepositImport struct {
+	Matched   int `json:"matched"`
+	Unmatched int `json:"unmatched"`
+	// Duplicates counts the credits received before, e.g. by an earlier
+	// import of the same report.
+	Duplicates int                  `json:"duplicates"`
+	Errors     []DepositImportError `json:"errors"`
+}
+
+// DepositImportError is a credit of a bank report that could not be
+// received.
+type DepositImportError struct {
+	Reference string `json:"reference"`
+	Error     string `json:"error"`
+}
+
+type depositService struct {
+	store repository.Store
+	audit AuditService
+	actor Actor
+}
+
+func NewDepositService(db *gorm.DB) DepositService {
+	return &depositService{
+		store: repository.NewGormStore(db),
+		audit: NewAuditService(db),
+		actor: SystemActor,
+	}
+}
+
+// NewDepositServiceWithStore returns a DepositService that keeps deposits
+// and accounts in store.
+func NewDepositServiceWithStore(store repository.Store) DepositService {
+	return &depositService{
+		store: store,
+		audit: &auditService{key: auditKeyFromEnv()},
+		actor: SystemActor,
+	}
+}
+
+func (s *depositService) WithActor(actor Actor) DepositService {
+	clone := *s
+	clone.actor = actor
+	return &clone
+}
+
+// depositAuditState is the audited view of a deposit.
+type depositAuditState struct {
+	Reference     string               `json:"reference"`
+	Amount        float64              `json:"amount"`
+	Currency      string               `json:"currency"`
+	Status        models.DepositStatus `json:"status"`
+	AccountID     string               `json:"account_id,omitempty"`
+	TransactionID string               `json:"transaction_id,omitempty"`
+	Reason        string               `json:"reason,omitempty"`
+}
+
+func depositAudit(d *models.Deposit) depositAuditState {
+	state := depositAuditState{Reference: d.Reference, Amount: d.Amount, Currency: d.Currency, Status: d.Status, Reason: d.Reason}
+	if d.AccountID != nil {
+		state.AccountID = d.AccountID.String()
+	}
+	if d.TransactionID != nil {
+		state.TransactionID = d.TransactionID.String()
+	}
+	return state
+}
+
+func (s *depositService) Receive(ctx context.Context, credit inbound.Credit) (_ *models.Deposit, err error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	ctx, span := accountTracer.Start(ctx, "DepositService.Receive")
+	defer endSpan(ctx, span, &err)
+
+	if err := validateCredit(&credit); err != nil {
+		return nil, err
+	}
+	deposit := &models.Deposit{
+		Reference:    credit.Reference,
+		Amount:       credit.Amount,
+		Currency:     credit.Currency,
+		CreditorIBAN: credit.CreditorIBAN,
+		DebtorName:   credit.DebtorName,
+		DebtorIBAN:   credit.DebtorIBAN,
+		Remittance:   credit.Remittance,
+		BookedAt:     credit.BookedAt,
+		Status:       models.DepositUnmatched,
+	}
+
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		account, reason, err := matchDeposit(ctx, tx, credit)
+		if err != nil {
+			return err
+		}
+		if account != nil {
+			transaction, err := NewAccountServiceWithStore(tx).WithActor(s.actor).TopUp(ctx, account.ID, credit.Amount, depositDetails(deposit))
+			switch {
+			case err == nil:
+				deposit.Status = models.DepositMatched
+				deposit.AccountID = &account.ID
+				deposit.TransactionID = &transaction.ID
+			case errors.Is(err, ErrAccountFrozen) || errors.Is(err, ErrAccountNotOpen):
+				// The account cannot take it, so an operator has to decide
+				reason = fmt.Sprintf("account %s: %v", account.ID, err)
+			default:
+				return err
+			}
+		}
+		deposit.Reason = reason
+
+		if err := tx.Deposits().Create(ctx, deposit); err != nil {
+			if errors.Is(err, repository.ErrDuplicate) {
+				return ErrDuplicateReference
+			}
+			return err
+		}
+		return s.audit.Record(ctx, tx, s.actor, AuditEvent{
+			Action:     AuditDepositReceived,
+			EntityType: "deposit",
+			EntityID:   deposit.ID.String(),
+			After:      depositAudit(deposit),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.String("deposit.status", string(deposit.Status)))
+	slog.InfoContext(ctx, "deposit received", "deposit_id", deposit.ID, "reference", deposit.Reference, "amount", deposit.Amount, "status", deposit.Status, "account_id", deposit.AccountID, "reason", deposit.Reason, "actor", s.actor.Name)
+	return deposit, nil
+}
+
+// validateCredit checks a received credit and normalises it.
+func validateCredit(c *inbound.Credit) error {
+	c.Reference = strings.TrimSpace(c.Reference)
+	if c.Reference == "" {
+		return &ValidationError{Field: "reference", Message: "the bank's reference is required"}
+	}
+	if c.Amount <= 0 {
+		return ErrInvalidAmount
+	}
+	c.Currency = strings.ToUpper(strings.TrimSpace(c.Currency))
+	if c.Currency == "" {
+		c.Currency = Currency()
+	}
+	c.CreditorIBAN = banking.NormalizeIBAN(c.CreditorIBAN)
+	c.DebtorIBAN = banking.NormalizeIBAN(c.DebtorIBAN)
+	c.DebtorName = strings.TrimSpace(c.DebtorName)
+	c.Remittance = strings.TrimSpace(c.Remittance)
+	return nil
+}
+
+// depositDetails describes the top-up a deposit is credited by with the
+// transfer's remittance information, or its sender, and the bank's reference.
+func depositDetails(deposit *models.Deposit) models.TransactionDetails {
+	description := deposit.Remittance
+	if description == "" {
+		description = "Bank transfer"
+		if deposit.DebtorName != "" {
+			description += " from " + deposit.DebtorName
+		}
+	}
+	return models.TransactionDetails{
+		Description: truncateText(description, MaxDescriptionLength),
+		ExternalRef: truncateText(deposit.Reference, MaxExternalRefLength),
+	}
+}
+
+// matchDeposit finds the account a credit is for: the one whose virtual
+// IBAN it was sent to or, failing that, whose virtual IBAN its remittance
+// information quotes. Otherwise it tells why there is none.
+func matchDeposit(ctx context.Context, tx repository.Store, credit inbound.Credit) (*models.Account, string, error) {
+	if credit.Currency != Currency() {
+		return nil, fmt.Sprintf("accounts hold %s, not %s", Currency(), credit.Currency), nil
+	}
+
+	candidates := quotedIBANs(credit.Remittance)
+	if credit.CreditorIBAN != "" {
+		candidates = append([]string{credit.CreditorIBAN}, candidates...)
+	}
+	if len(candidates) == 0 {
+		return nil, "no virtual IBAN given", nil
+	}
+	for _, iban := range candidates {
+		account, err := tx.Accounts().GetByVirtualIBAN(ctx, iban)
+		if err == nil {
+			return account, "", nil
+		}
+		if !errors.Is(err, repository.ErrNotFound) {
+			return nil, "", err
+		}
+	}
+	return nil, "no account has the virtual IBAN", nil
+}
+
+// quotedIBANs returns the valid IBANs in a text, printed with or without
+// spaces.
+func quotedIBANs(text string) []string {
+	compact := banking.NormalizeIBAN(text)
+	var ibans []string
+	for i := 0; i+4 <= len(compact); i++ {
+		if !isLetter(compact[i]) || !isLetter(compact[i+1]) || !isDigit(compact[i+2]) || !isDigit(compact[i+3]) {
+			continue
+		}
+		length := banking.Length(compact[i : i+2])
+		if length == 0 || i+length > len(compact) {
+			continue
+		}
+		if iban, err := banking.ValidateIBAN(compact[i : i+length]); err == nil {
+			ibans = append(ibans, iban)
+			i += length - 1
+		}
+	}
+	return ibans
+}
+
+func isLetter(c byte) bool { return c >= 'A' && c <= 'Z' }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+
+func (s *depositService) Import(ctx context.Context, r io.Reader) (*DepositImport, error) {
+	credits, err := inbound.ParseCamt(r)
+	if err != nil {
+		if errors.Is(err, inbound.ErrInvalidReport) {
+			return nil, &ValidationError{Field: "file", Message: err.Error()}
+		}
+		return nil, err
+	}
+
+	report := &DepositImport{Errors: []DepositImportError{}}
+	for _, credit := range credits {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		deposit, err := s.Receive(ctx, credit)
+		switch {
+		case errors.Is(err, ErrDuplicateReference):
+			report.Duplicates++
+		case err != nil:
+			report.Errors = append(report.Errors, DepositImportError{Reference: credit.Reference, Error: err.Error()})
+		case deposit.Status == models.DepositMatched:
+			report.Matched++
+		default:
+			report.Unmatched++
+		}
+	}
+
+	slog.InfoContext(ctx, "bank report imported", "matched", report.Matched, "unmatched", report.Unmatched, "duplicates", report.Duplicates, "errors", len(report.Errors), "actor", s.actor.Name)
+	return report, nil
+}
+
+func (s *depositService) GetDeposit(ctx context.Context, id uuid.UUID) (_ *models.Deposit, err error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+	ctx, span := accountTracer.Start(ctx, "DepositService.GetDeposit")
+	defer endSpan(ctx, span, &err)
+
+	deposit, err := s.store.Deposits().GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrDepositNotFound)
+	}
+	return deposit, nil
+}
+
+func (s *depositService) ListDeposits(ctx context.Context, status models.DepositStatus) (_ []models.Deposit, err error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+	ctx, span := accountTracer.Start(ctx, "DepositService.ListDeposits")
+	defer endSpan(ctx, span, &err)
+
+	return s.store.Deposits().ListByStatus(ctx, status)
+}
+
+func (s *depositService) Assign(ctx context.Context, depositID, accountID uuid.UUID) (_ *models.Deposit, err error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	ctx, span := accountTracer.Start(ctx, "DepositService.Assign")
+	defer endSpan(ctx, span, &err)
+	span.SetAttributes(attribute.String("account.id", accountID.String()))
+
+	var deposit *models.Deposit
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		var err error
+		if deposit, err = tx.Deposits().GetByID(ctx, depositID); err != nil {
+			return notFound(err, ErrDepositNotFound)
+		}
+		if deposit.Status != models.DepositUnmatched {
+			return ErrDepositNotUnmatched
+		}
+		if deposit.Currency != Currency() {
+			return &ValidationError{Field: "currency", Message: fmt.Sprintf("the deposit is in %s but accounts hold %s", deposit.Currency, Currency())}
+		}
+
+		transaction, err := NewAccountServiceWithStore(tx).WithActor(s.actor).TopUp(ctx, accountID, deposit.Amount, depositDetails(deposit))
+		if err != nil {
+			return err
+		}
+		before := depositAudit(deposit)
+		deposit.Status = models.DepositAssigned
+		deposit.AccountID = &accountID
+		deposit.TransactionID = &transaction.ID
+		if err := tx.Deposits().Update(ctx, deposit); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, tx, s.actor, AuditEvent{
+			Action:     AuditDepositAssigned,
+			EntityType: "deposit",
+			EntityID:   deposit.ID.String(),
+			Before:     before,
+			After:      depositAudit(deposit),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "deposit assigned", "deposit_id", deposit.ID, "account_id", accountID, "transaction_id", deposit.TransactionID, "actor", s.actor.Name)
+	return deposit, nil
+}
+
+// virtualIBANBatchSize is how many accounts AssignVirtualIBANs loads at a
+// time.
+const virtualIBANBatchSize = 500
+
+func (s *depositService) AssignVirtualIBANs(ctx context.Context) (int, error) {
+	assigned := 0
+	for {
+		accounts, err := s.store.Accounts().ListWithoutVirtualIBAN(ctx, virtualIBANBatchSize)
+		if err != nil || len(accounts) == 0 {
+			return assigned, err
+		}
+		for _, account := range accounts {
+			err := s.store.Transaction(ctx, func(tx repository.Store) error {
+				iban, err := newVirtualIBAN(ctx, tx)
+				if err != nil {
+					return err
+				}
+				if err := tx.Accounts().SetVirtualIBAN(ctx, account.ID, iban); err != nil {
+					return err
+				}
+				// Audit the account as it is now, not as it was listed
+				current, err := tx.Accounts().GetByID(ctx, account.ID)
+				if err != nil {
+					return err
+				}
+				return s.audit.Record(ctx, tx, s.actor, AuditEvent{
+					Action:     AuditVirtualIBANAssigned,
+					EntityType: "account",
+					EntityID:   account.ID.String(),
+					After:      accountAuditState{Balance: current.Balance, Status: current.Status, VirtualIBAN: iban},
+				})
+			})
+			if err != nil {
+				return assigned, err
+			}
+			assigned++
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"wallet/internal/banking"
+	"wallet/internal/inbound"
+	"wallet/internal/models"
+	"wallet/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+func TestDeposits(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	accounts := NewAccountServiceWithStore(store)
+	deposits := NewDepositServiceWithStore(store)
+	account := newTestAccount(t, accounts)
+	if account.VirtualIBAN == nil {
+		t.Fatal("new account: got no virtual IBAN")
+	}
+	iban := *account.VirtualIBAN
+	if _, err := banking.ValidateIBAN(iban); err != nil {
+		t.Fatalf("virtual IBAN %s: %v", iban, err)
+	}
+
+	deposit, err := deposits.Receive(ctx, inbound.Credit{Reference: "BANK-1", Amount: 40, CreditorIBAN: iban})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deposit.Status != models.DepositMatched || deposit.AccountID == nil || *deposit.AccountID != account.ID {
+		t.Errorf("transfer to the virtual IBAN: got %s for account %v want matched to %s", deposit.Status, deposit.AccountID, account.ID)
+	}
+	if _, err := deposits.Receive(ctx, inbound.Credit{Reference: "BANK-1", Amount: 40, CreditorIBAN: iban}); !errors.Is(err, ErrDuplicateReference) {
+		t.Errorf("receiving a transfer twice: got error %v want %v", err, ErrDuplicateReference)
+	}
+
+	// Senders paying into the pooled account quote the virtual IBAN
+	printed := iban[:4] + " " + iban[4:8] + " " + iban[8:]
+	deposit, err = deposits.Receive(ctx, inbound.Credit{Reference: "BANK-2", Amount: 10, CreditorIBAN: "DE89370400440532013000", Remittance: "top up " + printed + " thanks"})
+	if err != nil || deposit.Status != models.DepositMatched {
+		t.Errorf("transfer quoting the virtual IBAN: got %v, %v want matched", deposit, err)
+	}
+	checkBalances(t, accounts, account, 50, 50)
+
+	unknown, err := deposits.Receive(ctx, inbound.Credit{Reference: "BANK-3", Amount: 5, CreditorIBAN: "DE89370400440532013000"})
+	if err != nil || unknown.Status != models.DepositUnmatched || unknown.Reason == "" {
+		t.Fatalf("transfer to no account: got %v, %v want unmatched with a reason", unknown, err)
+	}
+	if _, err := accounts.Freeze(ctx, account.ID, "investigation"); err != nil {
+		t.Fatal(err)
+	}
+	frozen, err := deposits.Receive(ctx, inbound.Credit{Reference: "BANK-4", Amount: 7, CreditorIBAN: iban})
+	if err != nil || frozen.Status != models.DepositUnmatched {
+		t.Fatalf("transfer to a frozen account: got %v, %v want unmatched", frozen, err)
+	}
+	if _, err := accounts.Unfreeze(ctx, account.ID, "investigation closed"); err != nil {
+		t.Fatal(err)
+	}
+
+	suspense, err := deposits.ListDeposits(ctx, models.DepositUnmatched)
+	if err != nil || len(suspense) != 2 {
+		t.Fatalf("suspense queue: got %d deposits, %v want 2", len(suspense), err)
+	}
+	assigned, err := deposits.Assign(ctx, unknown.ID, account.ID)
+	if err != nil || assigned.Status != models.DepositAssigned || assigned.TransactionID == nil {
+		t.Fatalf("assigning a deposit: got %v, %v want assigned with a transaction", assigned, err)
+	}
+	if _, err := deposits.Assign(ctx, unknown.ID, account.ID); !errors.Is(err, ErrDepositNotUnmatched) {
+		t.Errorf("assigning a deposit twice: got error %v want %v", err, ErrDepositNotUnmatched)
+	}
+	checkBalances(t, accounts, account, 55, 55)
+
+	report := `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.054.001.02"><BkToCstmrDbtCdtNtfctn><Ntfctn>
+<Acct><Id><IBAN>DE89370400440532013000</IBAN></Id></Acct>
+<Ntry><Amt Ccy="USD">30.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts>BOOK</Sts><BookgDt><Dt>2025-03-03</Dt></BookgDt><AcctSvcrRef>BATCH-9</AcctSvcrRef>
+<NtryDtls>
+<TxDtls><AmtDtls><TxAmt><Amt Ccy="USD">20.00</Amt></TxAmt></AmtDtls><RltdPties><Dbtr><Nm>Jane Doe</Nm></Dbtr><CdtrAcct><Id><IBAN>` + iban + `</IBAN></Id></CdtrAcct></RltdPties></TxDtls>
+<TxDtls><AmtDtls><TxAmt><Amt Ccy="USD">10.00</Amt></TxAmt></AmtDtls><RmtInf><Ustrd>no reference</Ustrd></RmtInf></TxDtls>
+</NtryDtls></Ntry>
+<Ntry><Amt Ccy="USD">99.00</Amt><CdtDbtInd>DBIT</CdtDbtInd><Sts>BOOK</Sts><AcctSvcrRef>FEE-1</AcctSvcrRef></Ntry>
+<Ntry><Amt Ccy="USD">99.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts>PDNG</Sts><AcctSvcrRef>LATER-1</AcctSvcrRef></Ntry>
+</Ntfctn></BkToCstmrDbtCdtNtfctn></Document>`
+	imported, err := deposits.Import(ctx, strings.NewReader(report))
+	if err != nil || imported.Matched != 1 || imported.Unmatched != 1 || len(imported.Errors) != 0 {
+		t.Fatalf("importing a camt.054 notification: got %+v, %v want 1 matched and 1 unmatched", imported, err)
+	}
+	if imported, err = deposits.Import(ctx, strings.NewReader(report)); err != nil || imported.Duplicates != 2 {
+		t.Errorf("importing a notification twice: got %+v, %v want 2 duplicates", imported, err)
+	}
+	checkBalances(t, accounts, account, 75, 75)
+
+	// Accounts opened before virtual IBANs get one
+	legacy := &models.Account{ID: uuid.New(), UserID: account.UserID}
+	if err := store.Accounts().Create(ctx, legacy); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := deposits.AssignVirtualIBANs(ctx); err != nil || n != 1 {
+		t.Errorf("assigning virtual IBANs: got %d, %v want 1", n, err)
+	}
+	if legacy, _ = store.Accounts().GetByID(ctx, legacy.ID); legacy.VirtualIBAN == nil || *legacy.VirtualIBAN == iban {
+		t.Errorf("legacy account: got virtual IBAN %v want a new one", legacy.VirtualIBAN)
+	}
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"errors"
+	"wallet/internal/payments"
+	"wallet/internal/repository"
+)
+
+// Domain errors returned by the services. Callers match them with errors.Is
+// and must not rely on their messages.
+var (
+	// ErrInvalidAccountID is returned for an account reference that is
+	// neither an account ID nor an account number with a valid check digit.
+	ErrInvalidAccountID     = errors.New("invalid account ID or number")
+	ErrAccountNotFound      = errors.New("account not found")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrTransactionNotFound  = errors.New("transaction not found")
+	ErrDuplicateUser        = errors.New("user already exists")
+	ErrDuplicateTransaction = errors.New("duplicate transaction detected")
+	ErrInsufficientFunds    = errors.New("insufficient balance")
+	ErrAccountNotOpen       = errors.New("account is not open")
+	ErrAccountFrozen        = errors.New("account is frozen")
+	ErrAccountAlreadyFrozen = errors.New("account is already frozen")
+	ErrAccountNotFrozen     = errors.New("account is not frozen")
+	// ErrInvalidTransition is returned for a transaction status change that
+	// is not allowed, such as settling a transaction twice.
+	ErrInvalidTransition = errors.New("invalid transaction status change")
+
+	// ErrConcurrentModification is returned when a stream was appended to
+	// after the aggregate appending to it was loaded.
+	ErrConcurrentModification = errors.New("account was modified concurrently, please retry")
+
+	ErrBatchNotFound = errors.New("batch not found")
+	// ErrDuplicateReference is returned for a batch that reuses the
+	// reference of an item submitted before.
+	ErrDuplicateReference = errors.New("reference already submitted")
+
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrPaymentDeclined is returned for a top-up whose payment failed.
+	ErrPaymentDeclined = errors.New("payment declined")
+	// ErrPaymentProvider is returned when the payment provider could not be
+	// reached or refused a request.
+	ErrPaymentProvider = errors.New("payment provider error")
+	// ErrInvalidWebhook is returned for a webhook that is malformed or not
+	// signed by its provider.
+	ErrInvalidWebhook = payments.ErrInvalidWebhook
+
+	ErrBeneficiaryNotFound = errors.New("beneficiary not found")
+	ErrWithdrawalNotFound  = errors.New("withdrawal not found")
+
+	ErrDepositNotFound = errors.New("deposit not found")
+	// ErrDepositNotUnmatched is returned for assigning a deposit that is not
+	// in the suspense queue.
+	ErrDepositNotUnmatched = errors.New("deposit is not in the suspense queue")
+
+	ErrAdjustmentNotFound = errors.New("adjustment not found")
+	// ErrAdjustmentNotPending is returned for approving or rejecting an
+	// adjustment that has already been reviewed.
+	ErrAdjustmentNotPending = errors.New("adjustment is not pending")
+	// ErrSelfApproval is returned for an operator approving their own
+	// adjustment.
+	ErrSelfApproval = errors.New("adjustments must be approved by another operator")
+
+	// ErrAuditKeyMissing is returned for verifying an audit log with keyed
+	// entries without the key they were recorded with.
+	ErrAuditKeyMissing = errors.New("the audit log has keyed entries but AUDIT_HMAC_KEY is not set")
+
+	// ErrInvalidCursor is returned for a page cursor that was not issued by
+	// ListTransactions.
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// Invalid arguments to the services.
+var (
+	ErrInvalidAmount  = &ValidationError{Field: "amount", Message: "amount must be greater than 0"}
+	ErrZeroAdjustment = &ValidationError{Field: "amount", Message: "amount must not be 0"}
+	ErrReasonRequired = &ValidationError{Field: "reason", Message: "a reason is required"}
+	ErrNoteRequired   = &ValidationError{Field: "note", Message: "a note is required"}
+	ErrFutureSnapshot = &ValidationError{Field: "day", Message: "cannot snapshot a day that has not ended"}
+	ErrInvalidPeriod  = &ValidationError{Field: "to", Message: "to must be after from"}
+)
+
+// ValidationError reports an invalid argument. Match a specific one with
+// errors.Is, or any of them with errors.As.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// notFound translates the repositories' not-found error into the given domain
+// error and passes any other error through.
+func notFound(err, domainErr error) error {
+	if errors.Is(err, repository.ErrNotFound) {
+		return domainErr
+	}
+	return err
+}
@@ -0,0 +1,249 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+	"wallet/internal/models"
+	"wallet/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+// Account event types.
+const (
+	EventAccountOpened   = "AccountOpened"
+	EventFundsDeposited  = "FundsDeposited"
+	EventFundsCharged    = "FundsCharged"
+	EventFundsAdjusted   = "FundsAdjusted"
+	EventAccountFrozen   = "AccountFrozen"
+	EventAccountUnfrozen = "AccountUnfrozen"
+
+	EventTransactionPosted   = "TransactionPosted"
+	EventTransactionFailed   = "TransactionFailed"
+	EventTransactionReversed = "TransactionReversed"
+)
+
+// AccountOpened starts an account's stream. Accounts opened before account
+// numbers or virtual IBANs were issued have none.
+type AccountOpened struct {
+	UserID      uuid.UUID `json:"user_id"`
+	Number      string    `json:"number,omitempty"`
+	VirtualIBAN string    `json:"virtual_iban,omitempty"`
+}
+
+// FundsDeposited credits an account. Reason is only set for manual
+// adjustments recorded before FundsAdjusted, and the details only as far as
+// the client gave them. A pending deposit only credits the account once it
+// is posted.
+type FundsDeposited struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+	Ref           string    `json:"ref"`
+	Amount        float64   `json:"amount"`
+	Reason        string    `json:"reason,omitempty"`
+	Pending       bool      `json:"pending,omitempty"`
+
+	models.TransactionDetails
+}
+
+// FundsCharged debits an account. Reason is only set for manual adjustments
+// recorded before FundsAdjusted, and the details only as far as the client
+// gave them. A pending charge holds the amount against the available balance
+// until it is posted or fails.
+type FundsCharged struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+	Ref           string    `json:"ref"`
+	Amount        float64   `json:"amount"`
+	Reason        string    `json:"reason,omitempty"`
+	Pending       bool      `json:"pending,omitempty"`
+
+	models.TransactionDetails
+}
+
+// FundsAdjusted credits a positive or debits a negative amount on an
+// account, by hand of a finance operator.
+type FundsAdjusted struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+	Ref           string    `json:"ref"`
+	Amount        float64   `json:"amount"`
+	Reason        string    `json:"reason"`
+}
+
+// TransactionPosted settles a pending transaction.
+type TransactionPosted struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+}
+
+// TransactionFailed settles a pending transaction without moving funds,
+// releasing the hold of a pending charge.
+type TransactionFailed struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+	Reason        string    `json:"reason,omitempty"`
+}
+
+// TransactionReversed undoes a posted transaction with a posted transaction
+// of the opposite type, ReversalID.
+type TransactionReversed struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+	ReversalID    uuid.UUID `json:"reversal_id"`
+	Ref           string    `json:"ref"`
+	Reason        string    `json:"reason"`
+}
+
+// AccountFrozen blocks top-ups and charges on an account.
+type AccountFrozen struct {
+	Reason string `json:"reason"`
+}
+
+// AccountUnfrozen lifts a freeze.
+type AccountUnfrozen struct {
+	Reason string `json:"reason"`
+}
+
+// eventType returns the stored type name of an event payload.
+func eventType(payload any) (string, error) {
+	switch payload.(type) {
+	case AccountOpened:
+		return EventAccountOpened, nil
+	case FundsDeposited:
+		return EventFundsDeposited, nil
+	case FundsCharged:
+		return EventFundsCharged, nil
+	case FundsAdjusted:
+		return EventFundsAdjusted, nil
+	case AccountFrozen:
+		return EventAccountFrozen, nil
+	case AccountUnfrozen:
+		return EventAccountUnfrozen, nil
+	case TransactionPosted:
+		return EventTransactionPosted, nil
+	case TransactionFailed:
+		return EventTransactionFailed, nil
+	case TransactionReversed:
+		return EventTransactionReversed, nil
+	}
+	return "", fmt.Errorf("unknown event payload %T", payload)
+}
+
+// decodeEvent returns the typed payload of a stored event.
+func decodeEvent(e models.Event) (any, error) {
+	var payload any
+	var err error
+	switch e.Type {
+	case EventAccountOpened:
+		var p AccountOpened
+		err = json.Unmarshal([]byte(e.Data), &p)
+		payload = p
+	case EventFundsDeposited:
+		var p FundsDeposited
+		err = json.Unmarshal([]byte(e.Data), &p)
+		payload = p
+	case EventFundsCharged:
+		var p FundsCharged
+		err = json.Unmarshal([]byte(e.Data), &p)
+		payload = p
+	case EventFundsAdjusted:
+		var p FundsAdjusted
+		err = json.Unmarshal([]byte(e.Data), &p)
+		payload = p
+	case EventAccountFrozen:
+		var p AccountFrozen
+		err = json.Unmarshal([]byte(e.Data), &p)
+		payload = p
+	case EventAccountUnfrozen:
+		var p AccountUnfrozen
+		err = json.Unmarshal([]byte(e.Data), &p)
+		payload = p
+	case EventTransactionPosted:
+		var p TransactionPosted
+		err = json.Unmarshal([]byte(e.Data), &p)
+		payload = p
+	case EventTransactionFailed:
+		var p TransactionFailed
+		err = json.Unmarshal([]byte(e.Data), &p)
+		payload = p
+	case EventTransactionReversed:
+		var p TransactionReversed
+		err = json.Unmarshal([]byte(e.Data), &p)
+		payload = p
+	default:
+		return nil, fmt.Errorf("unknown event type %q at position %d", e.Type, e.Position)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decode %s at position %d: %w", e.Type, e.Position, err)
+	}
+	return payload, nil
+}
+
+type EventStore interface {
+	// Load returns the events of a stream in version order.
+	Load(ctx context.Context, tx repository.Store, streamID uuid.UUID) ([]models.Event, error)
+	// Append adds events to a stream, failing with ErrConcurrentModification
+	// unless the stream is still at expectedVersion.
+	Append(ctx context.Context, tx repository.Store, streamID uuid.UUID, expectedVersion int, payloads ...any) ([]models.Event, error)
+}
+
+type eventStore struct{}
+
+func NewEventStore() EventStore {
+	return &eventStore{}
+}
+
+func (s *eventStore) Load(ctx context.Context, tx repository.Store, streamID uuid.UUID) ([]models.Event, error) {
+	return tx.Events().Stream(ctx, streamID)
+}
+
+func (s *eventStore) Append(ctx context.Context, tx repository.Store, streamID uuid.UUID, expectedVersion int, payloads ...any) ([]models.Event, error) {
+	now := time.Now()
+	pending := make([]pendingEvent, len(payloads))
+	for i, payload := range payloads {
+		pending[i] = pendingEvent{payload: payload, occurredAt: now}
+	}
+	return appendEvents(ctx, tx, streamID, expectedVersion, pending)
+}
+
+// pendingEvent is an event payload waiting to be appended to a stream.
+type pendingEvent struct {
+	payload    any
+	occurredAt time.Time
+}
+
+func appendEvents(ctx context.Context, tx repository.Store, streamID uuid.UUID, expectedVersion int, pending []pendingEvent) ([]models.Event, error) {
+	current, err := tx.Events().Version(ctx, streamID)
+	if err != nil {
+		return nil, err
+	}
+	if current != expectedVersion {
+		return nil, ErrConcurrentModification
+	}
+
+	events := make([]models.Event, 0, len(pending))
+	for i, p := range pending {
+		typ, err := eventType(p.payload)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(p.payload)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, models.Event{
+			StreamID:   streamID,
+			Version:    expectedVersion + i + 1,
+			Type:       typ,
+			Data:       string(data),
+			OccurredAt: p.occurredAt,
+		})
+	}
+
+	// The unique stream/version index catches writers racing past the check above
+	if err := tx.Events().Append(ctx, events); err != nil {
+		if errors.Is(err, repository.ErrDuplicate) {
+			return nil, ErrConcurrentModification
+		}
+		return nil, err
+	}
+	return events, nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"wallet/internal/database"
+	"wallet/internal/models"
+	"wallet/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// newTestDB opens a temporary SQLite database, for tests of what the
+// in-memory store cannot show, such as database locking, and of the services
+// that query the database directly.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := database.Open(filepath.Join(t.TempDir(), "wallet.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db.GetDB()
+}
+
+func TestEventStoreConcurrentAppends(t *testing.T) {
+	ctx := context.Background()
+	svc := NewAccountService(newTestDB(t))
+	shared := newTestAccount(t, svc)
+	accounts := make([]*models.Account, 20)
+	for i := range accounts {
+		account, err := svc.CreateAccount(ctx, shared.UserID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		accounts[i] = account
+	}
+
+	// Writers to different streams and to the same stream queue for the
+	// database instead of failing
+	var wg sync.WaitGroup
+	for _, account := range accounts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := svc.TopUp(ctx, account.ID, 1, models.TransactionDetails{}); err != nil {
+				t.Errorf("top-up to its own account: %v", err)
+			}
+		}()
+	}
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := svc.TopUp(ctx, shared.ID, 1, models.TransactionDetails{}); err != nil {
+				t.Errorf("top-up to the shared account: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	checkVersion := func(account *models.Account, balance float64, version int) {
+		t.Helper()
+		got, err := svc.GetAccountByID(ctx, account.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Balance != balance || got.Version != version {
+			t.Errorf("account: got balance %v at version %d want %v at version %d", got.Balance, got.Version, balance, version)
+		}
+	}
+	for _, account := range accounts {
+		checkVersion(account, 1, 2)
+	}
+	checkVersion(shared, 50, 51)
+}
+
+func TestBusyDatabaseIsConcurrentModification(t *testing.T) {
+	err := operationError(context.Background(), fmt.Errorf("%w: database is locked", repository.ErrBusy))
+	if !errors.Is(err, ErrConcurrentModification) {
+		t.Errorf("got error %v want %v", err, ErrConcurrentModification)
+	}
+}
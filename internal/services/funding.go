@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"wallet/internal/models"
+	"wallet/internal/payments"
+	"wallet/internal/repository"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+)
+
+// FundingService funds top-ups through a payment provider. A top-up is
+// recorded as pending while the provider collects the money, and is settled
+// or failed by the provider's answer or, when that takes a while, by its
+// webhook.
+type FundingService interface {
+	// TopUp collects amount with the given payment method and credits the
+	// account once the payment succeeds. The top-up is returned posted, or
+	// still pending if the provider reports its outcome later; a declined
+	// payment fails it and returns ErrPaymentDeclined.
+	TopUp(ctx context.Context, accountID uuid.UUID, amount float64, paymentMethod string, details models.TransactionDetails) (*models.Transaction, error)
+	// Refund reverses a funded top-up and returns its money through the
+	// provider.
+	Refund(ctx context.Context, transactionID uuid.UUID, reason string) (*models.Payment, error)
+	// HandleWebhook applies a webhook sent by the named provider.
+	HandleWebhook(ctx context.Context, provider string, header http.Header, body []byte) error
+	// WithActor returns a copy of the service that attributes the changes
+	// it makes to the given actor in the audit log.
+	WithActor(actor Actor) FundingService
+}
+
+type fundingService struct {
+	store    repository.Store
+	accounts AccountService
+	provider payments.Provider
+	actor    Actor
+}
+
+func NewFundingService(db *gorm.DB, provider payments.Provider) FundingService {
+	return &fundingService{
+		store:    repository.NewGormStore(db),
+		accounts: paymentSettler(NewAccountService(db)),
+		provider: provider,
+		actor:    SystemActor,
+	}
+}
+
+// NewFundingServiceWithStore returns a FundingService that keeps payments
+// and accounts in store.
+func NewFundingServiceWithStore(store repository.Store, provider payments.Provider) FundingService {
+	return &fundingService{
+		store:    store,
+		accounts: paymentSettler(NewAccountServiceWithStore(store)),
+		provider: provider,
+		actor:    SystemActor,
+	}
+}
+
+// paymentSettler returns a copy of accounts that settles the top-ups
+// payments fund.
+func paymentSettler(accounts AccountService) AccountService {
+	clone := *accounts.(*accountService)
+	clone.settlesPayments = true
+	return &clone
+}
+
+func (s *fundingService) WithActor(actor Actor) FundingService {
+	clone := *s
+	clone.actor = actor
+	return &clone
+}
+
+func (s *fundingService) TopUp(ctx context.Context, accountID uuid.UUID, amount float64, paymentMethod string, details models.TransactionDetails) (_ *models.Transaction, err error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	ctx, span := accountTracer.Start(ctx, "FundingService.TopUp")
+	defer endSpan(ctx, span, &err)
+	span.SetAttributes(attribute.String("account.id", accountID.String()), attribute.String("payment.provider", s.provider.Name()))
+
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	accounts := s.accounts.WithActor(s.actor)
+
+	// Record the top-up first, so an account that cannot be credited is
+	// refused before any money is collected
+	transaction, err := accounts.PendingTopUp(ctx, accountID, amount, details)
+	if err != nil {
+		return nil, err
+	}
+
+	intent, err := s.provider.CreateIntent(ctx, payments.IntentRequest{
+		Amount:        transaction.Amount,
+		Currency:      Currency(),
+		PaymentMethod: paymentMethod,
+		Reference:     transaction.Ref,
+	})
+	if err != nil {
+		// Nothing was collected, so the top-up fails straight away
+		if _, failErr := accounts.FailTransaction(ctx, transaction.ID, "payment not created"); failErr != nil {
+			slog.ErrorContext(ctx, "failed to fail an unfunded top-up", "transaction_id", transaction.ID, "error", failErr)
+		}
+		return nil, providerError(err)
+	}
+
+	payment := &models.Payment{
+		Provider:      s.provider.Name(),
+		IntentID:      intent.ID,
+		TransactionID: transaction.ID,
+		AccountID:     accountID,
+		Amount:        transaction.Amount,
+		Status:        models.PaymentProcessing,
+	}
+	if err := s.store.Payments().Create(ctx, payment); err != nil {
+		return nil, err
+	}
+
+	intent, err = s.provider.Confirm(ctx, intent.ID)
+	if err != nil {
+		// Whether the money was collected is unknown; the provider's webhook
+		// settles the top-up, or it expires
+		slog.WarnContext(ctx, "payment confirmation failed", "transaction_id", transaction.ID, "intent_id", payment.IntentID, "error", err)
+		return transaction, nil
+	}
+	return s.apply(ctx, payment, intent.Status, intent.FailureReason)
+}
+
+// apply settles or fails the top-up of a processing payment according to
+// the status of its intent, and returns the top-up. Payments that are no
+// longer processing were already applied and are left as they are.
+func (s *fundingService) apply(ctx context.Context, payment *models.Payment, status payments.IntentStatus, reason string) (*models.Transaction, error) {
+	if payment.Status != models.PaymentProcessing {
+		return loadTransaction(ctx, s.store, payment.TransactionID)
+	}
+	accounts := s.accounts.WithActor(s.actor)
+
+	switch status {
+	case payments.IntentSucceeded:
+		transaction, err := accounts.SettleTransaction(ctx, payment.TransactionID)
+		if errors.Is(err, ErrInvalidTransition) {
+			// Settled by a concurrent webhook, or failed before the money came in
+			if transaction, err = loadTransaction(ctx, s.store, payment.TransactionID); err == nil && transaction.Status == models.TransactionFailed {
+				return transaction, s.refundFailedTopUp(ctx, payment)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		payment.Status = models.PaymentSucceeded
+		if err := s.store.Payments().Update(ctx, payment); err != nil {
+			return nil, err
+		}
+		slog.InfoContext(ctx, "payment succeeded", "transaction_id", payment.TransactionID, "intent_id", payment.IntentID, "provider", payment.Provider)
+		return transaction, nil
+
+	case payments.IntentFailed:
+		if reason == "" {
+			reason = "payment failed"
+		}
+		// A top-up that already failed, e.g. as it expired, stays failed
+		_, err := accounts.FailTransaction(ctx, payment.TransactionID, reason)
+		if errors.Is(err, ErrInvalidTransition) {
+			err = s.reverseUnfundedTopUp(ctx, payment, reason)
+		}
+		if err != nil {
+			return nil, err
+		}
+		payment.Status = models.PaymentFailed
+		payment.FailureReason = reason
+		if err := s.store.Payments().Update(ctx, payment); err != nil {
+			return nil, err
+		}
+		slog.InfoContext(ctx, "payment failed", "transaction_id", payment.TransactionID, "intent_id", payment.IntentID, "provider", payment.Provider, "reason", reason)
+		return nil, fmt.Errorf("%w: %s", ErrPaymentDeclined, reason)
+	}
+
+	// Still processing; the outcome comes with a webhook
+	return loadTransaction(ctx, s.store, payment.TransactionID)
+}
+
+// reverseUnfundedTopUp reverses the top-up of a failed payment if it was
+// posted anyway, e.g. by an operator, as its money was never collected. A
+// top-up whose money was spent in the meantime stays posted, and
+// reconciliation reports it as unfunded.
+func (s *fundingService) reverseUnfundedTopUp(ctx context.Context, payment *models.Payment, reason string) error {
+	transaction, err := loadTransaction(ctx, s.store, payment.TransactionID)
+	if err != nil || transaction.Status != models.TransactionPosted {
+		return err
+	}
+	_, err = s.accounts.WithActor(s.actor).ReverseTransaction(ctx, transaction.ID, "payment failed after the top-up was posted: "+reason)
+	if errors.Is(err, ErrInsufficientFunds) {
+		slog.ErrorContext(ctx, "top-up posted without its payment cannot be reversed", "transaction_id", transaction.ID, "account_id", transaction.AccountID, "intent_id", payment.IntentID, "amount", transaction.Amount)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	slog.WarnContext(ctx, "top-up reversed as its payment failed after it was posted", "transaction_id", transaction.ID, "intent_id", payment.IntentID)
+	return nil
+}
+
+// refundFailedTopUp returns the money of a payment that succeeded after its
+// top-up had failed, so it can never be credited.
+func (s *fundingService) refundFailedTopUp(ctx context.Context, payment *models.Payment) error {
+	refund, err := s.provider.Refund(ctx, payment.IntentID, payment.Amount)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrPaymentProvider, err)
+	}
+	payment.Status = models.PaymentRefunded
+	payment.FailureReason = "top-up failed before the payment succeeded"
+	payment.RefundID = refund.ID
+	if err := s.store.Payments().Update(ctx, payment); err != nil {
+		return err
+	}
+	slog.WarnContext(ctx, "payment refunded as its top-up had failed", "transaction_id", payment.TransactionID, "intent_id", payment.IntentID, "refund_id", refund.ID)
+	return nil
+}
+
+// Refund reverses the top-up first, which fails if its money was spent, and
+// then refunds the payment. If the provider fails, the payment is left
+// refund_pending and refunding it again retries the provider.
+func (s *fundingService) Refund(ctx context.Context, transactionID uuid.UUID, reason string) (_ *models.Payment, err error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	ctx, span := accountTracer.Start(ctx, "FundingService.Refund")
+	defer endSpan(ctx, span, &err)
+
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	payment, err := s.store.Payments().GetByTransactionID(ctx, transactionID)
+	if err != nil {
+		return nil, notFound(err, ErrPaymentNotFound)
+	}
+
+	if payment.Status == models.PaymentSucceeded {
+		if _, err := s.accounts.WithActor(s.actor).ReverseTransaction(ctx, transactionID, reason); err != nil {
+			return nil, err
+		}
+		payment.Status = models.PaymentRefundPending
+		if err := s.store.Payments().Update(ctx, payment); err != nil {
+			return nil, err
+		}
+	}
+	if payment.Status != models.PaymentRefundPending {
+		return nil, fmt.Errorf("%w: a %s payment cannot be refunded", ErrInvalidTransition, payment.Status)
+	}
+
+	refund, err := s.provider.Refund(ctx, payment.IntentID, payment.Amount)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrPaymentProvider, err)
+	}
+	payment.Status = models.PaymentRefunded
+	payment.RefundID = refund.ID
+	if err := s.store.Payments().Update(ctx, payment); err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "payment refunded", "transaction_id", transactionID, "intent_id", payment.IntentID, "refund_id", refund.ID, "reason", reason, "actor", s.actor.Name)
+	return payment, nil
+}
+
+// HandleWebhook applies the outcome of a payment. Webhooks may be sent more
+// than once, so applying one again changes nothing.
+func (s *fundingService) HandleWebhook(ctx context.Context, provider string, header http.Header, body []byte) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	ctx, span := accountTracer.Start(ctx, "FundingService.HandleWebhook")
+	defer endSpan(ctx, span, &err)
+
+	if provider != s.provider.Name() {
+		return fmt.Errorf("%w: unknown payment provider %q", ErrInvalidWebhook, provider)
+	}
+	event, err := s.provider.ParseWebhook(header, body)
+	if err != nil {
+		return err
+	}
+	span.SetAttributes(attribute.String("payment.intent_id", event.IntentID))
+
+	payment, err := s.store.Payments().GetByIntent(ctx, provider, event.IntentID)
+	if err != nil {
+		return notFound(err, ErrPaymentNotFound)
+	}
+	if _, err := s.apply(ctx, payment, event.Status, event.FailureReason); err != nil && !errors.Is(err, ErrPaymentDeclined) {
+		return err
+	}
+	return nil
+}
+
+// providerError translates an error of the payment provider into a domain
+// error.
+func providerError(err error) error {
+	if errors.Is(err, payments.ErrUnknownPaymentMethod) {
+		return &ValidationError{Field: "payment_method", Message: err.Error()}
+	}
+	return fmt.Errorf("%w: %v", ErrPaymentProvider, err)
+}
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"wallet/internal/models"
+	"wallet/internal/payments"
+	"wallet/internal/repository"
+)
+
+// newTestFunding returns a funding service whose fake provider sends its
+// webhooks straight back to it.
+func newTestFunding(t *testing.T, store repository.Store) (FundingService, *payments.FakeProvider) {
+	t.Helper()
+	var funding FundingService
+	webhooks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := funding.HandleWebhook(r.Context(), payments.FakeProviderName, r.Header, body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(webhooks.Close)
+
+	provider := payments.NewFakeProvider(webhooks.URL, []byte("secret"))
+	provider.Delay = 10 * time.Millisecond
+	t.Cleanup(provider.Wait)
+	funding = NewFundingServiceWithStore(store, provider)
+	return funding, provider
+}
+
+func TestFundingTopUp(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	funding, provider := newTestFunding(t, store)
+	accounts := NewAccountServiceWithStore(store)
+	account := newTestAccount(t, accounts)
+
+	transaction, err := funding.TopUp(ctx, account.ID, 20, payments.FakeCard, models.TransactionDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transaction.Status != models.TransactionPosted || transaction.Account.Balance != 20 {
+		t.Errorf("paid top-up: got %s with balance %v want posted with 20", transaction.Status, transaction.Account.Balance)
+	}
+
+	if _, err := funding.TopUp(ctx, account.ID, 5, payments.FakeCardDeclined, models.TransactionDetails{}); !errors.Is(err, ErrPaymentDeclined) {
+		t.Errorf("declined top-up: got error %v want %v", err, ErrPaymentDeclined)
+	}
+	var invalid *ValidationError
+	if _, err := funding.TopUp(ctx, account.ID, 5, "cash", models.TransactionDetails{}); !errors.As(err, &invalid) || invalid.Field != "payment_method" {
+		t.Errorf("unknown payment method: got error %v want a validation error for payment_method", err)
+	}
+
+	delayed, err := funding.TopUp(ctx, account.ID, 30, payments.FakeCardDelayed, models.TransactionDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if delayed.Status != models.TransactionPending {
+		t.Errorf("delayed top-up: got %s want pending", delayed.Status)
+	}
+	delayedDeclined, err := funding.TopUp(ctx, account.ID, 40, payments.FakeCardDelayedDeclined, models.TransactionDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider.Wait()
+
+	for _, tt := range []struct {
+		transaction *models.Transaction
+		want        models.TransactionStatus
+	}{
+		{delayed, models.TransactionPosted},
+		{delayedDeclined, models.TransactionFailed},
+	} {
+		got, err := store.Transactions().GetByID(ctx, tt.transaction.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != tt.want {
+			t.Errorf("top-up of %v after its webhook: got %s want %s", got.Amount, got.Status, tt.want)
+		}
+	}
+	got, err := accounts.GetAccountByID(ctx, account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Balance != 50 {
+		t.Errorf("balance: got %v want 50", got.Balance)
+	}
+}
+
+func TestFundingRefund(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	funding, _ := newTestFunding(t, store)
+	accounts := NewAccountServiceWithStore(store)
+	account := newTestAccount(t, accounts)
+
+	transaction, err := funding.TopUp(ctx, account.ID, 20, payments.FakeCard, models.TransactionDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := accounts.Charge(ctx, account.ID, 15, models.TransactionDetails{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := funding.Refund(ctx, transaction.ID, "customer request"); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("refund of spent money: got error %v want %v", err, ErrInsufficientFunds)
+	}
+	if _, err := accounts.TopUp(ctx, account.ID, 15, models.TransactionDetails{}); err != nil {
+		t.Fatal(err)
+	}
+
+	payment, err := funding.Refund(ctx, transaction.ID, "customer request")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Status != models.PaymentRefunded || payment.RefundID == "" {
+		t.Errorf("payment: got %s with refund %q want refunded", payment.Status, payment.RefundID)
+	}
+	got, err := accounts.GetAccountByID(ctx, account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Balance != 0 {
+		t.Errorf("balance: got %v want 0", got.Balance)
+	}
+	if _, err := funding.Refund(ctx, transaction.ID, "customer request"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("second refund: got error %v want %v", err, ErrInvalidTransition)
+	}
+}
+
+func TestFundingRefundsPaymentOfExpiredTopUp(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	funding, provider := newTestFunding(t, store)
+	accounts := NewAccountServiceWithStore(store)
+	account := newTestAccount(t, accounts)
+
+	transaction, err := funding.TopUp(ctx, account.ID, 20, payments.FakeCardDelayed, models.TransactionDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := accounts.ExpirePending(ctx, time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	provider.Wait()
+
+	payment, err := store.Payments().GetByTransactionID(ctx, transaction.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Status != models.PaymentRefunded {
+		t.Errorf("payment that succeeded after its top-up expired: got %s want refunded", payment.Status)
+	}
+}
+
+func TestFundingReversesTopUpPostedBeforeItsPaymentFailed(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	store := repository.NewGormStore(db)
+	funding, provider := newTestFunding(t, store)
+	provider.Delay = 200 * time.Millisecond
+	accounts := NewAccountServiceWithStore(store)
+	account := newTestAccount(t, accounts)
+
+	reversed, err := funding.TopUp(ctx, account.ID, 30, payments.FakeCardDelayedDeclined, models.TransactionDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spent, err := funding.TopUp(ctx, account.ID, 40, payments.FakeCardDelayedDeclined, models.TransactionDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := accounts.SettleTransaction(ctx, reversed.ID); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("settling a top-up funded by a payment: got error %v want %v", err, ErrInvalidTransition)
+	}
+
+	// Both top-ups get posted before their payments fail, and part of the
+	// money is spent
+	for _, transaction := range []*models.Transaction{reversed, spent} {
+		if _, err := paymentSettler(accounts).SettleTransaction(ctx, transaction.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := accounts.Charge(ctx, account.ID, 35, models.TransactionDetails{}); err != nil {
+		t.Fatal(err)
+	}
+	provider.Wait()
+
+	for _, tt := range []struct {
+		transaction *models.Transaction
+		want        models.TransactionStatus
+	}{
+		{reversed, models.TransactionReversed},
+		{spent, models.TransactionPosted},
+	} {
+		got, err := store.Transactions().GetByID(ctx, tt.transaction.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != tt.want {
+			t.Errorf("top-up of %v after its payment failed: got %s want %s", got.Amount, got.Status, tt.want)
+		}
+		payment, err := store.Payments().GetByTransactionID(ctx, tt.transaction.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if payment.Status != models.PaymentFailed {
+			t.Errorf("payment of %v: got %s want failed", payment.Amount, payment.Status)
+		}
+	}
+
+	report, err := NewReconciliationService(db).Reconcile(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Balanced() || len(report.UnfundedTopUps) != 1 || report.UnfundedTopUps[0].TransactionID != spent.ID {
+		t.Errorf("reconciliation: got unfunded top-ups %+v want the spent one", report.UnfundedTopUps)
+	}
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Operator is a finance operator allowed to make and review adjustments.
+// Operators authenticate with their token, over the API and on the command
+// line alike, so the maker-checker principle sees the same operator
+// whichever way they work.
+type Operator struct {
+	Name  string
+	Token string
+}
+
+// OperatorsFromEnv reads OPERATOR_TOKENS, a comma-separated list of
+// name:token pairs, e.g. "alice:s3cret,bob:t0ken". Malformed pairs are
+// skipped.
+func OperatorsFromEnv() []Operator {
+	var operators []Operator
+	for _, pair := range strings.Split(os.Getenv("OPERATOR_TOKENS"), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, token, ok := strings.Cut(pair, ":")
+		if !ok || name == "" || token == "" {
+			slog.Warn("skipping malformed OPERATOR_TOKENS entry", "entry", name)
+			continue
+		}
+		operators = append(operators, Operator{Name: name, Token: token})
+	}
+	return operators
+}
+
+// AuthenticateOperator returns the name of the operator whose token is
+// token, if any.
+func AuthenticateOperator(operators []Operator, token string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	// Compare against every token, so the time taken does not tell how
+	// much of a token was right
+	name := ""
+	for _, o := range operators {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(o.Token)) == 1 {
+			name = o.Name
+		}
+	}
+	return name, name != ""
+}
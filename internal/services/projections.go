@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+	"wallet/internal/models"
+	"wallet/internal/repository"
+
+	"gorm.io/gorm"
+)
+
+// AuditProjectionsRebuilt is recorded when the projections are replayed.
+const AuditProjectionsRebuilt = "projections.rebuilt"
+
+// ProjectionRebuild summarises a replay of the event store.
+type ProjectionRebuild struct {
+	StreamsBackfilled int `json:"streams_backfilled"`
+	EventsReplayed    int `json:"events_replayed"`
+}
+
+type ProjectionService interface {
+	Rebuild(ctx context.Context) (*ProjectionRebuild, error)
+}
+
+type projectionService struct {
+	db         *gorm.DB
+	audit      AuditService
+	projection AccountProjection
+	actor      Actor
+}
+
+func NewProjectionService(db *gorm.DB, actor Actor) ProjectionService {
+	return &projectionService{
+		db:         db,
+		audit:      NewAuditService(db),
+		projection: NewAccountProjection(),
+		actor:      actor,
+	}
+}
+
+// Rebuild resets the accounts table, empties the transactions table and
+// replays every event in the store through the account projection, in a
+// single database transaction. Accounts without a stream are backfilled from
+// their transactions first.
+func (s *projectionService) Rebuild(ctx context.Context) (*ProjectionRebuild, error) {
+	result := &ProjectionRebuild{}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		store := repository.NewGormStore(tx)
+
+		var legacy []models.Account
+		err := tx.Where("id NOT IN (?)", tx.Model(&models.Event{}).Distinct("stream_id")).Find(&legacy).Error
+		if err != nil {
+			return err
+		}
+		for i := range legacy {
+			if _, err := backfillAccountStream(ctx, store, &legacy[i]); err != nil {
+				return err
+			}
+		}
+		result.StreamsBackfilled = len(legacy)
+
+		// Start every account from scratch
+		err = tx.Session(&gorm.Session{AllowGlobalUpdate: true}).
+			Model(&models.Account{}).
+			UpdateColumns(map[string]any{"balance": 0, "available_balance": 0, "version": 0, "status": models.AccountActive}).Error
+		if err != nil {
+			return err
+		}
+		// The projection writes every transaction row, so they are
+		// replayed too rather than trusted
+		err = tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Unscoped().Delete(&models.Transaction{}).Error
+		if err != nil {
+			return err
+		}
+
+		var batch []models.Event
+		err = tx.Order("position ASC").FindInBatches(&batch, 500, func(batchTx *gorm.DB, _ int) error {
+			for _, e := range batch {
+				if err := s.projection.Project(ctx, store, e); err != nil {
+					return err
+				}
+			}
+			result.EventsReplayed += len(batch)
+			return nil
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, store, s.actor, AuditEvent{
+			Action:     AuditProjectionsRebuilt,
+			EntityType: "projection",
+			EntityID:   "accounts",
+			After:      result,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"wallet/internal/models"
+)
+
+func TestProjectionRebuild(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	accounts := NewAccountService(db)
+	account := newTestAccount(t, accounts)
+	topUp, err := accounts.TopUp(ctx, account.ID, 100, models.TransactionDetails{ExternalRef: "ORDER-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hold, err := accounts.PendingCharge(ctx, account.ID, 30, models.TransactionDetails{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Corrupt both projections behind the event store's back
+	mustExec(t, db, "UPDATE transactions SET amount = 1, status = 'failed', external_ref = '' WHERE id = ?", topUp.ID)
+	mustExec(t, db, "DELETE FROM transactions WHERE id = ?", hold.ID)
+	mustExec(t, db, "UPDATE accounts SET balance = 0, available_balance = 0 WHERE id = ?", account.ID)
+
+	result, err := NewProjectionService(db, SystemActor).Rebuild(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.EventsReplayed != 3 || result.StreamsBackfilled != 0 {
+		t.Errorf("got %+v want 3 events replayed and no streams backfilled", result)
+	}
+	checkBalances(t, accounts, account, 100, 70)
+
+	var restored models.Transaction
+	if err := db.First(&restored, "id = ?", topUp.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if restored.Amount != 100 || restored.Status != models.TransactionPosted || restored.ExternalRef != "ORDER-1" {
+		t.Errorf("got %s top-up of %v with reference %q want posted of 100 with ORDER-1", restored.Status, restored.Amount, restored.ExternalRef)
+	}
+	var recreated models.Transaction
+	if err := db.First(&recreated, "id = ?", hold.ID).Error; err != nil {
+		t.Fatalf("deleted hold: %v", err)
+	}
+	if recreated.Status != models.TransactionPending || recreated.Amount != 30 {
+		t.Errorf("got %s hold of %v want pending of 30", recreated.Status, recreated.Amount)
+	}
+}
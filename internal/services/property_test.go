@@ -0,0 +1,502 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"wallet/internal/models"
+	"wallet/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+var (
+	propertySeed  = flag.Uint64("property.seed", 0, "seed for the property tests, random when 0")
+	propertyRuns  = flag.Int("property.runs", 200, "number of operation sequences the property tests generate")
+	propertySteps = flag.Int("property.steps", 40, "maximum length of a generated operation sequence")
+)
+
+type opKind int
+
+const (
+	opCreate opKind = iota
+	opTopUp
+	opCharge
+	opAdjust
+	opFreeze
+	opUnfreeze
+	opConcurrent
+)
+
+// op is one step of a generated sequence. Accounts are referred to by the
+// order they were created in, modulo the number of accounts, so a sequence
+// stays valid when the shrinker drops some of its steps.
+type op struct {
+	kind    opKind
+	account int
+	// cents is the amount of money operations, negative for debit
+	// adjustments
+	cents int64
+	// batch holds the top-ups and charges a concurrent step runs at once
+	batch []op
+}
+
+func (o op) String() string {
+	switch o.kind {
+	case opCreate:
+		return "create"
+	case opTopUp:
+		return fmt.Sprintf("top-up #%d %s", o.account, formatCents(o.cents))
+	case opCharge:
+		return fmt.Sprintf("charge #%d %s", o.account, formatCents(o.cents))
+	case opAdjust:
+		return fmt.Sprintf("adjust #%d %s", o.account, formatCents(o.cents))
+	case opFreeze:
+		return fmt.Sprintf("freeze #%d", o.account)
+	case opUnfreeze:
+		return fmt.Sprintf("unfreeze #%d", o.account)
+	default:
+		steps := make([]string, len(o.batch))
+		for i, b := range o.batch {
+			steps[i] = b.String()
+		}
+		return "concurrently {" + strings.Join(steps, "; ") + "}"
+	}
+}
+
+func formatCents(cents int64) string {
+	return fmt.Sprintf("%.2f", float64(cents)/100)
+}
+
+// genAmount favours small amounts, with the occasional large one so that
+// charges regularly exceed the balance.
+func genAmount(r *rand.Rand) int64 {
+	switch r.IntN(4) {
+	case 0:
+		return 1 + r.Int64N(10)
+	case 1:
+		return 1 + r.Int64N(1_000_000)
+	default:
+		return 1 + r.Int64N(5_000)
+	}
+}
+
+func genOp(r *rand.Rand, concurrent bool) op {
+	o := op{account: r.IntN(4), cents: genAmount(r)}
+	n := 10
+	if !concurrent {
+		n = 13
+	}
+	switch k := r.IntN(n); {
+	case k < 4:
+		o.kind = opTopUp
+	case k < 8:
+		o.kind = opCharge
+	case k < 9:
+		o.kind = opAdjust
+		if r.IntN(2) == 0 {
+			o.cents = -o.cents
+		}
+	case k < 10 && concurrent:
+		o.kind = opFreeze
+	case k < 10:
+		o.kind = opCreate
+	case k < 11:
+		o.kind = opFreeze
+	case k < 12:
+		o.kind = opUnfreeze
+	default:
+		o.kind = opConcurrent
+		o.batch = make([]op, 2+r.IntN(6))
+		for i := range o.batch {
+			o.batch[i] = genOp(r, true)
+		}
+	}
+	return o
+}
+
+func genOps(r *rand.Rand, steps int) []op {
+	// Start with an account so that most sequences move money
+	ops := []op{{kind: opCreate}}
+	for range r.IntN(steps) {
+		ops = append(ops, genOp(r, false))
+	}
+	return ops
+}
+
+// modelAccount is what an account should look like after a sequence of
+// operations, with the balance in cents so that it is exact.
+type modelAccount struct {
+	id      uuid.UUID
+	balance int64
+	frozen  bool
+}
+
+// propertyRun applies a sequence of operations to a fresh AccountService and
+// checks them against a model of the books after every step.
+type propertyRun struct {
+	ctx      context.Context
+	store    repository.Store
+	svc      AccountService
+	accounts []*modelAccount
+}
+
+// runOps returns the first step that diverges from the model or breaks an
+// invariant, or nil when the whole sequence holds.
+func runOps(ops []op) error {
+	store := repository.NewMemoryStore()
+	run := &propertyRun{ctx: context.Background(), store: store, svc: NewAccountServiceWithStore(store)}
+	for i, o := range ops {
+		if err := run.apply(o); err != nil {
+			return fmt.Errorf("step %d (%s): %w", i, o, err)
+		}
+		if err := run.checkInvariants(); err != nil {
+			return fmt.Errorf("after step %d (%s): %w", i, o, err)
+		}
+	}
+	return nil
+}
+
+func (r *propertyRun) account(index int) *modelAccount {
+	if len(r.accounts) == 0 {
+		return nil
+	}
+	return r.accounts[index%len(r.accounts)]
+}
+
+func (r *propertyRun) apply(o op) error {
+	if o.kind == opCreate {
+		email := fmt.Sprintf("user%d@example.com", len(r.accounts))
+		account, err := r.svc.CreateAccountWithUser(r.ctx, email, "Jane", "Doe")
+		if err != nil {
+			return err
+		}
+		r.accounts = append(r.accounts, &modelAccount{id: account.ID})
+		return nil
+	}
+	if o.kind == opConcurrent {
+		return r.applyConcurrently(o.batch)
+	}
+
+	a := r.account(o.account)
+	if a == nil {
+		return nil
+	}
+	want := r.expect(a, o)
+	err := r.call(a, o)
+	if !errors.Is(err, want) {
+		return fmt.Errorf("got error %v want %v", err, want)
+	}
+	if err == nil {
+		r.update(a, o)
+	}
+	return nil
+}
+
+// expect returns the error the model predicts for an operation, nil if it
+// should succeed.
+func (r *propertyRun) expect(a *modelAccount, o op) error {
+	switch o.kind {
+	case opTopUp:
+		if a.frozen {
+			return ErrAccountFrozen
+		}
+	case opCharge:
+		if a.frozen {
+			return ErrAccountFrozen
+		}
+		if o.cents > a.balance {
+			return ErrInsufficientFunds
+		}
+	case opAdjust:
+		if -o.cents > a.balance {
+			return ErrInsufficientFunds
+		}
+	case opFreeze:
+		if a.frozen {
+			return ErrAccountAlreadyFrozen
+		}
+	case opUnfreeze:
+		if !a.frozen {
+			return ErrAccountNotFrozen
+		}
+	}
+	return nil
+}
+
+func (r *propertyRun) call(a *modelAccount, o op) error {
+	amount := float64(o.cents) / 100
+	var err error
+	switch o.kind {
+	case opTopUp:
+		_, err = r.svc.TopUp(r.ctx, a.id, amount, models.TransactionDetails{})
+	case opCharge:
+		_, err = r.svc.Charge(r.ctx, a.id, amount, models.TransactionDetails{})
+	case opAdjust:
+		_, err = r.svc.Adjust(r.ctx, a.id, amount, "property test")
+	case opFreeze:
+		_, err = r.svc.Freeze(r.ctx, a.id, "property test")
+	case opUnfreeze:
+		_, err = r.svc.Unfreeze(r.ctx, a.id, "property test")
+	}
+	return err
+}
+
+func (r *propertyRun) update(a *modelAccount, o op) {
+	switch o.kind {
+	case opTopUp, opAdjust:
+		a.balance += o.cents
+	case opCharge:
+		a.balance -= o.cents
+	case opFreeze:
+		a.frozen = true
+	case opUnfreeze:
+		a.frozen = false
+	}
+}
+
+// applyConcurrently runs a batch at once. Which charges fit in the balance
+// depends on the order they land in, so the model only requires that the
+// successful operations add up to the new balance.
+func (r *propertyRun) applyConcurrently(batch []op) error {
+	if len(r.accounts) == 0 {
+		return nil
+	}
+	errs := make([]error, len(batch))
+	var wg sync.WaitGroup
+	for i, o := range batch {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = r.call(r.account(o.account), o)
+		}()
+	}
+	wg.Wait()
+
+	// A freeze in the batch may land before any of the other operations
+	freezing := map[*modelAccount]bool{}
+	for i, o := range batch {
+		if o.kind == opFreeze && errs[i] == nil {
+			freezing[r.account(o.account)] = true
+		}
+	}
+	for i, o := range batch {
+		a := r.account(o.account)
+		switch err := errs[i]; {
+		case err == nil:
+			r.update(a, o)
+		case errors.Is(err, ErrInsufficientFunds) && (o.kind == opCharge || o.kind == opAdjust):
+		case errors.Is(err, ErrAccountFrozen) && (a.frozen || freezing[a]):
+		case errors.Is(err, ErrAccountAlreadyFrozen) && (a.frozen || freezing[a]):
+		default:
+			return fmt.Errorf("%s: unexpected error %v", o, err)
+		}
+	}
+	return nil
+}
+
+// checkInvariants verifies the books of every account against the model:
+//   - the balance is never negative and matches the model,
+//   - the balance is the sum of the account's posted transactions, and
+//     the available balance is less what pending charges hold,
+//   - replaying the account's events gives the same balance,
+//   - no two transactions share a ref.
+func (r *propertyRun) checkInvariants() error {
+	refs := map[string]bool{}
+	for i, a := range r.accounts {
+		account, err := r.svc.GetAccountByID(r.ctx, a.id)
+		if err != nil {
+			return err
+		}
+		balance := int64(math.Round(account.Balance * 100))
+		if balance < 0 {
+			return fmt.Errorf("account #%d: negative balance %s", i, formatCents(balance))
+		}
+		if balance != a.balance {
+			return fmt.Errorf("account #%d: got balance %s want %s", i, formatCents(balance), formatCents(a.balance))
+		}
+		if frozen := account.Status == models.AccountFrozen; frozen != a.frozen {
+			return fmt.Errorf("account #%d: got frozen %v want %v", i, frozen, a.frozen)
+		}
+
+		transactions, err := r.store.Transactions().ListByAccountID(r.ctx, a.id)
+		if err != nil {
+			return err
+		}
+		var sum, held int64
+		for _, t := range transactions {
+			// Only adjustments have signed amounts
+			cents := int64(math.Round(t.Amount * 100))
+			if cents == 0 || cents < 0 && t.TransactionType != models.AdjustmentTransaction {
+				return fmt.Errorf("account #%d: %s transaction %s of %v", i, t.TransactionType, t.Ref, t.Amount)
+			}
+			switch {
+			case t.Status == models.TransactionPending && t.TransactionType == models.Charge:
+				held += cents
+			case t.PostedAt == nil:
+			default:
+				sum += int64(math.Round(t.SignedAmount() * 100))
+			}
+			if refs[t.Ref] {
+				return fmt.Errorf("account #%d: duplicate ref %s", i, t.Ref)
+			}
+			refs[t.Ref] = true
+		}
+		if sum != balance {
+			return fmt.Errorf("account #%d: transactions sum to %s but the balance is %s", i, formatCents(sum), formatCents(balance))
+		}
+		if available := int64(math.Round(account.AvailableBalance * 100)); available != balance-held {
+			return fmt.Errorf("account #%d: available balance %s but %s of %s is held", i, formatCents(available), formatCents(held), formatCents(balance))
+		}
+
+		events, err := r.store.Events().Stream(r.ctx, a.id)
+		if err != nil {
+			return err
+		}
+		aggregate, err := NewAccountAggregate(a.id, events)
+		if err != nil {
+			return err
+		}
+		if replayed := int64(math.Round(aggregate.Balance * 100)); replayed != balance {
+			return fmt.Errorf("account #%d: events replay to %s but the balance is %s", i, formatCents(replayed), formatCents(balance))
+		}
+		if aggregate.Version != account.Version {
+			return fmt.Errorf("account #%d: stream at version %d but the account at %d", i, aggregate.Version, account.Version)
+		}
+	}
+	return nil
+}
+
+// shrink looks for a smaller sequence that still fails: it drops steps,
+// unwraps and trims concurrent batches, and lowers amounts, for as long as
+// any of these keeps the failure.
+func shrink(ops []op, fails func([]op) bool) []op {
+	for shrunk := true; shrunk; {
+		shrunk = false
+		for _, candidate := range shrinkCandidates(ops) {
+			if fails(candidate) {
+				ops, shrunk = candidate, true
+				break
+			}
+		}
+	}
+	return ops
+}
+
+// shrinkCandidates returns the sequences one shrinking step away from ops,
+// simplest first.
+func shrinkCandidates(ops []op) [][]op {
+	var candidates [][]op
+	replace := func(i int, with ...op) {
+		candidate := append(append(append([]op{}, ops[:i]...), with...), ops[i+1:]...)
+		candidates = append(candidates, candidate)
+	}
+
+	for i := range ops {
+		replace(i)
+	}
+	for i, o := range ops {
+		if o.kind != opConcurrent {
+			continue
+		}
+		replace(i, o.batch...)
+		for j := range o.batch {
+			smaller := o
+			smaller.batch = append(append([]op{}, o.batch[:j]...), o.batch[j+1:]...)
+			replace(i, smaller)
+		}
+	}
+	for i, o := range ops {
+		if o.account != 0 {
+			simpler := o
+			simpler.account = 0
+			replace(i, simpler)
+		}
+		if o.cents > 1 || o.cents < -1 {
+			for _, cents := range []int64{o.cents / abs(o.cents), o.cents / 2} {
+				simpler := o
+				simpler.cents = cents
+				replace(i, simpler)
+			}
+		}
+	}
+	return candidates
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func formatOps(ops []op) string {
+	var b strings.Builder
+	for i, o := range ops {
+		fmt.Fprintf(&b, "\n\t%d: %s", i, o)
+	}
+	return b.String()
+}
+
+func TestAccountServiceInvariants(t *testing.T) {
+	seed := *propertySeed
+	if seed == 0 {
+		seed = uint64(time.Now().UnixNano())
+	}
+	runs := *propertyRuns
+	if testing.Short() {
+		runs = min(runs, 20)
+	}
+	r := rand.New(rand.NewPCG(seed, 0))
+
+	for run := range runs {
+		ops := genOps(r, *propertySteps)
+		if err := runOps(ops); err != nil {
+			shrunk := shrink(ops, func(ops []op) bool { return runOps(ops) != nil })
+			t.Fatalf("run %d of seed %d broke the books: %v\nshrunk from %d to %d steps:%s\nrerun with -property.seed=%d",
+				run, seed, runOps(shrunk), len(ops), len(shrunk), formatOps(shrunk), seed)
+		}
+	}
+}
+
+func TestShrinkFindsMinimalSequence(t *testing.T) {
+	// A stand-in failure: some account is charged more than 100.00 in one go
+	fails := func(ops []op) bool {
+		for _, o := range ops {
+			if o.kind == opConcurrent {
+				for _, b := range o.batch {
+					if b.kind == opCharge && b.cents > 10_000 {
+						return true
+					}
+				}
+			}
+			if o.kind == opCharge && o.cents > 10_000 {
+				return true
+			}
+		}
+		return false
+	}
+	ops := []op{
+		{kind: opCreate},
+		{kind: opTopUp, account: 2, cents: 50_000},
+		{kind: opConcurrent, batch: []op{
+			{kind: opTopUp, account: 1, cents: 300},
+			{kind: opCharge, account: 3, cents: 40_000},
+		}},
+		{kind: opFreeze, account: 1},
+	}
+
+	got := shrink(ops, fails)
+
+	// Halving the amount stops at the last value that still fails
+	if len(got) != 1 || got[0].kind != opCharge || got[0].account != 0 || got[0].cents != 20_000 {
+		t.Errorf("got %s want a single charge of 200.00 to account #0", formatOps(got))
+	}
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+	"wallet/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ReconciliationMismatch describes an account whose stored balances do not
+// agree with the balances recomputed from its transactions. Difference is
+// that of the posted balances.
+type ReconciliationMismatch struct {
+	AccountID                uuid.UUID `json:"account_id"`
+	StoredBalance            float64   `json:"stored_balance"`
+	ComputedBalance          float64   `json:"computed_balance"`
+	Difference               float64   `json:"difference"`
+	StoredAvailableBalance   float64   `json:"stored_available_balance"`
+	ComputedAvailableBalance float64   `json:"computed_available_balance"`
+	TransactionCount         int64     `json:"transaction_count"`
+}
+
+// UnfundedTopUp is a top-up that was posted although its payment failed,
+// and could not be reversed, so it credited money that was never collected.
+type UnfundedTopUp struct {
+	TransactionID uuid.UUID `json:"transaction_id"`
+	AccountID     uuid.UUID `json:"account_id"`
+	Amount        float64   `json:"amount"`
+	IntentID      string    `json:"intent_id"`
+}
+
+// ReconciliationReport is the outcome of a single reconciliation run.
+type ReconciliationReport struct {
+	StartedAt       time.Time                `json:"started_at"`
+	FinishedAt      time.Time                `json:"finished_at"`
+	AccountsChecked int                      `json:"accounts_checked"`
+	Mismatches      []ReconciliationMismatch `json:"mismatches"`
+	UnfundedTopUps  []UnfundedTopUp          `json:"unfunded_top_ups"`
+}
+
+// Balanced reports whether every checked account matched its transactions
+// and every posted top-up was funded.
+func (r *ReconciliationReport) Balanced() bool {
+	return len(r.Mismatches) == 0 && len(r.UnfundedTopUps) == 0
+}
+
+type ReconciliationService interface {
+	Reconcile(ctx context.Context) (*ReconciliationReport, error)
+	LastReport() *ReconciliationReport
+}
+
+type reconciliationService struct {
+	db *gorm.DB
+
+	mu   sync.RWMutex
+	last *ReconciliationReport
+}
+
+func NewReconciliationService(db *gorm.DB) ReconciliationService {
+	return &reconciliationService{db: db}
+}
+
+// heldAmountSQL sums the pending charges held against an account.
+const heldAmountSQL = `COALESCE(SUM(CASE
+	WHEN transactions.status = 'pending' AND transactions.transaction_type = 'charge' THEN transactions.amount
+	ELSE 0 END), 0)`
+
+// accountLedger is the per-account aggregate scanned from the database.
+type accountLedger struct {
+	ID               uuid.UUID
+	Balance          float64
+	AvailableBalance float64
+	Computed         float64
+	Held             float64
+	TransactionCount int64
+}
+
+// Reconcile recomputes each account's posted balance from its posted
+// transactions, and its available balance by taking off the pending charges,
+// and reports every account whose stored balances differ, along with the
+// posted top-ups whose payment failed. The scan runs until done or ctx is
+// canceled, without a deadline of its own.
+func (s *reconciliationService) Reconcile(ctx context.Context) (*ReconciliationReport, error) {
+	report := &ReconciliationReport{
+		StartedAt:      time.Now(),
+		Mismatches:     []ReconciliationMismatch{},
+		UnfundedTopUps: []UnfundedTopUp{},
+	}
+
+	var ledgers []accountLedger
+	err := s.db.WithContext(ctx).Model(&models.Account{}).
+		Select("accounts.id, accounts.balance, accounts.available_balance, " + signedAmountSQL + " AS computed, " + heldAmountSQL + " AS held, COUNT(transactions.id) AS transaction_count").
+		Joins("LEFT JOIN transactions ON transactions.account_id = accounts.id AND transactions.deleted_at IS NULL").
+		Group("accounts.id, accounts.balance, accounts.available_balance").
+		Scan(&ledgers).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range ledgers {
+		stored := math.Round(l.Balance*100) / 100
+		computed := math.Round(l.Computed*100) / 100
+		storedAvailable := math.Round(l.AvailableBalance*100) / 100
+		computedAvailable := math.Round((l.Computed-l.Held)*100) / 100
+		if stored != computed || storedAvailable != computedAvailable {
+			report.Mismatches = append(report.Mismatches, ReconciliationMismatch{
+				AccountID:                l.ID,
+				StoredBalance:            stored,
+				ComputedBalance:          computed,
+				Difference:               math.Round((stored-computed)*100) / 100,
+				StoredAvailableBalance:   storedAvailable,
+				ComputedAvailableBalance: computedAvailable,
+				TransactionCount:         l.TransactionCount,
+			})
+		}
+	}
+
+	err = s.db.WithContext(ctx).Model(&models.Payment{}).
+		Select("payments.transaction_id, payments.account_id, payments.amount, payments.intent_id").
+		Joins("JOIN transactions ON transactions.id = payments.transaction_id").
+		Where("payments.status = ? AND transactions.status = ?", models.PaymentFailed, models.TransactionPosted).
+		Order("payments.created_at").
+		Scan(&report.UnfundedTopUps).Error
+	if err != nil {
+		return nil, err
+	}
+
+	report.AccountsChecked = len(ledgers)
+	report.FinishedAt = time.Now()
+
+	s.mu.Lock()
+	s.last = report
+	s.mu.Unlock()
+
+	return report, nil
+}
+
+// LastReport returns the result of the most recent run, or nil if
+// reconciliation has not run yet in this process.
+func (s *reconciliationService) LastReport() *ReconciliationReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.last
+}
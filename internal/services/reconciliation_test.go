@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"wallet/internal/models"
+)
+
+func TestReconcile(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+	accounts := NewAccountService(db)
+	reconciliation := NewReconciliationService(db)
+
+	balanced := newTestAccount(t, accounts)
+	drifted, err := accounts.CreateAccount(ctx, balanced.UserID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, account := range []*models.Account{balanced, drifted} {
+		if _, err := accounts.TopUp(ctx, account.ID, 100, models.TransactionDetails{}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := accounts.PendingCharge(ctx, account.ID, 30, models.TransactionDetails{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if reconciliation.LastReport() != nil {
+		t.Error("got a last report before the first run")
+	}
+	report, err := reconciliation.Reconcile(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.Balanced() || report.AccountsChecked != 2 {
+		t.Errorf("untouched books: got %+v want 2 balanced accounts", report)
+	}
+	if reconciliation.LastReport() != report {
+		t.Error("got a last report other than the run's")
+	}
+
+	tests := []struct {
+		name string
+		// drift changes the books of the drifted account behind the
+		// services' back
+		drift string
+		want  ReconciliationMismatch
+	}{
+		{
+			name:  "projected balance",
+			drift: `UPDATE accounts SET balance = balance + 5 WHERE id = ?`,
+			want:  ReconciliationMismatch{StoredBalance: 105, ComputedBalance: 100, Difference: 5, StoredAvailableBalance: 70, ComputedAvailableBalance: 70},
+		},
+		{
+			name:  "projected available balance",
+			drift: `UPDATE accounts SET available_balance = available_balance + 30 WHERE id = ?`,
+			want:  ReconciliationMismatch{StoredBalance: 100, ComputedBalance: 100, StoredAvailableBalance: 100, ComputedAvailableBalance: 70},
+		},
+		{
+			name:  "ledger",
+			drift: `UPDATE transactions SET amount = 90 WHERE account_id = ? AND transaction_type = 'top-up'`,
+			want:  ReconciliationMismatch{StoredBalance: 100, ComputedBalance: 90, Difference: 10, StoredAvailableBalance: 70, ComputedAvailableBalance: 60},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := db.Begin()
+			defer tx.Rollback()
+			mustExec(t, tx, tt.drift, drifted.ID)
+
+			report, err := NewReconciliationService(tx).Reconcile(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if report.Balanced() || len(report.Mismatches) != 1 {
+				t.Fatalf("got mismatches %+v want the drifted account only", report.Mismatches)
+			}
+			want := tt.want
+			want.AccountID = drifted.ID
+			want.TransactionCount = 2
+			if got := report.Mismatches[0]; got != want {
+				t.Errorf("got mismatch %+v want %+v", got, want)
+			}
+		})
+	}
+}
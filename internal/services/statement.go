@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"math"
+	"os"
+	"time"
+	"wallet/internal/models"
+	"wallet/internal/repository"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultCurrency is the currency balances are reported in when
+// WALLET_CURRENCY is not set.
+const DefaultCurrency = "USD"
+
+// Currency returns the ISO 4217 code of the currency the wallet keeps its
+// balances in.
+func Currency() string {
+	if currency := os.Getenv("WALLET_CURRENCY"); currency != "" {
+		return currency
+	}
+	return DefaultCurrency
+}
+
+// StatementLine is a transaction of a statement with the balance it left
+// the account at.
+type StatementLine struct {
+	Transaction models.Transaction
+	Balance     float64
+}
+
+// Statement is the transactions posted to an account over a period, from
+// From up to but excluding To, between its opening and closing balances.
+type Statement struct {
+	Account  models.Account
+	Currency string
+	From     time.Time
+	To       time.Time
+	// GeneratedAt is when the statement was put together.
+	GeneratedAt    time.Time
+	OpeningBalance float64
+	ClosingBalance float64
+	TotalCredits   float64
+	TotalDebits    float64
+	Lines          []StatementLine
+}
+
+type StatementService interface {
+	Statement(ctx context.Context, accountID uuid.UUID, from, to time.Time) (*Statement, error)
+}
+
+type statementService struct {
+	db       *gorm.DB
+	balances *balanceService
+}
+
+func NewStatementService(db *gorm.DB) StatementService {
+	return &statementService{db: db, balances: &balanceService{db: db}}
+}
+
+// Statement returns the statement of an account over [from, to). The
+// closing balance is the opening balance plus the lines, so the two always
+// add up. They are read outside a database transaction, which would take
+// the write lock.
+func (s *statementService) Statement(ctx context.Context, accountID uuid.UUID, from, to time.Time) (*Statement, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	if !from.Before(to) {
+		return nil, ErrInvalidPeriod
+	}
+
+	db := s.db.WithContext(ctx)
+	store := repository.NewGormStore(db)
+	account, err := store.Accounts().GetByID(ctx, accountID)
+	if err != nil {
+		return nil, contextError(ctx, notFound(err, ErrAccountNotFound))
+	}
+	user, err := store.Users().GetByID(ctx, account.UserID)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	account.User = *user
+
+	opening, err := s.balances.balanceAt(db, accountID, from, false)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	transactions, err := store.Transactions().ListPostedBetween(ctx, accountID, from, to)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	statement := &Statement{Account: *account, Currency: Currency(), From: from, To: to, GeneratedAt: time.Now()}
+	balance := opening.Balance
+	statement.OpeningBalance = balance
+	statement.Lines = make([]StatementLine, len(transactions))
+	for i, t := range transactions {
+		amount := t.SignedAmount()
+		balance += amount
+		if amount < 0 {
+			statement.TotalDebits -= amount
+		} else {
+			statement.TotalCredits += amount
+		}
+		balance = math.Round(balance*100) / 100
+		statement.Lines[i] = StatementLine{Transaction: t, Balance: balance}
+	}
+	statement.ClosingBalance = balance
+	statement.TotalCredits = math.Round(statement.TotalCredits*100) / 100
+	statement.TotalDebits = math.Round(statement.TotalDebits*100) / 100
+	return statement, nil
+}
@@ -1,39 +1,145 @@
 package services
 
 import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"time"
 	"wallet/internal/models"
+	"wallet/internal/repository"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// Page sizes used by ListTransactions.
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 100
+)
+
+// TransactionPage is a page of an account's transactions, newest first.
+type TransactionPage struct {
+	Transactions []models.Transaction `json:"transactions"`
+	// NextCursor continues after the last transaction of the page; it is
+	// empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
 type TransactionService interface {
-	GetTransactionByRef(ref string) (*models.Transaction, error)
-	GetTransactionsByAccountID(accountID uuid.UUID) ([]models.Transaction, error)
+	GetTransactionByRef(ctx context.Context, ref string) (*models.Transaction, error)
+	GetTransactionsByAccountID(ctx context.Context, accountID uuid.UUID) ([]models.Transaction, error)
+	ListTransactions(ctx context.Context, accountID uuid.UUID, cursor string, limit int) (*TransactionPage, error)
+	// SearchTransactions pages through the account's transactions that
+	// match filter, like ListTransactions.
+	SearchTransactions(ctx context.Context, accountID uuid.UUID, filter repository.TransactionFilter, cursor string, limit int) (*TransactionPage, error)
 }
 
 type transactionService struct {
-	db *gorm.DB
+	store repository.Store
 }
 
 func NewTransactionService(db *gorm.DB) TransactionService {
-	return &transactionService{db: db}
+	return NewTransactionServiceWithStore(repository.NewGormStore(db))
 }
 
-func (s *transactionService) GetTransactionByRef(ref string) (*models.Transaction, error) {
-	var transaction models.Transaction
-	err := s.db.First(&transaction, "ref = ?", ref).Error
+// NewTransactionServiceWithStore returns a TransactionService that reads
+// from store.
+func NewTransactionServiceWithStore(store repository.Store) TransactionService {
+	return &transactionService{store: store}
+}
+
+func (s *transactionService) GetTransactionByRef(ctx context.Context, ref string) (*models.Transaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	transaction, err := s.store.Transactions().GetByRef(ctx, ref)
 	if err != nil {
-		return nil, err
+		return nil, notFound(contextError(ctx, err), ErrTransactionNotFound)
 	}
-	return &transaction, nil
+	return transaction, nil
 }
 
-func (s *transactionService) GetTransactionsByAccountID(accountID uuid.UUID) ([]models.Transaction, error) {
-	var transactions []models.Transaction
-	err := s.db.Where("account_id = ?", accountID).Find(&transactions).Error
+// GetTransactionsByAccountID returns all of an account's transactions,
+// oldest first.
+func (s *transactionService) GetTransactionsByAccountID(ctx context.Context, accountID uuid.UUID) ([]models.Transaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	transactions, err := s.store.Transactions().ListByAccountID(ctx, accountID)
 	if err != nil {
-		return nil, err
+		return nil, contextError(ctx, err)
 	}
 	return transactions, nil
 }
+
+// ListTransactions returns up to limit transactions of the account created
+// before the given cursor, or the newest ones if the cursor is empty. The
+// limit falls back to DefaultPageSize and is capped at MaxPageSize.
+func (s *transactionService) ListTransactions(ctx context.Context, accountID uuid.UUID, cursor string, limit int) (*TransactionPage, error) {
+	return s.SearchTransactions(ctx, accountID, repository.TransactionFilter{}, cursor, limit)
+}
+
+func (s *transactionService) SearchTransactions(ctx context.Context, accountID uuid.UUID, filter repository.TransactionFilter, cursor string, limit int) (*TransactionPage, error) {
+	filter.Query = strings.TrimSpace(filter.Query)
+	filter.ExternalRef = strings.TrimSpace(filter.ExternalRef)
+	filter.MerchantCategoryCode = strings.TrimSpace(filter.MerchantCategoryCode)
+	if filter.MerchantCategoryCode != "" && !isMerchantCategoryCode(filter.MerchantCategoryCode) {
+		return nil, &ValidationError{Field: "merchant_category_code", Message: "merchant_category_code must be 4 digits"}
+	}
+
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+	limit = min(limit, MaxPageSize)
+
+	var after *repository.TransactionKey
+	if cursor != "" {
+		key, err := decodeTransactionCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		after = &key
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	// Fetch one extra row to find out whether there is another page
+	transactions, err := s.store.Transactions().ListPage(ctx, accountID, filter, after, limit+1)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+
+	page := &TransactionPage{Transactions: transactions}
+	if len(transactions) > limit {
+		page.Transactions = transactions[:limit]
+		last := page.Transactions[limit-1]
+		page.NextCursor = TransactionCursor(last)
+	}
+	return page, nil
+}
+
+// TransactionCursor builds the opaque cursor that continues a listing after t.
+func TransactionCursor(t models.Transaction) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(repository.UTCTimestamp(t.CreatedAt) + "|" + t.ID.String()))
+}
+
+func decodeTransactionCursor(cursor string) (repository.TransactionKey, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return repository.TransactionKey{}, ErrInvalidCursor
+	}
+	createdAt, id, ok := strings.Cut(string(raw), "|")
+	if !ok {
+		return repository.TransactionKey{}, ErrInvalidCursor
+	}
+	key := repository.TransactionKey{}
+	if key.CreatedAt, err = time.Parse(repository.TimestampLayout, createdAt); err != nil {
+		return repository.TransactionKey{}, ErrInvalidCursor
+	}
+	if key.ID, err = uuid.Parse(id); err != nil {
+		return repository.TransactionKey{}, ErrInvalidCursor
+	}
+	return key, nil
+}
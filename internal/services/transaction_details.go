@@ -0,0 +1,83 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+	"wallet/internal/models"
+)
+
+// Limits on the details a client can give a transaction.
+const (
+	MaxDescriptionLength  = 255
+	MaxExternalRefLength  = 100
+	MaxMerchantNameLength = 100
+	MaxMetadataSize       = 4096
+)
+
+// normalizeTransactionDetails trims the details of a new top-up or charge
+// and checks them against the limits. Metadata must be a JSON object and is
+// stored compacted.
+func normalizeTransactionDetails(details models.TransactionDetails) (models.TransactionDetails, error) {
+	details.Description = strings.TrimSpace(details.Description)
+	details.ExternalRef = strings.TrimSpace(details.ExternalRef)
+	details.MerchantName = strings.TrimSpace(details.MerchantName)
+	details.MerchantCategoryCode = strings.TrimSpace(details.MerchantCategoryCode)
+
+	for _, field := range []struct {
+		name, value string
+		max         int
+	}{
+		{"description", details.Description, MaxDescriptionLength},
+		{"external_ref", details.ExternalRef, MaxExternalRefLength},
+		{"merchant_name", details.MerchantName, MaxMerchantNameLength},
+	} {
+		if utf8.RuneCountInString(field.value) > field.max {
+			return details, &ValidationError{Field: field.name, Message: fmt.Sprintf("%s must be at most %d characters", field.name, field.max)}
+		}
+	}
+	if code := details.MerchantCategoryCode; code != "" && !isMerchantCategoryCode(code) {
+		return details, &ValidationError{Field: "merchant_category_code", Message: "merchant_category_code must be 4 digits"}
+	}
+
+	if len(details.Metadata) == 0 || string(details.Metadata) == "null" {
+		details.Metadata = nil
+		return details, nil
+	}
+	var object map[string]any
+	if err := json.Unmarshal(details.Metadata, &object); err != nil || object == nil {
+		return details, &ValidationError{Field: "metadata", Message: "metadata must be a JSON object"}
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, details.Metadata); err != nil {
+		return details, &ValidationError{Field: "metadata", Message: "metadata must be a JSON object"}
+	}
+	if compact.Len() > MaxMetadataSize {
+		return details, &ValidationError{Field: "metadata", Message: fmt.Sprintf("metadata must be at most %d bytes", MaxMetadataSize)}
+	}
+	details.Metadata = compact.Bytes()
+	return details, nil
+}
+
+// truncateText cuts text to at most max characters, for details the wallet
+// fills in from longer fields of its own.
+func truncateText(text string, max int) string {
+	if utf8.RuneCountInString(text) <= max {
+		return text
+	}
+	return string([]rune(text)[:max])
+}
+
+func isMerchantCategoryCode(code string) bool {
+	if len(code) != 4 {
+		return false
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
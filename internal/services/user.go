@@ -1,39 +1,50 @@
 package services
 
 import (
+	"context"
 	"wallet/internal/models"
+	"wallet/internal/repository"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
 type UserService interface {
-	GetUserByEmail(email string) (*models.User, error)
-	GetUserByID(id uuid.UUID) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error)
 }
 
 type userService struct {
-	db *gorm.DB
+	store repository.Store
 }
 
-func (s *userService) GetUserByEmail(email string) (*models.User, error) {
-	var user models.User
-	err := s.db.First(&user, "email = ?", email).Error
+func (s *userService) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	user, err := s.store.Users().GetByEmail(ctx, email)
 	if err != nil {
-		return nil, err
+		return nil, notFound(contextError(ctx, err), ErrUserNotFound)
 	}
-	return &user, nil
+	return user, nil
 }
 
-func (s *userService) GetUserByID(id uuid.UUID) (*models.User, error) {
-	var user models.User
-	err := s.db.First(&user, "id = ?", id).Error
+func (s *userService) GetUserByID(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+
+	user, err := s.store.Users().GetByID(ctx, id)
 	if err != nil {
-		return nil, err
+		return nil, notFound(contextError(ctx, err), ErrUserNotFound)
 	}
-	return &user, nil
+	return user, nil
 }
 
 func NewUserService(db *gorm.DB) UserService {
-	return &userService{db: db}
+	return NewUserServiceWithStore(repository.NewGormStore(db))
+}
+
+// NewUserServiceWithStore returns a UserService that reads from store.
+func NewUserServiceWithStore(store repository.Store) UserService {
+	return &userService{store: store}
 }
@@ -0,0 +1,630 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"wallet/internal/banking"
+	"wallet/internal/models"
+	"wallet/internal/payout"
+	"wallet/internal/repository"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"gorm.io/gorm"
+)
+
+// MaxPayoutEntries bounds the withdrawals paid out per run, so a payout
+// file stays within what banks accept.
+const MaxPayoutEntries = 5_000
+
+// DefaultPayoutOutbox is the directory payout files are written to unless
+// PAYOUT_OUTBOX_DIR says otherwise.
+const DefaultPayoutOutbox = "outbox"
+
+// achAccountNumber is the form of the account numbers ACH entries carry.
+var achAccountNumber = regexp.MustCompile(`^[0-9A-Za-z]{1,17}$`)
+
+// PayoutConfig is where payout files are written and who they pay from.
+type PayoutConfig struct {
+	Outbox     string
+	Originator payout.Originator
+}
+
+// PayoutConfigFromEnv reads the outbox from PAYOUT_OUTBOX_DIR and the
+// originator as payout.OriginatorFromEnv does.
+func PayoutConfigFromEnv() PayoutConfig {
+	outbox := os.Getenv("PAYOUT_OUTBOX_DIR")
+	if outbox == "" {
+		outbox = DefaultPayoutOutbox
+	}
+	return PayoutConfig{Outbox: outbox, Originator: payout.OriginatorFromEnv()}
+}
+
+// WithdrawalService pays money out of accounts to their holders' bank
+// accounts. A withdrawal holds its amount until a payout run writes it into
+// a file for the bank, and is completed or returned once the bank's answer
+// is imported.
+type WithdrawalService interface {
+	// AddBeneficiary validates and saves a bank account the account can
+	// withdraw to: an IBAN, with an optional BIC, or an ACH routing and
+	// account number.
+	AddBeneficiary(ctx context.Context, accountID uuid.UUID, beneficiary models.Beneficiary) (*models.Beneficiary, error)
+	ListBeneficiaries(ctx context.Context, accountID uuid.UUID) ([]models.Beneficiary, error)
+	// Withdraw requests a withdrawal to one of the account's beneficiaries,
+	// holding the amount against the available balance.
+	Withdraw(ctx context.Context, accountID, beneficiaryID uuid.UUID, amount float64) (*models.Withdrawal, error)
+	GetWithdrawal(ctx context.Context, id uuid.UUID) (*models.Withdrawal, error)
+	// RunPayouts writes the requested withdrawals into a payout file per
+	// format in the outbox and returns the files written.
+	RunPayouts(ctx context.Context) ([]models.Payout, error)
+	// ImportReturns applies a pain.002 status report or a NACHA return
+	// file: accepted withdrawals are completed, posting their charge, and
+	// returned ones give their amount back.
+	ImportReturns(ctx context.Context, r io.Reader) (*ReturnsReport, error)
+	// WithActor returns a copy of the service that attributes the changes
+	// it makes to the given actor in the audit log.
+	WithActor(actor Actor) WithdrawalService
+}
+
+// ReturnsReport is the outcome of importing a return file.
+type ReturnsReport struct {
+	Format    models.PayoutFormat `json:"format"`
+	Completed int                 `json:"completed"`
+	Returned  int                 `json:"returned"`
+	// Unchanged counts the statuses that were already applied, e.g. by an
+	// earlier import of the same file.
+	Unchanged int            `json:"unchanged"`
+	Errors    []ReturnsError `json:"errors"`
+}
+
+// ReturnsError is a status of a return file that could not be applied.
+type ReturnsError struct {
+	Reference string `json:"reference"`
+	Error     string `json:"error"`
+}
+
+type withdrawalService struct {
+	store    repository.Store
+	accounts AccountService
+	audit    AuditService
+	config   PayoutConfig
+	actor    Actor
+}
+
+func NewWithdrawalService(db *gorm.DB, config PayoutConfig) WithdrawalService {
+	return &withdrawalService{
+		store:    repository.NewGormStore(db),
+		accounts: withdrawalSettler(NewAccountService(db)),
+		audit:    NewAuditService(db),
+		config:   config,
+		actor:    SystemActor,
+	}
+}
+
+// NewWithdrawalServiceWithStore returns a WithdrawalService that keeps
+// withdrawals and accounts in store.
+func NewWithdrawalServiceWithStore(store repository.Store, config PayoutConfig) WithdrawalService {
+	return &withdrawalService{
+		store:    store,
+		accounts: withdrawalSettler(NewAccountServiceWithStore(store)),
+		audit:    &auditService{key: auditKeyFromEnv()},
+		config:   config,
+		actor:    SystemActor,
+	}
+}
+
+// withdrawalSettler returns a copy of accounts that settles the holds of
+// withdrawals.
+func withdrawalSettler(accounts AccountService) AccountService {
+	clone := *accounts.(*accountService)
+	clone.settlesWithdrawals = true
+	return &clone
+}
+
+func (s *withdrawalService) WithActor(actor Actor) WithdrawalService {
+	clone := *s
+	clone.actor = actor
+	return &clone
+}
+
+func (s *withdrawalService) AddBeneficiary(ctx context.Context, accountID uuid.UUID, beneficiary models.Beneficiary) (_ *models.Beneficiary, err error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	ctx, span := accountTracer.Start(ctx, "WithdrawalService.AddBeneficiary")
+	defer endSpan(ctx, span, &err)
+	span.SetAttributes(attribute.String("account.id", accountID.String()))
+
+	if err := validateBeneficiary(&beneficiary); err != nil {
+		return nil, err
+	}
+	if _, err := s.store.Accounts().GetByID(ctx, accountID); err != nil {
+		return nil, notFound(err, ErrAccountNotFound)
+	}
+	beneficiary.ID = uuid.Nil
+	beneficiary.AccountID = accountID
+
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		if err := tx.Beneficiaries().Create(ctx, &beneficiary); err != nil {
+			return err
+		}
+		return s.audit.Record(ctx, tx, s.actor, AuditEvent{
+			Action:     AuditBeneficiaryAdded,
+			EntityType: "beneficiary",
+			EntityID:   beneficiary.ID.String(),
+			After: map[string]string{
+				"account_id":     accountID.String(),
+				"name":           beneficiary.Name,
+				"format":         string(beneficiary.PayoutFormat()),
+				"account_number": maskAccountNumber(beneficiary.IBAN + beneficiary.AccountNumber),
+			},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "beneficiary added", "beneficiary_id", beneficiary.ID, "account_id", accountID, "format", beneficiary.PayoutFormat(), "actor", s.actor.Name)
+	return &beneficiary, nil
+}
+
+// validateBeneficiary checks a beneficiary's bank details and normalises
+// them.
+func validateBeneficiary(b *models.Beneficiary) error {
+	b.Name = strings.TrimSpace(b.Name)
+	if b.Name == "" {
+		return &ValidationError{Field: "name", Message: "a name is required"}
+	}
+
+	switch {
+	case b.IBAN != "" && (b.RoutingNumber != "" || b.AccountNumber != ""):
+		return &ValidationError{Field: "iban", Message: "give either an IBAN or a routing and account number, not both"}
+	case b.IBAN != "":
+		iban, err := banking.ValidateIBAN(b.IBAN)
+		if err != nil {
+			return &ValidationError{Field: "iban", Message: err.Error()}
+		}
+		b.IBAN = iban
+		if b.BIC != "" {
+			bic, err := banking.ValidateBIC(b.BIC)
+			if err != nil {
+				return &ValidationError{Field: "bic", Message: err.Error()}
+			}
+			b.BIC = bic
+		}
+		b.Savings = false
+	case b.RoutingNumber != "" || b.AccountNumber != "":
+		if err := banking.ValidateRoutingNumber(b.RoutingNumber); err != nil {
+			return &ValidationError{Field: "routing_number", Message: err.Error()}
+		}
+		if !achAccountNumber.MatchString(b.AccountNumber) {
+			return &ValidationError{Field: "account_number", Message: "an account number has 1 to 17 letters and digits"}
+		}
+		if b.BIC != "" {
+			return &ValidationError{Field: "bic", Message: "a BIC is only given with an IBAN"}
+		}
+	default:
+		return &ValidationError{Field: "iban", Message: "an IBAN, or a routing and account number, is required"}
+	}
+	return nil
+}
+
+// maskAccountNumber keeps the last four characters of an account number,
+// so the audit log identifies it without disclosing it.
+func maskAccountNumber(number string) string {
+	if len(number) <= 4 {
+		return number
+	}
+	return strings.Repeat("*", len(number)-4) + number[len(number)-4:]
+}
+
+func (s *withdrawalService) ListBeneficiaries(ctx context.Context, accountID uuid.UUID) (_ []models.Beneficiary, err error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+	ctx, span := accountTracer.Start(ctx, "WithdrawalService.ListBeneficiaries")
+	defer endSpan(ctx, span, &err)
+
+	if _, err := s.store.Accounts().GetByID(ctx, accountID); err != nil {
+		return nil, notFound(err, ErrAccountNotFound)
+	}
+	return s.store.Beneficiaries().ListByAccountID(ctx, accountID)
+}
+
+func (s *withdrawalService) Withdraw(ctx context.Context, accountID, beneficiaryID uuid.UUID, amount float64) (_ *models.Withdrawal, err error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	ctx, span := accountTracer.Start(ctx, "WithdrawalService.Withdraw")
+	defer endSpan(ctx, span, &err)
+	span.SetAttributes(attribute.String("account.id", accountID.String()))
+
+	if amount <= 0 {
+		return nil, ErrInvalidAmount
+	}
+	beneficiary, err := s.store.Beneficiaries().GetByID(ctx, beneficiaryID)
+	if err != nil {
+		return nil, notFound(err, ErrBeneficiaryNotFound)
+	}
+	if beneficiary.AccountID != accountID {
+		return nil, ErrBeneficiaryNotFound
+	}
+
+	accounts := s.accounts.WithActor(s.actor)
+	transaction, err := accounts.PendingCharge(ctx, accountID, amount, models.TransactionDetails{
+		Description: truncateText("Withdrawal to "+beneficiary.Name, MaxDescriptionLength),
+	})
+	if err != nil {
+		return nil, err
+	}
+	withdrawal := &models.Withdrawal{
+		AccountID:     accountID,
+		BeneficiaryID: beneficiaryID,
+		TransactionID: transaction.ID,
+		Amount:        transaction.Amount,
+	}
+	if err := s.store.Withdrawals().Create(ctx, withdrawal); err != nil {
+		// Release the hold of a withdrawal that will never be paid out
+		if _, failErr := accounts.FailTransaction(ctx, transaction.ID, "withdrawal not recorded"); failErr != nil {
+			slog.ErrorContext(ctx, "failed to release the hold of an unrecorded withdrawal", "transaction_id", transaction.ID, "error", failErr)
+		}
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "withdrawal requested", "withdrawal_id", withdrawal.ID, "account_id", accountID, "beneficiary_id", beneficiaryID, "amount", withdrawal.Amount, "actor", s.actor.Name)
+	return withdrawal, nil
+}
+
+func (s *withdrawalService) GetWithdrawal(ctx context.Context, id uuid.UUID) (_ *models.Withdrawal, err error) {
+	ctx, cancel := context.WithTimeout(ctx, readTimeout)
+	defer cancel()
+	ctx, span := accountTracer.Start(ctx, "WithdrawalService.GetWithdrawal")
+	defer endSpan(ctx, span, &err)
+
+	withdrawal, err := s.store.Withdrawals().GetByID(ctx, id)
+	if err != nil {
+		return nil, notFound(err, ErrWithdrawalNotFound)
+	}
+	return withdrawal, nil
+}
+
+// errNothingToPayOut is returned for a payout whose withdrawals were all
+// paid out by a concurrent run.
+var errNothingToPayOut = errors.New("nothing to pay out")
+
+// payoutItem is a withdrawal to pay out with the beneficiary it goes to.
+type payoutItem struct {
+	withdrawal  models.Withdrawal
+	beneficiary *models.Beneficiary
+}
+
+// RunPayouts pays out the oldest requested withdrawals. Those whose hold
+// was failed in the meantime, e.g. as it expired, are failed too. A file
+// that cannot be written leaves its withdrawals requested for the next run
+// and does not stop the other formats.
+func (s *withdrawalService) RunPayouts(ctx context.Context) (_ []models.Payout, err error) {
+	ctx, span := accountTracer.Start(ctx, "WithdrawalService.RunPayouts")
+	defer endSpan(ctx, span, &err)
+
+	requested, err := s.store.Withdrawals().ListByStatus(ctx, models.WithdrawalRequested, MaxPayoutEntries)
+	if err != nil {
+		return nil, err
+	}
+
+	items := map[models.PayoutFormat][]payoutItem{}
+	beneficiaries := map[uuid.UUID]*models.Beneficiary{}
+	for _, w := range requested {
+		transaction, err := s.store.Transactions().GetByID(ctx, w.TransactionID)
+		if err != nil {
+			return nil, err
+		}
+		if transaction.Status != models.TransactionPending {
+			w.Status = models.WithdrawalFailed
+			w.ReturnReason = fmt.Sprintf("hold was %s before the withdrawal was paid out", transaction.Status)
+			if err := s.store.Withdrawals().Update(ctx, &w); err != nil {
+				return nil, err
+			}
+			slog.WarnContext(ctx, "withdrawal failed before payout", "withdrawal_id", w.ID, "transaction_status", transaction.Status)
+			continue
+		}
+
+		beneficiary, ok := beneficiaries[w.BeneficiaryID]
+		if !ok {
+			if beneficiary, err = s.store.Beneficiaries().GetByID(ctx, w.BeneficiaryID); err != nil {
+				return nil, err
+			}
+			beneficiaries[w.BeneficiaryID] = beneficiary
+		}
+		format := beneficiary.PayoutFormat()
+		items[format] = append(items[format], payoutItem{withdrawal: w, beneficiary: beneficiary})
+	}
+
+	payouts := []models.Payout{}
+	var errs []error
+	for _, format := range []models.PayoutFormat{models.PayoutPain001, models.PayoutNACHA} {
+		if len(items[format]) == 0 {
+			continue
+		}
+		p, err := s.writePayout(ctx, format, items[format])
+		if errors.Is(err, errNothingToPayOut) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s payout: %w", format, err))
+			continue
+		}
+		payouts = append(payouts, *p)
+	}
+	return payouts, errors.Join(errs...)
+}
+
+// writePayout marks the withdrawals submitted and writes their file. The
+// file is written under a temporary name while the database transaction is
+// open and only renamed into the outbox once it commits, so the bank never
+// picks up a file whose withdrawals are still requested.
+func (s *withdrawalService) writePayout(ctx context.Context, format models.PayoutFormat, items []payoutItem) (_ *models.Payout, err error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	if err := os.MkdirAll(s.config.Outbox, 0o750); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(s.config.Outbox, ".payout-*")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	p := &models.Payout{ID: uuid.New(), Format: format, CreatedAt: time.Now()}
+	p.MessageID = payoutMessageID(p.ID)
+	extension := "xml"
+	if format == models.PayoutNACHA {
+		extension = "ach"
+	}
+	p.FileName = fmt.Sprintf("payout-%s-%s.%s", p.CreatedAt.Format("20060102-150405"), strings.ToLower(p.MessageID[2:10]), extension)
+
+	err = s.store.Transaction(ctx, func(tx repository.Store) error {
+		sequence, err := tx.Withdrawals().PayoutEntries(ctx, format)
+		if err != nil {
+			return err
+		}
+		file := &payout.File{MessageID: p.MessageID, CreatedAt: p.CreatedAt, Currency: Currency()}
+		for _, item := range items {
+			// Leave out what a concurrent run paid out since it was listed,
+			// and withdrawals whose hold expired since, which the next run
+			// fails
+			w, err := tx.Withdrawals().GetByID(ctx, item.withdrawal.ID)
+			if err != nil {
+				return err
+			}
+			if w.Status != models.WithdrawalRequested {
+				continue
+			}
+			hold, err := tx.Transactions().GetByID(ctx, w.TransactionID)
+			if err != nil {
+				return err
+			}
+			if hold.Status != models.TransactionPending {
+				continue
+			}
+			b := item.beneficiary
+			entry := payout.Entry{
+				Reference:     w.EndToEndID,
+				Amount:        w.Amount,
+				Name:          b.Name,
+				IBAN:          b.IBAN,
+				BIC:           b.BIC,
+				RoutingNumber: b.RoutingNumber,
+				AccountNumber: b.AccountNumber,
+				Savings:       b.Savings,
+				Remittance:    "Withdrawal " + w.EndToEndID,
+			}
+			if format == models.PayoutNACHA {
+				trace := payout.TraceNumber(s.config.Originator, sequence+len(file.Entries)+1)
+				w.TraceNumber = &trace
+				entry.Reference = trace
+				entry.Remittance = w.EndToEndID
+			}
+			w.Status = models.WithdrawalSubmitted
+			w.PayoutID = &p.ID
+			if err := tx.Withdrawals().Update(ctx, w); err != nil {
+				return err
+			}
+			file.Entries = append(file.Entries, entry)
+		}
+		if len(file.Entries) == 0 {
+			return errNothingToPayOut
+		}
+		p.Count = len(file.Entries)
+		p.Total = file.Total()
+		if err := tx.Withdrawals().CreatePayout(ctx, p); err != nil {
+			return err
+		}
+
+		if format == models.PayoutNACHA {
+			err = payout.WriteNACHA(tmp, s.config.Originator, file)
+		} else {
+			err = payout.WritePain001(tmp, s.config.Originator, file)
+		}
+		if err != nil {
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+
+		return s.audit.Record(ctx, tx, s.actor, AuditEvent{
+			Action:     AuditPayoutCreated,
+			EntityType: "payout",
+			EntityID:   p.ID.String(),
+			After:      map[string]any{"format": format, "file": p.FileName, "count": p.Count, "total": p.Total},
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(s.config.Outbox, p.FileName)); err != nil {
+		// The withdrawals are submitted, so the file must reach the outbox
+		slog.ErrorContext(ctx, "payout file not moved to the outbox", "payout_id", p.ID, "file", tmp.Name(), "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "payout file written", "payout_id", p.ID, "format", format, "file", p.FileName, "withdrawals", p.Count, "total", p.Total, "actor", s.actor.Name)
+	return p, nil
+}
+
+// payoutMessageID derives the message ID of a payout file from its ID, so
+// that a status report for the whole file leads back to it.
+func payoutMessageID(id uuid.UUID) string {
+	return "PO" + strings.ToUpper(strings.ReplaceAll(id.String(), "-", ""))
+}
+
+// ImportReturns applies every status of the file it can and reports the
+// others, so a file can be imported again once they are resolved.
+func (s *withdrawalService) ImportReturns(ctx context.Context, r io.Reader) (_ *ReturnsReport, err error) {
+	ctx, span := accountTracer.Start(ctx, "WithdrawalService.ImportReturns")
+	defer endSpan(ctx, span, &err)
+
+	format, statuses, err := payout.ParseReturns(r)
+	if err != nil {
+		return nil, &ValidationError{Field: "file", Message: err.Error()}
+	}
+	span.SetAttributes(attribute.String("payout.format", string(format)), attribute.Int("payout.statuses", len(statuses)))
+
+	report := &ReturnsReport{Format: format, Errors: []ReturnsError{}}
+	for _, status := range statuses {
+		withdrawals, err := s.returnedWithdrawals(ctx, format, status)
+		if err != nil {
+			reference := status.Reference
+			if reference == "" {
+				reference = status.MessageID
+			}
+			report.Errors = append(report.Errors, ReturnsError{Reference: reference, Error: err.Error()})
+			continue
+		}
+		for _, w := range withdrawals {
+			changed, err := s.applyReturn(ctx, &w, status)
+			switch {
+			case err != nil:
+				report.Errors = append(report.Errors, ReturnsError{Reference: w.EndToEndID, Error: err.Error()})
+			case !changed:
+				report.Unchanged++
+			case w.Status == models.WithdrawalCompleted:
+				report.Completed++
+			default:
+				report.Returned++
+			}
+		}
+	}
+
+	slog.InfoContext(ctx, "return file imported", "format", format, "completed", report.Completed, "returned", report.Returned, "unchanged", report.Unchanged, "errors", len(report.Errors), "actor", s.actor.Name)
+	return report, nil
+}
+
+// returnedWithdrawals returns the withdrawal a status is for, or every
+// withdrawal of its file if it answers for the whole file.
+func (s *withdrawalService) returnedWithdrawals(ctx context.Context, format models.PayoutFormat, status payout.Status) ([]models.Withdrawal, error) {
+	if status.Reference == "" {
+		id, err := uuid.Parse(strings.TrimPrefix(status.MessageID, "PO"))
+		if err != nil || !strings.HasPrefix(status.MessageID, "PO") {
+			return nil, fmt.Errorf("unknown payout message %q", status.MessageID)
+		}
+		return s.store.Withdrawals().ListByPayoutID(ctx, id)
+	}
+
+	var withdrawal *models.Withdrawal
+	var err error
+	if format == models.PayoutNACHA {
+		withdrawal, err = s.store.Withdrawals().GetByTraceNumber(ctx, status.Reference)
+	} else {
+		withdrawal, err = s.store.Withdrawals().GetByEndToEndID(ctx, status.Reference)
+	}
+	if err != nil {
+		return nil, notFound(err, ErrWithdrawalNotFound)
+	}
+	return []models.Withdrawal{*withdrawal}, nil
+}
+
+// applyReturn completes or returns a submitted withdrawal, and returns a
+// completed one whose transfer the bank sent back later. It reports whether
+// the withdrawal changed.
+func (s *withdrawalService) applyReturn(ctx context.Context, w *models.Withdrawal, status payout.Status) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+	accounts := s.accounts.WithActor(s.actor)
+
+	var err error
+	switch {
+	case w.Status == models.WithdrawalReturned, w.Status == models.WithdrawalCompleted && status.Outcome == payout.Accepted:
+		return false, nil
+
+	case w.Status == models.WithdrawalSubmitted && status.Outcome == payout.Accepted:
+		_, err = accounts.SettleTransaction(ctx, w.TransactionID)
+		err = s.alreadyApplied(ctx, err, w.TransactionID, models.TransactionPosted)
+		now := time.Now()
+		w.Status = models.WithdrawalCompleted
+		w.CompletedAt = &now
+
+	case w.Status == models.WithdrawalSubmitted:
+		_, err = accounts.FailTransaction(ctx, w.TransactionID, returnReason(status))
+		err = s.alreadyApplied(ctx, err, w.TransactionID, models.TransactionFailed)
+		w.Status = models.WithdrawalReturned
+
+	case w.Status == models.WithdrawalCompleted:
+		_, err = accounts.ReverseTransaction(ctx, w.TransactionID, returnReason(status))
+		err = s.alreadyApplied(ctx, err, w.TransactionID, models.TransactionReversed)
+		w.Status = models.WithdrawalReturned
+
+	default:
+		return false, fmt.Errorf("%w: a %s withdrawal was not paid out", ErrInvalidTransition, w.Status)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if status.Outcome == payout.Returned {
+		w.ReturnCode = status.Code
+		w.ReturnReason = status.Reason
+	}
+	if err := s.store.Withdrawals().Update(ctx, w); err != nil {
+		return false, err
+	}
+	slog.InfoContext(ctx, "withdrawal "+string(w.Status), "withdrawal_id", w.ID, "transaction_id", w.TransactionID, "return_code", w.ReturnCode, "actor", s.actor.Name)
+	return true, nil
+}
+
+// alreadyApplied drops the ErrInvalidTransition of a status change an
+// earlier, interrupted import already made to the withdrawal's transaction.
+func (s *withdrawalService) alreadyApplied(ctx context.Context, err error, transactionID uuid.UUID, want models.TransactionStatus) error {
+	if !errors.Is(err, ErrInvalidTransition) {
+		return err
+	}
+	if transaction, loadErr := s.store.Transactions().GetByID(ctx, transactionID); loadErr == nil && transaction.Status == want {
+		return nil
+	}
+	return err
+}
+
+// returnReason is the reason a returned withdrawal's transaction is failed
+// or reversed with.
+func returnReason(status payout.Status) string {
+	reason := "returned by the bank"
+	if status.Code != "" {
+		reason += ": " + status.Code
+	}
+	if status.Reason != "" {
+		reason += " " + status.Reason
+	}
+	return reason
+}
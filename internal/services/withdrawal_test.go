@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"wallet/internal/models"
+	"wallet/internal/payout"
+	"wallet/internal/repository"
+)
+
+// newTestWithdrawals returns a WithdrawalService on store that writes its
+// payout files to outbox.
+func newTestWithdrawals(store repository.Store, outbox string) WithdrawalService {
+	return NewWithdrawalServiceWithStore(store, PayoutConfig{
+		Outbox: outbox,
+		Originator: payout.Originator{
+			Name:          "Wallet Ltd",
+			IBAN:          "DE89370400440532013000",
+			BIC:           "COBADEFFXXX",
+			RoutingNumber: "021000021",
+			CompanyID:     "1234567890",
+		},
+	})
+}
+
+// sepaStatusReport returns a pain.002 report giving the status of a
+// withdrawal paid out with a pain.001 file.
+func sepaStatusReport(p models.Payout, w *models.Withdrawal, status string) string {
+	return `<?xml version="1.0"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:pain.002.001.03"><CstmrPmtStsRpt>
+<OrgnlGrpInfAndSts><OrgnlMsgId>` + p.MessageID + `</OrgnlMsgId><OrgnlMsgNmId>pain.001.001.03</OrgnlMsgNmId></OrgnlGrpInfAndSts>
+<OrgnlPmtInfAndSts><TxInfAndSts><OrgnlEndToEndId>` + w.EndToEndID + `</OrgnlEndToEndId><TxSts>` + status + `</TxSts></TxInfAndSts></OrgnlPmtInfAndSts>
+</CstmrPmtStsRpt></Document>`
+}
+
+func TestWithdrawals(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	accounts := NewAccountServiceWithStore(store)
+	outbox := t.TempDir()
+	withdrawals := newTestWithdrawals(store, outbox)
+	account := newTestAccount(t, accounts)
+	if _, err := accounts.TopUp(ctx, account.ID, 100, models.TransactionDetails{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var invalid *ValidationError
+	if _, err := withdrawals.AddBeneficiary(ctx, account.ID, models.Beneficiary{Name: "Jane Doe", IBAN: "DE88370400440532013000"}); !errors.As(err, &invalid) || invalid.Field != "iban" {
+		t.Errorf("wrong IBAN check digits: got error %v want a validation error for iban", err)
+	}
+	sepa, err := withdrawals.AddBeneficiary(ctx, account.ID, models.Beneficiary{Name: "Jane Doe", IBAN: "de89 3704 0044 0532 0130 00"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ach, err := withdrawals.AddBeneficiary(ctx, account.ID, models.Beneficiary{Name: "Jane Doe", RoutingNumber: "011000015", AccountNumber: "123456789"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	toSEPA, err := withdrawals.Withdraw(ctx, account.ID, sepa.ID, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	toACH, err := withdrawals.Withdraw(ctx, account.ID, ach.ID, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := withdrawals.Withdraw(ctx, account.ID, ach.ID, 60); !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("withdrawing more than is available: got error %v want %v", err, ErrInsufficientFunds)
+	}
+	checkBalances(t, accounts, account, 100, 50)
+
+	payouts, err := withdrawals.RunPayouts(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payouts) != 2 || payouts[0].Format != models.PayoutPain001 || payouts[1].Format != models.PayoutNACHA {
+		t.Fatalf("payouts: got %+v want a pain.001 and a NACHA file", payouts)
+	}
+	if again, err := withdrawals.RunPayouts(ctx); err != nil || len(again) != 0 {
+		t.Errorf("second payout run: got %d files, %v want none", len(again), err)
+	}
+	toACH, _ = withdrawals.GetWithdrawal(ctx, toACH.ID)
+	if toACH.Status != models.WithdrawalSubmitted || toACH.TraceNumber == nil {
+		t.Fatalf("paid out ACH withdrawal: got %s with trace number %v want submitted with one", toACH.Status, toACH.TraceNumber)
+	}
+
+	// Only the bank settles the holds of withdrawals
+	if _, err := accounts.FailTransaction(ctx, toACH.TransactionID, "manual"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("failing a withdrawal hold: got error %v want %v", err, ErrInvalidTransition)
+	}
+	if _, err := accounts.SettleTransaction(ctx, toSEPA.TransactionID); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("settling a withdrawal hold: got error %v want %v", err, ErrInvalidTransition)
+	}
+	checkBalances(t, accounts, account, 100, 50)
+
+	// The bank accepts the SEPA transfer and returns the ACH one
+	status := sepaStatusReport(payouts[0], toSEPA, "ACSC")
+	report, err := withdrawals.ImportReturns(ctx, strings.NewReader(status))
+	if err != nil || report.Completed != 1 || len(report.Errors) != 0 {
+		t.Fatalf("status report: got %+v, %v want 1 completed", report, err)
+	}
+	checkBalances(t, accounts, account, 70, 50)
+
+	returns := nachaReturnFile(t, outbox, "R03")
+	report, err = withdrawals.ImportReturns(ctx, strings.NewReader(returns))
+	if err != nil || report.Returned != 1 || len(report.Errors) != 0 {
+		t.Fatalf("return file: got %+v, %v want 1 returned", report, err)
+	}
+	checkBalances(t, accounts, account, 70, 70)
+	toACH, _ = withdrawals.GetWithdrawal(ctx, toACH.ID)
+	if toACH.Status != models.WithdrawalReturned || toACH.ReturnCode != "R03" {
+		t.Errorf("returned withdrawal: got %s with code %q want returned with R03", toACH.Status, toACH.ReturnCode)
+	}
+
+	if report, err = withdrawals.ImportReturns(ctx, strings.NewReader(returns)); err != nil || report.Unchanged != 1 {
+		t.Errorf("importing a return file twice: got %+v, %v want 1 unchanged", report, err)
+	}
+
+	// A completed withdrawal the bank returns later is reversed
+	status = strings.Replace(status, "<TxSts>ACSC</TxSts>", "<TxSts>RJCT</TxSts><StsRsnInf><Rsn><Cd>AC04</Cd></Rsn></StsRsnInf>", 1)
+	if report, err = withdrawals.ImportReturns(ctx, strings.NewReader(status)); err != nil || report.Returned != 1 {
+		t.Fatalf("late return: got %+v, %v want 1 returned", report, err)
+	}
+	checkBalances(t, accounts, account, 100, 100)
+
+	if files, _ := os.ReadDir(outbox); len(files) != 2 {
+		t.Errorf("outbox: got %d files want 2", len(files))
+	}
+}
+
+func TestPaidOutWithdrawalHoldsDoNotExpire(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryStore()
+	accounts := NewAccountServiceWithStore(store)
+	outbox := t.TempDir()
+	withdrawals := newTestWithdrawals(store, outbox)
+	account := newTestAccount(t, accounts)
+	if _, err := accounts.TopUp(ctx, account.ID, 100, models.TransactionDetails{}); err != nil {
+		t.Fatal(err)
+	}
+	sepa, err := withdrawals.AddBeneficiary(ctx, account.ID, models.Beneficiary{Name: "Jane Doe", IBAN: "DE89370400440532013000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ach, err := withdrawals.AddBeneficiary(ctx, account.ID, models.Beneficiary{Name: "Jane Doe", RoutingNumber: "011000015", AccountNumber: "123456789"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	toSEPA, err := withdrawals.Withdraw(ctx, account.ID, sepa.ID, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	toACH, err := withdrawals.Withdraw(ctx, account.ID, ach.ID, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payouts, err := withdrawals.RunPayouts(ctx)
+	if err != nil || len(payouts) != 2 {
+		t.Fatalf("payouts: got %d files, %v want 2", len(payouts), err)
+	}
+	unpaid, err := withdrawals.Withdraw(ctx, account.ID, sepa.ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the hold of the withdrawal not paid out yet expires
+	if n, err := accounts.ExpirePending(ctx, time.Now().Add(time.Second)); err != nil || n != 1 {
+		t.Fatalf("expired: got %d, %v want 1", n, err)
+	}
+	checkBalances(t, accounts, account, 100, 50)
+	if payouts, err := withdrawals.RunPayouts(ctx); err != nil || len(payouts) != 0 {
+		t.Errorf("payout run after expiry: got %d files, %v want none", len(payouts), err)
+	}
+	if unpaid, _ = withdrawals.GetWithdrawal(ctx, unpaid.ID); unpaid.Status != models.WithdrawalFailed {
+		t.Errorf("withdrawal whose hold expired: got %s want failed", unpaid.Status)
+	}
+
+	// The bank's outcomes still apply to the holds that did not expire
+	report, err := withdrawals.ImportReturns(ctx, strings.NewReader(sepaStatusReport(payouts[0], toSEPA, "ACSC")))
+	if err != nil || report.Completed != 1 || len(report.Errors) != 0 {
+		t.Fatalf("status report: got %+v, %v want 1 completed", report, err)
+	}
+	report, err = withdrawals.ImportReturns(ctx, strings.NewReader(nachaReturnFile(t, outbox, "R01")))
+	if err != nil || report.Returned != 1 || len(report.Errors) != 0 {
+		t.Fatalf("return file: got %+v, %v want 1 returned", report, err)
+	}
+	checkBalances(t, accounts, account, 70, 70)
+	if toACH, _ = withdrawals.GetWithdrawal(ctx, toACH.ID); toACH.Status != models.WithdrawalReturned {
+		t.Errorf("returned withdrawal: got %s want returned", toACH.Status)
+	}
+}
+
+func checkBalances(t *testing.T, accounts AccountService, account *models.Account, balance, available float64) {
+	t.Helper()
+	got, err := accounts.GetAccountByID(context.Background(), account.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Balance != balance || got.AvailableBalance != available {
+		t.Errorf("balances: got %v available %v want %v available %v", got.Balance, got.AvailableBalance, balance, available)
+	}
+}
+
+// nachaReturnFile returns the NACHA payout file in outbox as the bank
+// sends it back, with every entry returned for the given reason.
+func nachaReturnFile(t *testing.T, outbox, code string) string {
+	t.Helper()
+	files, _ := filepath.Glob(filepath.Join(outbox, "*.ach"))
+	if len(files) != 1 {
+		t.Fatalf("outbox: got %d NACHA files want 1", len(files))
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var records []string
+	for _, record := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		records = append(records, record)
+		if record[0] == '6' {
+			records = append(records, fmt.Sprintf("799%-3s%-15s%6s%-8s%-44s%-15s", code, record[79:94], "", record[3:11], "", "011000010000001"))
+		}
+	}
+	return strings.Join(records, "\n") + "\n"
+}
@@ -0,0 +1,189 @@
+package statement
+
+import (
+	"encoding/xml"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallet/internal/services"
+)
+
+const camtNamespace = "urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"
+
+// The camt.053.001.02 elements the wallet fills in, in schema order.
+type camtDocument struct {
+	XMLName   xml.Name      `xml:"Document"`
+	Namespace string        `xml:"xmlns,attr"`
+	Statement camtStatement `xml:"BkToCstmrStmt"`
+}
+
+type camtStatement struct {
+	GroupHeader struct {
+		MessageID string `xml:"MsgId"`
+		CreatedAt string `xml:"CreDtTm"`
+	} `xml:"GrpHdr"`
+	Stmt struct {
+		ID        string `xml:"Id"`
+		CreatedAt string `xml:"CreDtTm"`
+		Period    struct {
+			From string `xml:"FrDtTm"`
+			To   string `xml:"ToDtTm"`
+		} `xml:"FrToDt"`
+		Account struct {
+			ID       string `xml:"Id>Othr>Id"`
+			Currency string `xml:"Ccy"`
+			Owner    string `xml:"Ownr>Nm,omitempty"`
+		} `xml:"Acct"`
+		Balances []camtBalance `xml:"Bal"`
+		Summary  struct {
+			Entries struct {
+				Count     int    `xml:"NbOfNtries"`
+				Sum       string `xml:"Sum"`
+				NetAmount string `xml:"TtlNetNtryAmt"`
+				Indicator string `xml:"CdtDbtInd"`
+			} `xml:"TtlNtries"`
+			Credits camtEntryTotal `xml:"TtlCdtNtries"`
+			Debits  camtEntryTotal `xml:"TtlDbtNtries"`
+		} `xml:"TxsSummry"`
+		Entries []camtEntry `xml:"Ntry"`
+	} `xml:"Stmt"`
+}
+
+type camtAmount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+type camtBalance struct {
+	Type      string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount    camtAmount `xml:"Amt"`
+	Indicator string     `xml:"CdtDbtInd"`
+	Date      string     `xml:"Dt>Dt"`
+}
+
+type camtEntryTotal struct {
+	Count int    `xml:"NbOfNtries"`
+	Sum   string `xml:"Sum"`
+}
+
+type camtEntry struct {
+	Reference         string     `xml:"NtryRef"`
+	Amount            camtAmount `xml:"Amt"`
+	Indicator         string     `xml:"CdtDbtInd"`
+	Reversal          bool       `xml:"RvslInd,omitempty"`
+	Status            string     `xml:"Sts"`
+	BookedAt          string     `xml:"BookgDt>DtTm"`
+	ValueAt           string     `xml:"ValDt>DtTm"`
+	ServicerReference string     `xml:"AcctSvcrRef"`
+	BankCode          struct {
+		Code   string `xml:"Cd"`
+		Issuer string `xml:"Issr"`
+	} `xml:"BkTxCd>Prtry"`
+	Details struct {
+		ServicerReference string `xml:"Refs>AcctSvcrRef"`
+		Remittance        string `xml:"RmtInf>Ustrd"`
+	} `xml:"NtryDtls>TxDtls"`
+	AdditionalInfo string `xml:"AddtlNtryInf"`
+}
+
+// camtMaxText is the length of the Max35Text fields references go in.
+const camtMaxText = 35
+
+// WriteCAMT053 writes the statement as an ISO 20022 camt.053.001.02 bank to
+// customer statement, the version ERP systems most widely accept. Each
+// transaction is a booked entry whose remittance information carries its
+// full ref.
+func WriteCAMT053(w io.Writer, s *services.Statement) error {
+	number := accountNumber(s.Account)
+	end := periodEnd(s)
+
+	doc := camtDocument{Namespace: camtNamespace}
+	st := &doc.Statement
+	st.GroupHeader.MessageID = "STM-" + number[:16] + "-" + s.GeneratedAt.UTC().Format("20060102150405")
+	st.GroupHeader.CreatedAt = camtTime(s.GeneratedAt)
+	st.Stmt.ID = number[:16] + "-" + s.From.In(time.Local).Format("20060102") + "-" + end.In(time.Local).Format("20060102")
+	st.Stmt.CreatedAt = camtTime(s.GeneratedAt)
+	st.Stmt.Period.From = camtTime(s.From)
+	st.Stmt.Period.To = camtTime(end)
+	st.Stmt.Account.ID = number
+	st.Stmt.Account.Currency = s.Currency
+	st.Stmt.Account.Owner = strings.TrimSpace(s.Account.User.FirstName + " " + s.Account.User.LastName)
+	st.Stmt.Balances = []camtBalance{
+		camtBalanceOf("OPBD", s.OpeningBalance, s.From, s.Currency),
+		camtBalanceOf("CLBD", s.ClosingBalance, end, s.Currency),
+	}
+
+	summary := &st.Stmt.Summary
+	summary.Entries.Count = len(s.Lines)
+	summary.Entries.Sum = camtAmountValue(s.TotalCredits + s.TotalDebits)
+	summary.Entries.NetAmount = camtAmountValue(s.TotalCredits - s.TotalDebits)
+	summary.Entries.Indicator = camtIndicator(s.TotalCredits - s.TotalDebits)
+	summary.Credits.Sum = camtAmountValue(s.TotalCredits)
+	summary.Debits.Sum = camtAmountValue(s.TotalDebits)
+
+	st.Stmt.Entries = make([]camtEntry, len(s.Lines))
+	for i, l := range s.Lines {
+		t := l.Transaction
+		if t.SignedAmount() < 0 {
+			summary.Debits.Count++
+		} else {
+			summary.Credits.Count++
+		}
+
+		entry := &st.Stmt.Entries[i]
+		entry.Reference = shortRef(t, camtMaxText)
+		entry.Amount = camtAmount{Currency: s.Currency, Value: camtAmountValue(t.Amount)}
+		entry.Indicator = camtIndicator(t.SignedAmount())
+		entry.Reversal = t.ReversalOfID != nil
+		entry.Status = "BOOK"
+		entry.BookedAt = camtTime(bookedAt(t))
+		entry.ValueAt = camtTime(t.CreatedAt)
+		entry.ServicerReference = entry.Reference
+		entry.BankCode.Code = string(t.TransactionType)
+		entry.BankCode.Issuer = ofxBankID
+		entry.Details.ServicerReference = entry.Reference
+		entry.Details.Remittance = t.Ref
+		entry.AdditionalInfo = description(t) + " " + t.Ref
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func camtBalanceOf(code string, balance float64, t time.Time, currency string) camtBalance {
+	return camtBalance{
+		Type:      code,
+		Amount:    camtAmount{Currency: currency, Value: camtAmountValue(balance)},
+		Indicator: camtIndicator(balance),
+		Date:      t.In(time.Local).Format(time.DateOnly),
+	}
+}
+
+// camtIndicator marks an amount as a credit or a debit.
+func camtIndicator(amount float64) string {
+	if amount < 0 {
+		return "DBIT"
+	}
+	return "CRDT"
+}
+
+// camtAmountValue formats the absolute value of an amount; the sign is given
+// by the credit or debit indicator.
+func camtAmountValue(amount float64) string {
+	return strconv.FormatFloat(math.Abs(amount), 'f', 2, 64)
+}
+
+func camtTime(t time.Time) string {
+	return t.In(time.Local).Format(time.RFC3339)
+}
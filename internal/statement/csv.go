@@ -0,0 +1,37 @@
+package statement
+
+import (
+	"encoding/csv"
+	"io"
+	"time"
+
+	"wallet/internal/services"
+)
+
+// WriteCSV writes one row per transaction between an opening and a closing
+// balance row. Amounts are signed: credits are positive and debits negative.
+func WriteCSV(w io.Writer, s *services.Statement) error {
+	writer := csv.NewWriter(w)
+	rows := [][]string{
+		{"date", "reference", "type", "description", "amount", "balance", "currency"},
+		{s.From.Format(time.RFC3339), "", "opening_balance", "Opening balance", "", formatAmount(s.OpeningBalance), s.Currency},
+	}
+	for _, line := range s.Lines {
+		t := line.Transaction
+		rows = append(rows, []string{
+			bookedAt(t).Format(time.RFC3339Nano),
+			t.Ref,
+			string(t.TransactionType),
+			description(t),
+			formatAmount(t.SignedAmount()),
+			formatAmount(line.Balance),
+			s.Currency,
+		})
+	}
+	rows = append(rows, []string{s.To.Format(time.RFC3339), "", "closing_balance", "Closing balance", "", formatAmount(s.ClosingBalance), s.Currency})
+
+	if err := writer.WriteAll(rows); err != nil {
+		return err
+	}
+	return writer.Error()
+}
@@ -0,0 +1,125 @@
+package statement
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallet/internal/services"
+)
+
+// mt940NarrativeLines and mt940LineLength limit the :86: field to 6 lines
+// of 65 characters, and mt940RefLength the reference in the :61: field.
+const (
+	mt940NarrativeLines = 6
+	mt940LineLength     = 65
+	mt940RefLength      = 16
+)
+
+// WriteMT940 writes the statement as the text block of a SWIFT MT940
+// customer statement message. Dates are in server time, amounts use a
+// decimal comma and balances are marked C for credit or D for debit.
+func WriteMT940(w io.Writer, s *services.Statement) error {
+	b := bufio.NewWriter(w)
+	line := func(format string, args ...any) {
+		fmt.Fprintf(b, format+"\r\n", args...)
+	}
+
+	end := periodEnd(s)
+	currency := swiftText(s.Currency)
+	line(":20:STM%s%s", mt940Date(s.From), mt940Date(end))
+	line(":25:%s", accountNumber(s.Account))
+	line(":28C:1/1")
+	line(":60F:%s", mt940Balance(s.OpeningBalance, s.From, currency))
+	for _, l := range s.Lines {
+		t := l.Transaction
+		// Credits arrive as transfers; debits are booked as miscellaneous.
+		// A reversal is marked as reversing a debit or a credit instead.
+		code := "NTRF"
+		mark, reversalMark := "C", "RD"
+		if t.SignedAmount() < 0 {
+			code = "NMSC"
+			mark, reversalMark = "D", "RC"
+		}
+		if t.ReversalOfID != nil {
+			mark = reversalMark
+		}
+		// The value date is when the transaction was made, the entry date
+		// when it was posted. The reference is the start of the ref, which
+		// the narrative gives in full
+		line(":61:%s%s%s%s%s%s", mt940Date(t.CreatedAt), bookedAt(t).In(time.Local).Format("0102"), mark, mt940Amount(t.Amount), code, swiftText(t.Ref[:min(len(t.Ref), mt940RefLength)]))
+		for i, narrative := range mt940Narrative(description(t), t.Ref) {
+			if i == 0 {
+				line(":86:%s", narrative)
+			} else {
+				line("%s", narrative)
+			}
+		}
+	}
+	line(":62F:%s", mt940Balance(s.ClosingBalance, end, currency))
+	line(":64:%s", mt940Balance(s.ClosingBalance, end, currency))
+	line("-")
+
+	return b.Flush()
+}
+
+// mt940Balance formats a balance field: the credit or debit mark, the date,
+// the currency and the amount.
+func mt940Balance(balance float64, t time.Time, currency string) string {
+	mark := "C"
+	if balance < 0 {
+		mark = "D"
+	}
+	return mark + mt940Date(t) + currency + mt940Amount(balance)
+}
+
+func mt940Date(t time.Time) string {
+	return t.In(time.Local).Format("060102")
+}
+
+// mt940Amount formats the absolute value of an amount with a decimal comma.
+func mt940Amount(amount float64) string {
+	return strings.Replace(strconv.FormatFloat(math.Abs(amount), 'f', 2, 64), ".", ",", 1)
+}
+
+// mt940Narrative lays out the parts of an :86: field each on lines of their
+// own, so a ref is not broken up, dropping what does not fit. Lines are
+// broken early rather than start with a colon or a hyphen.
+func mt940Narrative(parts ...string) []string {
+	var lines []string
+	for _, text := range parts {
+		text = swiftText(text)
+		for len(text) > 0 && len(lines) < mt940NarrativeLines {
+			n := min(len(text), mt940LineLength)
+			for n > 1 && n < len(text) && (text[n] == ':' || text[n] == '-') {
+				n--
+			}
+			lines = append(lines, text[:n])
+			text = text[n:]
+		}
+	}
+	return lines
+}
+
+// swiftText replaces the characters outside the SWIFT X character set with
+// a question mark. Lines must also not start with a colon or a hyphen, which
+// mark fields and the end of the message.
+func swiftText(text string) string {
+	replaced := []byte(strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		case strings.ContainsRune("/-?:().,'+ ", r):
+			return r
+		}
+		return '?'
+	}, text))
+	if len(replaced) > 0 && (replaced[0] == ':' || replaced[0] == '-') {
+		replaced[0] = '.'
+	}
+	return string(replaced)
+}
@@ -0,0 +1,116 @@
+package statement
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/services"
+)
+
+// ofxBankID identifies the wallet as the account's bank. OFX allows up to
+// nine characters.
+const ofxBankID = "WALLET"
+
+// defaultQuickenBankID is sent as INTU.BID when WALLET_QFX_BANK_ID is not set.
+const defaultQuickenBankID = "00000"
+
+// ofxEscaper escapes the characters SGML reserves.
+var ofxEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+// WriteOFX writes the statement as an OFX 1.0.2 bank statement response,
+// the SGML dialect every personal finance app imports. With qfx set it adds
+// the Intuit bank ID Quicken requires, taken from WALLET_QFX_BANK_ID.
+func WriteOFX(w io.Writer, s *services.Statement, qfx bool) error {
+	b := bufio.NewWriter(w)
+	line := func(format string, args ...any) {
+		fmt.Fprintf(b, format+"\r\n", args...)
+	}
+
+	line("OFXHEADER:100")
+	line("DATA:OFXSGML")
+	line("VERSION:102")
+	line("SECURITY:NONE")
+	line("ENCODING:USASCII")
+	line("CHARSET:1252")
+	line("COMPRESSION:NONE")
+	line("OLDFILEUID:NONE")
+	line("NEWFILEUID:NONE")
+	line("")
+
+	line("<OFX>")
+	line("<SIGNONMSGSRSV1>")
+	line("<SONRS>")
+	line("<STATUS><CODE>0<SEVERITY>INFO</STATUS>")
+	line("<DTSERVER>%s", ofxTime(s.GeneratedAt))
+	line("<LANGUAGE>ENG")
+	line("<FI><ORG>Wallet<FID>%s</FI>", ofxBankID)
+	if qfx {
+		line("<INTU.BID>%s", quickenBankID())
+	}
+	line("</SONRS>")
+	line("</SIGNONMSGSRSV1>")
+
+	line("<BANKMSGSRSV1>")
+	line("<STMTTRNRS>")
+	line("<TRNUID>0")
+	line("<STATUS><CODE>0<SEVERITY>INFO</STATUS>")
+	line("<STMTRS>")
+	line("<CURDEF>%s", ofxEscaper.Replace(s.Currency))
+	line("<BANKACCTFROM><BANKID>%s<ACCTID>%s<ACCTTYPE>CHECKING</BANKACCTFROM>", ofxBankID, ofxAccountID(s.Account))
+	line("<BANKTRANLIST>")
+	line("<DTSTART>%s", ofxTime(s.From))
+	line("<DTEND>%s", ofxTime(periodEnd(s)))
+	for _, l := range s.Lines {
+		t := l.Transaction
+		trnType := "CREDIT"
+		if t.SignedAmount() < 0 {
+			trnType = "DEBIT"
+		}
+		line("<STMTTRN>")
+		line("<TRNTYPE>%s", trnType)
+		line("<DTPOSTED>%s", ofxTime(bookedAt(t)))
+		line("<TRNAMT>%s", formatAmount(t.SignedAmount()))
+		line("<FITID>%s", ofxEscaper.Replace(t.Ref))
+		line("<NAME>%s", ofxEscaper.Replace(ofxName(description(t))))
+		line("<MEMO>%s", ofxEscaper.Replace(t.Ref))
+		line("</STMTTRN>")
+	}
+	line("</BANKTRANLIST>")
+	line("<LEDGERBAL><BALAMT>%s<DTASOF>%s</LEDGERBAL>", formatAmount(s.ClosingBalance), ofxTime(periodEnd(s)))
+	line("</STMTRS>")
+	line("</STMTTRNRS>")
+	line("</BANKMSGSRSV1>")
+	line("</OFX>")
+
+	return b.Flush()
+}
+
+// ofxTime formats t as an OFX datetime in UTC.
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405.000") + "[0:GMT]"
+}
+
+// ofxAccountID shortens an account ID to the 22 characters OFX allows.
+func ofxAccountID(account models.Account) string {
+	return accountNumber(account)[:22]
+}
+
+// ofxName cuts a payee name to the 32 characters OFX allows.
+func ofxName(name string) string {
+	if runes := []rune(name); len(runes) > 32 {
+		return string(runes[:32])
+	}
+	return name
+}
+
+func quickenBankID() string {
+	if id := os.Getenv("WALLET_QFX_BANK_ID"); id != "" {
+		return id
+	}
+	return defaultQuickenBankID
+}
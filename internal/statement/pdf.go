@@ -0,0 +1,305 @@
+package statement
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"wallet/internal/services"
+)
+
+// Page geometry, in points, of the A4 pages statements are printed on.
+const (
+	pageWidth  = 595
+	pageHeight = 842
+	marginLeft = 40
+	// marginRight is the x coordinate right-aligned columns end at.
+	marginRight  = pageWidth - 40
+	tableTop     = 800
+	tableBottom  = 60
+	rowHeight    = 13
+	headerHeight = 20
+)
+
+// Columns of the transactions table: left edges of the text columns and
+// right edges of the amount columns.
+const (
+	colDate        = marginLeft
+	colReference   = 122
+	colDescription = 375
+	colAmount      = 480
+	colBalance     = marginRight
+)
+
+type pdfText struct {
+	x, y float64
+	size float64
+	bold bool
+	text string
+}
+
+type pdfRule struct {
+	x1, y1, x2, y2 float64
+}
+
+type pdfPage struct {
+	texts []pdfText
+	rules []pdfRule
+}
+
+func (p *pdfPage) text(x, y, size float64, bold bool, text string) {
+	p.texts = append(p.texts, pdfText{x: x, y: y, size: size, bold: bold, text: text})
+}
+
+// rightText right-aligns text at x.
+func (p *pdfPage) rightText(x, y, size float64, bold bool, text string) {
+	p.text(x-textWidth(text, size), y, size, bold, text)
+}
+
+func (p *pdfPage) rule(x1, y1, x2, y2 float64) {
+	p.rules = append(p.rules, pdfRule{x1, y1, x2, y2})
+}
+
+// WritePDF renders the statement as a printable A4 document: a summary of
+// the account and period on the first page, followed by the transactions
+// with their running balance, continued over as many pages as needed.
+func WritePDF(w io.Writer, s *services.Statement) error {
+	return writePDF(w, "Account statement", s.GeneratedAt, layoutStatement(s))
+}
+
+func layoutStatement(s *services.Statement) []*pdfPage {
+	first := &pdfPage{}
+	y := float64(tableTop)
+	first.text(marginLeft, y, 18, true, "Account statement")
+	y -= 28
+	holder := s.Account.User.FirstName + " " + s.Account.User.LastName
+	first.text(marginLeft, y, 11, true, holder)
+	y -= 14
+	first.text(marginLeft, y, 9, false, s.Account.User.Email)
+
+	details := [][2]string{
+		{"Account", s.Account.ID.String()},
+		{"Period", displayDate(s.From) + " to " + displayDate(periodEnd(s))},
+		{"Currency", s.Currency},
+		{"Generated", displayTime(s.GeneratedAt)},
+	}
+	y -= 24
+	for _, d := range details {
+		first.text(marginLeft, y, 9, true, d[0])
+		first.text(marginLeft+70, y, 9, false, d[1])
+		y -= 13
+	}
+
+	summary := [][2]string{
+		{"Opening balance", formatAmount(s.OpeningBalance)},
+		{"Total credits", formatAmount(s.TotalCredits)},
+		{"Total debits", formatAmount(-s.TotalDebits)},
+		{"Closing balance", formatAmount(s.ClosingBalance)},
+	}
+	y -= 12
+	for i, row := range summary {
+		bold := i == len(summary)-1
+		first.text(colDescription-60, y, 10, bold, row[0])
+		first.rightText(colBalance, y, 10, bold, row[1])
+		y -= 15
+	}
+
+	// The transactions table, between an opening and a closing balance row
+	type row struct {
+		date, reference, description, amount, balance string
+	}
+	rows := []row{{displayTime(s.From), "", "Opening balance", "", formatAmount(s.OpeningBalance)}}
+	for _, line := range s.Lines {
+		t := line.Transaction
+		rows = append(rows, row{displayTime(bookedAt(t)), t.Ref, description(t), formatAmount(t.SignedAmount()), formatAmount(line.Balance)})
+	}
+	rows = append(rows, row{displayTime(s.To), "", "Closing balance", "", formatAmount(s.ClosingBalance)})
+
+	pages := []*pdfPage{first}
+	page := first
+	y -= 20
+	y = tableHeader(page, y)
+	for _, r := range rows {
+		if y < tableBottom {
+			page = &pdfPage{}
+			pages = append(pages, page)
+			y = tableHeader(page, tableTop)
+		}
+		page.text(colDate, y, 8, false, r.date)
+		page.text(colReference, y, 7, false, fitText(r.reference, 7, colDescription-colReference-8))
+		page.text(colDescription, y, 8, false, r.description)
+		page.rightText(colAmount, y, 8, false, r.amount)
+		page.rightText(colBalance, y, 8, false, r.balance)
+		y -= rowHeight
+	}
+
+	for i, p := range pages {
+		p.rule(marginLeft, 40, marginRight, 40)
+		p.text(marginLeft, 28, 7, false, "Statement of account "+s.Account.ID.String())
+		p.rightText(marginRight, 28, 7, false, fmt.Sprintf("Page %d of %d", i+1, len(pages)))
+	}
+	return pages
+}
+
+// tableHeader draws the column headings at y and returns the y of the
+// first row.
+func tableHeader(p *pdfPage, y float64) float64 {
+	p.text(colDate, y, 9, true, "Date")
+	p.text(colReference, y, 9, true, "Reference")
+	p.text(colDescription, y, 9, true, "Description")
+	p.rightText(colAmount, y, 9, true, "Amount")
+	p.rightText(colBalance, y, 9, true, "Balance")
+	p.rule(marginLeft, y-5, marginRight, y-5)
+	return y - headerHeight
+}
+
+func displayDate(t time.Time) string {
+	return t.In(time.Local).Format(time.DateOnly)
+}
+
+func displayTime(t time.Time) string {
+	return t.In(time.Local).Format("2006-01-02 15:04")
+}
+
+// writePDF serialises pages as a PDF 1.4 document using the standard
+// Helvetica fonts, which every reader has, so no fonts are embedded.
+func writePDF(w io.Writer, title string, createdAt time.Time, pages []*pdfPage) error {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// Objects 1 to 5 are fixed, followed by a page and its content per page
+	const firstPage = 6
+	kids := make([]byte, 0, len(pages)*10)
+	for i := range pages {
+		kids = fmt.Appendf(kids, "%d 0 R ", firstPage+2*i)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", bytes.TrimSpace(kids), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object(fmt.Sprintf("<< /Title %s /Producer (wallet) /CreationDate (D:%s) >>", pdfString(title), createdAt.UTC().Format("20060102150405Z")))
+
+	for i, p := range pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pageWidth, pageHeight, firstPage+2*i+1))
+		content := p.content()
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// content returns the page's content stream.
+func (p *pdfPage) content() []byte {
+	var b bytes.Buffer
+	if len(p.rules) > 0 {
+		b.WriteString("0.5 w\n")
+		for _, r := range p.rules {
+			fmt.Fprintf(&b, "%.2f %.2f m %.2f %.2f l S\n", r.x1, r.y1, r.x2, r.y2)
+		}
+	}
+	for _, t := range p.texts {
+		font := "F1"
+		if t.bold {
+			font = "F2"
+		}
+		fmt.Fprintf(&b, "BT /%s %.1f Tf %.2f %.2f Td %s Tj ET\n", font, t.size, t.x, t.y, pdfString(t.text))
+	}
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n"))
+}
+
+// pdfString encodes text as a PDF string literal in WinAnsiEncoding.
+// Characters the encoding lacks are replaced by a question mark.
+func pdfString(text string) string {
+	var b bytes.Buffer
+	b.WriteByte('(')
+	for _, c := range winAnsi(text) {
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// winAnsiExtras are the characters WinAnsiEncoding places in 0x80-0x9f.
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, '„': 0x84, '…': 0x85, '‘': 0x91, '’': 0x92,
+	'“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '™': 0x99,
+}
+
+func winAnsi(text string) []byte {
+	encoded := make([]byte, 0, len(text))
+	for _, r := range text {
+		switch {
+		case r < 0x80 || (r >= 0xa0 && r <= 0xff):
+			encoded = append(encoded, byte(r))
+		case winAnsiExtras[r] != 0:
+			encoded = append(encoded, winAnsiExtras[r])
+		default:
+			encoded = append(encoded, '?')
+		}
+	}
+	return encoded
+}
+
+// helveticaWidths are the advance widths, in thousandths of the font size,
+// of the printable ASCII characters in Helvetica.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, // 0 to 9
+	278, 278, 584, 584, 584, 556, 1015, // : to @
+	667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, // A to M
+	722, 778, 667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, // N to Z
+	278, 278, 278, 469, 556, 333, // [ to `
+	556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, // a to m
+	556, 556, 556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, // n to z
+	334, 260, 334, 584, // { to ~
+}
+
+// textWidth returns the width of text set in Helvetica at the given size.
+// Bold text is slightly wider, which the layout leaves room for.
+func textWidth(text string, size float64) float64 {
+	width := 0
+	for _, c := range winAnsi(text) {
+		if c >= 32 && c < 127 {
+			width += helveticaWidths[c-32]
+		} else {
+			width += 556
+		}
+	}
+	return float64(width) * size / 1000
+}
+
+// fitText shortens text with an ellipsis until it fits in width.
+func fitText(text string, size, width float64) string {
+	if textWidth(text, size) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && textWidth(string(runes)+"...", size) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
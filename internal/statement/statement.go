@@ -0,0 +1,155 @@
+// Package statement renders account statements for customers, accountants
+// and the software they use: CSV for spreadsheets, PDF for people, OFX/QFX
+// for importing into Money, GnuCash or Quicken, and MT940 or camt.053 for
+// the ERP systems of corporate clients.
+package statement
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/services"
+)
+
+type Format string
+
+const (
+	CSV Format = "csv"
+	PDF Format = "pdf"
+	OFX Format = "ofx"
+	// QFX is OFX with the Intuit extensions Quicken expects.
+	QFX Format = "qfx"
+	// MT940 is the SWIFT customer statement message.
+	MT940 Format = "mt940"
+	// CAMT053 is the ISO 20022 bank to customer statement, camt.053.001.02.
+	CAMT053 Format = "camt053"
+)
+
+// Formats lists the supported formats.
+var Formats = []Format{CSV, PDF, OFX, QFX, MT940, CAMT053}
+
+// ParseFormat returns the format with the given name, case-insensitively.
+func ParseFormat(name string) (Format, error) {
+	for _, f := range Formats {
+		if strings.EqualFold(name, string(f)) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown statement format %q", name)
+}
+
+// ContentType returns the media type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case PDF:
+		return "application/pdf"
+	case OFX:
+		return "application/x-ofx"
+	case QFX:
+		return "application/vnd.intu.qfx"
+	case MT940:
+		return "text/plain; charset=us-ascii"
+	case CAMT053:
+		return "application/xml"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Extension returns the file name extension of the format.
+func (f Format) Extension() string {
+	switch f {
+	case MT940:
+		return "sta"
+	case CAMT053:
+		return "xml"
+	}
+	return string(f)
+}
+
+// Filename returns a download name for the statement in this format.
+func (f Format) Filename(s *services.Statement) string {
+	return fmt.Sprintf("statement-%s-%s-%s.%s", s.Account.ID.String()[:8], s.From.Format("20060102"), s.To.Format("20060102"), f.Extension())
+}
+
+// Write renders the statement in the given format.
+func Write(w io.Writer, f Format, s *services.Statement) error {
+	switch f {
+	case CSV:
+		return WriteCSV(w, s)
+	case PDF:
+		return WritePDF(w, s)
+	case OFX:
+		return WriteOFX(w, s, false)
+	case QFX:
+		return WriteOFX(w, s, true)
+	case MT940:
+		return WriteMT940(w, s)
+	case CAMT053:
+		return WriteCAMT053(w, s)
+	}
+	return fmt.Errorf("unknown statement format %q", f)
+}
+
+// formatAmount formats an amount with two decimals and no grouping.
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
+
+// bookedAt returns when a transaction was posted to the balance, which for
+// rows recorded before transactions could be pending is when it was made.
+func bookedAt(t models.Transaction) time.Time {
+	if t.PostedAt != nil {
+		return *t.PostedAt
+	}
+	return t.CreatedAt
+}
+
+// description names a transaction for people reading the statement: by
+// the description or merchant its client gave, or else by its type.
+func description(t models.Transaction) string {
+	if t.ReversalOfID != nil {
+		return "Reversal"
+	}
+	switch {
+	case t.Description != "" && t.MerchantName != "":
+		return t.MerchantName + ": " + t.Description
+	case t.Description != "":
+		return t.Description
+	case t.MerchantName != "":
+		return t.MerchantName
+	}
+	switch t.TransactionType {
+	case models.Charge:
+		return "Charge"
+	case models.AdjustmentTransaction:
+		return "Adjustment"
+	}
+	return "Top-up"
+}
+
+// accountNumber returns the account ID without hyphens, which fits the 34
+// or 35 characters bank formats allow for account identifiers.
+func accountNumber(account models.Account) string {
+	return strings.ReplaceAll(account.ID.String(), "-", "")
+}
+
+// shortRef returns the ref of a transaction for reference fields limited to
+// max characters. Refs that are too long are replaced by the transaction ID,
+// cut to max; the full ref is still given in the narrative.
+func shortRef(t models.Transaction, max int) string {
+	if len(t.Ref) <= max {
+		return t.Ref
+	}
+	id := strings.ReplaceAll(t.ID.String(), "-", "")
+	return id[:min(max, len(id))]
+}
+
+// periodEnd returns the last instant covered by a statement, for formats
+// that give periods as inclusive ranges.
+func periodEnd(s *services.Statement) time.Time {
+	return s.To.Add(-time.Second)
+}
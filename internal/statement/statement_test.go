@@ -0,0 +1,204 @@
+package statement
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"wallet/internal/models"
+	"wallet/internal/services"
+
+	"github.com/google/uuid"
+)
+
+// newTestStatement returns a March 2025 statement of a top-up, a charge
+// that was pending for a day and the charge's reversal.
+func newTestStatement() *services.Statement {
+	at := func(day, hour int) *time.Time {
+		t := time.Date(2025, time.March, day, hour, 0, 0, 0, time.Local)
+		return &t
+	}
+	charge := uuid.MustParse("6f1c2a9e-0b7d-4c3e-8a51-2d9e4f7b3c10")
+	topUp := models.Transaction{
+		ID:                 uuid.MustParse("0a8e5d2c-3f41-4b9a-9c6e-71d2b8e4f5a3"),
+		TransactionType:    models.TopUp,
+		Amount:             100,
+		Ref:                "TXN-9b3e1f7a-2c4d-4e8b-a1f0-5d6c7e8f9a0b-1740992400000000000",
+		CreatedAt:          *at(3, 9),
+		PostedAt:           at(3, 9),
+		TransactionDetails: models.TransactionDetails{Description: "Salary"},
+	}
+	coffee := models.Transaction{
+		ID:                 charge,
+		TransactionType:    models.Charge,
+		Amount:             30.5,
+		Ref:                "TXN-c4d5e6f7-a8b9-4c0d-9e1f-2a3b4c5d6e7f-1741078800000000000",
+		CreatedAt:          *at(4, 9),
+		PostedAt:           at(5, 9),
+		TransactionDetails: models.TransactionDetails{Description: "Flat white", MerchantName: "Café Nero"},
+	}
+	reversal := models.Transaction{
+		ID:              uuid.MustParse("d2e3f4a5-b6c7-4d8e-9f0a-1b2c3d4e5f60"),
+		TransactionType: models.TopUp,
+		Amount:          30.5,
+		Ref:             "REV-1",
+		ReversalOfID:    &charge,
+		CreatedAt:       *at(6, 9),
+		PostedAt:        at(6, 9),
+	}
+	return &services.Statement{
+		Account: models.Account{
+			ID:   uuid.MustParse("7c9e6679-7425-40de-944b-e07fc1f90ae7"),
+			User: models.User{FirstName: "Jane", LastName: "Doe"},
+		},
+		Currency:       "EUR",
+		From:           time.Date(2025, time.March, 1, 0, 0, 0, 0, time.Local),
+		To:             time.Date(2025, time.April, 1, 0, 0, 0, 0, time.Local),
+		GeneratedAt:    time.Date(2025, time.April, 1, 6, 0, 0, 0, time.UTC),
+		OpeningBalance: 50,
+		ClosingBalance: 150,
+		TotalCredits:   130.5,
+		TotalDebits:    30.5,
+		Lines: []services.StatementLine{
+			{Transaction: topUp, Balance: 150},
+			{Transaction: coffee, Balance: 119.5},
+			{Transaction: reversal, Balance: 150},
+		},
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteCSV(&b, newTestStatement()); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 6 {
+		t.Fatalf("got %d records want a header, the opening balance, 3 transactions and the closing balance", len(records))
+	}
+	// Debits are negative and the balance runs down the rows
+	want := [][]string{
+		{"opening_balance", "Opening balance", "", "50.00", "EUR"},
+		{"top-up", "Salary", "100.00", "150.00", "EUR"},
+		{"charge", "Café Nero: Flat white", "-30.50", "119.50", "EUR"},
+		{"top-up", "Reversal", "30.50", "150.00", "EUR"},
+		{"closing_balance", "Closing balance", "", "150.00", "EUR"},
+	}
+	for i, record := range records[1:] {
+		if got := record[2:]; !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("record %d: got %q want %q", i+1, got, want[i])
+		}
+	}
+	if records[2][1] != newTestStatement().Lines[0].Transaction.Ref {
+		t.Errorf("got reference %q want the full ref", records[2][1])
+	}
+}
+
+func TestWriteOFX(t *testing.T) {
+	t.Setenv("WALLET_QFX_BANK_ID", "12345")
+	s := newTestStatement()
+	s.Lines[1].Transaction.MerchantName = "Nero & Sons, the coffee roasters"
+
+	var ofx, qfx bytes.Buffer
+	if err := WriteOFX(&ofx, s, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteOFX(&qfx, s, true); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"<ACCTID>7c9e6679742540de944be0<ACCTTYPE>CHECKING",
+		"<TRNTYPE>DEBIT\r\n<DTPOSTED>" + s.Lines[1].Transaction.PostedAt.UTC().Format("20060102150405") + ".000[0:GMT]\r\n<TRNAMT>-30.50",
+		// Payee names are escaped once cut to 32 characters
+		"<NAME>Nero &amp; Sons, the coffee roasters\r\n",
+		"<LEDGERBAL><BALAMT>150.00",
+	} {
+		if !strings.Contains(ofx.String(), want) {
+			t.Errorf("got OFX\n%s\nwant it to contain %q", ofx.String(), want)
+		}
+	}
+	if strings.Contains(ofx.String(), "INTU.BID") || !strings.Contains(qfx.String(), "<INTU.BID>12345\r\n") {
+		t.Errorf("got the Intuit bank ID in OFX %t and QFX %t want in QFX only", strings.Contains(ofx.String(), "INTU.BID"), strings.Contains(qfx.String(), "INTU.BID"))
+	}
+}
+
+func TestWritePDF(t *testing.T) {
+	var b bytes.Buffer
+	if err := WritePDF(&b, newTestStatement()); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b.Bytes(), []byte("%PDF-1.4\n")) || !bytes.HasSuffix(b.Bytes(), []byte("%%EOF\n")) {
+		t.Errorf("got %q... want a PDF document", b.Bytes()[:min(b.Len(), 16)])
+	}
+}
+
+func TestWriteMT940(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteMT940(&b, newTestStatement()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The :61: reference is the start of the ref, the narrative the full
+	// ref; the charge is entered the day after it was made
+	want := strings.ReplaceAll(`:20:STM250301250331
+:25:7c9e6679742540de944be07fc1f90ae7
+:28C:1/1
+:60F:C250301EUR50,00
+:61:2503030303C100,00NTRFTXN-9b3e1f7a-2c4
+:86:Salary
+TXN-9b3e1f7a-2c4d-4e8b-a1f0-5d6c7e8f9a0b-1740992400000000000
+:61:2503040305D30,50NMSCTXN-c4d5e6f7-a8b
+:86:Caf? Nero: Flat white
+TXN-c4d5e6f7-a8b9-4c0d-9e1f-2a3b4c5d6e7f-1741078800000000000
+:61:2503060306RD30,50NTRFREV-1
+:86:Reversal
+REV-1
+:62F:C250331EUR150,00
+:64:C250331EUR150,00
+-
+`, "\n", "\r\n")
+	if got := b.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteCAMT053(t *testing.T) {
+	var b bytes.Buffer
+	if err := WriteCAMT053(&b, newTestStatement()); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc camtDocument
+	if err := xml.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatalf("decode %s: %v", b.String(), err)
+	}
+	st := doc.Statement.Stmt
+	if st.Account.Owner != "Jane Doe" || len(st.Balances) != 2 || st.Balances[1].Amount.Value != "150.00" {
+		t.Errorf("got account %+v and balances %+v want Jane Doe's with a closing balance of 150.00", st.Account, st.Balances)
+	}
+	summary := st.Summary
+	if summary.Entries.Count != 3 || summary.Entries.NetAmount != "100.00" || summary.Credits.Count != 2 || summary.Debits.Sum != "30.50" {
+		t.Errorf("got summary %+v want 3 entries netting 100.00, 2 credits and 30.50 of debits", summary)
+	}
+	if len(st.Entries) != 3 {
+		t.Fatalf("got %d entries want 3", len(st.Entries))
+	}
+	// Refs longer than the reference fields are identified by the
+	// transaction ID, and given in full in the remittance information
+	charge := st.Entries[1]
+	if charge.Reference != "6f1c2a9e0b7d4c3e8a512d9e4f7b3c10" || charge.Indicator != "DBIT" || !strings.HasPrefix(charge.Details.Remittance, "TXN-c4d5e6f7") {
+		t.Errorf("got charge entry %+v", charge)
+	}
+	if reversal := st.Entries[2]; !reversal.Reversal || reversal.Reference != "REV-1" || reversal.Indicator != "CRDT" {
+		t.Errorf("got reversal entry %+v want a reversed credit referenced REV-1", reversal)
+	}
+}
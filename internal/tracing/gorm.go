@@ -0,0 +1,85 @@
+package tracing
+
+import (
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const gormSpanKey = "tracing:span"
+
+// GormPlugin traces the queries run with a context that carries a span, as
+// children of that span, so migrations and background jobs stay out of the
+// traces. Statements are recorded without their bound values.
+type GormPlugin struct{}
+
+func (GormPlugin) Name() string {
+	return "tracing"
+}
+
+// gormCallback is a point in one of gorm's callback chains.
+type gormCallback interface {
+	Register(name string, fn func(*gorm.DB)) error
+}
+
+func (p GormPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	chains := []struct {
+		operation     string
+		before, after gormCallback
+	}{
+		{"create", cb.Create().Before("gorm:create"), cb.Create().After("gorm:create")},
+		{"query", cb.Query().Before("gorm:query"), cb.Query().After("gorm:query")},
+		{"update", cb.Update().Before("gorm:update"), cb.Update().After("gorm:update")},
+		{"delete", cb.Delete().Before("gorm:delete"), cb.Delete().After("gorm:delete")},
+		{"row", cb.Row().Before("gorm:row"), cb.Row().After("gorm:row")},
+		{"raw", cb.Raw().Before("gorm:raw"), cb.Raw().After("gorm:raw")},
+	}
+
+	for _, chain := range chains {
+		if err := chain.before.Register("tracing:before_"+chain.operation, startGormSpan("gorm."+chain.operation)); err != nil {
+			return err
+		}
+		if err := chain.after.Register("tracing:after_"+chain.operation, endGormSpan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func startGormSpan(name string) func(tx *gorm.DB) {
+	tracer := Tracer("wallet/internal/database")
+	return func(tx *gorm.DB) {
+		if !trace.SpanContextFromContext(tx.Statement.Context).IsValid() {
+			return
+		}
+		ctx, span := tracer.Start(tx.Statement.Context, name,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attribute.String("db.system", "sqlite")),
+		)
+		tx.Statement.Context = ctx
+		tx.InstanceSet(gormSpanKey, span)
+	}
+}
+
+func endGormSpan(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(gormSpanKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	span.SetAttributes(
+		attribute.String("db.sql.table", tx.Statement.Table),
+		attribute.String("db.statement", tx.Statement.SQL.String()),
+		attribute.Int64("db.rows_affected", tx.Statement.RowsAffected),
+	)
+
+	// A missing record is an expected outcome, not a failure
+	err := tx.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	End(span, err)
+}
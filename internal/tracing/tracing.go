@@ -0,0 +1,108 @@
+// Package tracing configures OpenTelemetry tracing for the wallet.
+//
+// Exporters are chosen with OTEL_TRACES_EXPORTER, a comma-separated list of
+// "otlp" (OTLP over HTTP, configured by the standard OTEL_EXPORTER_OTLP_*
+// variables), "stdout" (pretty-printed JSON, written to OTEL_TRACES_FILE
+// when set) and "none". Tracing is disabled when the variable is empty.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName is the default service.name resource attribute; it can be
+// overridden with OTEL_SERVICE_NAME.
+const ServiceName = "wallet"
+
+// Setup installs the global tracer provider and the W3C trace context and
+// baggage propagators. The returned function flushes and stops the exporters.
+func Setup(ctx context.Context) (shutdown func(context.Context) error, err error) {
+	// Always propagate inbound trace context, even when nothing is exported
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var closers []func(context.Context) error
+	shutdown = func(ctx context.Context) error {
+		var errs []error
+		for _, closer := range closers {
+			errs = append(errs, closer(ctx))
+		}
+		return errors.Join(errs...)
+	}
+
+	var options []sdktrace.TracerProviderOption
+	for _, name := range strings.Split(os.Getenv("OTEL_TRACES_EXPORTER"), ",") {
+		name = strings.TrimSpace(name)
+		var exporter sdktrace.SpanExporter
+		switch name {
+		case "", "none":
+			continue
+		case "otlp":
+			exporter, err = otlptracehttp.New(ctx)
+		case "stdout":
+			var w io.Writer = os.Stdout
+			if path := os.Getenv("OTEL_TRACES_FILE"); path != "" {
+				f, openErr := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+				if openErr != nil {
+					return shutdown, openErr
+				}
+				closers = append(closers, func(context.Context) error { return f.Close() })
+				w = f
+			}
+			exporter, err = stdouttrace.New(stdouttrace.WithWriter(w), stdouttrace.WithPrettyPrint())
+		default:
+			err = fmt.Errorf("unknown trace exporter %q", name)
+		}
+		if err != nil {
+			return shutdown, err
+		}
+		options = append(options, sdktrace.WithBatcher(exporter))
+	}
+	if len(options) == 0 {
+		return shutdown, nil
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", ServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return shutdown, err
+	}
+
+	provider := sdktrace.NewTracerProvider(append(options, sdktrace.WithResource(res))...)
+	otel.SetTracerProvider(provider)
+
+	// Flush the spans before the file they are written to is closed
+	closers = append([]func(context.Context) error{provider.Shutdown}, closers...)
+	return shutdown, nil
+}
+
+// Tracer returns the named tracer of the global provider.
+func Tracer(name string) trace.Tracer {
+	return otel.Tracer(name)
+}
+
+// End records err on the span, if any, and ends it.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,43 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSetup(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "traces.json")
+	t.Setenv("OTEL_TRACES_EXPORTER", "stdout")
+	t.Setenv("OTEL_TRACES_FILE", path)
+
+	shutdown, err := Setup(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, span := Tracer("wallet/internal/tracing").Start(ctx, "TestSetup")
+	End(span, errors.New("insufficient balance"))
+	// Shutting down flushes the batched spans to the file
+	if err := shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"Name": "TestSetup"`, `"Description": "insufficient balance"`, `"Value": "wallet"`} {
+		if !strings.Contains(string(written), want) {
+			t.Errorf("got traces %s want them to contain %s", written, want)
+		}
+	}
+
+	t.Setenv("OTEL_TRACES_EXPORTER", "none, zipkin")
+	if _, err := Setup(ctx); err == nil || err.Error() != `unknown trace exporter "zipkin"` {
+		t.Errorf("unknown exporter: got error %v", err)
+	}
+}
@@ -1,6 +1,45 @@
 CREATE TABLE `users` (`id` TEXT,`email` text NOT NULL,`first_name` text NOT NULL,`last_name` text NOT NULL,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,PRIMARY KEY (`id`),CONSTRAINT `uni_users_email` UNIQUE (`email`));
 CREATE INDEX `idx_users_deleted_at` ON `users`(`deleted_at`);
-CREATE TABLE IF NOT EXISTS "accounts"  (`id` TEXT,`balance` decimal(10,2) NOT NULL DEFAULT 0,`user_id` TEXT NOT NULL,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,PRIMARY KEY (`id`),CONSTRAINT `fk_users_accounts` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`));
+CREATE TABLE IF NOT EXISTS "accounts"  (`id` TEXT,`balance` decimal(10,2) NOT NULL DEFAULT 0,`available_balance` decimal(10,2) NOT NULL DEFAULT 0,`version` integer NOT NULL DEFAULT 0,`status` varchar(10) NOT NULL DEFAULT "active",`user_id` TEXT NOT NULL,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`virtual_iban` text,`number` text,PRIMARY KEY (`id`),CONSTRAINT `fk_users_accounts` FOREIGN KEY (`user_id`) REFERENCES `users`(`id`));
 CREATE INDEX `idx_accounts_deleted_at` ON `accounts`(`deleted_at`);
-CREATE TABLE IF NOT EXISTS "transactions"  (`id` TEXT,`transaction_type` varchar(10) NOT NULL,`amount` decimal(10,2) NOT NULL,`ref` text NOT NULL,`account_id` TEXT NOT NULL,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,PRIMARY KEY (`id`),CONSTRAINT `fk_transactions_account` FOREIGN KEY (`account_id`) REFERENCES `accounts`(`id`),CONSTRAINT `uni_transactions_ref` UNIQUE (`ref`),CONSTRAINT `chk_transactions_transaction_type` CHECK (transaction_type IN ('top-up', 'charge')));
+CREATE UNIQUE INDEX `idx_accounts_virtual_iban` ON `accounts`(`virtual_iban`);
+CREATE UNIQUE INDEX `idx_accounts_number` ON `accounts`(`number`);
+CREATE TABLE IF NOT EXISTS "transactions"  (`id` TEXT,`transaction_type` varchar(10) NOT NULL,`amount` decimal(10,2) NOT NULL,`ref` text NOT NULL,`account_id` TEXT NOT NULL,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,`status` varchar(10) NOT NULL DEFAULT "posted",`posted_at` datetime,`reversal_of_id` uuid,`description` text,`metadata` text,`external_ref` text,`merchant_name` text,`merchant_category_code` varchar(4),PRIMARY KEY (`id`),CONSTRAINT `fk_transactions_account` FOREIGN KEY (`account_id`) REFERENCES `accounts`(`id`),CONSTRAINT `uni_transactions_ref` UNIQUE (`ref`),CONSTRAINT `chk_transactions_transaction_type` CHECK (transaction_type IN ('top-up', 'charge', 'adjustment')),CONSTRAINT `chk_transactions_status` CHECK (status IN ('pending', 'posted', 'failed', 'reversed')));
 CREATE INDEX `idx_transactions_deleted_at` ON `transactions`(`deleted_at`);
+CREATE INDEX `idx_transactions_status` ON `transactions`(`status`);
+CREATE INDEX `idx_transactions_external_ref` ON `transactions`(`external_ref`);
+CREATE INDEX `idx_transactions_merchant_category_code` ON `transactions`(`merchant_category_code`);
+CREATE TABLE `balance_snapshots` (`id` TEXT,`account_id` TEXT NOT NULL,`closing_at` datetime NOT NULL,`balance` decimal(10,2) NOT NULL,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,PRIMARY KEY (`id`),CONSTRAINT `fk_balance_snapshots_account` FOREIGN KEY (`account_id`) REFERENCES `accounts`(`id`));
+CREATE UNIQUE INDEX `idx_balance_snapshots_account_closing` ON `balance_snapshots`(`account_id`,`closing_at`);
+CREATE INDEX `idx_balance_snapshots_deleted_at` ON `balance_snapshots`(`deleted_at`);
+CREATE TABLE `audit_entries` (`sequence` integer,`action` text NOT NULL,`actor` text NOT NULL,`request_id` text,`entity_type` text NOT NULL,`entity_id` text NOT NULL,`before` text NOT NULL,`after` text NOT NULL,`prev_hash` text NOT NULL,`hash` text NOT NULL,`keyed` numeric NOT NULL DEFAULT false,`created_at` datetime,PRIMARY KEY (`sequence`),CONSTRAINT `uni_audit_entries_hash` UNIQUE (`hash`));
+CREATE INDEX `idx_audit_entries_action` ON `audit_entries`(`action`);
+CREATE INDEX `idx_audit_entries_entity_id` ON `audit_entries`(`entity_id`);
+CREATE TRIGGER audit_entries_no_delete BEFORE DELETE ON audit_entries
+	BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END;
+CREATE TRIGGER audit_entries_no_update BEFORE UPDATE ON audit_entries
+	BEGIN SELECT RAISE(ABORT, 'audit log is append-only'); END;
+CREATE TABLE `events` (`position` integer PRIMARY KEY AUTOINCREMENT,`stream_id` uuid NOT NULL,`version` integer NOT NULL,`type` text NOT NULL,`data` text NOT NULL,`occurred_at` datetime NOT NULL);
+CREATE INDEX `idx_events_type` ON `events`(`type`);
+CREATE UNIQUE INDEX `idx_events_stream_version` ON `events`(`stream_id`,`version`);
+CREATE TRIGGER events_no_update BEFORE UPDATE ON events
+	BEGIN SELECT RAISE(ABORT, 'event store is append-only'); END;
+CREATE TRIGGER events_no_delete BEFORE DELETE ON events
+	BEGIN SELECT RAISE(ABORT, 'event store is append-only'); END;
+CREATE TABLE `payments` (`id` TEXT,`provider` text NOT NULL,`intent_id` text NOT NULL,`transaction_id` uuid NOT NULL,`account_id` uuid NOT NULL,`amount` decimal(10,2) NOT NULL,`status` varchar(16) NOT NULL,`failure_reason` text,`refund_id` text,`created_at` datetime,`updated_at` datetime,PRIMARY KEY (`id`),CONSTRAINT `uni_payments_transaction_id` UNIQUE (`transaction_id`),CONSTRAINT `chk_payments_status` CHECK (status IN ('processing', 'succeeded', 'failed', 'refund_pending', 'refunded')));
+CREATE INDEX `idx_payments_account_id` ON `payments`(`account_id`);
+CREATE UNIQUE INDEX `idx_payments_provider_intent` ON `payments`(`provider`,`intent_id`);
+CREATE TABLE `beneficiaries` (`id` TEXT,`account_id` uuid NOT NULL,`name` text NOT NULL,`iban` text,`bic` text,`routing_number` text,`account_number` text,`savings` numeric NOT NULL DEFAULT false,`created_at` datetime,`updated_at` datetime,`deleted_at` datetime,PRIMARY KEY (`id`));
+CREATE INDEX `idx_beneficiaries_deleted_at` ON `beneficiaries`(`deleted_at`);
+CREATE INDEX `idx_beneficiaries_account_id` ON `beneficiaries`(`account_id`);
+CREATE TABLE `withdrawals` (`id` TEXT,`account_id` uuid NOT NULL,`beneficiary_id` uuid NOT NULL,`transaction_id` uuid NOT NULL,`amount` decimal(10,2) NOT NULL,`status` varchar(10) NOT NULL,`end_to_end_id` text NOT NULL,`trace_number` text,`payout_id` uuid,`return_code` text,`return_reason` text,`completed_at` datetime,`created_at` datetime,`updated_at` datetime,PRIMARY KEY (`id`),CONSTRAINT `uni_withdrawals_end_to_end_id` UNIQUE (`end_to_end_id`),CONSTRAINT `uni_withdrawals_trace_number` UNIQUE (`trace_number`),CONSTRAINT `uni_withdrawals_transaction_id` UNIQUE (`transaction_id`),CONSTRAINT `chk_withdrawals_status` CHECK (status IN ('requested', 'submitted', 'completed', 'returned', 'failed')));
+CREATE INDEX `idx_withdrawals_payout_id` ON `withdrawals`(`payout_id`);
+CREATE INDEX `idx_withdrawals_status` ON `withdrawals`(`status`);
+CREATE INDEX `idx_withdrawals_account_id` ON `withdrawals`(`account_id`);
+CREATE TABLE `payouts` (`id` TEXT,`format` varchar(10) NOT NULL,`message_id` text NOT NULL,`file_name` text NOT NULL,`count` integer NOT NULL,`total` decimal(12,2) NOT NULL,`created_at` datetime,PRIMARY KEY (`id`),CONSTRAINT `uni_payouts_message_id` UNIQUE (`message_id`),CONSTRAINT `chk_payouts_format` CHECK (format IN ('pain.001', 'nacha')));
+CREATE TABLE `deposits` (`id` TEXT,`reference` text NOT NULL,`amount` decimal(10,2) NOT NULL,`currency` varchar(3) NOT NULL,`creditor_iban` text,`debtor_name` text,`debtor_iban` text,`remittance` text,`booked_at` datetime,`status` varchar(10) NOT NULL,`account_id` uuid,`transaction_id` uuid,`reason` text,`created_at` datetime,`updated_at` datetime,PRIMARY KEY (`id`),CONSTRAINT `uni_deposits_reference` UNIQUE (`reference`),CONSTRAINT `uni_deposits_transaction_id` UNIQUE (`transaction_id`),CONSTRAINT `chk_deposits_status` CHECK (status IN ('matched', 'unmatched', 'assigned')));
+CREATE INDEX `idx_deposits_status` ON `deposits`(`status`);
+CREATE INDEX `idx_deposits_account_id` ON `deposits`(`account_id`);
+CREATE TABLE `adjustments` (`id` TEXT,`account_id` uuid NOT NULL,`amount` decimal(10,2) NOT NULL,`reason_code` varchar(20) NOT NULL,`note` text NOT NULL,`status` varchar(10) NOT NULL,`requested_by` text NOT NULL,`reviewed_by` text,`review_note` text,`reviewed_at` datetime,`transaction_id` uuid,`created_at` datetime,`updated_at` datetime,PRIMARY KEY (`id`),CONSTRAINT `uni_adjustments_transaction_id` UNIQUE (`transaction_id`),CONSTRAINT `chk_adjustments_reason_code` CHECK (reason_code IN ('goodwill', 'write_off', 'correction', 'fee_refund', 'chargeback', 'other')),CONSTRAINT `chk_adjustments_status` CHECK (status IN ('pending', 'approved', 'rejected')));
+CREATE INDEX `idx_adjustments_status` ON `adjustments`(`status`);
+CREATE INDEX `idx_adjustments_account_id` ON `adjustments`(`account_id`);